@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// auditedCommands are the commands that add, remove or move worktrees; each
+// of their outcomes is recorded in the audit log.
+var auditedCommands = map[string]bool{
+	"checkout": true, "create": true, "pr": true, "mr": true,
+	"remove": true, "move": true, "cleanup": true, "snapshot restore": true,
+}
+
+var (
+	// auditCommand is the command being run, e.g. "snapshot restore".
+	auditCommand string
+	// audited is set once the command recorded an outcome.
+	audited bool
+
+	historyAll    bool
+	historyBranch string
+	historyOp     string
+	historyUser   string
+	historySince  string
+	historyLimit  int
+	historyJSON   bool
+)
+
+// auditRecord is one line of the audit log.
+type auditRecord struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Host    string    `json:"host,omitempty"`
+	Op      string    `json:"op"`
+	Repo    string    `json:"repo,omitempty"`
+	Branch  string    `json:"branch,omitempty"`
+	Path    string    `json:"path,omitempty"`
+	Detail  string    `json:"detail,omitempty"`
+	Outcome string    `json:"outcome"`
+	Error   string    `json:"error,omitempty"`
+	// Args are the arguments of a command that failed before it got to a
+	// worktree.
+	Args []string `json:"args,omitempty"`
+}
+
+// auditPath is the per-user audit log, one JSON record per line.
+func auditPath() string {
+	return filepath.Join(stateDir(), "audit.jsonl")
+}
+
+// auditRepo names the current repository by its main worktree (the checkout
+// of a submodule), or by its git directory when it is bare.
+func auditRepo() string {
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return ""
+	}
+	commonDir = resolvePath(commonDir)
+	if dir, ok := submoduleWorktree(commonDir); ok {
+		return dir
+	}
+	if filepath.Base(commonDir) == ".git" {
+		return filepath.Dir(commonDir)
+	}
+	return commonDir
+}
+
+// recordAudit appends the outcome of the current command for one worktree
+// to the audit log; err is nil when it succeeded. A log that cannot be
+// written is reported, but does not fail the command.
+func recordAudit(branch, path, detail string, err error) {
+	rec := auditRecord{
+		Time:    time.Now().Truncate(time.Second),
+		User:    loginName(),
+		Op:      auditCommand,
+		Repo:    auditRepo(),
+		Branch:  branch,
+		Path:    path,
+		Detail:  detail,
+		Outcome: "ok",
+	}
+	if err != nil {
+		rec.Outcome, rec.Error = "failed", err.Error()
+	}
+	appendAudit(rec)
+}
+
+// auditFailure records a failed command that did not record anything
+// itself, e.g. because it stopped before it got to a worktree.
+func auditFailure(cmd *cobra.Command, args []string, err error) {
+	if audited || cmd == nil || !auditedCommands[commandName(cmd)] {
+		return
+	}
+	auditCommand = commandName(cmd)
+	appendAudit(auditRecord{
+		Time:    time.Now().Truncate(time.Second),
+		User:    loginName(),
+		Op:      auditCommand,
+		Repo:    auditRepo(),
+		Outcome: "failed",
+		Error:   err.Error(),
+		Args:    args,
+	})
+}
+
+// commandName is the path of cmd below wt, e.g. "snapshot restore".
+func commandName(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" ")
+}
+
+func appendAudit(rec auditRecord) {
+	audited = true
+	if rec.Op == "" {
+		rec.Op = "unknown"
+	}
+	rec.Host, _ = os.Hostname()
+	data, err := json.Marshal(rec)
+	if err == nil {
+		err = appendLine(auditPath(), data)
+	}
+	if err != nil {
+		warnf("warning: failed to write the audit log: %v\n", err)
+	}
+}
+
+// appendLine adds a line to path in a single write, so that records of
+// concurrent wt processes do not interleave.
+func appendLine(path string, line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readAudit reads the audit log, oldest first. Lines that do not parse are
+// skipped.
+func readAudit() ([]auditRecord, error) {
+	data, err := os.ReadFile(auditPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []auditRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var rec auditRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) == nil {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// auditFilter selects records for 'wt history'; empty fields match all.
+type auditFilter struct {
+	Repo   string
+	Branch string
+	Op     string
+	User   string
+	Since  time.Time
+}
+
+func (f auditFilter) match(rec auditRecord) bool {
+	return (f.Repo == "" || samePath(rec.Repo, f.Repo)) &&
+		(f.Branch == "" || rec.Branch == f.Branch) &&
+		(f.Op == "" || rec.Op == f.Op) &&
+		(f.User == "" || rec.User == f.User) &&
+		!rec.Time.Before(f.Since)
+}
+
+// filterAudit returns the last limit records that match, oldest first; a
+// limit of 0 returns all.
+func filterAudit(records []auditRecord, filter auditFilter, limit int) []auditRecord {
+	var matched []auditRecord
+	for _, rec := range records {
+		if filter.match(rec) {
+			matched = append(matched, rec)
+		}
+	}
+	if limit > 0 && len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+	return matched
+}
+
+// printAudit renders records as a table, with the repository when they
+// come from more than one.
+func printAudit(out io.Writer, records []auditRecord, withRepo bool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, rec := range records {
+		target := rec.Branch
+		if target == "" {
+			target = strings.Join(rec.Args, " ")
+		}
+		fields := []string{rec.Time.Local().Format("2006-01-02 15:04:05"), rec.User, rec.Op, rec.Outcome, target}
+		if withRepo {
+			fields = append(fields, rec.Repo)
+		}
+		note := rec.Path
+		if rec.Detail != "" {
+			note += " (" + rec.Detail + ")"
+		}
+		if rec.Error != "" {
+			// Hints follow the error on further lines.
+			note, _, _ = strings.Cut(rec.Error, "\n")
+		}
+		fields = append(fields, note)
+		fmt.Fprintln(w, strings.Join(fields, "\t"))
+	}
+	return w.Flush()
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the log of created, removed and moved worktrees",
+	Long: `Show the audit log: every checkout, create, pr, mr, remove, move, cleanup
+and snapshot restore, with when, who, the branch, the path and whether it
+worked. Inside a repository only its records are shown; --all shows those
+of every repository.
+
+The log is kept per user in ~/.local/state/wt/audit.jsonl (under
+$XDG_STATE_HOME when set), one JSON record per line, for the retention
+period (config key retention, default 90d; see 'wt gc').
+
+Examples:
+  wt history                     # The last 20 records of this repository
+  wt history --all --since 7d    # Everything of the last week
+  wt history --op remove --user alice
+  wt history -n 0 --json         # All records of this repository, as JSON lines`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter := auditFilter{Branch: historyBranch, Op: historyOp, User: historyUser}
+		if historySince != "" {
+			d, err := parsePeriod(historySince, "--since")
+			if err != nil {
+				return err
+			}
+			filter.Since = time.Now().Add(-d)
+		}
+		if !historyAll {
+			filter.Repo = auditRepo()
+		}
+		records, err := readAudit()
+		if err != nil {
+			return fmt.Errorf("failed to read the audit log: %w", err)
+		}
+		records = filterAudit(records, filter, historyLimit)
+
+		if historyJSON {
+			for _, rec := range records {
+				data, err := json.Marshal(rec)
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+			}
+			return nil
+		}
+		if len(records) == 0 {
+			infof("No records\n")
+			return nil
+		}
+		return printAudit(os.Stdout, records, filter.Repo == "")
+	},
+}
+
+// gcAuditLog drops audit records older than the retention period.
+func gcAuditLog(retention time.Duration, dryRun bool) gcResult {
+	result := gcResult{Name: "audit log", Unit: "records"}
+	records, err := readAudit()
+	if err != nil || len(records) == 0 {
+		return result
+	}
+	cutoff := time.Now().Add(-retention)
+	var kept bytes.Buffer
+	for _, rec := range records {
+		if rec.Time.Before(cutoff) {
+			result.Removed++
+			continue
+		}
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return gcResult{Name: result.Name, Unit: result.Unit}
+		}
+		kept.Write(append(data, '\n'))
+	}
+	if result.Removed > 0 && !dryRun {
+		if err := writeFileAtomic(auditPath(), kept.Bytes()); err != nil {
+			warnf("warning: failed to write the audit log: %v\n", err)
+		}
+	}
+	return result
+}
+
+func init() {
+	historyCmd.Flags().BoolVar(&historyAll, "all", false, "Show the records of every repository")
+	historyCmd.Flags().StringVar(&historyBranch, "branch", "", "Only records of this branch")
+	historyCmd.Flags().StringVar(&historyOp, "op", "", "Only records of this command, e.g. remove or 'snapshot restore'")
+	historyCmd.Flags().StringVar(&historyUser, "user", "", "Only records of this user")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "Only records of this period, e.g. 7d, 2w or 12h")
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Show at most this many of the latest records (0 for all)")
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "Print the records as JSON lines")
+	rootCmd.AddCommand(historyCmd)
+	gcTasks = append(gcTasks, gcAuditLog)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestAuditLog(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("USER", "alice")
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { auditCommand, audited = "", false })
+
+	auditCommand, audited = "create", false
+	recordAudit("feature", "/wt/feature", "", nil)
+	auditCommand = "move"
+	recordAudit("feature", "/wt/moved", "from /wt/feature", nil)
+	auditCommand = "cleanup"
+	recordAudit("old", "/wt/old", "", errors.New("pre-remove hook failed\nuse --force"))
+
+	// A failed command that recorded nothing gets a record with its args.
+	audited = false
+	auditFailure(removeCmd, []string{"remove", "nope"}, errors.New("no worktree found"))
+	// Once something was recorded, a failure adds nothing.
+	auditFailure(removeCmd, []string{"remove", "nope"}, errors.New("no worktree found"))
+	// Neither do commands that do not change worktrees.
+	audited = false
+	auditFailure(&cobra.Command{Use: "list"}, nil, errors.New("boom"))
+
+	records, err := readAudit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("readAudit() = %d records, want 4: %+v", len(records), records)
+	}
+	if rec := records[0]; rec.Op != "create" || rec.User != "alice" || rec.Outcome != "ok" || !samePath(rec.Repo, repoDir) {
+		t.Errorf("first record = %+v, want an ok create by alice in %s", rec, repoDir)
+	}
+	if rec := records[2]; rec.Outcome != "failed" || !strings.HasPrefix(rec.Error, "pre-remove hook failed") {
+		t.Errorf("cleanup record = %+v, want a failure", rec)
+	}
+	if rec := records[3]; rec.Op != "remove" || strings.Join(rec.Args, " ") != "remove nope" {
+		t.Errorf("remove record = %+v, want the failed command with its args", rec)
+	}
+
+	if got := filterAudit(records, auditFilter{Branch: "feature"}, 0); len(got) != 2 {
+		t.Errorf("--branch feature matched %d, want 2", len(got))
+	}
+	if got := filterAudit(records, auditFilter{Repo: repoDir}, 1); len(got) != 1 || got[0].Op != "remove" {
+		t.Errorf("-n 1 = %+v, want the latest record", got)
+	}
+	if got := filterAudit(records, auditFilter{User: "bob"}, 0); len(got) != 0 {
+		t.Errorf("--user bob matched %d, want 0", len(got))
+	}
+	if got := filterAudit(records, auditFilter{Repo: "/elsewhere"}, 0); len(got) != 0 {
+		t.Errorf("another repository matched %d, want 0", len(got))
+	}
+
+	var out strings.Builder
+	if err := printAudit(&out, records, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"/wt/moved (from /wt/feature)", "failed  old", "pre-remove hook failed\n", "remove nope"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printAudit output does not contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "use --force") {
+		t.Errorf("printAudit shows the hint lines of errors:\n%s", out.String())
+	}
+}
+
+func TestGCAuditLog(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	for _, age := range []time.Duration{100 * 24 * time.Hour, time.Hour} {
+		appendAudit(auditRecord{Time: time.Now().Add(-age), Op: "create", Outcome: "ok"})
+	}
+
+	if result := gcAuditLog(defaultRetention, true); result.Removed != 1 {
+		t.Errorf("dry run removed %d, want 1", result.Removed)
+	}
+	if records, _ := readAudit(); len(records) != 2 {
+		t.Errorf("dry run left %d records, want 2", len(records))
+	}
+	gcAuditLog(defaultRetention, false)
+	if records, _ := readAudit(); len(records) != 1 {
+		t.Errorf("gc left %d records, want 1", len(records))
+	}
+}
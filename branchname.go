@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// branchFields holds --field values for the branch template.
+var branchFields []string
+
+// templateFieldRe matches the {.name} placeholders of a branch template.
+var templateFieldRe = regexp.MustCompile(`\{\s*\.(\w+)\s*\}`)
+
+var slugRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify turns free text into a branch name component: lowercase words
+// joined by dashes.
+func slugify(s string) string {
+	return strings.Trim(slugRe.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// templateUser is {.user}: the login name, as a slug.
+func templateUser() string {
+	return slugify(loginName())
+}
+
+// loginName is the name of the user running wt.
+func loginName() string {
+	name := os.Getenv("USER")
+	if name == "" {
+		name = os.Getenv("USERNAME")
+	}
+	if name == "" {
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+	}
+	// Windows reports DOMAIN\name.
+	if i := strings.LastIndex(name, `\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// parseBranchFields parses --field key=value pairs.
+func parseBranchFields(fields []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid --field %q (use key=value)", field)
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
+// renderBranchTemplate fills the template for a new branch called name.
+// {.slug} is name as a slug, {.name} the name as given and {.user} the
+// login name; other fields come from given or are asked for.
+func renderBranchTemplate(tmpl, name string, given map[string]string) (string, error) {
+	values := map[string]any{"slug": slugify(name), "name": name, "user": templateUser()}
+	for key, value := range given {
+		values[key] = value
+	}
+	for _, match := range templateFieldRe.FindAllStringSubmatch(tmpl, -1) {
+		field := match[1]
+		if _, ok := values[field]; ok {
+			continue
+		}
+		value, err := inputPrompt(field)
+		if err != nil {
+			return "", fmt.Errorf("the branch template needs a value for %s: pass --field %s=<value>", field, field)
+		}
+		values[field] = value
+	}
+
+	tpl, err := template.New("branchTemplate").Delims("{", "}").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid branch template: %w", err)
+	}
+	var rendered bytes.Buffer
+	if err := tpl.Execute(&rendered, values); err != nil {
+		return "", fmt.Errorf("invalid branch template: %w", err)
+	}
+	return rendered.String(), nil
+}
+
+// newBranchName returns the name wt gives a new branch the user called
+// name: the branch template applied to it, unless name already follows the
+// naming rule, and checked against that rule, so teams whose server rejects
+// other names find out before anything is created. Existing branches keep
+// their name.
+func newBranchName(name string) (string, error) {
+	cfg := loadConfig()
+	var rule *regexp.Regexp
+	if expr := cfg.get("branch-regex"); expr != "" {
+		var err error
+		if rule, err = regexp.Compile(expr); err != nil {
+			return "", fmt.Errorf("invalid branch-regex %q: %w", expr, err)
+		}
+	}
+
+	if gitRefExists("refs/heads/" + name) {
+		return name, nil
+	}
+	branch := name
+	if tmpl := cfg.get("branch-template"); tmpl != "" && (rule == nil || !rule.MatchString(name)) {
+		given, err := parseBranchFields(branchFields)
+		if err != nil {
+			return "", err
+		}
+		if branch, err = renderBranchTemplate(tmpl, name, given); err != nil {
+			return "", err
+		}
+	}
+
+	if rule != nil && !rule.MatchString(branch) {
+		return "", fmt.Errorf("branch name '%s' does not match the naming rule %s (%s)", branch, rule, cfg.Values["branch-regex"].Source)
+	}
+	if output, err := gitCommand("check-ref-format", "--branch", branch).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("'%s' is not a valid branch name: %s", branch, strings.TrimSpace(string(output)))
+	}
+	if branch != name {
+		infof("Branch name: %s\n", branch)
+	}
+	return branch, nil
+}
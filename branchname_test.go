@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Fix login timeout":  "fix-login-timeout",
+		"  JIRA-123: crash!": "jira-123-crash",
+		"already-a-slug":     "already-a-slug",
+	}
+	for input, want := range tests {
+		if got := slugify(input); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestNewBranchName(t *testing.T) {
+	t.Setenv("USER", "Jane")
+	t.Setenv("CI", "true")
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("WT_CONFIG", configFile)
+	originalFields := branchFields
+	t.Cleanup(func() { branchFields = originalFields })
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "branch", "legacy_name")
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	config := "branch-template: '{.user}/{.type}/{.slug}'\nbranch-regex: '^[a-z]+/(feat|fix)/[a-z0-9-]+$'\n"
+	if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	branchFields = []string{"type=fix"}
+	if got, err := newBranchName("Login Timeout"); err != nil || got != "jane/fix/login-timeout" {
+		t.Errorf("newBranchName() = %q, %v; want jane/fix/login-timeout", got, err)
+	}
+	if got, err := newBranchName("bob/feat/search"); err != nil || got != "bob/feat/search" {
+		t.Errorf("newBranchName() of a conforming name = %q, %v; want it unchanged", got, err)
+	}
+	if got, err := newBranchName("legacy_name"); err != nil || got != "legacy_name" {
+		t.Errorf("newBranchName() of an existing branch = %q, %v; want it unchanged", got, err)
+	}
+
+	branchFields = []string{"type=chore"}
+	if _, err := newBranchName("cleanup"); err == nil || !strings.Contains(err.Error(), "naming rule") {
+		t.Errorf("newBranchName() breaking the rule = %v, want a naming rule error", err)
+	}
+
+	// Without a value for a field and no way to ask, the field is named.
+	branchFields = nil
+	if _, err := newBranchName("search"); err == nil || !strings.Contains(err.Error(), "--field type=") {
+		t.Errorf("newBranchName() without a type = %v, want a hint to pass --field type=", err)
+	}
+}
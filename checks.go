@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checksInterval is how often 'wt status --checks' asks the code host again
+// about a commit whose CI has not finished; it has rate limits, the local
+// status does not.
+const checksInterval = time.Minute
+
+// checksFinalTTL is how long a passed or failed result is trusted. It only
+// changes when someone reruns the checks.
+const checksFinalTTL = 10 * time.Minute
+
+// checksWorkers is how many commits are asked about at the same time.
+const checksWorkers = 8
+
+// ciNotPushed is the CI state of a branch whose head is not on the code
+// host, so no CI ran on it.
+const ciNotPushed = "not pushed"
+
+// originProvider is the code host of origin, for the CI column.
+func originProvider() (provider, error) {
+	output, err := gitCommand("remote", "get-url", "origin").Output()
+	if err != nil {
+		return provider{}, fmt.Errorf("--checks needs an origin remote on GitHub or GitLab")
+	}
+	remote, _ := parseRemoteURL(strings.TrimSpace(string(output)))
+	host := strings.ToLower(remote.Host)
+	switch {
+	case strings.Contains(host, "github"):
+		return githubProvider, nil
+	case strings.Contains(host, "gitlab"):
+		return gitlabProvider, nil
+	}
+	return provider{}, fmt.Errorf("--checks needs an origin remote on GitHub or GitLab, not %q", strings.TrimSpace(string(output)))
+}
+
+// ciResult is the CI state of one commit and when the code host said so.
+type ciResult struct {
+	State   string    `json:"state"`
+	Checked time.Time `json:"checked"`
+}
+
+// fresh reports whether the result can be shown without asking again.
+func (r ciResult) fresh(now time.Time) bool {
+	ttl := checksInterval
+	if r.State == "passed" || r.State == "failed" {
+		ttl = checksFinalTTL
+	}
+	return now.Sub(r.Checked) < ttl
+}
+
+// ciCachePath is where the CI results of the repository at commonDir are
+// kept between wt commands, by commit.
+func ciCachePath(commonDir string) string {
+	sum := sha1.Sum([]byte(commonDir))
+	return filepath.Join(cacheDir(), "ci", hex.EncodeToString(sum[:8])+".json")
+}
+
+func loadCICache(commonDir string) map[string]ciResult {
+	results := map[string]ciResult{}
+	if data, err := os.ReadFile(ciCachePath(commonDir)); err == nil {
+		_ = json.Unmarshal(data, &results)
+	}
+	return results
+}
+
+// saveCICache writes the results back, without the ones too old to be
+// shown again, which keeps the file small.
+func saveCICache(commonDir string, results map[string]ciResult, now time.Time) {
+	for sha, r := range results {
+		if !r.fresh(now) {
+			delete(results, sha)
+		}
+	}
+	if data, err := json.Marshal(results); err == nil {
+		_ = writeFileAtomic(ciCachePath(commonDir), data)
+	}
+}
+
+// ciChecks finds the CI state of the head of each worktree's branch, as
+// reported by the code host.
+type ciChecks struct {
+	provider  provider
+	commonDir string
+	fetched   time.Time
+	states    map[string]string
+	err       error
+}
+
+func newCIChecks(commonDir string) (*ciChecks, error) {
+	p, err := originProvider()
+	if err != nil {
+		return nil, err
+	}
+	return &ciChecks{provider: p, commonDir: commonDir}, nil
+}
+
+// get returns the CI state by branch for items, asking the code host
+// about the heads it has no fresh result for, at most checksWorkers at a
+// time, and at most once per checksInterval. A head that is not pushed is
+// not asked about. A failure keeps the last known states and is reported
+// in err.
+func (c *ciChecks) get(items []listItem, now time.Time) map[string]string {
+	if !c.fetched.IsZero() && now.Sub(c.fetched) < checksInterval {
+		return c.states
+	}
+	c.fetched = now
+	if c.states == nil {
+		c.states = map[string]string{}
+	}
+
+	cache := loadCICache(c.commonDir)
+	branches := map[string][]string{}
+	for _, item := range items {
+		switch {
+		case item.Branch == "" || item.Head == "":
+		case item.Upstream == "" || item.UpstreamGone || item.Ahead > 0:
+			c.states[item.Branch] = ciNotPushed
+		case cache[item.Head].fresh(now):
+			c.states[item.Branch] = cache[item.Head].State
+		default:
+			branches[item.Head] = append(branches[item.Head], item.Branch)
+		}
+	}
+	if len(branches) == 0 {
+		c.err = nil
+		return c.states
+	}
+	// Look the token up once, before the workers would all do it.
+	if _, err := c.provider.token(); err != nil {
+		c.err = err
+		return c.states
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	heads := make(chan string)
+	for i := 0; i < checksWorkers && i < len(branches); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sha := range heads {
+				state, err := c.provider.headChecks(sha)
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					cache[sha] = ciResult{State: state, Checked: now}
+					for _, branch := range branches[sha] {
+						c.states[branch] = state
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for sha := range branches {
+		heads <- sha
+	}
+	close(heads)
+	wg.Wait()
+
+	c.err = firstErr
+	saveCICache(c.commonDir, cache, now)
+	return c.states
+}
+
+// headChecks asks the code host for the CI state of commit sha: the check
+// runs and commit statuses on GitHub, the latest pipeline on GitLab.
+func (p provider) headChecks(sha string) (string, error) {
+	what := "CI of " + shortSHA(sha)
+	var state string
+	var err error
+	switch p.Name {
+	case githubProvider.Name:
+		var runs, statuses []byte
+		runs, err = p.output(what, "api", "repos/{owner}/{repo}/commits/"+sha+"/check-runs?per_page=100")
+		if err == nil {
+			statuses, err = p.output(what, "api", "repos/{owner}/{repo}/commits/"+sha+"/status")
+		}
+		if err == nil {
+			state, err = parseGitHubCommitChecks(runs, statuses)
+		}
+	case gitlabProvider.Name:
+		var commit []byte
+		commit, err = p.output(what, "api", "projects/:id/repository/commits/"+sha)
+		if err == nil {
+			state, err = parseGitLabCommit(commit)
+		}
+	default:
+		err = fmt.Errorf("no CI checks for %s", p.Name)
+	}
+	if errors.Is(err, errProviderNotFound) || (err != nil && strings.Contains(err.Error(), "No commit found")) {
+		// Pushed elsewhere, or the remote branch moved on.
+		return ciNotPushed, nil
+	}
+	return state, err
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+// parseGitHubCommitChecks summarizes the check runs and commit statuses of
+// a commit: failed when any failed, running while any has not finished,
+// else passed.
+func parseGitHubCommitChecks(runs, statuses []byte) (string, error) {
+	var checkRuns struct {
+		CheckRuns []struct {
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	var combined struct {
+		Statuses []struct {
+			State string `json:"state"`
+		} `json:"statuses"`
+	}
+	if err := json.Unmarshal(runs, &checkRuns); err != nil {
+		return "", fmt.Errorf("unexpected output of gh: %w", err)
+	}
+	if err := json.Unmarshal(statuses, &combined); err != nil {
+		return "", fmt.Errorf("unexpected output of gh: %w", err)
+	}
+	if len(checkRuns.CheckRuns)+len(combined.Statuses) == 0 {
+		return "no checks", nil
+	}
+	failed, running := false, false
+	for _, run := range checkRuns.CheckRuns {
+		switch run.Conclusion {
+		case "failure", "cancelled", "timed_out", "action_required", "startup_failure":
+			failed = true
+		}
+		running = running || run.Status != "completed"
+	}
+	for _, status := range combined.Statuses {
+		failed = failed || status.State == "failure" || status.State == "error"
+		running = running || status.State == "pending"
+	}
+	switch {
+	case failed:
+		return "failed", nil
+	case running:
+		return "running", nil
+	}
+	return "passed", nil
+}
+
+// parseGitLabCommit returns the state of the latest pipeline of a commit.
+func parseGitLabCommit(data []byte) (string, error) {
+	var commit struct {
+		LastPipeline *struct {
+			Status string `json:"status"`
+		} `json:"last_pipeline"`
+	}
+	if err := json.Unmarshal(data, &commit); err != nil {
+		return "", fmt.Errorf("unexpected output of glab: %w", err)
+	}
+	if commit.LastPipeline == nil {
+		return "no checks", nil
+	}
+	switch status := commit.LastPipeline.Status; status {
+	case "success":
+		return "passed", nil
+	case "failed":
+		return "failed", nil
+	case "created", "pending", "preparing", "running", "scheduled", "waiting_for_resource":
+		return "running", nil
+	default:
+		return status, nil
+	}
+}
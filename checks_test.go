@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseGitHubCommitChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		runs     string
+		statuses string
+		want     string
+	}{
+		{"green", `{"check_runs": [{"status": "completed", "conclusion": "success"}]}`, `{"statuses": [{"state": "success"}]}`, "passed"},
+		{"red", `{"check_runs": [{"status": "completed", "conclusion": "failure"}, {"status": "in_progress"}]}`, `{"statuses": []}`, "failed"},
+		{"busy", `{"check_runs": [{"status": "completed", "conclusion": "success"}]}`, `{"statuses": [{"state": "pending"}]}`, "running"},
+		{"status error", `{"check_runs": []}`, `{"statuses": [{"state": "error"}]}`, "failed"},
+		{"none", `{"check_runs": []}`, `{"state": "pending", "statuses": []}`, "no checks"},
+	}
+	for _, tt := range tests {
+		got, err := parseGitHubCommitChecks([]byte(tt.runs), []byte(tt.statuses))
+		if err != nil || got != tt.want {
+			t.Errorf("%s: parseGitHubCommitChecks() = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := parseGitHubCommitChecks([]byte("not json"), []byte("{}")); err == nil {
+		t.Error("parseGitHubCommitChecks() of garbage succeeded")
+	}
+}
+
+func TestParseGitLabCommit(t *testing.T) {
+	for data, want := range map[string]string{
+		`{"id": "abc", "last_pipeline": {"status": "running"}}`:  "running",
+		`{"id": "abc", "last_pipeline": {"status": "success"}}`:  "passed",
+		`{"id": "abc", "last_pipeline": {"status": "failed"}}`:   "failed",
+		`{"id": "abc", "last_pipeline": {"status": "canceled"}}`: "canceled",
+		`{"id": "abc", "last_pipeline": null}`:                   "no checks",
+	} {
+		if got, err := parseGitLabCommit([]byte(data)); err != nil || got != want {
+			t.Errorf("parseGitLabCommit(%s) = %q, %v; want %q", data, got, err, want)
+		}
+	}
+}
+
+func TestCIChecksCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	commonDir := t.TempDir()
+	now := time.Now()
+	saveCICache(commonDir, map[string]ciResult{
+		"green":   {State: "passed", Checked: now.Add(-5 * time.Minute)},
+		"busy":    {State: "running", Checked: now.Add(-5 * time.Minute)},
+		"ancient": {State: "failed", Checked: now.Add(-time.Hour)},
+	}, now)
+	cache := loadCICache(commonDir)
+	if !cache["green"].fresh(now) || cache["busy"].fresh(now) {
+		t.Errorf("fresh: passed 5m ago = %v, running 5m ago = %v", cache["green"].fresh(now), cache["busy"].fresh(now))
+	}
+	if _, ok := cache["ancient"]; ok {
+		t.Error("saveCICache() kept a result too old to show")
+	}
+
+	// Heads that are not pushed, and fresh ones, are never asked about.
+	items := []listItem{
+		{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Branch: "local", Head: "l0ca1"}}},
+		{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Branch: "ahead", Head: "a4ead"}, Upstream: "origin/ahead", Ahead: 2}},
+		{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Branch: "feature", Head: "green"}, Upstream: "origin/feature"}},
+	}
+	checks := &ciChecks{provider: provider{Name: "nowhere"}, commonDir: commonDir}
+	states := checks.get(items, now)
+	want := map[string]string{"local": ciNotPushed, "ahead": ciNotPushed, "feature": "passed"}
+	for branch, state := range want {
+		if states[branch] != state {
+			t.Errorf("state of %s = %q, want %q", branch, states[branch], state)
+		}
+	}
+	if checks.err != nil {
+		t.Errorf("get() asked the code host: %v", checks.err)
+	}
+}
+
+func TestCIChecksAsksCodeHost(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gh is a shell script")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	t.Setenv("GH_TOKEN", "secret")
+	bin := t.TempDir()
+	script := `#!/bin/sh
+case "$2" in
+  */bad0*) echo 'gh: No commit found for SHA: bad0 (HTTP 422)' >&2; exit 1 ;;
+  */check-runs*) echo '{"check_runs": [{"status": "completed", "conclusion": "failure"}]}' ;;
+  *) echo '{"statuses": []}' ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "gh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	delete(providerTokens, githubProvider.Name)
+	t.Cleanup(func() { delete(providerTokens, githubProvider.Name) })
+
+	commonDir := t.TempDir()
+	items := []listItem{
+		{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Branch: "red", Head: "f00d"}, Upstream: "origin/red"}},
+		{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Branch: "also-red", Head: "f00d"}, Upstream: "origin/also-red"}},
+		{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Branch: "elsewhere", Head: "bad0"}, Upstream: "fork/elsewhere"}},
+	}
+	checks := &ciChecks{provider: githubProvider, commonDir: commonDir}
+	states := checks.get(items, time.Now())
+	if checks.err != nil {
+		t.Fatal(checks.err)
+	}
+	want := map[string]string{"red": "failed", "also-red": "failed", "elsewhere": ciNotPushed}
+	for branch, state := range want {
+		if states[branch] != state {
+			t.Errorf("state of %s = %q, want %q", branch, states[branch], state)
+		}
+	}
+	if cached := loadCICache(commonDir)["f00d"]; cached.State != "failed" {
+		t.Errorf("cached result = %+v", cached)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultArtifactGlobs are the build output directories 'wt clean' removes
+// unless the artifacts setting says otherwise.
+const defaultArtifactGlobs = "target/,node_modules/,dist/,.venv/"
+
+var (
+	cleanAll    bool
+	cleanDryRun bool
+	cleanForce  bool
+	cleanGlobs  []string
+)
+
+// artifact is a file or directory matched by an artifact glob.
+type artifact struct {
+	Path string
+	Size int64
+}
+
+// matchArtifact reports whether rel (slash-separated, relative to the
+// worktree) matches glob. Globs without a slash match by name at any depth;
+// a trailing slash restricts a glob to directories.
+func matchArtifact(glob, rel string, isDir bool) bool {
+	if strings.HasSuffix(glob, "/") {
+		if !isDir {
+			return false
+		}
+		glob = strings.TrimSuffix(glob, "/")
+	}
+	if strings.Contains(glob, "/") {
+		ok, _ := path.Match(strings.TrimPrefix(glob, "/"), rel)
+		return ok
+	}
+	ok, _ := path.Match(glob, path.Base(rel))
+	return ok
+}
+
+// findArtifacts walks a worktree for paths matching globs. Matched
+// directories are not descended into, and nested repositories or worktrees
+// (anything with its own .git) are left alone.
+func findArtifacts(root string, globs []string) ([]artifact, error) {
+	var found []artifact
+	err := filepath.WalkDir(longPath(root), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, relErr := filepath.Rel(longPath(root), p)
+		if relErr != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Lstat(filepath.Join(p, ".git")); err == nil {
+				return filepath.SkipDir
+			}
+			if rel == scratchDir {
+				// Emptied as a whole; see scratchContents.
+				return filepath.SkipDir
+			}
+		}
+
+		for _, glob := range globs {
+			if !matchArtifact(glob, rel, d.IsDir()) {
+				continue
+			}
+			item := artifact{Path: filepath.Join(root, filepath.FromSlash(rel))}
+			if d.IsDir() {
+				item.Size = dirSize(item.Path)
+				found = append(found, item)
+				return filepath.SkipDir
+			}
+			if info, err := d.Info(); err == nil {
+				item.Size = info.Size()
+			}
+			found = append(found, item)
+			return nil
+		}
+		return nil
+	})
+	return found, err
+}
+
+// cleanTargets returns the worktrees 'wt clean' works on: all of them, the
+// worktrees of the given branches, or the current worktree.
+func cleanTargets(all bool, branches []string) ([]worktreeEntry, error) {
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []worktreeEntry
+	switch {
+	case all:
+		for _, e := range entries {
+			if !e.Bare && !e.Prunable {
+				targets = append(targets, e)
+			}
+		}
+	case len(branches) > 0:
+		for _, branch := range branches {
+			found := false
+			for _, e := range entries {
+				if e.Branch == branch {
+					targets = append(targets, e)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("no worktree found for branch: %s", branch)
+			}
+		}
+	default:
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.Bare && isWithin(e.Path, cwd) && (len(targets) == 0 || len(e.Path) > len(targets[0].Path)) {
+				targets = []worktreeEntry{e}
+			}
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("not inside a worktree; pass branches or --all")
+		}
+	}
+	return targets, nil
+}
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean [branch...]",
+	Short: "Remove build artifacts from worktrees",
+	Long: `Remove build artifacts (target/, node_modules/, dist/, .venv/, ...) from
+worktrees to reclaim disk space without removing the worktrees themselves.
+
+Works on the current worktree, the worktrees of the given branches, or all
+worktrees with --all. The globs come from the 'artifacts' setting (see
+'wt config --help') or --glob. A glob without a slash matches by name at any
+depth; a trailing slash matches directories only. Unless --glob is given,
+the contents of the scratch directory (see the scratch setting) go as well.
+
+Examples:
+  wt clean --dry-run                # Show what would be removed here
+  wt clean --all                    # Clean every worktree (asks first)
+  wt clean feature-a feature-b -f   # Clean two worktrees without asking
+  wt clean --glob build/ --glob '*.log'`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cleanAll && len(args) > 0 {
+			return fmt.Errorf("pass branches or --all, not both")
+		}
+		globs := cleanGlobs
+		if len(globs) == 0 {
+			globs = splitList(loadConfig().get("artifacts"))
+		}
+		if len(globs) == 0 {
+			return fmt.Errorf("no artifact globs configured")
+		}
+
+		targets, err := cleanTargets(cleanAll, args)
+		if err != nil {
+			return err
+		}
+
+		var found []artifact
+		var total int64
+		for _, wt := range targets {
+			items, err := findArtifacts(wt.Path, globs)
+			if err != nil {
+				warnf("warning: failed to scan %s: %v\n", wt.Path, err)
+			}
+			if len(cleanGlobs) == 0 {
+				items = append(items, scratchContents(wt.Path)...)
+			}
+			for _, item := range items {
+				fmt.Printf("  %-8s %s\n", formatSize(item.Size), item.Path)
+				total += item.Size
+			}
+			found = append(found, items...)
+		}
+
+		if len(found) == 0 {
+			infof("No build artifacts found\n")
+			return nil
+		}
+		if cleanDryRun {
+			fmt.Printf("Would remove %d path(s), %s\n", len(found), formatSize(total))
+			return nil
+		}
+
+		if !cleanForce {
+			ok, err := confirmPrompt(fmt.Sprintf("Remove %d path(s), %s", len(found), formatSize(total)))
+			if errors.Is(err, errPromptDisabled) {
+				return fmt.Errorf("removing build artifacts needs confirmation, use --force: %w", err)
+			}
+			if !ok {
+				infof("Nothing removed\n")
+				return nil
+			}
+		}
+
+		removed, failed := 0, 0
+		var freed int64
+		for _, item := range found {
+			if err := os.RemoveAll(longPath(item.Path)); err != nil {
+				warnf("  Failed to remove %s: %v\n", item.Path, err)
+				failed++
+				continue
+			}
+			removed++
+			freed += item.Size
+		}
+
+		if ciMode() {
+			fmt.Println(machineSummary("clean", "removed", removed, "failed", failed, "freed", freed))
+		} else {
+			successf("Removed %d path(s), freed %s", removed, formatSize(freed))
+		}
+		if failed > 0 {
+			return fmt.Errorf("failed to remove %d path(s)", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	cleanCmd.Flags().BoolVar(&cleanAll, "all", false, "Clean all worktrees of the repository")
+	cleanCmd.Flags().BoolVar(&cleanDryRun, "dry-run", false, "Show what would be removed and how much space it takes")
+	cleanCmd.Flags().BoolVarP(&cleanForce, "force", "f", false, "Remove without confirmation")
+	cleanCmd.Flags().StringArrayVar(&cleanGlobs, "glob", nil, "Artifact glob to remove (repeatable; overrides the artifacts setting)")
+	rootCmd.AddCommand(cleanCmd)
+}
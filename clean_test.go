@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMatchArtifact(t *testing.T) {
+	tests := []struct {
+		glob  string
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"node_modules/", "node_modules", true, true},
+		{"node_modules/", "web/node_modules", true, true},
+		{"node_modules/", "node_modules", false, false},
+		{"*.log", "logs/app.log", false, true},
+		{"build/out", "build/out", true, true},
+		{"build/out", "sub/build/out", true, false},
+		{"dist/", "distribution", true, false},
+	}
+	for _, tt := range tests {
+		if got := matchArtifact(tt.glob, tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("matchArtifact(%q, %q, %v) = %v, want %v", tt.glob, tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestFindArtifacts(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"src/main.go":                   "package main",
+		"target/debug/app":              "binary",
+		"web/node_modules/pkg/index.js": "module.exports = 1",
+		"nested/.git":                   "gitdir: elsewhere",
+		"nested/target/keep":            "nested worktree",
+		"debug.log":                     "log",
+	}
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := findArtifacts(root, []string{"target/", "node_modules/", "*.log"})
+	if err != nil {
+		t.Fatalf("findArtifacts() error: %v", err)
+	}
+	var got []string
+	for _, item := range found {
+		rel, _ := filepath.Rel(root, item.Path)
+		got = append(got, filepath.ToSlash(rel))
+		if item.Size == 0 {
+			t.Errorf("artifact %s has no size", rel)
+		}
+	}
+	sort.Strings(got)
+	if want := "debug.log,target,web/node_modules"; strings.Join(got, ",") != want {
+		t.Errorf("findArtifacts() = %v, want %s", got, want)
+	}
+}
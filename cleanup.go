@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	cleanupJSON   bool
+	cleanupReport string
+)
+
+// Results of cleanup for one worktree.
+const (
+	cleanupRemoved     = "removed"
+	cleanupWouldRemove = "would remove"
+	cleanupSkipped     = "skipped"
+	cleanupDirty       = "local changes"
+	cleanupFailed      = "failed"
+)
+
+// cleanupItem is what cleanup did, or would do, with one worktree.
+type cleanupItem struct {
+	Branch string `json:"branch"`
+	Path   string `json:"path"`
+	// Reason is why it may go: merged, expired or stale.
+	Reason        string `json:"reason"`
+	MergedInto    string `json:"merged_into,omitempty"`
+	Size          int64  `json:"size"`
+	Result        string `json:"result"`
+	Error         string `json:"error,omitempty"`
+	BranchDeleted bool   `json:"branch_deleted,omitempty"`
+}
+
+// cleanupSummary is the structured result of a cleanup run, printed by
+// --json and rendered by --report.
+type cleanupSummary struct {
+	Repo      string        `json:"repo"`
+	Base      string        `json:"base"`
+	DryRun    bool          `json:"dry_run"`
+	Time      time.Time     `json:"time"`
+	Worktrees []cleanupItem `json:"worktrees"`
+}
+
+// count returns how many worktrees ended with result.
+func (s cleanupSummary) count(result string) int {
+	n := 0
+	for _, item := range s.Worktrees {
+		if item.Result == result {
+			n++
+		}
+	}
+	return n
+}
+
+// reclaimed is the space the removed worktrees took, or would take in a
+// dry run.
+func (s cleanupSummary) reclaimed() int64 {
+	var size int64
+	for _, item := range s.Worktrees {
+		if item.Result == cleanupRemoved || item.Result == cleanupWouldRemove {
+			size += item.Size
+		}
+	}
+	return size
+}
+
+// headline sums the run up in one line.
+func (s cleanupSummary) headline() string {
+	if s.DryRun {
+		return fmt.Sprintf("%d to remove, %d with local changes, %s to reclaim",
+			s.count(cleanupWouldRemove), s.count(cleanupDirty), formatSize(s.reclaimed()))
+	}
+	return fmt.Sprintf("%d removed, %d skipped, %d with local changes, %d failed, %s reclaimed",
+		s.count(cleanupRemoved), s.count(cleanupSkipped), s.count(cleanupDirty), s.count(cleanupFailed), formatSize(s.reclaimed()))
+}
+
+// checkCleanupOutput validates --report and --json before cleanup starts.
+func checkCleanupOutput() error {
+	switch {
+	case cleanupReport != "" && cleanupJSON:
+		return fmt.Errorf("--report and --json cannot be combined")
+	case cleanupReport != "" && cleanupReport != "markdown" && cleanupReport != "html":
+		return fmt.Errorf("unknown report format %q (use markdown or html)", cleanupReport)
+	}
+	return nil
+}
+
+// writeCleanupOutput prints the summary as --json or --report asked for.
+func writeCleanupOutput(w io.Writer, s cleanupSummary) error {
+	switch {
+	case cleanupJSON:
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case cleanupReport == "html":
+		return writeCleanupHTML(w, s)
+	}
+	return writeCleanupMarkdown(w, s)
+}
+
+// cleanupDetails describes the result of an item for the report.
+func cleanupDetails(item cleanupItem) string {
+	details := item.Result
+	if item.BranchDeleted {
+		details += ", branch deleted"
+	}
+	if item.Error != "" {
+		details += ": " + item.Error
+	}
+	return details
+}
+
+// markdownCell escapes what would end a markdown table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+func writeCleanupMarkdown(w io.Writer, s cleanupSummary) error {
+	var b strings.Builder
+	title := "wt cleanup of " + s.Repo
+	if s.DryRun {
+		title += " (dry run)"
+	}
+	fmt.Fprintf(&b, "## %s\n\n", title)
+	fmt.Fprintf(&b, "%s, base `%s`: %s\n", s.Time.Format("2006-01-02 15:04"), s.Base, s.headline())
+
+	var dirty []cleanupItem
+	if len(s.Worktrees) > 0 {
+		b.WriteString("\n| Branch | Reason | Size | Result |\n| --- | --- | ---: | --- |\n")
+	}
+	for _, item := range s.Worktrees {
+		if item.Result == cleanupDirty {
+			dirty = append(dirty, item)
+			continue
+		}
+		reason := item.Reason
+		if item.MergedInto != "" {
+			reason = "merged into `" + item.MergedInto + "`"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", markdownCell(item.Branch), reason, formatSize(item.Size), markdownCell(cleanupDetails(item)))
+	}
+	if len(dirty) > 0 {
+		b.WriteString("\n### Skipped: local changes\n\n")
+		for _, item := range dirty {
+			fmt.Fprintf(&b, "- `%s` (%s)\n", item.Branch, item.Path)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var cleanupHTMLTemplate = template.Must(template.New("cleanup").Funcs(template.FuncMap{
+	"size":    formatSize,
+	"details": cleanupDetails,
+}).Parse(`<h2>wt cleanup of {{.Repo}}{{if .DryRun}} (dry run){{end}}</h2>
+<p>{{.Time.Format "2006-01-02 15:04"}}, base <code>{{.Base}}</code>: {{.Headline}}</p>
+{{- if .Listed}}
+<table>
+<tr><th>Branch</th><th>Reason</th><th>Size</th><th>Result</th></tr>
+{{- range .Listed}}
+<tr><td><code>{{.Branch}}</code></td><td>{{if .MergedInto}}merged into <code>{{.MergedInto}}</code>{{else}}{{.Reason}}{{end}}</td><td align="right">{{size .Size}}</td><td>{{details .}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Dirty}}
+<h3>Skipped: local changes</h3>
+<ul>
+{{- range .Dirty}}
+<li><code>{{.Branch}}</code> ({{.Path}})</li>
+{{- end}}
+</ul>
+{{- end}}
+`))
+
+func writeCleanupHTML(w io.Writer, s cleanupSummary) error {
+	data := struct {
+		cleanupSummary
+		Headline      string
+		Listed, Dirty []cleanupItem
+	}{cleanupSummary: s, Headline: s.headline()}
+	for _, item := range s.Worktrees {
+		if item.Result == cleanupDirty {
+			data.Dirty = append(data.Dirty, item)
+		} else {
+			data.Listed = append(data.Listed, item)
+		}
+	}
+	return cleanupHTMLTemplate.Execute(w, data)
+}
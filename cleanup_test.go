@@ -1,11 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetMergedBranches(t *testing.T) {
@@ -204,3 +206,67 @@ func TestCleanupE2E(t *testing.T) {
 		t.Error("Worktree was not removed after cleanup --force")
 	}
 }
+
+func TestCleanupReport(t *testing.T) {
+	t.Cleanup(func() { cleanupJSON, cleanupReport = false, "" })
+	summary := cleanupSummary{
+		Repo: "wt",
+		Base: "main",
+		Time: time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC),
+		Worktrees: []cleanupItem{
+			{Branch: "feat|x", Path: "/wt/feat", Reason: "merged", MergedInto: "main", Size: 2048, Result: cleanupRemoved, BranchDeleted: true},
+			{Branch: "old", Path: "/wt/old", Reason: "expired", Size: 1024, Result: cleanupFailed, Error: "pre-remove hook failed"},
+			{Branch: "wip", Path: "/wt/wip", Reason: "merged", MergedInto: "main", Size: 512, Result: cleanupDirty},
+		},
+	}
+	if got := summary.headline(); got != "1 removed, 0 skipped, 1 with local changes, 1 failed, 2.0K reclaimed" {
+		t.Errorf("headline() = %q", got)
+	}
+
+	var out bytes.Buffer
+	cleanupReport = "markdown"
+	if err := writeCleanupOutput(&out, summary); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"## wt cleanup of wt\n",
+		"2026-03-02 09:30, base `main`: 1 removed",
+		"| `feat\\|x` | merged into `main` | 2.0K | removed, branch deleted |\n",
+		"| `old` | expired | 1.0K | failed: pre-remove hook failed |\n",
+		"### Skipped: local changes\n\n- `wip` (/wt/wip)\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("markdown lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	cleanupReport = "html"
+	summary.Worktrees[1].Branch = "<old>"
+	if err := writeCleanupOutput(&out, summary); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<h2>wt cleanup of wt</h2>", "<code>&lt;old&gt;</code>", "<li><code>wip</code> (/wt/wip)</li>"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("html lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	cleanupReport, cleanupJSON = "", true
+	if err := writeCleanupOutput(&out, summary); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"result": "local changes"`) {
+		t.Errorf("json = %s", out.String())
+	}
+
+	cleanupReport = "markdown"
+	if err := checkCleanupOutput(); err == nil {
+		t.Error("--report with --json was accepted")
+	}
+	cleanupJSON, cleanupReport = false, "pdf"
+	if err := checkCleanupOutput(); err == nil {
+		t.Error("unknown report format was accepted")
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// completionCacheTTL bounds how long cached completion candidates are trusted
+// even when the refs stamp did not change (e.g. refs updated by a tool that
+// preserves directory mtimes).
+const completionCacheTTL = 10 * time.Minute
+
+var (
+	completeWorktreesOnly bool
+	shellenvCompletions   bool
+)
+
+// branchesCompletionCmd is called by the shell completion functions. It prints
+// one candidate per line and never fails loudly, since errors would end up in
+// the user's prompt while they are typing.
+var branchesCompletionCmd = &cobra.Command{
+	Use:    "__branches",
+	Short:  "List branch names for shell completion",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, branch := range completionCandidates(completeWorktreesOnly) {
+			fmt.Println(branch)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(branchesCompletionCmd)
+	branchesCompletionCmd.Flags().BoolVar(&completeWorktreesOnly, "worktrees", false, "Only list branches that have a worktree")
+
+	// cobra adds its completion command only when the root command runs;
+	// adding it now lets 'install' go under it.
+	rootCmd.InitDefaultCompletionCmd()
+	for _, cmd := range rootCmd.Commands() {
+		if cmd.Name() == "completion" {
+			cmd.AddCommand(completionInstallCmd)
+		}
+	}
+	completionInstallCmd.Flags().BoolVar(&completionInstallDryRun, "dry-run", false, "Show what would be written or removed, without doing it")
+	completionInstallCmd.Flags().BoolVar(&completionInstallUninstall, "uninstall", false, "Remove the installed completion script")
+
+	checkoutCmd.ValidArgsFunction = completeBranchArgs(false)
+	removeCmd.ValidArgsFunction = completeBranchArgs(true)
+	execCmd.ValidArgsFunction = completeBranchArgs(true)
+	restackCmd.ValidArgsFunction = completeBranchArgs(true)
+	describeCmd.ValidArgsFunction = completeBranchArgs(false)
+	infoCmd.ValidArgsFunction = completeBranchArgs(true)
+	tagAddCmd.ValidArgsFunction = completeBranchArgs(false)
+	tagRemoveCmd.ValidArgsFunction = completeBranchArgs(false)
+	moveCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 1 {
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		}
+		return completeBranchArgs(true)(cmd, args, toComplete)
+	}
+}
+
+// completeBranchArgs returns a cobra completion function for the first
+// positional argument of a command.
+func completeBranchArgs(worktreesOnly bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completionCandidates(worktreesOnly), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completionCandidates returns branch names for completion, served from the
+// per-repo cache when it is still fresh.
+func completionCandidates(worktreesOnly bool) []string {
+	kind := "branches"
+	load := getAvailableBranches
+	if worktreesOnly {
+		kind = "worktrees"
+		load = getExistingWorktreeBranches
+	}
+
+	branches, err := cachedBranchList(kind, load)
+	if err != nil {
+		return nil
+	}
+	return branches
+}
+
+type branchCache struct {
+	Stamp    int64     `json:"stamp"`
+	Created  time.Time `json:"created"`
+	Branches []string  `json:"branches"`
+}
+
+// cachedBranchList returns the result of load, caching it per repository and
+// kind. The cache is invalidated when anything under the repository's refs
+// changes or when it is older than completionCacheTTL.
+func cachedBranchList(kind string, load func() ([]string, error)) ([]string, error) {
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return nil, err
+	}
+
+	stamp := refsStamp(commonDir)
+	cachePath := completionCachePath(commonDir, kind)
+
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var cached branchCache
+		if json.Unmarshal(data, &cached) == nil && cached.Stamp == stamp && time.Since(cached.Created) < completionCacheTTL {
+			return cached.Branches, nil
+		}
+	}
+
+	branches, err := load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(branches)
+
+	// Failing to write the cache only costs speed on the next invocation.
+	if data, err := json.Marshal(branchCache{Stamp: stamp, Created: time.Now(), Branches: branches}); err == nil {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+			_ = os.WriteFile(cachePath, data, 0o644)
+		}
+	}
+
+	return branches, nil
+}
+
+// gitCommonDir returns the absolute path of the repository's shared git
+// directory, which is the same for the main checkout and all its worktrees.
+func gitCommonDir() (string, error) {
+	output, err := gitCommand("rev-parse", "--git-common-dir").Output()
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository")
+	}
+	// Relative results are relative to the current directory.
+	return filepath.Abs(strings.TrimSpace(string(output)))
+}
+
+// refsStamp summarizes the modification times of everything that affects the
+// branch and worktree lists. Ref updates go through lock files that are
+// renamed into place, so directory mtimes change whenever a ref does.
+func refsStamp(commonDir string) int64 {
+	var stamp int64
+	note := func(path string) {
+		if info, err := os.Stat(path); err == nil && info.ModTime().UnixNano() > stamp {
+			stamp = info.ModTime().UnixNano()
+		}
+	}
+
+	note(filepath.Join(commonDir, "packed-refs"))
+	note(filepath.Join(commonDir, "worktrees"))
+	_ = filepath.WalkDir(filepath.Join(commonDir, "refs"), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			note(path)
+		}
+		return nil
+	})
+	return stamp
+}
+
+func completionCachePath(commonDir, kind string) string {
+	sum := sha1.Sum([]byte(commonDir))
+	return filepath.Join(cacheDir(), "completion", hex.EncodeToString(sum[:8])+"-"+kind+".json")
+}
+
+// cacheDir returns the directory for wt's disposable caches.
+func cacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "wt")
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "wt")
+	}
+	return filepath.Join(os.TempDir(), "wt-cache")
+}
+
+// bashCompletionShims stand in for the two bash-completion functions the
+// cobra bash completion uses, for systems without the bash-completion package.
+const bashCompletionShims = `if ! type _get_comp_words_by_ref >/dev/null 2>&1; then
+    _get_comp_words_by_ref() {
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        prev="${COMP_WORDS[COMP_CWORD-1]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    }
+fi
+if ! type _filedir >/dev/null 2>&1; then
+    _filedir() {
+        if [ "$1" = -d ]; then
+            COMPREPLY=( $(compgen -d -- "$cur") )
+        else
+            COMPREPLY=( $(compgen -f -- "$cur") )
+        fi
+    }
+fi
+`
+
+// writeCobraCompletion writes the full completion for 'wt shellenv
+// --completions'. Without a shell the output serves bash and zsh alike, like
+// the rest of shellenv, so the script for the running shell is loaded when
+// it is sourced.
+func writeCobraCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		fmt.Fprint(w, bashCompletionShims)
+		return rootCmd.GenBashCompletionV2(w, true)
+	case "powershell":
+		return rootCmd.GenPowerShellCompletionWithDesc(w)
+	case "zsh":
+		// compdef only exists after compinit; without it, skip quietly.
+		var script bytes.Buffer
+		if err := rootCmd.GenZshCompletion(&script); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "if (( $+functions[compdef] )); then\n%sfi\n", script.String())
+		return err
+	}
+	_, err := fmt.Fprintf(w, `# Full completion of commands, flags and branch names
+if [ -n "$ZSH_VERSION" ]; then
+    (( $+functions[compdef] )) && eval "$(command wt completion zsh)"
+elif [ -n "$BASH_VERSION" ]; then
+%s    eval "$(command wt completion bash)"
+fi
+`, bashCompletionShims)
+	return err
+}
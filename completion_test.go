@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachedBranchListUsesCacheUntilRefsChange(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tmpDir, "cache"))
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+
+	calls := 0
+	load := func() ([]string, error) {
+		calls++
+		return getAvailableBranches()
+	}
+
+	first, err := cachedBranchList("branches", load)
+	if err != nil {
+		t.Fatalf("cachedBranchList() error: %v", err)
+	}
+	if _, err := cachedBranchList("branches", load); err != nil {
+		t.Fatalf("cachedBranchList() error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected second call to be served from cache, load called %d times", calls)
+	}
+	if strings.Join(first, ",") != "main" {
+		t.Fatalf("cachedBranchList() = %v, want [main]", first)
+	}
+
+	// Make sure the new ref lands with a distinguishable mtime.
+	time.Sleep(10 * time.Millisecond)
+	runGitCommand(t, repoDir, "branch", "feature/cached")
+
+	updated, err := cachedBranchList("branches", load)
+	if err != nil {
+		t.Fatalf("cachedBranchList() error: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected cache to be invalidated by new branch, load called %d times", calls)
+	}
+	if strings.Join(updated, ",") != "feature/cached,main" {
+		t.Fatalf("cachedBranchList() = %v, want [feature/cached main]", updated)
+	}
+}
+
+func TestCompletionCachePathIsPerRepoAndKind(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	a := completionCachePath("/src/a/.git", "branches")
+	b := completionCachePath("/src/b/.git", "branches")
+	w := completionCachePath("/src/a/.git", "worktrees")
+
+	if a == b {
+		t.Error("expected different repositories to use different cache files")
+	}
+	if a == w {
+		t.Error("expected different kinds to use different cache files")
+	}
+	if !strings.HasPrefix(a, filepath.Join(os.Getenv("XDG_CACHE_HOME"), "wt")) {
+		t.Errorf("cache path %s not under XDG_CACHE_HOME", a)
+	}
+}
+
+func TestBranchesCompletionCommandRegistered(t *testing.T) {
+	cmd, _, err := rootCmd.Find([]string{"__branches"})
+	if err != nil || cmd.Name() != "__branches" {
+		t.Fatalf("__branches command not registered: %v", err)
+	}
+	if !cmd.Hidden {
+		t.Error("__branches should be hidden from help output")
+	}
+	if checkoutCmd.ValidArgsFunction == nil || removeCmd.ValidArgsFunction == nil {
+		t.Error("checkout and remove should provide dynamic branch completion")
+	}
+}
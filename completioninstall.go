@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	completionInstallDryRun    bool
+	completionInstallUninstall bool
+)
+
+// completionTarget is where a shell loads completion scripts from on its
+// own, with what the user still has to do for it, if anything.
+type completionTarget struct {
+	Path string
+	Hint string
+}
+
+// completionShell is the shell 'wt completion install' installs for: the
+// argument, else the one in $SHELL.
+func completionShell(args []string) (string, error) {
+	shell := ""
+	if len(args) > 0 {
+		shell = strings.ToLower(args[0])
+	} else {
+		shell = filepath.Base(os.Getenv("SHELL"))
+	}
+	switch shell {
+	case "bash", "zsh", "fish":
+		return shell, nil
+	case "":
+		return "", fmt.Errorf("cannot tell the shell from $SHELL; pass bash, zsh or fish")
+	}
+	return "", fmt.Errorf("unsupported shell %q (use bash, zsh or fish; for PowerShell and tcsh see 'wt init')", shell)
+}
+
+// xdgDir returns $<env>, else ~/<fallback>.
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, fallback)
+}
+
+// bashCompletionInstalled reports whether the bash-completion package, which
+// loads the scripts in its user directory, seems to be there.
+func bashCompletionInstalled() bool {
+	for _, path := range []string{
+		"/usr/share/bash-completion/bash_completion",
+		"/etc/bash_completion",
+		"/usr/local/share/bash-completion/bash_completion",
+		"/opt/homebrew/share/bash-completion/bash_completion",
+		"/opt/homebrew/etc/profile.d/bash_completion.sh",
+		"/usr/local/etc/profile.d/bash_completion.sh",
+	} {
+		if fileExists(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ohMyZshCustom returns the custom directory of oh-my-zsh, or "" when it
+// is not installed.
+func ohMyZshCustom() string {
+	if dir := os.Getenv("ZSH_CUSTOM"); dir != "" {
+		return dir
+	}
+	zsh := os.Getenv("ZSH")
+	if zsh == "" {
+		home, _ := os.UserHomeDir()
+		zsh = filepath.Join(home, ".oh-my-zsh")
+	}
+	if info, err := os.Stat(filepath.Join(zsh, "oh-my-zsh.sh")); err == nil && !info.IsDir() {
+		return filepath.Join(zsh, "custom")
+	}
+	return ""
+}
+
+// completionTargetFor returns where the completion script for shell goes:
+//
+//	bash  the user directory of bash-completion v2, loaded on demand
+//	zsh   a plugin in oh-my-zsh's custom directory, else a directory that
+//	      has to be on fpath
+//	fish  fish's own completions directory, loaded on demand
+func completionTargetFor(shell string) completionTarget {
+	switch shell {
+	case "bash":
+		dir := os.Getenv("BASH_COMPLETION_USER_DIR")
+		if dir == "" {
+			dir = filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")), "bash-completion")
+		}
+		target := completionTarget{Path: filepath.Join(dir, "completions", "wt")}
+		if !bashCompletionInstalled() {
+			target.Hint = "bash loads it through the bash-completion package (version 2), which does not seem to be installed"
+		}
+		return target
+	case "zsh":
+		if custom := ohMyZshCustom(); custom != "" {
+			return completionTarget{
+				Path: filepath.Join(custom, "plugins", "wt", "_wt"),
+				Hint: "add wt to plugins=(...) in ~/.zshrc, unless it is there already",
+			}
+		}
+		dir := filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")), "zsh", "site-functions")
+		return completionTarget{
+			Path: filepath.Join(dir, "_wt"),
+			Hint: fmt.Sprintf("add this to ~/.zshrc before compinit, unless it is there already:\n  fpath=(%s $fpath)", dir),
+		}
+	}
+	return completionTarget{Path: filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "fish", "completions", "wt.fish")}
+}
+
+// completionScript returns cobra's completion script for shell: every
+// command, flag and branch name, like 'wt completion <shell>'.
+func completionScript(shell string) ([]byte, error) {
+	var script bytes.Buffer
+	var err error
+	switch shell {
+	case "bash":
+		err = rootCmd.GenBashCompletionV2(&script, true)
+	case "zsh":
+		err = rootCmd.GenZshCompletion(&script)
+	case "fish":
+		err = rootCmd.GenFishCompletion(&script, true)
+	}
+	return script.Bytes(), err
+}
+
+var completionInstallCmd = &cobra.Command{
+	Use:   "install [bash|zsh|fish]",
+	Short: "Install the completion script where the shell loads it by itself",
+	Long: `Install the completion script of wt where the shell loads it by itself,
+without touching any rc file: for those who manage their shell setup by
+hand, or only want completion without the shell integration of 'wt init'.
+
+Without a shell argument the shell in $SHELL is used. The script goes to:
+  bash  $BASH_COMPLETION_USER_DIR/completions/wt, by default
+        ~/.local/share/bash-completion/completions/wt (bash-completion v2)
+  zsh   $ZSH_CUSTOM/plugins/wt/_wt with oh-my-zsh (add wt to plugins),
+        else ~/.local/share/zsh/site-functions/_wt (add it to fpath)
+  fish  ~/.config/fish/completions/wt.fish
+
+Run it again after upgrading wt to pick up new commands and flags.
+
+Examples:
+  wt completion install              # For the shell in $SHELL
+  wt completion install fish --dry-run
+  wt completion install zsh --uninstall`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shell, err := completionShell(args)
+		if err != nil {
+			return err
+		}
+		target := completionTargetFor(shell)
+
+		if completionInstallUninstall {
+			if !fileExists(target.Path) {
+				infof("No %s completion installed at %s\n", shell, target.Path)
+				return nil
+			}
+			if completionInstallDryRun {
+				fmt.Printf("Would remove %s\n", target.Path)
+				return nil
+			}
+			if err := os.Remove(target.Path); err != nil {
+				return err
+			}
+			if shell == "zsh" && filepath.Base(filepath.Dir(target.Path)) == "wt" {
+				// The oh-my-zsh plugin directory, which only held the script
+				os.Remove(filepath.Dir(target.Path))
+			}
+			successf("Removed %s completion from %s", shell, target.Path)
+			return nil
+		}
+
+		script, err := completionScript(shell)
+		if err != nil {
+			return err
+		}
+		if completionInstallDryRun {
+			fmt.Printf("Would write the %s completion (%d bytes) to %s\n", shell, len(script), target.Path)
+			if target.Hint != "" {
+				fmt.Printf("Then %s\n", target.Hint)
+			}
+			return nil
+		}
+		if err := writeFileAtomic(target.Path, script); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target.Path, err)
+		}
+		os.Chmod(target.Path, 0o644)
+		successf("Installed %s completion to %s", shell, target.Path)
+		if target.Hint != "" {
+			infof("Now %s\n", target.Hint)
+		}
+		infof("It is loaded by new shells.\n")
+		return nil
+	},
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompletionShell(t *testing.T) {
+	t.Setenv("SHELL", "/usr/bin/zsh")
+	if shell, err := completionShell(nil); err != nil || shell != "zsh" {
+		t.Errorf("completionShell() = %q, %v; want zsh from $SHELL", shell, err)
+	}
+	if shell, err := completionShell([]string{"Fish"}); err != nil || shell != "fish" {
+		t.Errorf("completionShell(Fish) = %q, %v; want fish", shell, err)
+	}
+	if _, err := completionShell([]string{"powershell"}); err == nil {
+		t.Error("completionShell(powershell) succeeded")
+	}
+}
+
+func TestCompletionTargetFor(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("BASH_COMPLETION_USER_DIR", "")
+	t.Setenv("ZSH_CUSTOM", "")
+	t.Setenv("ZSH", "")
+
+	tests := []struct {
+		shell string
+		want  string
+	}{
+		{"bash", filepath.Join(home, ".local", "share", "bash-completion", "completions", "wt")},
+		{"zsh", filepath.Join(home, ".local", "share", "zsh", "site-functions", "_wt")},
+		{"fish", filepath.Join(home, ".config", "fish", "completions", "wt.fish")},
+	}
+	for _, tt := range tests {
+		if got := completionTargetFor(tt.shell).Path; got != tt.want {
+			t.Errorf("completionTargetFor(%s) = %s, want %s", tt.shell, got, tt.want)
+		}
+	}
+
+	t.Setenv("BASH_COMPLETION_USER_DIR", "/opt/bc")
+	if got := completionTargetFor("bash").Path; got != filepath.Join("/opt/bc", "completions", "wt") {
+		t.Errorf("bash target ignores $BASH_COMPLETION_USER_DIR: %s", got)
+	}
+
+	// oh-my-zsh, found in its default place
+	omz := filepath.Join(home, ".oh-my-zsh")
+	if err := os.MkdirAll(omz, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(omz, "oh-my-zsh.sh"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := completionTargetFor("zsh").Path; got != filepath.Join(omz, "custom", "plugins", "wt", "_wt") {
+		t.Errorf("zsh target with oh-my-zsh = %s", got)
+	}
+	t.Setenv("ZSH_CUSTOM", "/elsewhere")
+	if got := completionTargetFor("zsh").Path; got != filepath.Join("/elsewhere", "plugins", "wt", "_wt") {
+		t.Errorf("zsh target ignores $ZSH_CUSTOM: %s", got)
+	}
+}
+
+func TestCompletionInstallAndUninstall(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ZSH_CUSTOM", filepath.Join(home, "custom"))
+	defer func() { completionInstallDryRun, completionInstallUninstall = false, false }()
+
+	target := filepath.Join(home, "custom", "plugins", "wt", "_wt")
+	completionInstallDryRun = true
+	if err := completionInstallCmd.RunE(completionInstallCmd, []string{"zsh"}); err != nil {
+		t.Fatal(err)
+	}
+	if fileExists(target) {
+		t.Fatal("--dry-run wrote the script")
+	}
+
+	completionInstallDryRun = false
+	if err := completionInstallCmd.RunE(completionInstallCmd, []string{"zsh"}); err != nil {
+		t.Fatal(err)
+	}
+	script, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(script, []byte("#compdef wt")) {
+		t.Errorf("installed script is not a zsh completion:\n%.80s", script)
+	}
+
+	completionInstallUninstall = true
+	if err := completionInstallCmd.RunE(completionInstallCmd, []string{"zsh"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Dir(target)); !os.IsNotExist(err) {
+		t.Errorf("--uninstall left the plugin directory: %v", err)
+	}
+}
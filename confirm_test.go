@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadConfirmPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "root: /srv\nconfirm:\n  remove_dirty: Always\n  cleanup: never\n  delete_branch: sometimes\n  rebase: always\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	policies, problems := readConfirmPolicies(path)
+	if policies["remove_dirty"].Policy != confirmAlways || policies["cleanup"].Policy != confirmNever {
+		t.Errorf("readConfirmPolicies() = %v", policies)
+	}
+	if _, ok := policies["delete_branch"]; ok {
+		t.Error("invalid policy was kept")
+	}
+	if policies["cleanup"].Source != path+":4" {
+		t.Errorf("source = %q, want %s:4", policies["cleanup"].Source, path)
+	}
+	if len(problems) != 2 || !strings.Contains(problems[0], ":5: invalid confirm policy for delete_branch") ||
+		!strings.Contains(problems[1], ":6: unknown confirm action \"rebase\"") {
+		t.Errorf("problems = %q", problems)
+	}
+	// The section is not an unknown key to the settings reader.
+	if _, _, problems := readConfigFile(path); len(problems) != 0 {
+		t.Errorf("readConfigFile() problems = %q", problems)
+	}
+
+	if err := os.WriteFile(path, []byte("confirm: always\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, problems := readConfirmPolicies(path); len(problems) != 1 || !strings.Contains(problems[0], "confirm must map actions") {
+		t.Errorf("problems = %q", problems)
+	}
+}
+
+func TestConfirmAction(t *testing.T) {
+	t.Cleanup(func() { confirmPolicies = nil })
+	t.Setenv("CI", "true")
+
+	confirmPolicies = nil
+	if ok, err := confirmAction("cleanup", "Remove", false); !ok || err != nil {
+		t.Errorf("default without ask = %v, %v, want true", ok, err)
+	}
+	if _, err := confirmAction("cleanup", "Remove", true); err == nil {
+		t.Error("default with ask did not prompt")
+	}
+
+	confirmPolicies = map[string]confirmSetting{"cleanup": {Policy: confirmNever}, "delete_branch": {Policy: confirmAlways}}
+	if ok, err := confirmAction("cleanup", "Remove", true); !ok || err != nil {
+		t.Errorf("never = %v, %v, want true", ok, err)
+	}
+	if _, err := confirmAction("delete_branch", "Delete", false); err == nil {
+		t.Error("always did not prompt")
+	}
+}
+
+func TestConfirmRemoveDirtyAndDeleteBranch(t *testing.T) {
+	t.Cleanup(func() { confirmPolicies = nil })
+	t.Setenv("CI", "true")
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, repoDir, "config", "wt.base", "main")
+	loadWorktreeConfig()
+
+	if err := os.WriteFile(filepath.Join(repoDir, "new.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := localChanges(repoDir); got != 1 {
+		t.Errorf("localChanges() = %d, want 1", got)
+	}
+	confirmPolicies = nil
+	if _, err := confirmRemoveDirty("main", repoDir, false); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("default = %v, want a refusal that mentions --force", err)
+	}
+	if force, err := confirmRemoveDirty("main", repoDir, true); !force || err != nil {
+		t.Errorf("default with --force = %v, %v, want true, nil", force, err)
+	}
+	confirmPolicies = map[string]confirmSetting{"remove_dirty": {Policy: confirmNever}}
+	if force, err := confirmRemoveDirty("main", repoDir, false); !force || err != nil {
+		t.Errorf("never = %v, %v, want true, nil", force, err)
+	}
+	confirmPolicies = map[string]confirmSetting{"remove_dirty": {Policy: confirmAlways}}
+	if _, err := confirmRemoveDirty("main", repoDir, true); err == nil || !strings.Contains(err.Error(), "1 local change(s)") {
+		t.Errorf("always in CI = %v, want a confirmation error", err)
+	}
+
+	// Merged into main: deleted without asking, even in CI.
+	runGitCommand(t, repoDir, "branch", "merged")
+	confirmPolicies = nil
+	deleteBranch("merged", false)
+	if gitRefExists("refs/heads/merged") {
+		t.Error("merged branch was kept")
+	}
+
+	// With a commit of its own: kept unless never asked.
+	runGitCommand(t, repoDir, "checkout", "-q", "-b", "work")
+	runGitCommand(t, repoDir, "commit", "--allow-empty", "-m", "work")
+	runGitCommand(t, repoDir, "checkout", "-q", "main")
+	deleteBranch("work", false)
+	if !gitRefExists("refs/heads/work") {
+		t.Fatal("branch with unpushed commits was deleted without confirmation")
+	}
+	confirmPolicies = map[string]confirmSetting{"delete_branch": {Policy: confirmNever}}
+	deleteBranch("work", false)
+	if gitRefExists("refs/heads/work") {
+		t.Error("branch was kept with confirm.delete_branch never")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// doctorStatus is the outcome of one doctor check.
+type doctorStatus int
+
+const (
+	doctorOK doctorStatus = iota
+	doctorWarn
+	doctorFail
+	doctorSkip
+)
+
+func (s doctorStatus) String() string {
+	switch s {
+	case doctorOK:
+		return "ok"
+	case doctorWarn:
+		return "warn"
+	case doctorFail:
+		return "FAIL"
+	default:
+		return "skip"
+	}
+}
+
+// doctorResult is what a check found, with a hint on how to fix it.
+type doctorResult struct {
+	Name    string
+	Status  doctorStatus
+	Message string
+	Hint    string
+}
+
+// doctorCheck inspects one aspect of the setup. A check may report several
+// results (e.g. one per shell mode).
+type doctorCheck func() []doctorResult
+
+// doctorChecks run in order; later features register more.
+var doctorChecks = []doctorCheck{
+	checkGit,
+	checkShellIntegration,
+}
+
+func checkGit() []doctorResult {
+	output, err := gitCommand("--version").Output()
+	if err != nil {
+		return []doctorResult{{Name: "git", Status: doctorFail, Message: "git not found in PATH", Hint: "install git"}}
+	}
+	return []doctorResult{{Name: "git", Status: doctorOK, Message: strings.TrimSpace(string(output))}}
+}
+
+// shellProbeTimeout bounds how long a spawned shell may take to start.
+const shellProbeTimeout = 10 * time.Second
+
+// probeShellFunction starts shell in mode ("login" or "interactive") and
+// reports whether the wt shell function is defined once its rc files ran.
+func probeShellFunction(shell, mode string) (bool, error) {
+	var args []string
+	var stdin io.Reader
+	switch mode {
+	case "login":
+		args = []string{"-l", "-i"}
+	default:
+		args = []string{"-i"}
+	}
+	switch shell {
+	case "bash":
+		args = append(args, "-c", `[ "$(type -t wt)" = function ] && echo WT_FUNCTION_LOADED`)
+	case "zsh":
+		args = append(args, "-c", `[[ "$(whence -w wt)" == *function ]] && echo WT_FUNCTION_LOADED`)
+	case "tcsh":
+		// The integration is an alias there. -l must be tcsh's only flag,
+		// so the login shell reads the probe from stdin.
+		probe := `alias wt | grep -q WT_CD_FILE && echo WT_FUNCTION_LOADED`
+		if mode == "login" {
+			args, stdin = []string{"-l"}, strings.NewReader(probe+"\n")
+		} else {
+			args = []string{"-c", probe}
+		}
+	default:
+		return false, fmt.Errorf("unsupported shell: %s", shell)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shellProbeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, shell, args...)
+	// No terminal: interactive shells complain about job control on stderr.
+	cmd.Stdin = stdin
+	cmd.Stderr = nil
+	// A non-zero exit just means the function is missing.
+	output, _ := cmd.Output()
+	if ctx.Err() != nil {
+		return false, fmt.Errorf("%s did not start within %s", shell, shellProbeTimeout)
+	}
+	if strings.Contains(string(output), "WT_FUNCTION_LOADED") {
+		return true, nil
+	}
+	if _, err := exec.LookPath(shell); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// checkShellIntegration spawns the user's shell as a login and as an
+// interactive shell and checks that both define the wt function, i.e. that
+// the block 'wt init' installed is in a file that actually gets sourced.
+func checkShellIntegration() []doctorResult {
+	if runtime.GOOS == "windows" {
+		return []doctorResult{{Name: "shell integration", Status: doctorSkip, Message: "not checked on Windows; run '. $PROFILE' and 'Get-Command wt'"}}
+	}
+	shell := filepath.Base(os.Getenv("SHELL"))
+	if shell != "bash" && shell != "zsh" && shell != "tcsh" {
+		return []doctorResult{{Name: "shell integration", Status: doctorSkip, Message: fmt.Sprintf("unsupported shell %q", os.Getenv("SHELL"))}}
+	}
+
+	var results []doctorResult
+	loaded := 0
+	for _, mode := range []string{"login", "interactive"} {
+		name := fmt.Sprintf("%s %s shell", shell, mode)
+		ok, err := probeShellFunction(shell, mode)
+		switch {
+		case err != nil:
+			results = append(results, doctorResult{Name: name, Status: doctorWarn, Message: err.Error()})
+		case ok:
+			loaded++
+			results = append(results, doctorResult{Name: name, Status: doctorOK, Message: "wt function loaded"})
+		default:
+			results = append(results, doctorResult{
+				Name:    name,
+				Status:  doctorWarn,
+				Message: fmt.Sprintf("wt function not loaded (%s is not sourced)", selectShellConfigPath(shell, mode)),
+				Hint:    fmt.Sprintf("wt init %s --%s", shell, mode),
+			})
+		}
+	}
+	if loaded == 0 {
+		for i := range results {
+			if results[i].Status == doctorWarn {
+				results[i].Status = doctorFail
+			}
+		}
+	}
+	return results
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that git and the shell integration work",
+	Long: `Check the wt setup and print a hint for every problem found.
+
+Checks:
+  git                 git is installed
+  shell integration   new login and interactive shells define the wt function
+  object store        the object store all worktrees share is not badly packed
+                      (see 'wt maintenance')`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		failed, warned := 0, 0
+		for _, check := range doctorChecks {
+			for _, result := range check() {
+				fmt.Printf("[%-4s] %s: %s\n", result.Status, result.Name, result.Message)
+				if result.Hint != "" && result.Status != doctorOK {
+					fmt.Printf("       fix: %s\n", result.Hint)
+				}
+				switch result.Status {
+				case doctorFail:
+					failed++
+				case doctorWarn:
+					warned++
+				}
+			}
+		}
+		if ciMode() {
+			fmt.Println(machineSummary("doctor", "failed", failed, "warnings", warned))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
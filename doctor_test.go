@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestProbeShellFunction(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if ok, err := probeShellFunction("bash", "interactive"); err != nil || ok {
+		t.Errorf("probeShellFunction() without rc file = %v, %v; want false, nil", ok, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(home, ".bashrc"), []byte("wt() { :; }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := probeShellFunction("bash", "interactive"); err != nil || !ok {
+		t.Errorf("probeShellFunction() interactive = %v, %v; want true, nil", ok, err)
+	}
+	if ok, _ := probeShellFunction("bash", "login"); ok {
+		t.Error("probeShellFunction() login should not read ~/.bashrc without a login file sourcing it")
+	}
+}
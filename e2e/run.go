@@ -5,10 +5,13 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -16,9 +19,15 @@ import (
 
 // Scenario file structure
 type ScenarioFile struct {
-	Name        string     `yaml:"name"`
-	Description string     `yaml:"description"`
-	Scenarios   []Scenario `yaml:"scenarios"`
+	Name        string             `yaml:"name"`
+	Description string             `yaml:"description"`
+	Fragments   map[string][]Setup `yaml:"fragments"`
+	Scenarios   []Scenario         `yaml:"scenarios"`
+}
+
+// Fragment file structure (shared setup blocks for all scenario files)
+type FragmentFile struct {
+	Fragments map[string][]Setup `yaml:"fragments"`
 }
 
 // Individual test scenario
@@ -30,16 +39,40 @@ type Scenario struct {
 	SkipShells   []string `yaml:"skip_shells"`
 	SkipOS       []string `yaml:"skip_os"`
 	SkipShellenv bool     `yaml:"skip_shellenv"`
+	FakeHome     bool     `yaml:"fake_home"`
 	Interactive  bool     `yaml:"interactive"`
 }
 
 // Setup step (branch creation, file creation, etc.)
 type Setup struct {
-	CreateBranch string    `yaml:"create_branch"`
-	CreateFile   *FileSpec `yaml:"create_file"`
-	GitAdd       string    `yaml:"git_add"`
-	GitCommit    string    `yaml:"git_commit"`
-	GitCheckout  string    `yaml:"git_checkout"`
+	Include      string        `yaml:"include"`
+	CreateBranch string        `yaml:"create_branch"`
+	CreateFile   *FileSpec     `yaml:"create_file"`
+	CreateRemote string        `yaml:"create_remote"`
+	GitAdd       string        `yaml:"git_add"`
+	GitCommit    string        `yaml:"git_commit"`
+	GitCheckout  string        `yaml:"git_checkout"`
+	Dirty        *DirtySpec    `yaml:"dirty"`
+	Conflict     *ConflictSpec `yaml:"merge_conflict"`
+}
+
+// Uncommitted changes for the dirty setup step. Worktree names the branch
+// whose worktree gets the changes (created with wt if needed); empty means
+// the main repository.
+type DirtySpec struct {
+	Worktree  string   `yaml:"worktree"`
+	Staged    []string `yaml:"staged"`
+	Unstaged  []string `yaml:"unstaged"`
+	Untracked []string `yaml:"untracked"`
+}
+
+// An unresolved merge of Branch into the worktree of Worktree (or the main
+// repository), both sides having changed Path. Branch must not be checked out
+// in any worktree.
+type ConflictSpec struct {
+	Worktree string `yaml:"worktree"`
+	Branch   string `yaml:"branch"`
+	Path     string `yaml:"path"`
 }
 
 // File specification for create_file setup
@@ -62,6 +95,23 @@ type Expect struct {
 	Branch            string `yaml:"branch"`
 	OutputContains    string `yaml:"output_contains"`
 	OutputNotContains string `yaml:"output_not_contains"`
+	// Golden output: the whole output, with the test directory written as
+	// $TEST_DIR, Unix times at the end of a line as <time> and commit ids
+	// as <commit>
+	OutputEquals string `yaml:"output_equals"`
+
+	// Git state after the step
+	WorktreeExists  string          `yaml:"worktree_exists"`
+	WorktreeMissing string          `yaml:"worktree_missing"`
+	BranchExists    string          `yaml:"branch_exists"`
+	BranchMissing   string          `yaml:"branch_missing"`
+	BranchUpstream  *UpstreamExpect `yaml:"branch_upstream"`
+}
+
+// Expected upstream of a local branch; an empty Upstream means none is set
+type UpstreamExpect struct {
+	Branch   string `yaml:"branch"`
+	Upstream string `yaml:"upstream"`
 }
 
 // Test result
@@ -77,12 +127,38 @@ func main() {
 	// Parse flags
 	shellsFlag := flag.String("shells", "", "Comma-separated list of shells to test (bash,zsh,powershell,pwsh)")
 	scenariosDir := flag.String("scenarios", "e2e/scenarios", "Directory containing scenario YAML files")
+	fragmentsDir := flag.String("fragments", "e2e/fragments", "Directory containing shared setup fragments")
 	wtBinary := flag.String("wt", "", "Path to wt binary (default: auto-detect)")
 	verbose := flag.Bool("verbose", false, "Verbose output")
 	showOutput := flag.Bool("show-output", false, "Print scenario output for each run")
 	keepTmp := flag.Bool("keep-tmp", false, "Keep temporary directories created during tests")
+	coverage := flag.String("coverage", "", "Build wt with -cover and write merged coverage data to this directory")
+	record := flag.String("record", "", "Save the script and output of each scenario run, and the test directory of failed ones, in this directory")
+	replay := flag.String("replay", "", "Run the recorded script of a scenario (<file>/<scenario>) again, from the --record directory")
+	lintScripts := flag.Bool("lint-scripts", false, "Check that each generated script parses in its shell before running it")
 	flag.Parse()
 
+	// A replay runs a recorded script; it needs neither scenarios nor wt
+	if *replay != "" {
+		if *record == "" {
+			fmt.Println("ERROR: --replay needs the --record directory of the run")
+			os.Exit(1)
+		}
+		var shells []string
+		if *shellsFlag != "" {
+			shells = strings.Split(*shellsFlag, ",")
+		}
+		failed, err := replayScenario(*record, *replay, shells)
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Determine shells to test
 	shells := determineShells(*shellsFlag)
 	if len(shells) == 0 {
@@ -90,6 +166,22 @@ func main() {
 		os.Exit(1)
 	}
 
+	// With --coverage, build an instrumented binary instead of using --wt
+	var coverDir string
+	if *coverage != "" {
+		if *wtBinary != "" {
+			fmt.Println("ERROR: --coverage builds its own wt binary; do not combine it with --wt")
+			os.Exit(1)
+		}
+		dir, built, err := buildCoverageBinary(*coverage)
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		coverDir = dir
+		*wtBinary = built
+	}
+
 	// Find wt binary (must be absolute path)
 	binary := findWtBinary(*wtBinary)
 	if binary == "" {
@@ -108,7 +200,7 @@ func main() {
 	fmt.Printf("Using wt binary: %s\n", binary)
 
 	// Load scenarios
-	scenarios, err := loadScenarios(*scenariosDir)
+	scenarios, err := loadScenarios(*scenariosDir, *fragmentsDir)
 	if err != nil {
 		fmt.Printf("ERROR: Failed to load scenarios: %v\n", err)
 		os.Exit(1)
@@ -133,7 +225,7 @@ func main() {
 				}
 
 				// Run scenario
-				result := runScenario(binary, shell, file.Name, scenario, *verbose, *showOutput, *keepTmp)
+				result := runScenario(binary, shell, file.Name, scenario, coverDir, *record, *verbose, *showOutput, *keepTmp, *lintScripts)
 
 				if result.Passed {
 					fmt.Printf("PASS: %s/%s\n", file.Name, scenario.Name)
@@ -167,6 +259,13 @@ func main() {
 	fmt.Printf("Failed:  %d\n", failed)
 	fmt.Printf("Skipped: %d\n", skipped)
 
+	if coverDir != "" {
+		if err := mergeCoverage(coverDir); err != nil {
+			fmt.Printf("ERROR: Failed to merge coverage data: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	if failed > 0 {
 		os.Exit(1)
 	}
@@ -249,7 +348,75 @@ func findWtBinary(specified string) string {
 	return ""
 }
 
-func loadScenarios(dir string) ([]ScenarioFile, error) {
+// buildCoverageBinary builds wt from the current directory with coverage
+// instrumentation into dir and prepares dir/raw for the counter files.
+// It returns the absolute coverage directory and the binary path.
+func buildCoverageBinary(dir string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	raw := filepath.Join(dir, "raw")
+	// Stale counters from an earlier run would skew the merged profile
+	if err := os.RemoveAll(raw); err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(raw, 0755); err != nil {
+		return "", "", err
+	}
+
+	binary := filepath.Join(dir, "wt")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	cmd := exec.Command("go", "build", "-cover", "-o", binary, ".")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("building instrumented wt binary: %w", err)
+	}
+	fmt.Printf("Collecting coverage in: %s\n", dir)
+	return dir, binary, nil
+}
+
+// mergeCoverage merges the counters of all wt invocations into dir/merged
+// and converts them to a single text profile, dir/coverage.out, usable with
+// 'go tool cover'.
+func mergeCoverage(dir string) error {
+	raw := filepath.Join(dir, "raw")
+	merged := filepath.Join(dir, "merged")
+	if err := os.RemoveAll(merged); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(merged, 0755); err != nil {
+		return err
+	}
+	profile := filepath.Join(dir, "coverage.out")
+
+	steps := [][]string{
+		{"tool", "covdata", "merge", "-i=" + raw, "-o=" + merged},
+		{"tool", "covdata", "textfmt", "-i=" + merged, "-o=" + profile},
+		{"tool", "covdata", "percent", "-i=" + merged},
+	}
+	fmt.Printf("\n=== Coverage ===\n")
+	for _, args := range steps {
+		cmd := exec.Command("go", args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("go %s: %w", strings.Join(args[:3], " "), err)
+		}
+	}
+	fmt.Printf("Profile: %s (view with: go tool cover -html=%s)\n", profile, profile)
+	return nil
+}
+
+func loadScenarios(dir, fragmentsDir string) ([]ScenarioFile, error) {
+	shared, err := loadFragments(fragmentsDir)
+	if err != nil {
+		return nil, err
+	}
+
 	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
 	if err != nil {
 		return nil, err
@@ -266,12 +433,80 @@ func loadScenarios(dir string) ([]ScenarioFile, error) {
 		if err := yaml.Unmarshal(data, &sf); err != nil {
 			return nil, fmt.Errorf("parsing %s: %w", f, err)
 		}
+		for i := range sf.Scenarios {
+			setup, err := expandSetup(sf.Scenarios[i].Setup, sf.Fragments, shared, nil)
+			if err != nil {
+				return nil, fmt.Errorf("%s: scenario %s: %w", f, sf.Scenarios[i].Name, err)
+			}
+			sf.Scenarios[i].Setup = setup
+		}
 		scenarios = append(scenarios, sf)
 	}
 
 	return scenarios, nil
 }
 
+// loadFragments reads the shared setup fragments from every YAML file in
+// dir. A missing directory means there are none.
+func loadFragments(dir string) (map[string][]Setup, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	fragments := make(map[string][]Setup)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", f, err)
+		}
+
+		var ff FragmentFile
+		if err := yaml.Unmarshal(data, &ff); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", f, err)
+		}
+		for name, setup := range ff.Fragments {
+			if _, dup := fragments[name]; dup {
+				return nil, fmt.Errorf("%s: fragment %s is defined more than once", f, name)
+			}
+			fragments[name] = setup
+		}
+	}
+	return fragments, nil
+}
+
+// expandSetup replaces every include step with the setup steps of the named
+// fragment, recursively. Fragments defined in the scenario file take
+// precedence over shared ones; stack holds the includes being expanded to
+// detect cycles.
+func expandSetup(setup []Setup, local, shared map[string][]Setup, stack []string) ([]Setup, error) {
+	var expanded []Setup
+	for _, step := range setup {
+		if step.Include == "" {
+			expanded = append(expanded, step)
+			continue
+		}
+		for _, name := range stack {
+			if name == step.Include {
+				return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), name)
+			}
+		}
+		fragment, ok := local[step.Include]
+		if !ok {
+			fragment, ok = shared[step.Include]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown fragment: %s", step.Include)
+		}
+		steps, err := expandSetup(fragment, local, shared, append(stack, step.Include))
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, steps...)
+	}
+	return expanded, nil
+}
+
 func shouldSkip(scenario Scenario, shell string) bool {
 	// Skip interactive tests for now
 	if scenario.Interactive {
@@ -295,19 +530,52 @@ func shouldSkip(scenario Scenario, shell string) bool {
 	return false
 }
 
-func runScenario(wtBinary, shell, fileName string, scenario Scenario, verbose, showOutput, keepTmp bool) Result {
+func runScenario(wtBinary, shell, fileName string, scenario Scenario, coverDir, recordDir string, verbose, showOutput, keepTmp, lintScripts bool) Result {
 	result := Result{
 		Scenario: fmt.Sprintf("%s/%s", fileName, scenario.Name),
 		Shell:    shell,
 	}
 
-	// Generate test script
-	script := generateScript(wtBinary, shell, scenario, verbose, showOutput, keepTmp)
+	// Generate test script; when recording, the script keeps its test
+	// directory and the runner removes it if the scenario passes
+	script := generateScript(wtBinary, shell, scenario, verbose, showOutput, keepTmp || recordDir != "")
 
 	if verbose {
 		fmt.Printf("--- Script for %s ---\n%s\n---\n", scenario.Name, script)
 	}
 
+	var record, testDir string
+	if recordDir != "" {
+		var err error
+		record, testDir, err = startRecord(recordDir, shell, result.Scenario, script)
+		if err != nil {
+			result.Error = fmt.Sprintf("could not record: %v", err)
+			return result
+		}
+	}
+
+	result = executeScenario(result, shell, script, coverDir, testDir, lintScripts)
+
+	if record != "" {
+		finishRecord(record, testDir, result, keepTmp)
+		if !result.Passed {
+			result.Error += fmt.Sprintf(" (recorded in %s)", record)
+		}
+	}
+	return result
+}
+
+func executeScenario(result Result, shell, script, coverDir, testDir string, lintScripts bool) Result {
+	// A script that does not parse fails here, not halfway through with
+	// errors that point at wt
+	if lintScripts {
+		if err := lintScript(shell, script); err != nil {
+			result.Error = err.Error()
+			result.Passed = false
+			return result
+		}
+	}
+
 	// Execute script
 	var cmd *exec.Cmd
 	if shell == "powershell" || shell == "pwsh" {
@@ -315,6 +583,15 @@ func runScenario(wtBinary, shell, fileName string, scenario Scenario, verbose, s
 	} else {
 		cmd = exec.Command(shell, "-c", script)
 	}
+	cmd.Env = os.Environ()
+	if coverDir != "" {
+		// Every wt process started by the script writes its counters here
+		cmd.Env = append(cmd.Env, "GOCOVERDIR="+filepath.Join(coverDir, "raw"))
+	}
+	if testDir != "" {
+		// The test directory of a recorded run, kept on failure
+		cmd.Env = append(cmd.Env, "WT_E2E_TEST_DIR="+testDir)
+	}
 
 	output, err := cmd.CombinedOutput()
 	result.Output = string(output)
@@ -334,6 +611,222 @@ func runScenario(wtBinary, shell, fileName string, scenario Scenario, verbose, s
 	return result
 }
 
+func scriptExt(shell string) string {
+	if shell == "powershell" || shell == "pwsh" {
+		return ".ps1"
+	}
+	return ".sh"
+}
+
+// startRecord prepares the record of a scenario run with shell under
+// recordDir/<shell>/<file>/<scenario>, replacing an earlier one, and saves
+// the generated script there. The test directory gets a neutral name under
+// recordDir/tmp, since scenarios check the output of wt, which shows its
+// path, and its path is saved in the record. It returns the record and the
+// test directory.
+func startRecord(recordDir, shell, scenario, script string) (string, string, error) {
+	recordDir, err := filepath.Abs(recordDir)
+	if err != nil {
+		return "", "", err
+	}
+	dir := filepath.Join(recordDir, shell, filepath.FromSlash(scenario))
+	if old, err := os.ReadFile(filepath.Join(dir, "test-dir")); err == nil {
+		os.RemoveAll(strings.TrimSpace(string(old)))
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(filepath.Join(recordDir, "tmp"), 0755); err != nil {
+		return "", "", err
+	}
+	testDir, err := os.MkdirTemp(filepath.Join(recordDir, "tmp"), "wt-e2e-")
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "test-dir"), []byte(testDir+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return dir, testDir, os.WriteFile(filepath.Join(dir, "script"+scriptExt(shell)), []byte(script), 0644)
+}
+
+// finishRecord saves the output of the run in the record and, unless it
+// failed or --keep-tmp is given, removes its test directory.
+func finishRecord(dir, testDir string, result Result, keepTmp bool) {
+	log := result.Output
+	if !result.Passed {
+		log += fmt.Sprintf("\n=== FAIL: %s\n", result.Error)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "output.log"), []byte(log), 0644); err != nil {
+		fmt.Printf("WARNING: could not record the output of %s: %v\n", result.Scenario, err)
+	}
+	if result.Passed && !keepTmp {
+		os.RemoveAll(testDir)
+		os.Remove(filepath.Join(dir, "test-dir"))
+	}
+}
+
+// replayTestDir empties the test directory of the record in dir, or makes
+// one when the run passed and its test directory was removed.
+func replayTestDir(recordDir, dir string) (string, error) {
+	if data, err := os.ReadFile(filepath.Join(dir, "test-dir")); err == nil {
+		testDir := strings.TrimSpace(string(data))
+		return testDir, os.RemoveAll(testDir)
+	}
+	tmp, err := filepath.Abs(filepath.Join(recordDir, "tmp"))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return "", err
+	}
+	testDir, err := os.MkdirTemp(tmp, "wt-e2e-")
+	if err != nil {
+		return "", err
+	}
+	return testDir, os.WriteFile(filepath.Join(dir, "test-dir"), []byte(testDir+"\n"), 0644)
+}
+
+// replayScenario runs the recorded script of scenario (<file>/<scenario>)
+// again for each recorded shell, or only the given ones, in the test
+// directory of the record, emptied first so the setup starts over.
+// The output is shown and recorded, and the test directory is kept. It
+// returns the number of failed runs.
+func replayScenario(recordDir, scenario string, shells []string) (int, error) {
+	if len(shells) == 0 {
+		entries, err := os.ReadDir(recordDir)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && entry.Name() != "tmp" {
+				shells = append(shells, entry.Name())
+			}
+		}
+	}
+
+	failed, replayed := 0, 0
+	for _, shell := range shells {
+		dir, err := filepath.Abs(filepath.Join(recordDir, shell, filepath.FromSlash(scenario)))
+		if err != nil {
+			return failed, err
+		}
+		script := filepath.Join(dir, "script"+scriptExt(shell))
+		if _, err := os.Stat(script); err != nil {
+			continue
+		}
+		replayed++
+		testDir, err := replayTestDir(recordDir, dir)
+		if err != nil {
+			return failed, err
+		}
+
+		fmt.Printf("\n=== Replaying %s with %s ===\n", scenario, shell)
+		var cmd *exec.Cmd
+		if shell == "powershell" || shell == "pwsh" {
+			cmd = exec.Command(shell, "-NoProfile", "-File", script)
+		} else {
+			cmd = exec.Command(shell, script)
+		}
+		cmd.Env = append(os.Environ(), "WT_E2E_TEST_DIR="+testDir)
+		var log strings.Builder
+		cmd.Stdout = io.MultiWriter(os.Stdout, &log)
+		cmd.Stderr = io.MultiWriter(os.Stderr, &log)
+		result := Result{Scenario: scenario, Shell: shell, Passed: true}
+		if err := cmd.Run(); err != nil {
+			result.Passed = false
+			result.Error = err.Error()
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				result.Error = fmt.Sprintf("exit code %d", exitErr.ExitCode())
+			}
+		}
+		result.Output = log.String()
+		finishRecord(dir, testDir, result, true)
+
+		if result.Passed {
+			fmt.Printf("PASS: %s\n", scenario)
+		} else {
+			fmt.Printf("FAIL: %s\n  Error: %s\n", scenario, result.Error)
+			failed++
+		}
+		fmt.Printf("  TEST_DIR=%s\n", testDir)
+	}
+	if replayed == 0 {
+		return 0, fmt.Errorf("no recorded script for %s in %s", scenario, recordDir)
+	}
+	return failed, nil
+}
+
+// scriptErrorLine finds the line number in the parse errors of bash
+// ("line 12: syntax error ..."), zsh ("script.sh:12: parse error ...") and
+// PowerShell (as printed by lintScript: "line 12: ...").
+var scriptErrorLine = regexp.MustCompile(`(?:line |:)(\d+):`)
+
+// lintScript parses script with shell without running it: bash -n or
+// zsh -n for POSIX shells, [scriptblock]::Create for PowerShell. A parse
+// error is returned with the offending generated line and its neighbours.
+func lintScript(shell, script string) error {
+	ext := scriptExt(shell)
+	f, err := os.CreateTemp("", "wt-e2e-*"+ext)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(script)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	if ext == ".ps1" {
+		check := fmt.Sprintf(`try { [void][scriptblock]::Create((Get-Content -Raw -LiteralPath '%s')) } catch {
+	$e = $_.Exception; if ($e.InnerException) { $e = $e.InnerException }
+	if ($e.Errors) { foreach ($p in $e.Errors) { "line $($p.Extent.StartLineNumber): $($p.Message)" } } else { $e.Message }
+	exit 1
+}`, strings.ReplaceAll(f.Name(), "'", "''"))
+		cmd = exec.Command(shell, "-NoProfile", "-Command", check)
+	} else {
+		cmd = exec.Command(shell, "-n", f.Name())
+	}
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*exec.ExitError); !ok {
+		return fmt.Errorf("could not lint the generated script: %v", err)
+	}
+	message := strings.TrimSpace(strings.ReplaceAll(string(output), f.Name(), "script"))
+	if message == "" {
+		message = err.Error()
+	}
+	m := scriptErrorLine.FindStringSubmatch(message)
+	if m == nil {
+		return fmt.Errorf("generated %s script does not parse: %s", shell, message)
+	}
+	line, _ := strconv.Atoi(m[1])
+	return fmt.Errorf("generated %s script does not parse: %s\n%s", shell, message, scriptContext(script, line, 2))
+}
+
+// scriptContext shows line of script with around lines before and after
+// it, numbered, with the line itself marked.
+func scriptContext(script string, line, around int) string {
+	lines := strings.Split(script, "\n")
+	var sb strings.Builder
+	for n := max(line-around, 1); n <= min(line+around, len(lines)); n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		sb.WriteString(fmt.Sprintf("  %s %4d | %s\n", marker, n, lines[n-1]))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
 func generateScript(wtBinary, shell string, scenario Scenario, verbose, showOutput, keepTmp bool) string {
 	if shell == "powershell" || shell == "pwsh" {
 		return generatePowerShellScript(wtBinary, scenario, verbose, showOutput, keepTmp)
@@ -341,16 +834,34 @@ func generateScript(wtBinary, shell string, scenario Scenario, verbose, showOutp
 	return generatePosixScript(wtBinary, shell, scenario, verbose, showOutput, keepTmp)
 }
 
+// posixGoldenCheck compares the output of a step with its golden output,
+// after normalizing what differs from run to run, and shows a diff if not.
+func posixGoldenCheck(sb *strings.Builder, want string) {
+	sb.WriteString("cat > \"$TEST_DIR/golden.want\" <<'__WT_GOLDEN__'\n")
+	sb.WriteString(strings.TrimRight(want, "\n") + "\n")
+	sb.WriteString("__WT_GOLDEN__\n")
+	// The test directory may be reported through a symlink-free path
+	sb.WriteString("__real_dir=$(cd \"$TEST_DIR\" && pwd -P)\n")
+	// Trailing empty lines do not count, as in the golden output
+	sb.WriteString("__got=$(printf '%s\\n' \"$__output\" | tr -d '\\r' | sed -e \"s|$__real_dir|\\$TEST_DIR|g\" -e \"s|$TEST_DIR|\\$TEST_DIR|g\" " +
+		"-e 's/ [0-9]\\{10\\}$/ <time>/' -e 's/[0-9a-f]\\{40\\}/<commit>/g')\n")
+	sb.WriteString("printf '%s\\n' \"$__got\" > \"$TEST_DIR/golden.got\"\n")
+	sb.WriteString("diff -u \"$TEST_DIR/golden.want\" \"$TEST_DIR/golden.got\" || { echo 'Output differs from the golden output'; exit 1; }\n")
+}
+
 func generatePosixScript(wtBinary, shell string, scenario Scenario, verbose, showOutput, keepTmp bool) string {
 	var sb strings.Builder
 
 	// Header
 	sb.WriteString("set -e\n")
 	sb.WriteString(fmt.Sprintf("export WT_BIN='%s'\n", wtBinary))
-	sb.WriteString("TEST_DIR=$(mktemp -d)\n")
+	// The runner picks the test directory when it records the run
+	sb.WriteString("TEST_DIR=\"${WT_E2E_TEST_DIR:-$(mktemp -d)}\"\n")
 	sb.WriteString("REPO_DIR=\"$TEST_DIR/test-repo\"\n")
 	sb.WriteString("REPO_NAME=\"test-repo\"\n")
 	sb.WriteString("export WORKTREE_ROOT=\"$TEST_DIR/worktrees\"\n")
+	// Keep the user's global config (and global hooks) out of the tests
+	sb.WriteString("export WT_CONFIG=\"$TEST_DIR/config.yaml\"\n")
 	sb.WriteString("mkdir -p \"$REPO_DIR\"\n")
 	sb.WriteString("cd \"$REPO_DIR\"\n")
 	sb.WriteString("git init --quiet\n")
@@ -361,6 +872,9 @@ func generatePosixScript(wtBinary, shell string, scenario Scenario, verbose, sho
 	sb.WriteString("git commit -m 'initial' --quiet\n")
 	sb.WriteString("git branch -M main\n")
 	sb.WriteString(fmt.Sprintf("export PATH=\"%s:$PATH\"\n", filepath.Dir(wtBinary)))
+	if scenario.FakeHome {
+		posixFakeHome(&sb, shell)
+	}
 
 	// Setup steps
 	for _, setup := range scenario.Setup {
@@ -372,6 +886,14 @@ func generatePosixScript(wtBinary, shell string, scenario Scenario, verbose, sho
 		if setup.CreateFile != nil {
 			sb.WriteString(fmt.Sprintf("echo '%s' > '%s'\n", setup.CreateFile.Content, setup.CreateFile.Path))
 		}
+		if setup.CreateRemote != "" {
+			// Bare repository named like the test repo, so the repo name stays the same
+			sb.WriteString(fmt.Sprintf("__remote=\"$TEST_DIR/remotes/%s/$REPO_NAME.git\"\n", setup.CreateRemote))
+			sb.WriteString("git init --bare --quiet \"$__remote\"\n")
+			sb.WriteString(fmt.Sprintf("git remote add '%s' \"$__remote\"\n", setup.CreateRemote))
+			sb.WriteString(fmt.Sprintf("git push --quiet -u '%s' --all\n", setup.CreateRemote))
+			sb.WriteString(fmt.Sprintf("git remote set-head '%s' main\n", setup.CreateRemote))
+		}
 		if setup.GitAdd != "" {
 			sb.WriteString(fmt.Sprintf("git add '%s'\n", setup.GitAdd))
 		}
@@ -381,6 +903,36 @@ func generatePosixScript(wtBinary, shell string, scenario Scenario, verbose, sho
 		if setup.GitCheckout != "" {
 			sb.WriteString(fmt.Sprintf("git checkout '%s' --quiet\n", setup.GitCheckout))
 		}
+		if setup.Dirty != nil {
+			posixEnterWorktree(&sb, setup.Dirty.Worktree)
+			for _, path := range setup.Dirty.Staged {
+				sb.WriteString(fmt.Sprintf("echo 'staged change' >> '%s'\n", path))
+				sb.WriteString(fmt.Sprintf("git add '%s'\n", path))
+			}
+			for _, path := range setup.Dirty.Unstaged {
+				// Only tracked files can have unstaged modifications
+				sb.WriteString(fmt.Sprintf("[ -n \"$(git ls-files -- '%s')\" ] || { echo 'tracked' > '%s'; git add '%s'; git commit -m 'track %s' --quiet; }\n", path, path, path, path))
+				sb.WriteString(fmt.Sprintf("echo 'unstaged change' >> '%s'\n", path))
+			}
+			for _, path := range setup.Dirty.Untracked {
+				sb.WriteString(fmt.Sprintf("echo 'untracked' > '%s'\n", path))
+			}
+			sb.WriteString("cd \"$REPO_DIR\"\n")
+		}
+		if c := setup.Conflict; c != nil {
+			// Commit their side on the branch in a throwaway worktree
+			sb.WriteString(fmt.Sprintf("git worktree add --quiet \"$TEST_DIR/conflict-tmp\" '%s'\n", c.Branch))
+			sb.WriteString(fmt.Sprintf("echo 'theirs' > \"$TEST_DIR/conflict-tmp/%s\"\n", c.Path))
+			sb.WriteString(fmt.Sprintf("git -C \"$TEST_DIR/conflict-tmp\" add '%s'\n", c.Path))
+			sb.WriteString(fmt.Sprintf("git -C \"$TEST_DIR/conflict-tmp\" commit -m 'theirs: %s' --quiet\n", c.Path))
+			sb.WriteString("git worktree remove --force \"$TEST_DIR/conflict-tmp\"\n")
+			posixEnterWorktree(&sb, c.Worktree)
+			sb.WriteString(fmt.Sprintf("echo 'ours' > '%s'\n", c.Path))
+			sb.WriteString(fmt.Sprintf("git add '%s'\n", c.Path))
+			sb.WriteString(fmt.Sprintf("git commit -m 'ours: %s' --quiet\n", c.Path))
+			sb.WriteString(fmt.Sprintf("if git merge --no-edit '%s' >/dev/null 2>&1; then echo 'merge of %s did not conflict'; exit 1; fi\n", c.Branch, c.Branch))
+			sb.WriteString("cd \"$REPO_DIR\"\n")
+		}
 	}
 
 	// Source shellenv unless skipped
@@ -397,7 +949,7 @@ func generatePosixScript(wtBinary, shell string, scenario Scenario, verbose, sho
 		}
 		if step.Run != "" {
 			runCmd := step.Run
-			needsOutput := step.Expect != nil && (step.Expect.OutputContains != "" || step.Expect.OutputNotContains != "")
+			needsOutput := step.Expect != nil && (step.Expect.OutputContains != "" || step.Expect.OutputNotContains != "" || step.Expect.OutputEquals != "")
 			expectsNonZero := step.Expect != nil && step.Expect.ExitCode != nil && *step.Expect.ExitCode != 0
 
 			if expectsNonZero {
@@ -411,7 +963,9 @@ func generatePosixScript(wtBinary, shell string, scenario Scenario, verbose, sho
 				sb.WriteString("__exit_code=$?\n")
 				sb.WriteString("set -e\n")
 			} else {
-				// Normal execution with set -e active
+				// Normal execution with set -e active; reset the exit code
+				// an earlier step may have left behind
+				sb.WriteString("__exit_code=0\n")
 				if needsOutput {
 					sb.WriteString(fmt.Sprintf("__output=$(%s 2>&1) || __exit_code=$?\n", runCmd))
 					sb.WriteString("__exit_code=${__exit_code:-0}\n")
@@ -442,6 +996,10 @@ func generatePosixScript(wtBinary, shell string, scenario Scenario, verbose, sho
 					sb.WriteString(fmt.Sprintf("echo \"$__output\" | grep -q '%s' && { echo \"Output should not contain '%s'\"; exit 1; } || true\n",
 						step.Expect.OutputNotContains, step.Expect.OutputNotContains))
 				}
+				if step.Expect.OutputEquals != "" {
+					posixGoldenCheck(&sb, step.Expect.OutputEquals)
+				}
+				posixGitStateChecks(&sb, step.Expect)
 			}
 		}
 	}
@@ -468,15 +1026,72 @@ func generatePosixScript(wtBinary, shell string, scenario Scenario, verbose, sho
 	return sb.String()
 }
 
+// posixFakeHome points HOME at an empty directory so 'wt init' writes rc
+// files there, and defines login_shell and interactive_shell, which run a
+// command in a fresh shell that only knows the fake HOME and the test's
+// environment. Shell functions of the test script are not inherited.
+func posixFakeHome(sb *strings.Builder, shell string) {
+	sb.WriteString("export HOME=\"$TEST_DIR/home\"\n")
+	sb.WriteString("mkdir -p \"$HOME\"\n")
+	sb.WriteString("unset ZDOTDIR\n")
+	sb.WriteString(fmt.Sprintf("export SHELL=\"$(command -v %s)\"\n", shell))
+	// Login shells run /etc/profile, which may reset PATH; put wt back on it
+	// the way a user's own startup files would
+	sb.WriteString(`echo "export PATH=\"$(dirname "$WT_BIN"):\$PATH\"" | tee "$HOME/.profile" > "$HOME/.zshenv"` + "\n")
+	env := `env -i HOME="$HOME" PATH="$PATH" SHELL="$SHELL" TERM=dumb WORKTREE_ROOT="$WORKTREE_ROOT" WT_CONFIG="$WT_CONFIG" REPO_DIR="$REPO_DIR" ${GOCOVERDIR:+"GOCOVERDIR=$GOCOVERDIR"}`
+	sb.WriteString(fmt.Sprintf("login_shell() { %s \"$SHELL\" -l -i -c \"$1\"; }\n", env))
+	sb.WriteString(fmt.Sprintf("interactive_shell() { %s \"$SHELL\" -i -c \"$1\"; }\n", env))
+}
+
+// posixGitStateChecks asserts the git state expectations of a step. Git
+// is queried directly, so the checks hold wherever the step left the shell.
+func posixGitStateChecks(sb *strings.Builder, e *Expect) {
+	if e.WorktreeExists != "" {
+		sb.WriteString(fmt.Sprintf("git worktree list --porcelain | grep -qx 'branch refs/heads/%s' || { echo \"Expected a worktree for branch %s\"; exit 1; }\n",
+			e.WorktreeExists, e.WorktreeExists))
+	}
+	if e.WorktreeMissing != "" {
+		sb.WriteString(fmt.Sprintf("! git worktree list --porcelain | grep -qx 'branch refs/heads/%s' || { echo \"Expected no worktree for branch %s\"; exit 1; }\n",
+			e.WorktreeMissing, e.WorktreeMissing))
+	}
+	if e.BranchExists != "" {
+		sb.WriteString(fmt.Sprintf("git show-ref --verify --quiet 'refs/heads/%s' || { echo \"Expected branch %s to exist\"; exit 1; }\n",
+			e.BranchExists, e.BranchExists))
+	}
+	if e.BranchMissing != "" {
+		sb.WriteString(fmt.Sprintf("! git show-ref --verify --quiet 'refs/heads/%s' || { echo \"Expected branch %s to be deleted\"; exit 1; }\n",
+			e.BranchMissing, e.BranchMissing))
+	}
+	if u := e.BranchUpstream; u != nil {
+		sb.WriteString(fmt.Sprintf("__upstream=$(git for-each-ref --format='%%(upstream:short)' 'refs/heads/%s')\n", u.Branch))
+		sb.WriteString(fmt.Sprintf("[ \"$__upstream\" = '%s' ] || { echo \"Expected upstream of %s to be '%s', got '$__upstream'\"; exit 1; }\n",
+			u.Upstream, u.Branch, u.Upstream))
+	}
+}
+
+// posixEnterWorktree changes into the worktree of branch, creating it with
+// wt (without the shell function, so nothing else changes directory), or
+// into the main repository if branch is empty.
+func posixEnterWorktree(sb *strings.Builder, branch string) {
+	if branch == "" {
+		sb.WriteString("cd \"$REPO_DIR\"\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("cd \"$(\"$WT_BIN\" checkout --quiet '%s')\"\n", branch))
+}
+
 func generatePowerShellScript(wtBinary string, scenario Scenario, verbose, showOutput, keepTmp bool) string {
 	var sb strings.Builder
 
 	// Header
 	sb.WriteString("$ErrorActionPreference = 'Stop'\n")
 	sb.WriteString(fmt.Sprintf("$env:WT_BIN = '%s'\n", wtBinary))
-	sb.WriteString("$TestDir = Join-Path $env:TEMP \"wt-e2e-$(Get-Random)\"\n")
+	// The runner picks the test directory when it records the run
+	sb.WriteString("$TestDir = if ($env:WT_E2E_TEST_DIR) { $env:WT_E2E_TEST_DIR } else { Join-Path $env:TEMP \"wt-e2e-$(Get-Random)\" }\n")
 	sb.WriteString("$RepoDir = Join-Path $TestDir 'test-repo'\n")
 	sb.WriteString("$env:WORKTREE_ROOT = Join-Path $TestDir 'worktrees'\n")
+	// Keep the user's global config (and global hooks) out of the tests
+	sb.WriteString("$env:WT_CONFIG = Join-Path $TestDir 'config.yaml'\n")
 	sb.WriteString("New-Item -ItemType Directory -Path $RepoDir -Force | Out-Null\n")
 	sb.WriteString("Push-Location $RepoDir\n")
 	sb.WriteString("git init --quiet\n")
@@ -487,6 +1102,9 @@ func generatePowerShellScript(wtBinary string, scenario Scenario, verbose, showO
 	sb.WriteString("git commit -m 'initial' --quiet\n")
 	sb.WriteString("git branch -M main\n")
 	sb.WriteString(fmt.Sprintf("$env:PATH = '%s;' + $env:PATH\n", filepath.Dir(wtBinary)))
+	if scenario.FakeHome {
+		powerShellFakeHome(&sb)
+	}
 
 	// Setup steps
 	for _, setup := range scenario.Setup {
@@ -498,6 +1116,14 @@ func generatePowerShellScript(wtBinary string, scenario Scenario, verbose, showO
 		if setup.CreateFile != nil {
 			sb.WriteString(fmt.Sprintf("Set-Content -Path '%s' -Value '%s'\n", setup.CreateFile.Path, setup.CreateFile.Content))
 		}
+		if setup.CreateRemote != "" {
+			// Bare repository named like the test repo, so the repo name stays the same
+			sb.WriteString(fmt.Sprintf("$__remote = Join-Path $TestDir 'remotes/%s/test-repo.git'\n", setup.CreateRemote))
+			sb.WriteString("git init --bare --quiet $__remote\n")
+			sb.WriteString(fmt.Sprintf("git remote add '%s' $__remote\n", setup.CreateRemote))
+			sb.WriteString(fmt.Sprintf("git push --quiet -u '%s' --all\n", setup.CreateRemote))
+			sb.WriteString(fmt.Sprintf("git remote set-head '%s' main\n", setup.CreateRemote))
+		}
 		if setup.GitAdd != "" {
 			sb.WriteString(fmt.Sprintf("git add '%s'\n", setup.GitAdd))
 		}
@@ -507,11 +1133,43 @@ func generatePowerShellScript(wtBinary string, scenario Scenario, verbose, showO
 		if setup.GitCheckout != "" {
 			sb.WriteString(fmt.Sprintf("git checkout '%s' --quiet\n", setup.GitCheckout))
 		}
+		if setup.Dirty != nil {
+			powerShellEnterWorktree(&sb, setup.Dirty.Worktree)
+			for _, path := range setup.Dirty.Staged {
+				sb.WriteString(fmt.Sprintf("Add-Content -Path '%s' -Value 'staged change'\n", path))
+				sb.WriteString(fmt.Sprintf("git add '%s'\n", path))
+			}
+			for _, path := range setup.Dirty.Unstaged {
+				// Only tracked files can have unstaged modifications
+				sb.WriteString(fmt.Sprintf("if (-not (git ls-files -- '%s')) { Set-Content -Path '%s' -Value 'tracked'; git add '%s'; git commit -m 'track %s' --quiet }\n", path, path, path, path))
+				sb.WriteString(fmt.Sprintf("Add-Content -Path '%s' -Value 'unstaged change'\n", path))
+			}
+			for _, path := range setup.Dirty.Untracked {
+				sb.WriteString(fmt.Sprintf("Set-Content -Path '%s' -Value 'untracked'\n", path))
+			}
+			sb.WriteString("Set-Location $RepoDir\n")
+		}
+		if c := setup.Conflict; c != nil {
+			// Commit their side on the branch in a throwaway worktree
+			sb.WriteString("$__conflictTmp = Join-Path $TestDir 'conflict-tmp'\n")
+			sb.WriteString(fmt.Sprintf("git worktree add --quiet $__conflictTmp '%s'\n", c.Branch))
+			sb.WriteString(fmt.Sprintf("Set-Content -Path (Join-Path $__conflictTmp '%s') -Value 'theirs'\n", c.Path))
+			sb.WriteString(fmt.Sprintf("git -C $__conflictTmp add '%s'\n", c.Path))
+			sb.WriteString(fmt.Sprintf("git -C $__conflictTmp commit -m 'theirs: %s' --quiet\n", c.Path))
+			sb.WriteString("git worktree remove --force $__conflictTmp\n")
+			powerShellEnterWorktree(&sb, c.Worktree)
+			sb.WriteString(fmt.Sprintf("Set-Content -Path '%s' -Value 'ours'\n", c.Path))
+			sb.WriteString(fmt.Sprintf("git add '%s'\n", c.Path))
+			sb.WriteString(fmt.Sprintf("git commit -m 'ours: %s' --quiet\n", c.Path))
+			sb.WriteString(fmt.Sprintf("git merge --no-edit '%s' | Out-Null\n", c.Branch))
+			sb.WriteString(fmt.Sprintf("if ($LASTEXITCODE -eq 0) { throw 'merge of %s did not conflict' }\n", c.Branch))
+			sb.WriteString("Set-Location $RepoDir\n")
+		}
 	}
 
 	// Source shellenv unless skipped
 	if !scenario.SkipShellenv {
-		sb.WriteString("$shellenv = & $env:WT_BIN shellenv\n")
+		sb.WriteString("$shellenv = & $env:WT_BIN shellenv powershell\n")
 		sb.WriteString("Invoke-Expression ($shellenv -join \"`n\")\n")
 	}
 
@@ -534,7 +1192,7 @@ func generatePowerShellScript(wtBinary string, scenario Scenario, verbose, showO
 				runCmd = "& " + runCmd
 			}
 
-			needsOutput := step.Expect != nil && (step.Expect.OutputContains != "" || step.Expect.OutputNotContains != "")
+			needsOutput := step.Expect != nil && (step.Expect.OutputContains != "" || step.Expect.OutputNotContains != "" || step.Expect.OutputEquals != "")
 			expectsNonZero := step.Expect != nil && step.Expect.ExitCode != nil && *step.Expect.ExitCode != 0
 
 			if expectsNonZero {
@@ -585,6 +1243,14 @@ func generatePowerShellScript(wtBinary string, scenario Scenario, verbose, showO
 					sb.WriteString(fmt.Sprintf("if ($__output.Contains('%s')) { throw \"Output should not contain '%s'\" }\n",
 						step.Expect.OutputNotContains, step.Expect.OutputNotContains))
 				}
+				if step.Expect.OutputEquals != "" {
+					// Same normalization as the POSIX check; paths use forward slashes
+					sb.WriteString("$__want = @'\n" + strings.TrimRight(step.Expect.OutputEquals, "\n") + "\n'@\n")
+					sb.WriteString("$__got = ($__output -replace \"`r\", '').TrimEnd().Replace($TestDir, '$TEST_DIR').Replace('\\', '/')\n")
+					sb.WriteString("$__got = $__got -replace '(?m) [0-9]{10}$', ' <time>' -replace '[0-9a-f]{40}', '<commit>'\n")
+					sb.WriteString("if ($__got -ne ($__want -replace \"`r\", '').TrimEnd()) { throw \"Output differs from the golden output:`n--- want`n$__want`n--- got`n$__got\" }\n")
+				}
+				powerShellGitStateChecks(&sb, step.Expect)
 			}
 		}
 	}
@@ -605,3 +1271,50 @@ func generatePowerShellScript(wtBinary string, scenario Scenario, verbose, showO
 
 	return sb.String()
 }
+
+// powerShellFakeHome is the PowerShell version of posixFakeHome. $PROFILE
+// points into the fake HOME, and Invoke-FreshShell runs a command in a new
+// PowerShell process that loads that profile, as a new session would.
+func powerShellFakeHome(sb *strings.Builder) {
+	sb.WriteString("$FakeHome = Join-Path $TestDir 'home'\n")
+	sb.WriteString("New-Item -ItemType Directory -Path $FakeHome -Force | Out-Null\n")
+	sb.WriteString("$env:HOME = $FakeHome\n")
+	sb.WriteString("$env:USERPROFILE = $FakeHome\n")
+	sb.WriteString("$env:PROFILE = Join-Path $FakeHome 'Documents/PowerShell/Microsoft.PowerShell_profile.ps1'\n")
+	sb.WriteString("$PROFILE = $env:PROFILE\n")
+	sb.WriteString("function Invoke-FreshShell([string]$Command) { & (Get-Process -Id $PID).Path -NoProfile -Command \". `$env:PROFILE; $Command\" }\n")
+}
+
+// powerShellGitStateChecks is the PowerShell version of posixGitStateChecks.
+func powerShellGitStateChecks(sb *strings.Builder, e *Expect) {
+	if e.WorktreeExists != "" {
+		sb.WriteString(fmt.Sprintf("if (-not ((git worktree list --porcelain) -contains 'branch refs/heads/%s')) { throw \"Expected a worktree for branch %s\" }\n",
+			e.WorktreeExists, e.WorktreeExists))
+	}
+	if e.WorktreeMissing != "" {
+		sb.WriteString(fmt.Sprintf("if ((git worktree list --porcelain) -contains 'branch refs/heads/%s') { throw \"Expected no worktree for branch %s\" }\n",
+			e.WorktreeMissing, e.WorktreeMissing))
+	}
+	if e.BranchExists != "" {
+		sb.WriteString(fmt.Sprintf("if (-not ((git for-each-ref --format='%%(refname)' 'refs/heads/%s') -contains 'refs/heads/%s')) { throw \"Expected branch %s to exist\" }\n",
+			e.BranchExists, e.BranchExists, e.BranchExists))
+	}
+	if e.BranchMissing != "" {
+		sb.WriteString(fmt.Sprintf("if ((git for-each-ref --format='%%(refname)' 'refs/heads/%s') -contains 'refs/heads/%s') { throw \"Expected branch %s to be deleted\" }\n",
+			e.BranchMissing, e.BranchMissing, e.BranchMissing))
+	}
+	if u := e.BranchUpstream; u != nil {
+		sb.WriteString(fmt.Sprintf("$__upstream = \"$(git for-each-ref --format='%%(upstream:short)' 'refs/heads/%s')\"\n", u.Branch))
+		sb.WriteString(fmt.Sprintf("if ($__upstream -ne '%s') { throw \"Expected upstream of %s to be '%s', got '$__upstream'\" }\n",
+			u.Upstream, u.Branch, u.Upstream))
+	}
+}
+
+// powerShellEnterWorktree is the PowerShell version of posixEnterWorktree.
+func powerShellEnterWorktree(sb *strings.Builder, branch string) {
+	if branch == "" {
+		sb.WriteString("Set-Location $RepoDir\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("Set-Location (& $env:WT_BIN checkout --quiet '%s')\n", branch))
+}
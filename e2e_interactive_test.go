@@ -68,6 +68,9 @@ func newPtyZsh(t *testing.T, rcContent string) (*ptyShell, error) {
 		fmt.Sprintf("ZDOTDIR=%s", tmpDir),
 		"HOME="+tmpDir,
 		"TERM=xterm-256color",
+		// These tests play a human at a terminal; keep wt out of its CI profile,
+		// which disables interactive prompts.
+		"CI=",
 	)
 
 	// Start the command
@@ -111,6 +114,9 @@ func newPtyBash(t *testing.T, rcContent string) (*ptyShell, error) {
 	cmd.Env = append(os.Environ(),
 		"HOME="+tmpDir,
 		"TERM=xterm-256color",
+		// These tests play a human at a terminal; keep wt out of its CI profile,
+		// which disables interactive prompts.
+		"CI=",
 	)
 
 	// Start the command
@@ -165,6 +171,9 @@ func newPtyPowerShell(t *testing.T, profileContent string) (*ptyShell, error) {
 	cmd.Env = append(os.Environ(),
 		"HOME="+tmpDir,
 		"USERPROFILE="+tmpDir,
+		// These tests play a human at a terminal; keep wt out of its CI profile,
+		// which disables interactive prompts.
+		"CI=",
 	)
 
 	// Start the command
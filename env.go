@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// worktreeEnv describes the worktree containing the current directory. The
+// branch is empty on a detached HEAD.
+func worktreeEnv() ([][2]string, error) {
+	output, err := gitCommand("rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("not in a worktree")
+	}
+	worktree := strings.TrimSpace(string(output))
+
+	output, _ = gitCommand("branch", "--show-current").Output()
+	branch := strings.TrimSpace(string(output))
+
+	info, err := getRepoInfo()
+	if err != nil {
+		return nil, err
+	}
+	return [][2]string{
+		{"WT_BRANCH", branch},
+		{"WT_REPO", info.Name},
+		{"WT_WORKTREE", worktree},
+	}, nil
+}
+
+var worktreeEnvCsh bool
+
+// worktreeEnvCmd is called by the shell wrapper after it changed into a
+// worktree. It prints NAME=value lines; the wrapper exports them as is. With
+// --csh it prints setenv commands for the tcsh integration instead.
+var worktreeEnvCmd = &cobra.Command{
+	Use:    "__worktree-env",
+	Short:  "Print the worktree variables the shell wrapper exports",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vars, err := worktreeEnv()
+		if err != nil {
+			return err
+		}
+		if worktreeEnvCsh {
+			fmt.Println(cshEnv(vars))
+			return nil
+		}
+		for _, v := range vars {
+			fmt.Printf("%s=%s\n", v[0], v[1])
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(worktreeEnvCmd)
+	worktreeEnvCmd.Flags().BoolVar(&worktreeEnvCsh, "csh", false, "Print csh setenv commands")
+}
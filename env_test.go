@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorktreeEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	worktree := filepath.Join(tmpDir, "feature")
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "feature", worktree)
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(worktree); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := worktreeEnv()
+	if err != nil {
+		t.Fatalf("worktreeEnv() error: %v", err)
+	}
+	got := make(map[string]string)
+	for _, v := range vars {
+		got[v[0]] = v[1]
+	}
+	if got["WT_BRANCH"] != "feature" {
+		t.Errorf("WT_BRANCH = %q, want feature", got["WT_BRANCH"])
+	}
+	if got["WT_REPO"] != "repo" {
+		t.Errorf("WT_REPO = %q, want repo", got["WT_REPO"])
+	}
+	want, _ := filepath.EvalSymlinks(worktree)
+	if resolved, _ := filepath.EvalSymlinks(got["WT_WORKTREE"]); resolved != want {
+		t.Errorf("WT_WORKTREE = %q, want %q", got["WT_WORKTREE"], want)
+	}
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktreeEnv(); err == nil {
+		t.Error("expected an error outside a worktree")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultEphemeralTTL is how long an ephemeral worktree lives without --ttl.
+const defaultEphemeralTTL = 24 * time.Hour
+
+var (
+	checkoutEphemeral bool
+	checkoutTTL       string
+)
+
+// ephemeralTTL is how long the worktree of this checkout lives, or 0 for a
+// worktree that stays until it is removed. --ttl implies --ephemeral.
+func ephemeralTTL() (time.Duration, error) {
+	if checkoutTTL != "" {
+		return parsePeriod(checkoutTTL, "--ttl")
+	}
+	if checkoutEphemeral {
+		return defaultEphemeralTTL, nil
+	}
+	return 0, nil
+}
+
+// setEphemeral records when the new worktree of branch expires, or forgets
+// an expiry left over from an earlier worktree of the branch when ttl is 0.
+func setEphemeral(branch string, ttl time.Duration) {
+	store := loadMeta()
+	meta := store.Branches[branch]
+	if ttl == 0 && meta.Expires == nil {
+		return
+	}
+	meta.Expires = nil
+	if ttl > 0 {
+		expires := time.Now().Add(ttl).Truncate(time.Second)
+		meta.Expires = &expires
+	}
+	store.set(branch, meta)
+	if err := store.save(); err != nil {
+		warnf("warning: failed to save worktree metadata: %v\n", err)
+		return
+	}
+	if meta.Expires != nil {
+		infof("Ephemeral worktree: 'wt cleanup' removes it after %s\n", meta.Expires.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+// expiredEphemeral returns the branches whose ephemeral worktree expired
+// before now.
+func expiredEphemeral(now time.Time) []string {
+	var branches []string
+	for branch, meta := range loadMeta().Branches {
+		if meta.Expires != nil && meta.Expires.Before(now) {
+			branches = append(branches, branch)
+		}
+	}
+	sort.Strings(branches)
+	return branches
+}
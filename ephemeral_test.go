@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEphemeralTTL(t *testing.T) {
+	defer func() { checkoutEphemeral, checkoutTTL = false, "" }()
+
+	if ttl, err := ephemeralTTL(); err != nil || ttl != 0 {
+		t.Errorf("ephemeralTTL() without flags = %v, %v, want 0", ttl, err)
+	}
+	checkoutEphemeral = true
+	if ttl, _ := ephemeralTTL(); ttl != defaultEphemeralTTL {
+		t.Errorf("ephemeralTTL() with --ephemeral = %v, want %v", ttl, defaultEphemeralTTL)
+	}
+	checkoutEphemeral, checkoutTTL = false, "2d"
+	if ttl, _ := ephemeralTTL(); ttl != 48*time.Hour {
+		t.Errorf("ephemeralTTL() with --ttl 2d = %v, want 48h", ttl)
+	}
+	checkoutTTL = "soon"
+	if _, err := ephemeralTTL(); err == nil || !strings.Contains(err.Error(), "--ttl") {
+		t.Errorf("ephemeralTTL() with --ttl soon = %v, want an invalid --ttl error", err)
+	}
+}
+
+func TestSetEphemeral(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	setEphemeral("review", time.Hour)
+	setEphemeral("old-review", time.Hour)
+	store := loadMeta()
+	past := time.Now().Add(-time.Minute)
+	meta := store.Branches["old-review"]
+	meta.Expires = &past
+	store.set("old-review", meta)
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := expiredEphemeral(time.Now()); strings.Join(got, ",") != "old-review" {
+		t.Errorf("expiredEphemeral() = %v, want [old-review]", got)
+	}
+	if label := loadMeta().Branches["review"].label(); !strings.Contains(label, "ephemeral until") {
+		t.Errorf("label() = %q, want the expiry", label)
+	}
+
+	// Checking the branch out again without --ephemeral forgets the expiry.
+	setEphemeral("old-review", 0)
+	if _, ok := loadMeta().Branches["old-review"]; ok {
+		t.Error("setEphemeral(0) kept the expiry")
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	execAll  bool
+	execJSON bool
+	execTail int
+	execJobs int
+)
+
+// execResult is the outcome of running the command in one worktree.
+type execResult struct {
+	Branch     string   `json:"branch"`
+	Path       string   `json:"path"`
+	ExitCode   int      `json:"exit_code"`
+	Passed     bool     `json:"passed"`
+	DurationMS int64    `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+	OutputTail []string `json:"output_tail"`
+}
+
+// tailLines returns the last n lines of output, ignoring trailing newlines.
+func tailLines(output string, n int) []string {
+	lines := strings.Split(strings.TrimRight(output, "\r\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return []string{}
+	}
+	if n >= 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, "\r")
+	}
+	return lines
+}
+
+// exitCode extracts the exit code of a finished command; -1 means it could
+// not be started.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// execPlaceholders are replaced in the words of the command, and exported
+// as the variable after them, for each worktree it runs in.
+var execPlaceholders = []shellPlaceholder{
+	{"{branch}", "WT_BRANCH"},
+	{"{branch_slug}", "WT_BRANCH_SLUG"},
+	{"{path}", "WT_PATH"},
+	{"{repo}", "WT_REPO"},
+	{"{commit}", "WT_COMMIT"},
+}
+
+// execValues returns the values of the placeholders for a worktree, in the
+// order of execPlaceholders. A detached HEAD stands in for the branch with
+// its short commit.
+func execValues(entry worktreeEntry, repo string) []string {
+	branch := entry.Branch
+	if branch == "" && len(entry.Head) >= 7 {
+		branch = entry.Head[:7]
+	}
+	return []string{branch, slugify(branch), entry.Path, repo, entry.Head}
+}
+
+// execCommand builds the command for a worktree: placeholders in its words
+// replaced, their variables exported. A command given as a single word
+// with spaces or shell syntax in it, e.g. 'docker build -t app:{branch} .',
+// is run by the shell, with quoted references to the variables in place of
+// the placeholders; see shellReferences for when that fails.
+func execCommand(entry worktreeEntry, repo string, argv []string) (*exec.Cmd, error) {
+	values := execValues(entry, repo)
+	env := os.Environ()
+	for i, p := range execPlaceholders {
+		env = append(env, p.Env+"="+values[i])
+	}
+	var cmd *exec.Cmd
+	if len(argv) == 1 && strings.ContainsAny(argv[0], " \t|&;<>()$`*?") {
+		command, err := shellReferences(argv[0], execPlaceholders, values)
+		if err != nil {
+			return nil, err
+		}
+		cmd = shellCommand(command)
+	} else {
+		pairs := make([]string, 0, 2*len(values))
+		for i, p := range execPlaceholders {
+			pairs = append(pairs, p.Name, values[i])
+		}
+		replacer := strings.NewReplacer(pairs...)
+		words := make([]string, len(argv))
+		for i, word := range argv {
+			words[i] = replacer.Replace(word)
+		}
+		cmd = exec.Command(words[0], words[1:]...)
+	}
+	cmd.Dir = entry.Path
+	cmd.Env = env
+	return cmd, nil
+}
+
+// execRepoName is the repository name for {repo}, or "" outside of one.
+func execRepoName() string {
+	info, err := getRepoInfo()
+	if err != nil {
+		return ""
+	}
+	return info.Name
+}
+
+// runInWorktree runs argv in the worktree and captures its combined output.
+func runInWorktree(entry worktreeEntry, repo string, argv []string, tail int) execResult {
+	result := execResult{Branch: branchLabel(worktreeStatus{worktreeEntry: entry}), Path: entry.Path}
+
+	var output bytes.Buffer
+	cmd, err := execCommand(entry, repo, argv)
+	if err != nil {
+		result.ExitCode = -1
+		result.Error = err.Error()
+		return result
+	}
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	start := time.Now()
+	err = cmd.Run()
+	result.DurationMS = time.Since(start).Milliseconds()
+
+	result.ExitCode = exitCode(err)
+	result.Passed = err == nil
+	if result.ExitCode == -1 {
+		result.Error = err.Error()
+	}
+	result.OutputTail = tailLines(output.String(), tail)
+	return result
+}
+
+// execTargets returns the worktrees 'wt exec --all' runs in.
+func execTargets() ([]worktreeEntry, error) {
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return nil, err
+	}
+	var targets []worktreeEntry
+	for _, e := range entries {
+		if !e.Bare && !e.Prunable {
+			targets = append(targets, e)
+		}
+	}
+	return targets, nil
+}
+
+// execEverywhere runs argv in every target with up to jobs commands at a
+// time. Results keep the order of targets.
+func execEverywhere(targets []worktreeEntry, argv []string, jobs, tail int) []execResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+	repo := execRepoName()
+	results := make([]execResult, len(targets))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i] = runInWorktree(targets[i], repo, argv, tail)
+				if !execJSON {
+					infof("%s %s\n", execStatus(results[i]), results[i].Branch)
+				}
+			}
+		}()
+	}
+	for i := range targets {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	return results
+}
+
+func execStatus(r execResult) string {
+	if r.Passed {
+		return "[pass]"
+	}
+	return "[FAIL]"
+}
+
+// printExecReport prints the pass/fail matrix, followed by the output tail
+// of every failed worktree.
+func printExecReport(results []execResult) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BRANCH\tRESULT\tEXIT\tTIME\tPATH")
+	for _, r := range results {
+		result := "pass"
+		if !r.Passed {
+			result = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1fs\t%s\n", r.Branch, result, r.ExitCode, float64(r.DurationMS)/1000, r.Path)
+	}
+	w.Flush()
+
+	for _, r := range results {
+		if r.Passed {
+			continue
+		}
+		fmt.Printf("\n--- %s (exit %d) ---\n", r.Branch, r.ExitCode)
+		if r.Error != "" {
+			fmt.Println(r.Error)
+		}
+		for _, line := range r.OutputTail {
+			fmt.Println(line)
+		}
+	}
+}
+
+var execCmd = &cobra.Command{
+	Use:   "exec [branch] -- <command> [args...]",
+	Short: "Run a command in one or every worktree",
+	Long: `Run a command in the worktree of a branch, or with --all in every worktree.
+
+The command is run directly, not through a shell, unless it is a single
+argument with spaces or shell syntax in it, e.g. 'make test | tail -3',
+which the shell runs (cmd on Windows). With a branch the command's output
+and exit code pass through.
+
+In each worktree, these placeholders in the command are replaced, and
+exported as the variable next to them, so that every branch can produce
+its own artifacts:
+  {branch}       WT_BRANCH       the branch, or the short commit when detached
+  {branch_slug}  WT_BRANCH_SLUG  the branch in lowercase letters, digits and
+                                 dashes, e.g. for image tags
+  {path}         WT_PATH         the worktree
+  {repo}         WT_REPO         the repository name
+  {commit}       WT_COMMIT       the checked out commit
+In a command the shell runs, a placeholder becomes a quoted reference to
+its variable instead, so the shell never sees a value, such as a branch
+name with ; or $( in it, as syntax; don't quote it again. cmd expands
+variables before it parses the line, so on Windows a value with one of
+"&|^%<> in it is refused there; pass the command as separate words.
+
+'wt foreach' is 'wt exec --all'.
+
+With --all the output is captured and wt prints a pass/fail matrix with the
+exit code of every worktree and the last lines of output of the failed
+ones. wt exits non-zero if the command failed anywhere.
+
+Examples:
+  wt exec feature-x -- make test         # Run in one worktree
+  wt exec --all -- golangci-lint run     # Which branches still fail lint?
+  wt exec --all --jobs 4 --tail 20 -- go test ./...
+  wt exec --all --json -- make lint      # Results as JSON
+  wt foreach -- 'docker build -t app:{branch_slug} .'`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if execAll {
+			return execAllWorktrees(cmd, args)
+		}
+		if cmd.ArgsLenAtDash() == 0 {
+			return fmt.Errorf("usage: wt exec <branch> -- <command> (or --all -- <command>)")
+		}
+		branch, argv := args[0], args[1:]
+		// Flag parsing stops at the branch, so the -- after it is kept.
+		if len(argv) > 0 && argv[0] == "--" {
+			argv = argv[1:]
+		}
+		if len(argv) == 0 {
+			return fmt.Errorf("usage: wt exec <branch> -- <command> (or --all -- <command>)")
+		}
+
+		path, ok := worktreeExists(branch)
+		if !ok {
+			return fmt.Errorf("no worktree found for branch: %s", branch)
+		}
+		entry := worktreeEntry{Path: path, Branch: branch}
+		entries, _ := snapshot.Worktrees()
+		for _, e := range entries {
+			if samePath(e.Path, path) {
+				entry = e
+			}
+		}
+		child, err := execCommand(entry, execRepoName(), argv)
+		if err != nil {
+			return err
+		}
+		child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := child.Run(); err != nil {
+			if code := exitCode(err); code > 0 {
+				os.Exit(code)
+			}
+			return err
+		}
+		return nil
+	},
+}
+
+func execAllWorktrees(cmd *cobra.Command, argv []string) error {
+	targets, err := execTargets()
+	if err != nil {
+		return err
+	}
+	results := execEverywhere(targets, argv, execJobs, execTail)
+
+	failed := 0
+	for _, r := range results {
+		if !r.Passed {
+			failed++
+		}
+	}
+
+	if execJSON {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		printExecReport(results)
+		fmt.Println()
+		if ciMode() {
+			fmt.Println(machineSummary("exec", "passed", len(results)-failed, "failed", failed))
+		} else {
+			fmt.Printf("%d passed, %d failed\n", len(results)-failed, failed)
+		}
+	}
+
+	if failed > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("command failed in %d of %d worktree(s)", failed, len(results))
+	}
+	return nil
+}
+
+var foreachCmd = &cobra.Command{
+	Use:   "foreach -- <command> [args...]",
+	Short: "Run a command in every worktree",
+	Long: `Run a command in every worktree and print a pass/fail report; the same
+as 'wt exec --all', with the same placeholders (see 'wt exec --help').
+
+Examples:
+  wt foreach -- 'docker build -t app:{branch_slug} .'
+  wt foreach -j 4 -- go test ./...`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: execAllWorktrees,
+}
+
+func init() {
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().BoolVar(&execAll, "all", false, "Run in every worktree and print a pass/fail report")
+	execCmd.Flags().BoolVar(&execJSON, "json", false, "With --all, print the results as JSON")
+	execCmd.Flags().IntVar(&execTail, "tail", 10, "With --all, lines of output kept per worktree")
+	execCmd.Flags().IntVarP(&execJobs, "jobs", "j", 1, "With --all, worktrees to run in at the same time")
+	rootCmd.AddCommand(execCmd)
+
+	foreachCmd.Flags().SetInterspersed(false)
+	foreachCmd.Flags().BoolVar(&execJSON, "json", false, "Print the results as JSON")
+	foreachCmd.Flags().IntVar(&execTail, "tail", 10, "Lines of output kept per worktree")
+	foreachCmd.Flags().IntVarP(&execJobs, "jobs", "j", 1, "Worktrees to run in at the same time")
+	rootCmd.AddCommand(foreachCmd)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTailLines(t *testing.T) {
+	tests := []struct {
+		output string
+		n      int
+		want   []string
+	}{
+		{"", 3, []string{}},
+		{"one\ntwo\nthree\n", 2, []string{"two", "three"}},
+		{"one\r\ntwo\r\n", 5, []string{"one", "two"}},
+		{"a\nb\n", 0, []string{}},
+	}
+	for _, tt := range tests {
+		if got := tailLines(tt.output, tt.n); strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("tailLines(%q, %d) = %q, want %q", tt.output, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestExecEverywhere(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "plain", filepath.Join(tmpDir, "plain"))
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "marked", filepath.Join(tmpDir, "marked"))
+	if err := os.WriteFile(filepath.Join(tmpDir, "marked", "marker.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, filepath.Join(tmpDir, "marked"), "add", "marker.txt")
+	runGitCommand(t, filepath.Join(tmpDir, "marked"), "commit", "-m", "add marker")
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := execTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := execEverywhere(targets, []string{"git", "ls-files", "--error-unmatch", "marker.txt"}, 2, 5)
+	if len(results) != 3 {
+		t.Fatalf("execEverywhere() returned %d results, want 3", len(results))
+	}
+
+	for _, r := range results {
+		wantPass := r.Branch == "marked"
+		if r.Passed != wantPass {
+			t.Errorf("%s: passed = %v, want %v (exit %d, output %q)", r.Branch, r.Passed, wantPass, r.ExitCode, r.OutputTail)
+		}
+		if !r.Passed && (r.ExitCode == 0 || len(r.OutputTail) == 0) {
+			t.Errorf("%s: failed run should have an exit code and output, got %d %q", r.Branch, r.ExitCode, r.OutputTail)
+		}
+	}
+	if results[0].Branch != "main" {
+		t.Errorf("results out of order: first is %q, want main", results[0].Branch)
+	}
+
+	missing := execEverywhere(targets[:1], []string{"wt-no-such-command"}, 1, 5)
+	if missing[0].Passed || missing[0].ExitCode != -1 || missing[0].Error == "" {
+		t.Errorf("missing command result = %+v, want start error", missing[0])
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	head := "0123456789abcdef0123456789abcdef01234567"
+	entry := worktreeEntry{Path: "/wt/feature", Branch: "Feature/ABC-1.2", Head: head}
+
+	cmd, err := execCommand(entry, "app", []string{"docker", "build", "-t", "app:{branch_slug}", "--label", "src={repo}@{commit}", "{}", "."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"docker", "build", "-t", "app:feature-abc-1-2", "--label", "src=app@" + head, "{}", "."}
+	if strings.Join(cmd.Args, "|") != strings.Join(want, "|") {
+		t.Errorf("args = %q, want %q", cmd.Args, want)
+	}
+	if cmd.Dir != "/wt/feature" {
+		t.Errorf("dir = %q", cmd.Dir)
+	}
+	env := strings.Join(cmd.Env, "\n")
+	for _, v := range []string{"WT_BRANCH=Feature/ABC-1.2", "WT_BRANCH_SLUG=feature-abc-1-2", "WT_PATH=/wt/feature", "WT_REPO=app", "WT_COMMIT=" + head} {
+		if !strings.Contains(env, "\n"+v+"\n") && !strings.HasSuffix(env, "\n"+v) {
+			t.Errorf("env lacks %s", v)
+		}
+	}
+
+	// A single word with shell syntax goes to the shell.
+	if cmd, err = execCommand(entry, "app", []string{"echo {branch} | tr a-z A-Z"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cmd.Args) != 3 || !strings.Contains(cmd.Args[2], "WT_BRANCH") || strings.Contains(cmd.Args[2], "Feature") {
+		t.Errorf("shell args = %q", cmd.Args)
+	}
+	if cmd, _ = execCommand(entry, "app", []string{"make"}); len(cmd.Args) != 1 {
+		t.Errorf("plain command args = %q", cmd.Args)
+	}
+
+	detached := worktreeEntry{Path: "/wt/x", Head: head}
+	if values := execValues(detached, "app"); values[0] != "0123456" || values[1] != "0123456" {
+		t.Errorf("detached values = %q", values)
+	}
+}
+
+func TestExecCommandQuotesValues(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	dir := t.TempDir()
+	branch := "x;touch${IFS}PWNED;#`touch PWNED2`$(touch PWNED3)'"
+	entry := worktreeEntry{Path: dir, Branch: branch}
+	cmd, err := execCommand(entry, "app", []string{"echo building {branch}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "building "+branch {
+		t.Errorf("output = %q, want the branch name as is", got)
+	}
+	for _, name := range []string{"PWNED", "PWNED2", "PWNED3"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("the branch name ran as a command: %s exists", name)
+		}
+	}
+}
+
+func TestExecEverywhereTemplates(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "feature/x", filepath.Join(tmpDir, "x"))
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := execTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := execEverywhere(targets, []string{`echo "{branch_slug} $WT_BRANCH"`}, 1, 5)
+	if got := strings.Join(results[1].OutputTail, "|"); got != "feature-x feature/x" {
+		t.Errorf("output = %q, want %q", got, "feature-x feature/x")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+)
+
+// leaveWorktrees changes the current directory to a worktree that stays when
+// it is inside one of paths, which are about to be removed: git, hooks and
+// the remaining removals need a directory that still exists. It prefers the
+// main worktree. It returns where the shell should go afterwards, or "" when
+// the current directory was elsewhere.
+func leaveWorktrees(paths ...string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", nil
+	}
+	inside := false
+	for _, path := range paths {
+		if isWithin(path, cwd) {
+			inside = true
+			break
+		}
+	}
+	if !inside {
+		return "", nil
+	}
+
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.Bare || entry.Prunable || leaving(entry.Path, paths) {
+			continue
+		}
+		if err := os.Chdir(longPath(entry.Path)); err != nil {
+			return "", err
+		}
+		return entry.Path, nil
+	}
+	// Only a bare repository is left; git works from its directory too, but
+	// the shell has nowhere useful to go.
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return "", err
+	}
+	return "", os.Chdir(longPath(commonDir))
+}
+
+// leaving reports whether path is one of paths, or inside one.
+func leaving(path string, paths []string) bool {
+	for _, p := range paths {
+		if isWithin(p, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupFamily creates a repository whose main worktree is on another branch
+// than the default one, with linked worktrees for main and feature.
+func setupFamily(t *testing.T) (repoDir, mainWt, featureWt string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	repoDir = filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "checkout", "-b", "develop")
+	mainWt = filepath.Join(tmpDir, "wt-main")
+	featureWt = filepath.Join(tmpDir, "wt-feature")
+	runGitCommand(t, repoDir, "worktree", "add", mainWt, "main")
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "feature", featureWt)
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() {
+		os.Chdir(origDir)
+		snapshot.invalidate()
+	})
+	return repoDir, mainWt, featureWt
+}
+
+func chdirFamily(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.invalidate()
+}
+
+func TestRepoInfoSameFromEveryWorktree(t *testing.T) {
+	t.Setenv("WORKTREE_ROOT", "")
+	repoDir, mainWt, featureWt := setupFamily(t)
+	sub := filepath.Join(featureWt, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{repoDir, mainWt, featureWt, sub} {
+		chdirFamily(t, dir)
+		info, err := getRepoInfo()
+		if err != nil {
+			t.Fatalf("getRepoInfo() in %s: %v", dir, err)
+		}
+		// The checkout of the common git directory, not the worktree that
+		// happens to have the default branch.
+		if !samePath(info.Main, repoDir) || info.Name != "repo" {
+			t.Errorf("getRepoInfo() in %s = %s (%s), want %s (repo)", dir, info.Main, info.Name, repoDir)
+		}
+	}
+}
+
+func TestLeaveWorktrees(t *testing.T) {
+	repoDir, mainWt, featureWt := setupFamily(t)
+
+	chdirFamily(t, mainWt)
+	if to, err := leaveWorktrees(featureWt); err != nil || to != "" {
+		t.Errorf("leaveWorktrees() outside of it = %q, %v, want it to stay", to, err)
+	}
+
+	sub := filepath.Join(featureWt, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	chdirFamily(t, sub)
+	to, err := leaveWorktrees(featureWt)
+	if err != nil || !samePath(to, repoDir) {
+		t.Fatalf("leaveWorktrees() = %q, %v, want the main worktree %s", to, err, repoDir)
+	}
+	if cwd, _ := os.Getwd(); !samePath(cwd, repoDir) {
+		t.Errorf("current directory = %s, want %s", cwd, repoDir)
+	}
+
+	// Leaving the main worktree too goes to the next one that stays.
+	chdirFamily(t, repoDir)
+	if to, err := leaveWorktrees(repoDir, featureWt); err != nil || !samePath(to, mainWt) {
+		t.Errorf("leaveWorktrees() of the main worktree = %q, %v, want %s", to, err, mainWt)
+	}
+}
+
+func TestRepoConfigFileFallsBackToMainWorktree(t *testing.T) {
+	repoDir, _, featureWt := setupFamily(t)
+	chdirFamily(t, featureWt)
+	if got := repoConfigFile(); !samePath(got, filepath.Join(featureWt, ".wt.yaml")) {
+		t.Errorf("repoConfigFile() without any file = %s, want the one of the current worktree", got)
+	}
+
+	mainFile := filepath.Join(repoDir, ".wt.yaml")
+	if err := os.WriteFile(mainFile, []byte("strategy: inside-dotdir\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := repoConfigFile(); !samePath(got, mainFile) {
+		t.Errorf("repoConfigFile() = %s, want the main worktree's %s", got, mainFile)
+	}
+	if got := repoConfigPath(); !samePath(got, filepath.Join(featureWt, ".wt.yaml")) {
+		t.Errorf("repoConfigPath() = %s, want the current worktree's file to write to", got)
+	}
+
+	own := filepath.Join(featureWt, ".wt.yaml")
+	if err := os.WriteFile(own, []byte("strategy: global\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := repoConfigFile(); !samePath(got, own) {
+		t.Errorf("repoConfigFile() = %s, want the current worktree's %s", got, own)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var fetchAll bool
+
+// fetchRemotes returns the remotes the upstreams of the worktrees' branches
+// live on, or origin when none has an upstream.
+func fetchRemotes(entries []worktreeEntry) ([]string, error) {
+	branches := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Branch != "" {
+			branches[entry.Branch] = true
+		}
+	}
+	output, _ := gitCommand("config", "--get-regexp", `^branch\..*\.remote$`).Output()
+	seen := make(map[string]bool)
+	var remotes []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, remote, ok := strings.Cut(line, " ")
+		branch := strings.TrimSuffix(strings.TrimPrefix(key, "branch."), ".remote")
+		// "." is the repository itself, for branches tracking local ones.
+		if !ok || !branches[branch] || remote == "." || seen[remote] {
+			continue
+		}
+		seen[remote] = true
+		remotes = append(remotes, remote)
+	}
+	if len(remotes) == 0 {
+		if gitCommand("remote", "get-url", "origin").Run() != nil {
+			return nil, fmt.Errorf("no remote to fetch: no worktree has an upstream and there is no origin")
+		}
+		remotes = []string{"origin"}
+	}
+	sort.Strings(remotes)
+	return remotes, nil
+}
+
+// lastFetch is when any worktree last fetched, from git's FETCH_HEAD, or the
+// zero time when none ever did.
+func lastFetch(commonDir string) time.Time {
+	var last time.Time
+	for _, dir := range append([]string{commonDir}, globDirs(filepath.Join(commonDir, "worktrees", "*"))...) {
+		if info, err := os.Stat(filepath.Join(dir, "FETCH_HEAD")); err == nil && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last
+}
+
+// upstreamChange is how a worktree's branch relates to its upstream after a
+// fetch, compared to before it.
+type upstreamChange struct {
+	Branch   string
+	Path     string
+	Upstream string
+	Ahead    int
+	Behind   int
+	// New is how many of the commits behind arrived with this fetch.
+	New     int
+	Gone    bool
+	NewGone bool
+}
+
+// upstreamChanges compares the upstream state of the worktrees' branches
+// before and after a fetch.
+func upstreamChanges(entries []worktreeEntry, before, after map[string]branchRef) []upstreamChange {
+	var changes []upstreamChange
+	for _, entry := range entries {
+		if entry.Branch == "" {
+			continue
+		}
+		ref := after[entry.Branch]
+		old := before[entry.Branch]
+		change := upstreamChange{
+			Branch:   entry.Branch,
+			Path:     entry.Path,
+			Upstream: ref.Upstream,
+			Ahead:    ref.Ahead,
+			Behind:   ref.Behind,
+			Gone:     ref.UpstreamGone,
+			NewGone:  ref.UpstreamGone && !old.UpstreamGone,
+		}
+		if !old.UpstreamGone && ref.Behind > old.Behind {
+			change.New = ref.Behind - old.Behind
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func printUpstreamChanges(out io.Writer, changes []upstreamChange) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, c := range changes {
+		upstream, state := c.Upstream, ""
+		switch {
+		case upstream == "":
+			upstream, state = "-", "no upstream"
+		case c.NewGone:
+			state = "upstream gone (deleted on the remote)"
+		case c.Gone:
+			state = "upstream gone"
+		case c.Ahead == 0 && c.Behind == 0:
+			state = "up to date"
+		default:
+			var parts []string
+			if c.Behind > 0 {
+				behind := fmt.Sprintf("behind %d", c.Behind)
+				if c.New > 0 {
+					behind += fmt.Sprintf(" (%d new)", c.New)
+				}
+				parts = append(parts, behind)
+			}
+			if c.Ahead > 0 {
+				parts = append(parts, fmt.Sprintf("ahead %d", c.Ahead))
+			}
+			state = strings.Join(parts, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Branch, upstream, state)
+	}
+	return w.Flush()
+}
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "Fetch once for all worktrees and show how their upstreams moved",
+	Long: `Fetch the remotes the worktrees' branches track, once for the whole
+repository, as all worktrees share its objects and remote branches. Then
+show per worktree how far it is behind or ahead of its upstream, how many
+of the commits behind just arrived, and whether the upstream was deleted
+on the remote, e.g. after its pull request was merged.
+
+Remote branches deleted on the remote are pruned. Without upstreams, origin
+is fetched; --all fetches every remote. 'wt status', 'wt cleanup' and 'wt
+list --filter merged' work from what was fetched last and do not fetch
+themselves; cleanup says so when the last fetch is more than a day old.
+
+Examples:
+  wt fetch
+  wt fetch --all`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := snapshot.Worktrees()
+		if err != nil {
+			return err
+		}
+		before, err := snapshot.BranchRefs()
+		if err != nil {
+			return err
+		}
+
+		gitArgs := []string{"fetch", "--prune"}
+		if fetchAll {
+			gitArgs = append(gitArgs, "--all")
+			infof("Fetching all remotes\n")
+		} else {
+			remotes, err := fetchRemotes(entries)
+			if err != nil {
+				return err
+			}
+			if len(remotes) > 1 {
+				gitArgs = append(gitArgs, "--multiple")
+			}
+			gitArgs = append(gitArgs, remotes...)
+			infof("Fetching %s\n", strings.Join(remotes, ", "))
+		}
+		if err := objectGit("", gitOutput(), os.Stderr, gitArgs...); err != nil {
+			return fmt.Errorf("failed to fetch: %w", err)
+		}
+
+		snapshot.invalidate()
+		after, err := snapshot.BranchRefs()
+		if err != nil {
+			return err
+		}
+		changes := upstreamChanges(entries, before, after)
+		if ciMode() {
+			behind, gone := 0, 0
+			for _, c := range changes {
+				if c.Behind > 0 && !c.Gone {
+					behind++
+				}
+				if c.Gone {
+					gone++
+				}
+			}
+			fmt.Println(machineSummary("fetch", "worktrees", len(changes), "behind", behind, "gone", gone))
+			return nil
+		}
+		return printUpstreamChanges(os.Stdout, changes)
+	},
+}
+
+// hintOldFetch tells that upstream state comes from a fetch that is more
+// than a day old, when wt knows of one.
+func hintOldFetch() {
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return
+	}
+	if last := lastFetch(commonDir); !last.IsZero() && time.Since(last) > 24*time.Hour {
+		infof("Last fetched %s; 'wt fetch' updates the remote branches\n", formatAge(time.Since(last)))
+	}
+}
+
+func init() {
+	fetchCmd.Flags().BoolVar(&fetchAll, "all", false, "Fetch every remote")
+	rootCmd.AddCommand(fetchCmd)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpstreamChanges(t *testing.T) {
+	entries := []worktreeEntry{
+		{Path: "/wt/main", Branch: "main"},
+		{Path: "/wt/merged", Branch: "merged"},
+		{Path: "/wt/gone", Branch: "gone"},
+		{Path: "/wt/local", Branch: "local"},
+		{Path: "/wt/detached"},
+	}
+	before := map[string]branchRef{
+		"main":   {Name: "main", Upstream: "origin/main", Behind: 1, Ahead: 2},
+		"merged": {Name: "merged", Upstream: "origin/merged", Behind: 1},
+		"gone":   {Name: "gone", Upstream: "origin/gone", UpstreamGone: true},
+		"local":  {Name: "local"},
+	}
+	after := map[string]branchRef{
+		"main":   {Name: "main", Upstream: "origin/main", Behind: 4, Ahead: 2},
+		"merged": {Name: "merged", Upstream: "origin/merged", UpstreamGone: true},
+		"gone":   {Name: "gone", Upstream: "origin/gone", UpstreamGone: true},
+		"local":  {Name: "local"},
+	}
+	changes := upstreamChanges(entries, before, after)
+	want := []upstreamChange{
+		{Branch: "main", Path: "/wt/main", Upstream: "origin/main", Ahead: 2, Behind: 4, New: 3},
+		{Branch: "merged", Path: "/wt/merged", Upstream: "origin/merged", Gone: true, NewGone: true},
+		{Branch: "gone", Path: "/wt/gone", Upstream: "origin/gone", Gone: true},
+		{Branch: "local", Path: "/wt/local"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("upstreamChanges() = %+v, want %+v", changes, want)
+	}
+
+	var out bytes.Buffer
+	if err := printUpstreamChanges(&out, changes); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"main    origin/main    behind 4 (3 new), ahead 2",
+		"merged  origin/merged  upstream gone (deleted on the remote)",
+		"gone    origin/gone    upstream gone",
+		"local   -              no upstream",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("output lacks %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestFetchRemotesAndLastFetch(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() {
+		os.Chdir(origDir)
+		snapshot.invalidate()
+	})
+	chdirFamily(t, repoDir)
+
+	entries := []worktreeEntry{{Path: repoDir, Branch: "main"}}
+	if _, err := fetchRemotes(entries); err == nil {
+		t.Error("fetchRemotes() without remotes succeeded")
+	}
+
+	for _, remote := range []string{"origin", "upstream", "fork"} {
+		remoteDir := filepath.Join(tmpDir, remote+".git")
+		runGitCommand(t, tmpDir, "init", "--bare", remoteDir)
+		runGitCommand(t, repoDir, "remote", "add", remote, remoteDir)
+	}
+	if got, err := fetchRemotes(entries); err != nil || !reflect.DeepEqual(got, []string{"origin"}) {
+		t.Errorf("fetchRemotes() without upstreams = %v, %v, want [origin]", got, err)
+	}
+
+	runGitCommand(t, repoDir, "push", "upstream", "main")
+	runGitCommand(t, repoDir, "branch", "--set-upstream-to", "upstream/main", "main")
+	runGitCommand(t, repoDir, "branch", "other")
+	runGitCommand(t, repoDir, "push", "fork", "other")
+	runGitCommand(t, repoDir, "branch", "--set-upstream-to", "fork/other", "other")
+	// Only the remotes of branches checked out in a worktree.
+	if got, err := fetchRemotes(entries); err != nil || !reflect.DeepEqual(got, []string{"upstream"}) {
+		t.Errorf("fetchRemotes() = %v, %v, want [upstream]", got, err)
+	}
+
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lastFetch(commonDir); !got.IsZero() {
+		t.Errorf("lastFetch() before fetching = %v, want zero", got)
+	}
+	runGitCommand(t, repoDir, "fetch", "upstream")
+	if got := lastFetch(commonDir); got.IsZero() {
+		t.Error("lastFetch() after fetching is zero")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var checkoutForceBranch string
+
+// unpushedCommits counts the commits of branch that are neither in base nor
+// on any remote, i.e. the ones resetting the branch would lose.
+func unpushedCommits(branch, base string) (int, error) {
+	output, err := gitCommand("rev-list", "--count", "refs/heads/"+branch, "--not", base, "--remotes").Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to compare %s with %s: %w", branch, base, err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
+// checkResetBranch makes sure an existing branch can be reset to base: it
+// must not be checked out anywhere, and the user must agree. Commits that
+// would be lost always need a yes; without a prompt (CI) they stop it.
+func checkResetBranch(branch, base string) error {
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Branch == branch {
+			return fmt.Errorf("branch '%s' is checked out in %s\nRemove that worktree first: wt remove %s", branch, entry.Path, branch)
+		}
+	}
+	if busyPath, reason, busy := busyWorktree(branch); busy {
+		return fmt.Errorf("branch '%s' is in use in %s (%s); finish or abort that first", branch, busyPath, reason)
+	}
+
+	unpushed, err := unpushedCommits(branch, base)
+	if err != nil {
+		return err
+	}
+	tip, _ := revParse("refs/heads/" + branch)
+	if len(tip) > 7 {
+		tip = tip[:7]
+	}
+	label := fmt.Sprintf("Reset branch '%s' (at %s) to %s", branch, tip, base)
+	if unpushed > 0 {
+		label = fmt.Sprintf("Reset branch '%s' to %s and drop its %d commit(s) that are on no remote", branch, base, unpushed)
+	}
+	ok, err := confirmPrompt(label)
+	switch {
+	case errors.Is(err, errPromptDisabled) && unpushed > 0:
+		return fmt.Errorf("branch '%s' has %d commit(s) that are on no remote; push them, or reset it interactively: %w", branch, unpushed, err)
+	case errors.Is(err, errPromptDisabled):
+		// Nothing would be lost: every commit is on a remote or in base.
+	case err != nil:
+		return err
+	case !ok:
+		return fmt.Errorf("kept branch '%s' as it is", branch)
+	}
+	infof("Branch '%s' was at %s; 'git branch -f %s %s' restores it\n", branch, tip, branch, tip)
+	return nil
+}
+
+// recreateBranch checks branch out in a new worktree, fresh from base: a
+// new branch is created, an existing one is reset once checkResetBranch
+// allows it.
+func recreateBranch(branch, base, patchFile string) error {
+	if !gitRefExists("refs/heads/" + branch) {
+		// Also when origin has the branch: it starts over from base too.
+		return addBranchWorktree(branch, base, patchFile, "-b")
+	}
+	if err := checkResetBranch(branch, base); err != nil {
+		return err
+	}
+	return addBranchWorktree(branch, base, patchFile, "-B")
+}
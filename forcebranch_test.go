@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecreateBranch(t *testing.T) {
+	originalRoot, originalStrategy, originalPattern := worktreeRoot, worktreeStrategy, worktreePattern
+	t.Cleanup(func() {
+		worktreeRoot, worktreeStrategy, worktreePattern = originalRoot, originalStrategy, originalPattern
+	})
+	tmpDir := t.TempDir()
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	worktreeStrategy = "global"
+	worktreePattern = ""
+	t.Setenv("CI", "true")
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "branch", "ticket")
+	runGitCommand(t, repoDir, "checkout", "-q", "-b", "local-work")
+	runGitCommand(t, repoDir, "commit", "--allow-empty", "-m", "not pushed")
+	runGitCommand(t, repoDir, "checkout", "-q", "main")
+	runGitCommand(t, repoDir, "commit", "--allow-empty", "-m", "main moved on")
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.invalidate()
+
+	if n, err := unpushedCommits("local-work", "main"); err != nil || n != 1 {
+		t.Errorf("unpushedCommits(local-work) = %d, %v; want 1", n, err)
+	}
+	// Without a prompt, commits that would be lost stop the reset.
+	if err := recreateBranch("local-work", "main", ""); !errors.Is(err, errPromptDisabled) {
+		t.Errorf("recreateBranch(local-work) = %v, want errPromptDisabled", err)
+	}
+
+	// ticket is behind main: nothing is lost, so it starts over from main.
+	if err := recreateBranch("ticket", "main", ""); err != nil {
+		t.Fatalf("recreateBranch(ticket) error: %v", err)
+	}
+	ticket, _ := exec.Command("git", "rev-parse", "ticket").Output()
+	mainTip, _ := exec.Command("git", "rev-parse", "main").Output()
+	if string(ticket) != string(mainTip) {
+		t.Errorf("ticket is at %s, want the tip of main %s", ticket, mainTip)
+	}
+
+	snapshot.invalidate()
+	if err := recreateBranch("ticket", "main", ""); err == nil || !strings.Contains(err.Error(), "checked out") {
+		t.Errorf("recreateBranch() of a checked out branch = %v, want a checked out error", err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var cleanupFree string
+
+// parseSize parses a disk space amount such as 20G, 512M or 1.5T, in binary
+// units like formatSize prints them; a trailing B or iB is accepted. what
+// names the setting in errors.
+func parseSize(value, what string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	unit := int64(1)
+	if i := strings.IndexAny(s, "KMGTP"); i >= 0 && i == len(s)-1 {
+		unit = int64(1) << (10 * (strings.IndexByte("KMGTP", s[i]) + 1))
+		s = s[:i]
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid %s %q (use e.g. 20G, 512M or 1.5T)", what, value)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// freeCandidate is a worktree 'wt cleanup --free' may remove.
+type freeCandidate struct {
+	listItem
+	// Reason is why it may go: merged, stale or expired.
+	Reason string
+}
+
+// score ranks candidates: big worktrees nobody touched for long go first.
+// A candidate is at least a day old, so fresh merges still count by size.
+func (c freeCandidate) score(now time.Time) float64 {
+	days := now.Sub(c.LastActivity()).Hours() / 24
+	if c.LastActivity().IsZero() || days < 1 {
+		days = 1
+	}
+	return float64(c.Size) * days
+}
+
+// collectFreeCandidates returns the worktrees that are safe to remove for
+// space: clean, unlocked linked worktrees of a branch that is merged, whose
+// ephemeral worktree expired, or that went stale. Their sizes are measured.
+func collectFreeCandidates(merged, expired map[string]bool) ([]freeCandidate, error) {
+	statuses, err := collectWorktreeStatus(true)
+	if err != nil {
+		return nil, err
+	}
+	state := loadState()
+	now := time.Now()
+	threshold := staleAfter()
+	var candidates []freeCandidate
+	for i, st := range statuses {
+		if i == 0 || st.Bare || st.Prunable || st.Locked || st.Branch == "" || st.Dirty() || st.StatusError != "" {
+			continue
+		}
+		c := freeCandidate{listItem: listItem{worktreeStatus: st, LastVisit: state.lastVisit(st.Path)}}
+		switch {
+		case expired[st.Branch]:
+			c.Reason = "expired"
+		case merged[st.Branch]:
+			c.Reason = "merged"
+		case isStale(c.listItem, now, threshold):
+			c.Reason = "stale"
+		default:
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(c *freeCandidate) {
+			defer wg.Done()
+			c.Size = dirSize(c.Path)
+		}(&candidates[i])
+	}
+	wg.Wait()
+	return candidates, nil
+}
+
+// planFree picks the candidates to remove to free goal bytes: the best
+// ranked first until the goal is met, then without those that turn out not
+// to be needed, so that no worktree goes for nothing. When the goal cannot
+// be met, all candidates are picked. It returns the plan and what it frees.
+func planFree(candidates []freeCandidate, goal int64, now time.Time) ([]freeCandidate, int64) {
+	ranked := append([]freeCandidate(nil), candidates...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score(now) > ranked[j].score(now)
+	})
+
+	var plan []freeCandidate
+	var total int64
+	for _, c := range ranked {
+		if total >= goal {
+			break
+		}
+		plan = append(plan, c)
+		total += c.Size
+	}
+	if total < goal {
+		return plan, total
+	}
+	// Drop the lowest ranked picks the goal does not need.
+	for i := len(plan) - 1; i >= 0; i-- {
+		if total-plan[i].Size >= goal {
+			total -= plan[i].Size
+			plan = append(plan[:i], plan[i+1:]...)
+		}
+	}
+	return plan, total
+}
+
+// printFreePlan shows the worktrees of a --free plan with their sizes and
+// why they may go.
+func printFreePlan(out io.Writer, plan []freeCandidate, now time.Time) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, c := range plan {
+		age := "never used"
+		if last := c.LastActivity(); !last.IsZero() {
+			age = "used " + formatAge(now.Sub(last))
+		}
+		fmt.Fprintf(w, "  - %s\t%s\t%s, %s\t%s\n", c.Branch, formatSize(c.Size), c.Reason, age, c.Path)
+	}
+	return w.Flush()
+}
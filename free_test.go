@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"20G", 20 << 30},
+		{"20GB", 20 << 30},
+		{"20GiB", 20 << 30},
+		{"512m", 512 << 20},
+		{"1.5T", 3 << 39},
+		{"100", 100},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.value, "--free")
+		if err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []string{"", "G", "-1G", "20X", "0"} {
+		if _, err := parseSize(value, "--free"); err == nil {
+			t.Errorf("parseSize(%q) succeeded", value)
+		}
+	}
+}
+
+func TestPlanFree(t *testing.T) {
+	now := time.Now()
+	candidate := func(branch string, size int64, idle time.Duration) freeCandidate {
+		c := freeCandidate{Reason: "merged"}
+		c.Branch, c.Size, c.LastCommit = branch, size, now.Add(-idle)
+		return c
+	}
+	day := 24 * time.Hour
+	candidates := []freeCandidate{
+		candidate("small-old", 1<<30, 100*day),
+		candidate("big-new", 10<<30, day),
+		candidate("mid-old", 5<<30, 30*day),
+		candidate("tiny", 1<<20, 10*day),
+	}
+	branches := func(plan []freeCandidate) string {
+		var names []string
+		for _, c := range plan {
+			names = append(names, c.Branch)
+		}
+		return strings.Join(names, " ")
+	}
+
+	tests := []struct {
+		goal      int64
+		want      string
+		wantTotal int64
+	}{
+		// Ranked by size times idle days: mid-old, small-old, big-new, tiny.
+		{4 << 30, "mid-old", 5 << 30},
+		// mid-old and small-old fall short; with big-new, small-old is not
+		// needed any more.
+		{14 << 30, "mid-old big-new", 15 << 30},
+		{100 << 30, "mid-old small-old big-new tiny", 16<<30 + 1<<20},
+	}
+	for _, tt := range tests {
+		plan, total := planFree(candidates, tt.goal, now)
+		if got := branches(plan); got != tt.want || total != tt.wantTotal {
+			t.Errorf("planFree(%s) = %s (%s), want %s (%s)", formatSize(tt.goal), got, formatSize(total), tt.want, formatSize(tt.wantTotal))
+		}
+	}
+}
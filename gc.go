@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultRetention = 90 * 24 * time.Hour
+	// maxCompletionCache bounds the completion cache; entries are tiny, so
+	// only a machine with a great many repositories gets near it.
+	maxCompletionCache = 8 << 20
+	// Leftovers of an interrupted state write are removed after this long.
+	strayStateAge = time.Hour
+)
+
+var gcDryRun bool
+
+// gcResult reports what a gc task removed, or would remove on a dry run.
+type gcResult struct {
+	Name    string
+	Removed int
+	Bytes   int64
+	Unit    string // what Removed counts, e.g. "entries" or "files"
+}
+
+// gcTask expires one kind of wt data. Tasks only delete when dryRun is
+// false.
+type gcTask func(retention time.Duration, dryRun bool) gcResult
+
+// gcTasks run in order; features that keep more data register more.
+var gcTasks = []gcTask{gcVisits, gcCompletionCache, gcStrayStateFiles}
+
+func parseRetention(value string) (time.Duration, error) {
+	return parsePeriod(value, "retention")
+}
+
+// retention is how long wt keeps history, from the retention setting.
+func retention() time.Duration {
+	d, err := parseRetention(loadConfig().get("retention"))
+	if err != nil {
+		return defaultRetention
+	}
+	return d
+}
+
+// gcVisits drops the last visits of worktrees that no longer exist or were
+// not visited within the retention period.
+func gcVisits(retention time.Duration, dryRun bool) gcResult {
+	result := gcResult{Name: "last visits", Unit: "entries"}
+	state := loadState()
+	cutoff := time.Now().Add(-retention)
+	for path, visited := range state.Visits {
+		if _, err := os.Stat(longPath(path)); os.IsNotExist(err) || visited.Before(cutoff) {
+			delete(state.Visits, path)
+			result.Removed++
+		}
+	}
+	if result.Removed > 0 && !dryRun {
+		if err := state.save(); err != nil {
+			warnf("warning: failed to save state: %v\n", err)
+		}
+	}
+	return result
+}
+
+// gcCompletionCache removes completion cache entries wt no longer trusts,
+// and the oldest others while the cache is over its size bound.
+func gcCompletionCache(_ time.Duration, dryRun bool) gcResult {
+	result := gcResult{Name: "completion cache", Unit: "files"}
+	dir := filepath.Join(cacheDir(), "completion")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return result
+	}
+
+	type cacheFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var kept []cacheFile
+	var keptSize int64
+	remove := func(f cacheFile) {
+		if dryRun || os.Remove(f.path) == nil {
+			result.Removed++
+			result.Bytes += f.size
+		}
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		f := cacheFile{filepath.Join(dir, e.Name()), info.Size(), info.ModTime()}
+		if time.Since(f.modTime) > completionCacheTTL {
+			remove(f)
+			continue
+		}
+		kept = append(kept, f)
+		keptSize += f.size
+	}
+
+	sort.Slice(kept, func(i, j int) bool { return kept[i].modTime.Before(kept[j].modTime) })
+	for _, f := range kept {
+		if keptSize <= maxCompletionCache {
+			break
+		}
+		remove(f)
+		keptSize -= f.size
+	}
+	return result
+}
+
+// gcStrayStateFiles removes temporary files of state writes that never
+// completed.
+func gcStrayStateFiles(_ time.Duration, dryRun bool) gcResult {
+	result := gcResult{Name: "interrupted state writes", Unit: "files"}
+	matches, _ := filepath.Glob(filepath.Join(stateDir(), "state-*.json"))
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < strayStateAge {
+			continue
+		}
+		if dryRun || os.Remove(path) == nil {
+			result.Removed++
+			result.Bytes += info.Size()
+		}
+	}
+	return result
+}
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Expire wt's own state and caches",
+	Long: `Remove data wt keeps for itself that has outlived its use: last visits of
+worktrees that are gone or were not visited within the retention period
+(config key retention, default 90d), audit log records older than that,
+expired completion cache entries, metadata of deleted branches, known
+repositories that are gone and leftovers of interrupted writes.
+Repositories and worktrees are not touched; see 'wt cleanup' and 'git gc'
+for those.
+
+Examples:
+  wt gc --dry-run   # Show what would be removed
+  wt gc`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		period := retention()
+		verb := "removed"
+		if gcDryRun {
+			verb = "would remove"
+		}
+		var removed int
+		var reclaimed int64
+		for _, task := range gcTasks {
+			result := task(period, gcDryRun)
+			removed += result.Removed
+			reclaimed += result.Bytes
+			if result.Removed == 0 {
+				continue
+			}
+			line := fmt.Sprintf("%s: %s %d %s", result.Name, verb, result.Removed, result.Unit)
+			if result.Bytes > 0 {
+				line += fmt.Sprintf(" (%s)", formatSize(result.Bytes))
+			}
+			fmt.Println(line)
+		}
+
+		if ciMode() {
+			fmt.Println(machineSummary("gc", "removed", removed, "bytes", reclaimed))
+			return nil
+		}
+		switch {
+		case removed == 0:
+			infof("Nothing to remove\n")
+		case gcDryRun:
+			infof("%d item(s) would be removed, %s\n", removed, formatSize(reclaimed))
+		default:
+			successf("Removed %d item(s), reclaimed %s", removed, formatSize(reclaimed))
+		}
+		return nil
+	},
+}
+
+func init() {
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "Show what would be removed without removing anything")
+	rootCmd.AddCommand(gcCmd)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGCVisits(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	recent, old, gone := filepath.Join(dir, "recent"), filepath.Join(dir, "old"), filepath.Join(dir, "gone")
+	for _, path := range []string{recent, old} {
+		if err := os.Mkdir(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state := loadState()
+	state.Visits[resolvePath(recent)] = time.Now().Add(-time.Hour)
+	state.Visits[resolvePath(old)] = time.Now().Add(-100 * 24 * time.Hour)
+	state.Visits[resolvePath(gone)] = time.Now()
+	if err := state.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := gcVisits(defaultRetention, true); result.Removed != 2 {
+		t.Errorf("dry run removed %d visits, want 2", result.Removed)
+	}
+	if n := len(loadState().Visits); n != 3 {
+		t.Fatalf("dry run changed the state: %d visits left, want 3", n)
+	}
+	if result := gcVisits(defaultRetention, false); result.Removed != 2 {
+		t.Errorf("gcVisits() removed %d visits, want 2", result.Removed)
+	}
+	visits := loadState().Visits
+	if _, ok := visits[resolvePath(recent)]; !ok || len(visits) != 1 {
+		t.Errorf("visits left = %v, want only %s", visits, recent)
+	}
+}
+
+func TestGCCompletionCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := filepath.Join(cacheDir(), "completion")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(`{"branches":[]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		when := time.Now().Add(-age)
+		if err := os.Chtimes(path, when, when); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	fresh := write("fresh-branches.json", time.Minute)
+	expired := write("expired-branches.json", 24*time.Hour)
+
+	result := gcCompletionCache(defaultRetention, false)
+	if result.Removed != 1 || result.Bytes == 0 {
+		t.Errorf("gcCompletionCache() = %+v, want one file removed", result)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("fresh cache entry was removed: %v", err)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("expired cache entry was kept")
+	}
+}
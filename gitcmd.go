@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultGitTimeout bounds every git command unless git-timeout or
+	// --git-timeout say otherwise: not at all, since a clone or fetch of a
+	// large repository can take any time.
+	defaultGitTimeout time.Duration = 0
+	// gitStopGrace is how long git gets to clean up after being asked to
+	// stop, e.g. to remove its lock files, before it is killed.
+	gitStopGrace = 10 * time.Second
+)
+
+var (
+	gitTimeoutFlag string
+	// gitTimeout bounds each git command wt runs; 0 means no limit.
+	gitTimeout = defaultGitTimeout
+)
+
+// parseGitTimeout parses the git-timeout setting: a Go duration such as
+// "90s" or "10m", or "0" for no limit.
+func parseGitTimeout(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid git timeout %q (use e.g. 90s or 10m, or 0 for no limit)", value)
+	}
+	return d, nil
+}
+
+// applyGitTimeoutFlag lets --git-timeout override the setting.
+func applyGitTimeoutFlag() error {
+	if gitTimeoutFlag == "" {
+		return nil
+	}
+	d, err := parseGitTimeout(gitTimeoutFlag)
+	if err != nil {
+		return err
+	}
+	gitTimeout = d
+	return nil
+}
+
+// timedCommand is a git command with the timer of its timeout, which is
+// stopped once the command has finished: Run, Output, CombinedOutput and
+// Wait release it, so loops such as 'status --watch' don't pile up timers.
+type timedCommand struct {
+	*exec.Cmd
+	cancel context.CancelFunc
+}
+
+func (c *timedCommand) Run() error {
+	defer c.cancel()
+	return c.Cmd.Run()
+}
+
+func (c *timedCommand) Output() ([]byte, error) {
+	defer c.cancel()
+	return c.Cmd.Output()
+}
+
+func (c *timedCommand) CombinedOutput() ([]byte, error) {
+	defer c.cancel()
+	return c.Cmd.CombinedOutput()
+}
+
+func (c *timedCommand) Wait() error {
+	defer c.cancel()
+	return c.Cmd.Wait()
+}
+
+// gitCommand returns a git command that is stopped when it runs longer than
+// gitTimeout, so a credential helper waiting for input or a remote that
+// never answers cannot hang wt.
+func gitCommand(args ...string) *timedCommand {
+	if gitTimeout <= 0 {
+		return &timedCommand{Cmd: exec.Command("git", args...), cancel: func() {}}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Cancel = func() error {
+		warnf("git %s did not finish within %s, stopping it (see --git-timeout)\n", strings.Join(args, " "), gitTimeout)
+		return stopProcessTree(cmd.Process)
+	}
+	cmd.WaitDelay = gitStopGrace
+	return &timedCommand{Cmd: cmd, cancel: cancel}
+}
+
+// stopProcessTree stops a git process along with what it started, such as
+// ssh or a credential helper, which would otherwise outlive it. On Unix they
+// get SIGTERM, on which git also removes its lock files; whatever has not
+// exited after gitStopGrace is killed by exec. Windows has no SIGTERM, so
+// there the whole tree is killed at once.
+func stopProcessTree(process *os.Process) error {
+	if runtime.GOOS == "windows" {
+		if exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(process.Pid)).Run() == nil {
+			return nil
+		}
+		return process.Kill()
+	}
+	for _, pid := range descendants(process.Pid) {
+		if child, err := os.FindProcess(pid); err == nil {
+			_ = child.Signal(syscall.SIGTERM)
+		}
+	}
+	return process.Signal(syscall.SIGTERM)
+}
+
+// descendants returns the processes pid started, directly or through
+// others, as listed by ps.
+func descendants(pid int) []int {
+	var output strings.Builder
+	ps := exec.Command("ps", "-A", "-o", "pid=,ppid=")
+	ps.Stdout = &output
+	if err := ps.Run(); err != nil {
+		return nil
+	}
+	var result []int
+	for _, p := range parseDescendants(output.String(), pid) {
+		// ps lists itself, as a child of wt.
+		if p != ps.Process.Pid {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// parseDescendants finds the descendants of pid in "pid ppid" lines.
+func parseDescendants(psOutput string, pid int) []int {
+	children := make(map[int][]int)
+	for _, line := range strings.Split(psOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		child, err1 := strconv.Atoi(fields[0])
+		parent, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			children[parent] = append(children[parent], child)
+		}
+	}
+	var result []int
+	queue := children[pid]
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		result = append(result, next)
+		queue = append(queue, children[next]...)
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseGitTimeout(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90s", 90 * time.Second, false},
+		{" 10m ", 10 * time.Minute, false},
+		{"0", 0, false},
+		{"5", 0, true},
+		{"-1m", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseGitTimeout(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseGitTimeout(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGitCommandTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test alias is a shell command")
+	}
+	original := gitTimeout
+	t.Cleanup(func() { gitTimeout = original })
+	gitTimeout = 200 * time.Millisecond
+
+	start := time.Now()
+	err := gitCommand("-c", "alias.hang=!sleep 30", "hang").Run()
+	if err == nil {
+		t.Fatal("a hanging git command finished without error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("git was stopped after %s, want about %s", elapsed, gitTimeout)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if pids := descendants(os.Getpid()); len(pids) != 0 {
+		// Only the hung alias' sleep could still be around.
+		t.Errorf("processes left behind after the timeout: %v", pids)
+	}
+
+	gitTimeout = 0
+	if err := gitCommand("--version").Run(); err != nil {
+		t.Errorf("gitCommand() without a timeout: %v", err)
+	}
+}
+
+func TestGitCommandReleasesTimer(t *testing.T) {
+	original := gitTimeout
+	t.Cleanup(func() { gitTimeout = original })
+	gitTimeout = time.Hour
+
+	cmd := gitCommand("--version")
+	released := false
+	cancel := cmd.cancel
+	cmd.cancel = func() {
+		released = true
+		cancel()
+	}
+	if _, err := cmd.Output(); err != nil {
+		t.Fatal(err)
+	}
+	if !released {
+		t.Error("the timer of a finished git command is still pending")
+	}
+}
+
+func TestParseDescendants(t *testing.T) {
+	ps := "  1     0\n 10     1\n 11    10\n 12    11\n 13     1\n 14    10\n"
+	if got := parseDescendants(ps, 10); fmt.Sprint(got) != "[11 14 12]" {
+		t.Errorf("parseDescendants(10) = %v, want [11 14 12]", got)
+	}
+	if got := parseDescendants(ps, 12); len(got) != 0 {
+		t.Errorf("parseDescendants(12) = %v, want none", got)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// gitConfigSetting is one git config key and value a rule sets.
+type gitConfigSetting struct {
+	Key   string
+	Value string
+}
+
+// gitConfigRule sets git config in new worktrees of matching repositories.
+type gitConfigRule struct {
+	// Match is a glob against "host/owner/name" or the repository name;
+	// empty matches every repository.
+	Match  string
+	Set    []gitConfigSetting
+	Source string
+	// Repo is set for rules of the repo file, which only apply with
+	// trust-repo: settings such as core.hooksPath, core.sshCommand or
+	// alias.* run commands.
+	Repo bool
+}
+
+// gitConfigKeyRegex accepts section.key and section.subsection.key.
+var gitConfigKeyRegex = regexp.MustCompile(`^[A-Za-z][-A-Za-z0-9]*(\..+)?\.[A-Za-z][-A-Za-z0-9]*$`)
+
+// readGitConfigRules parses the git_config section of a config file:
+//
+//	git_config:
+//	  - match: github.com/acme/*
+//	    set:
+//	      user.email: me@acme.com
+//
+// Syntax errors of the file itself are left to readConfigFile.
+func readGitConfigRules(file string) (rules []gitConfigRule, problems []string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil
+	}
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "git_config" {
+			continue
+		}
+		section := root.Content[i+1]
+		if section.Kind != yaml.SequenceNode {
+			return nil, []string{fmt.Sprintf("%s:%d: git_config must be a list of rules", file, section.Line)}
+		}
+		for _, item := range section.Content {
+			rule, problem := parseGitConfigRule(file, item)
+			if problem != "" {
+				problems = append(problems, problem)
+				continue
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules, problems
+}
+
+func parseGitConfigRule(file string, item *yaml.Node) (gitConfigRule, string) {
+	rule := gitConfigRule{Source: fmt.Sprintf("%s:%d", file, item.Line)}
+	if item.Kind != yaml.MappingNode {
+		return rule, fmt.Sprintf("%s: git_config rule must be a mapping with match and set", rule.Source)
+	}
+	for i := 0; i+1 < len(item.Content); i += 2 {
+		key, value := item.Content[i], item.Content[i+1]
+		switch key.Value {
+		case "match":
+			if value.Kind != yaml.ScalarNode {
+				return rule, fmt.Sprintf("%s:%d: match must be a string", file, value.Line)
+			}
+			if _, err := path.Match(value.Value, ""); err != nil {
+				return rule, fmt.Sprintf("%s:%d: invalid match pattern %q: %v", file, value.Line, value.Value, err)
+			}
+			rule.Match = value.Value
+		case "set":
+			if value.Kind != yaml.MappingNode {
+				return rule, fmt.Sprintf("%s:%d: set must map git config keys to values", file, value.Line)
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				name, setting := value.Content[j], value.Content[j+1]
+				if !gitConfigKeyRegex.MatchString(name.Value) {
+					return rule, fmt.Sprintf("%s:%d: invalid git config key %q (want section.key)", file, name.Line, name.Value)
+				}
+				if setting.Kind != yaml.ScalarNode {
+					return rule, fmt.Sprintf("%s:%d: %s must be a string", file, setting.Line, name.Value)
+				}
+				rule.Set = append(rule.Set, gitConfigSetting{Key: name.Value, Value: setting.Value})
+			}
+		default:
+			return rule, fmt.Sprintf("%s:%d: unknown git_config rule key %q (known keys: match, set)", file, key.Line, key.Value)
+		}
+	}
+	if len(rule.Set) == 0 {
+		return rule, fmt.Sprintf("%s: git_config rule sets nothing", rule.Source)
+	}
+	return rule, ""
+}
+
+// repoIdentity is what git_config rules match against, e.g.
+// "github.com/acme/api"; parts that are unknown are left out.
+func repoIdentity(info repoInfo) string {
+	var parts []string
+	for _, part := range []string{info.Host, info.Owner, info.Name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (r gitConfigRule) matches(info repoInfo) bool {
+	if r.Match == "" {
+		return true
+	}
+	if ok, _ := path.Match(r.Match, repoIdentity(info)); ok {
+		return true
+	}
+	ok, _ := path.Match(r.Match, info.Name)
+	return ok
+}
+
+// gitConfigFor returns the settings of all rules matching the repository,
+// in order; later rules win for the same key.
+func gitConfigFor(rules []gitConfigRule, info repoInfo) []gitConfigSetting {
+	var settings []gitConfigSetting
+	index := make(map[string]int)
+	for _, rule := range rules {
+		if !rule.matches(info) {
+			continue
+		}
+		for _, s := range rule.Set {
+			if i, ok := index[s.Key]; ok {
+				settings[i] = s
+				continue
+			}
+			index[s.Key] = len(settings)
+			settings = append(settings, s)
+		}
+	}
+	return settings
+}
+
+// enableWorktreeConfig turns on extensions.worktreeConfig so 'git config
+// --worktree' writes to the worktree instead of the shared repository
+// config. As git-worktree(1) requires, core.bare=true and core.worktree
+// move from the shared config to the main worktree's config.worktree first.
+func enableWorktreeConfig() error {
+	output, _ := gitCommand("config", "--bool", "extensions.worktreeConfig").Output()
+	if strings.TrimSpace(string(output)) == "true" {
+		return nil
+	}
+
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return err
+	}
+	shared := filepath.Join(commonDir, "config")
+	for _, key := range []string{"core.bare", "core.worktree"} {
+		value, err := gitCommand("config", "--file", shared, key).Output()
+		if err != nil || (key == "core.bare" && strings.TrimSpace(string(value)) != "true") {
+			continue
+		}
+		if err := gitCommand("config", "--file", filepath.Join(commonDir, "config.worktree"), key, strings.TrimSpace(string(value))).Run(); err != nil {
+			return fmt.Errorf("failed to move %s to config.worktree: %w", key, err)
+		}
+		if err := gitCommand("config", "--file", shared, "--unset", key).Run(); err != nil {
+			return fmt.Errorf("failed to move %s to config.worktree: %w", key, err)
+		}
+	}
+	if err := gitCommand("config", "--file", shared, "extensions.worktreeConfig", "true").Run(); err != nil {
+		return fmt.Errorf("failed to enable extensions.worktreeConfig: %w", err)
+	}
+	return nil
+}
+
+// trustedGitConfigRules returns the git_config rules of cfg that may
+// apply: without trust-repo those of the repo file are left out, with a
+// warning.
+func trustedGitConfigRules(cfg worktreeConfig) []gitConfigRule {
+	if cfg.trustsRepo() {
+		return cfg.GitConfig
+	}
+	var rules []gitConfigRule
+	skipped := 0
+	for _, rule := range cfg.GitConfig {
+		if rule.Repo {
+			skipped++
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if skipped > 0 {
+		warnf("warning: ignoring %d git_config rule(s) of the repo file; let repositories set git config with 'wt config set trust-repo true'\n", skipped)
+	}
+	return rules
+}
+
+// applyWorktreeGitConfig writes the git_config settings matching the
+// repository into the new worktree at dir. Like hooks, failures are
+// reported but don't fail the command: the worktree exists already.
+func applyWorktreeGitConfig(info repoInfo, dir string) {
+	settings := gitConfigFor(trustedGitConfigRules(loadConfig()), info)
+	if len(settings) == 0 {
+		return
+	}
+	if err := enableWorktreeConfig(); err != nil {
+		warnf("warning: git_config not applied: %v\n", err)
+		return
+	}
+
+	var applied []string
+	for _, s := range settings {
+		output, err := gitCommand("-C", dir, "config", "--worktree", s.Key, s.Value).CombinedOutput()
+		if err != nil {
+			warnf("warning: failed to set %s: %s\n", s.Key, strings.TrimSpace(string(output)))
+			continue
+		}
+		applied = append(applied, s.Key)
+	}
+	if len(applied) > 0 {
+		infof("Applied git config: %s\n", strings.Join(applied, ", "))
+	}
+}
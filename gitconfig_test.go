@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadGitConfigRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `root: /srv/worktrees
+git_config:
+  - set:
+      core.hooksPath: ~/.githooks
+  - match: github.com/acme/*
+    set:
+      user.email: me@acme.com
+      commit.gpgsign: "true"
+  - match: oss-*
+    set:
+      email: missing-section
+  - bogus: 1
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, problems := readGitConfigRules(path)
+	if len(rules) != 2 {
+		t.Fatalf("readGitConfigRules() rules = %+v, want 2", rules)
+	}
+	if rules[1].Match != "github.com/acme/*" || len(rules[1].Set) != 2 || rules[1].Set[1] != (gitConfigSetting{"commit.gpgsign", "true"}) {
+		t.Errorf("rule = %+v", rules[1])
+	}
+	if len(problems) != 2 || !strings.Contains(problems[0], `invalid git config key "email"`) || !strings.Contains(problems[1], `unknown git_config rule key "bogus"`) {
+		t.Errorf("problems = %v", problems)
+	}
+
+	// The section is not a plain setting.
+	if _, _, problems := readConfigFile(path); len(problems) != 0 {
+		t.Errorf("readConfigFile() problems = %v, want none", problems)
+	}
+}
+
+func TestGitConfigFor(t *testing.T) {
+	rules := []gitConfigRule{
+		{Set: []gitConfigSetting{{"user.email", "me@example.com"}, {"core.hooksPath", "~/.githooks"}}},
+		{Match: "github.com/acme/*", Set: []gitConfigSetting{{"user.email", "me@acme.com"}}},
+		{Match: "scratch", Set: []gitConfigSetting{{"commit.gpgsign", "false"}}},
+	}
+
+	acme := gitConfigFor(rules, repoInfo{Host: "github.com", Owner: "acme", Name: "api"})
+	want := []gitConfigSetting{{"user.email", "me@acme.com"}, {"core.hooksPath", "~/.githooks"}}
+	if len(acme) != len(want) || acme[0] != want[0] || acme[1] != want[1] {
+		t.Errorf("gitConfigFor(acme) = %v, want %v", acme, want)
+	}
+
+	local := gitConfigFor(rules, repoInfo{Name: "scratch"})
+	if len(local) != 3 || local[0].Value != "me@example.com" || local[2].Key != "commit.gpgsign" {
+		t.Errorf("gitConfigFor(scratch) = %v", local)
+	}
+}
+
+func TestApplyWorktreeGitConfig(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	if err := os.MkdirAll(filepath.Join(configHome, "wt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := "git_config:\n  - match: repo\n    set:\n      user.email: work@example.com\n"
+	if err := os.WriteFile(filepath.Join(configHome, "wt", "config.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	worktree := filepath.Join(tmpDir, "feature")
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "feature", worktree)
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	applyWorktreeGitConfig(repoInfo{Main: repoDir, Name: "repo"}, worktree)
+
+	email := func(dir string) string {
+		output, _ := exec.Command("git", "-C", dir, "config", "user.email").Output()
+		return strings.TrimSpace(string(output))
+	}
+	if got := email(worktree); got != "work@example.com" {
+		t.Errorf("worktree user.email = %q, want work@example.com", got)
+	}
+	if got := email(repoDir); got != "test@example.com" {
+		t.Errorf("main clone user.email = %q, want it unchanged", got)
+	}
+}
+
+func TestTrustedGitConfigRules(t *testing.T) {
+	cfg := worktreeConfig{
+		Values: map[string]configValue{"trust-repo": {Value: "false"}},
+		GitConfig: []gitConfigRule{
+			{Set: []gitConfigSetting{{Key: "user.email", Value: "me@example.com"}}},
+			{Set: []gitConfigSetting{{Key: "core.hooksPath", Value: "hooks"}}, Repo: true},
+		},
+	}
+	if got := gitConfigFor(trustedGitConfigRules(cfg), repoInfo{Name: "repo"}); len(got) != 1 || got[0].Key != "user.email" {
+		t.Errorf("settings without trust = %+v", got)
+	}
+	cfg.Values["trust-repo"] = configValue{Value: "true"}
+	if got := trustedGitConfigRules(cfg); len(got) != 2 {
+		t.Errorf("trustedGitConfigRules() with trust = %+v", got)
+	}
+}
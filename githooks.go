@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// hookManager is a tool that keeps a repository's git hooks in the
+// repository and installs them with a command. Markers are the files that
+// show a repository uses it.
+type hookManager struct {
+	Name    string
+	Markers []string
+	Install string
+}
+
+var hookManagers = []hookManager{
+	{Name: "husky", Markers: []string{".husky"}, Install: "npx husky"},
+	{Name: "lefthook", Markers: []string{"lefthook.yml", ".lefthook.yml", "lefthook.yaml", ".lefthook.yaml"}, Install: "lefthook install"},
+	{Name: "pre-commit", Markers: []string{".pre-commit-config.yaml"}, Install: "pre-commit install"},
+}
+
+// detectHookManager returns the hook manager the worktree at path uses, if
+// any.
+func detectHookManager(path string) (hookManager, bool) {
+	for _, m := range hookManagers {
+		for _, marker := range m.Markers {
+			if fileExists(filepath.Join(path, marker)) {
+				return m, true
+			}
+		}
+	}
+	return hookManager{}, false
+}
+
+// gitHooksDir returns the directory git runs the hooks of the worktree at
+// path from, and whether core.hooksPath chose it. A relative core.hooksPath
+// (husky's .husky/_) is relative to each worktree, so unlike .git/hooks it
+// is not shared between them.
+func gitHooksDir(path string) (string, bool, error) {
+	output, err := gitCommand("-C", path, "rev-parse", "--path-format=absolute", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to find the git hooks of %s", path)
+	}
+	hooksPath, _ := gitCommand("-C", path, "config", "core.hooksPath").Output()
+	return filepath.Clean(strings.TrimSpace(string(output))), strings.TrimSpace(string(hooksPath)) != "", nil
+}
+
+// installedGitHooks returns the names of the hooks git would run from dir:
+// executables that are not samples.
+func installedGitHooks(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var hooks []string
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".sample") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || (runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0) {
+			continue
+		}
+		hooks = append(hooks, e.Name())
+	}
+	return hooks
+}
+
+// ensureGitHooks gives the new worktree at path working git hooks when
+// core.hooksPath points inside each worktree, where hook managers generate
+// them in a directory git ignores: it runs the hooks-install command, or
+// else copies the hooks of the main worktree. Without either it warns, as
+// otherwise commit hooks silently don't run.
+func ensureGitHooks(info repoInfo, path string) {
+	dir, custom, err := gitHooksDir(path)
+	if err != nil || !custom || len(installedGitHooks(dir)) > 0 {
+		return
+	}
+
+	if command := strings.TrimSpace(loadConfig().get("hooks-install")); command != "" {
+		infof("Installing git hooks: %s\n", command)
+		cmd := shellCommand(command)
+		cmd.Dir = path
+		cmd.Env = hookEnv("hooks-install", hookContext{Repo: info, Path: path})
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			warnf("warning: hooks-install %q failed: %v\n", command, err)
+		}
+	} else if src, _, err := gitHooksDir(info.Main); err == nil && resolvePath(src) != resolvePath(dir) && len(installedGitHooks(src)) > 0 {
+		if err := copyTree(src, dir); err != nil {
+			warnf("warning: failed to copy the git hooks of the main worktree: %v\n", err)
+		} else {
+			infof("Copied the git hooks of the main worktree to %s\n", dir)
+		}
+	}
+
+	if len(installedGitHooks(dir)) == 0 {
+		install := "install them in the worktree"
+		if m, ok := detectHookManager(path); ok {
+			install = fmt.Sprintf("run '%s' in the worktree", m.Install)
+		}
+		warnf("warning: core.hooksPath %s has no hooks in the new worktree, so commit hooks will not run; %s, or set hooks-install\n", dir, install)
+	}
+}
+
+// checkWorktreeHooks flags worktrees of the current repository in which
+// commit hooks silently don't run: core.hooksPath points to a directory
+// without hooks, or a hook manager is set up but never installed.
+func checkWorktreeHooks() []doctorResult {
+	const name = "git hooks"
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return []doctorResult{{Name: name, Status: doctorSkip, Message: "not in a git repository"}}
+	}
+
+	var results []doctorResult
+	warned := map[string]bool{}
+	managed := false
+	for _, e := range entries {
+		if e.Bare || e.Prunable {
+			continue
+		}
+		dir, custom, err := gitHooksDir(e.Path)
+		if err != nil {
+			continue
+		}
+		m, hasManager := detectHookManager(e.Path)
+		managed = managed || custom || hasManager
+		if len(installedGitHooks(dir)) > 0 {
+			continue
+		}
+		switch {
+		case custom:
+			hint := "run 'wt config set hooks-install <command>' so new worktrees install them"
+			if hasManager {
+				hint = fmt.Sprintf("run '%s' in %s; %s", m.Install, e.Path, hint)
+			}
+			results = append(results, doctorResult{Name: name, Status: doctorWarn,
+				Message: fmt.Sprintf("%s: core.hooksPath %s has no hooks, commit hooks don't run", e.Path, dir), Hint: hint})
+		case hasManager && !warned[dir]:
+			// .git/hooks is shared: once is enough.
+			warned[dir] = true
+			results = append(results, doctorResult{Name: name, Status: doctorWarn,
+				Message: fmt.Sprintf("%s uses %s, but no git hooks are installed", e.Path, m.Name), Hint: fmt.Sprintf("run '%s' in %s", m.Install, e.Path)})
+		}
+	}
+	if len(results) > 0 {
+		return results
+	}
+	if !managed {
+		return []doctorResult{{Name: name, Status: doctorOK, Message: "repository uses neither core.hooksPath nor a hook manager"}}
+	}
+	return []doctorResult{{Name: name, Status: doctorOK, Message: "hooks are installed in every worktree"}}
+}
+
+func init() {
+	doctorChecks = append(doctorChecks, checkWorktreeHooks)
+}
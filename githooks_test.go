@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestInstalledGitHooks(t *testing.T) {
+	dir := t.TempDir()
+	for name, mode := range map[string]os.FileMode{"pre-commit": 0o755, "pre-push.sample": 0o755, "commit-msg": 0o644} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "pre-commit"
+	if runtime.GOOS == "windows" {
+		want = "commit-msg,pre-commit"
+	}
+	if got := strings.Join(installedGitHooks(dir), ","); got != want {
+		t.Errorf("installedGitHooks() = %s, want %s", got, want)
+	}
+}
+
+func TestEnsureGitHooks(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	chdirFamily(t, repoDir)
+
+	// husky's layout: hooks generated in an ignored directory of the worktree
+	runGitCommand(t, repoDir, "config", "core.hooksPath", ".husky/_")
+	if err := os.MkdirAll(filepath.Join(repoDir, ".husky", "_"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, ".husky", "_", "pre-commit"), []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if results := checkWorktreeHooks(); len(results) != 1 || results[0].Status != doctorOK {
+		t.Errorf("main worktree with hooks = %+v", results)
+	}
+
+	wtPath := filepath.Join(tmpDir, "feature")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "feature", wtPath)
+	snapshot.invalidate()
+	results := checkWorktreeHooks()
+	if len(results) != 1 || results[0].Status != doctorWarn || !strings.Contains(results[0].Message, wtPath) {
+		t.Errorf("worktree without hooks = %+v", results)
+	}
+
+	ensureGitHooks(repoInfo{Main: repoDir}, wtPath)
+	if got := installedGitHooks(filepath.Join(wtPath, ".husky", "_")); len(got) != 1 || got[0] != "pre-commit" {
+		t.Errorf("hooks of the new worktree = %v, want the main worktree's pre-commit", got)
+	}
+	if results := checkWorktreeHooks(); results[0].Status != doctorOK {
+		t.Errorf("after ensureGitHooks = %+v", results)
+	}
+}
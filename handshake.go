@@ -0,0 +1,25 @@
+package main
+
+import "fmt"
+
+// shellenvProtocol is the version of what the shell integration relies on
+// in the binary: the navigation marker, WT_SESSION, __worktree-env and
+// __branches. The integration embeds the number it was generated with and
+// compares it with 'wt shellenv --version-check', so raise it whenever the
+// integration starts using something older binaries lack.
+const shellenvProtocol = 1
+
+var shellenvVersionCheck bool
+
+// isVersionCheck reports whether wt runs only for the handshake of the shell
+// integration. The integration runs it once per shell, so it skips loading
+// the configuration.
+func isVersionCheck(args []string) bool {
+	return len(args) == 2 && args[0] == "shellenv" && args[1] == "--version-check"
+}
+
+// printVersionCheck answers the handshake: the protocol, then the version
+// for messages.
+func printVersionCheck() {
+	fmt.Println(shellenvProtocol, version)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestIsVersionCheck(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"shellenv", "--version-check"}, true},
+		{[]string{"shellenv"}, false},
+		{[]string{"shellenv", "bash", "--version-check"}, false},
+		{[]string{"list", "--version-check"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isVersionCheck(tt.args); got != tt.want {
+			t.Errorf("isVersionCheck(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestShellenvEmbedsProtocol(t *testing.T) {
+	for shell, want := range map[string]string{
+		"bash":       fmt.Sprintf("__wt_protocol=%d\n", shellenvProtocol),
+		"powershell": fmt.Sprintf("$global:WtProtocol = %d\n", shellenvProtocol),
+	} {
+		output, err := exec.Command("go", "run", ".", "shellenv", shell).Output()
+		if err != nil {
+			t.Fatalf("wt shellenv %s: %v", shell, err)
+		}
+		if !strings.Contains(string(output), want) {
+			t.Errorf("wt shellenv %s does not contain %q", shell, want)
+		}
+	}
+
+	output, err := exec.Command("go", "run", ".", "shellenv", "--version-check").Output()
+	if err != nil {
+		t.Fatalf("wt shellenv --version-check: %v", err)
+	}
+	if got := strings.Fields(string(output)); len(got) != 2 || got[0] != fmt.Sprint(shellenvProtocol) {
+		t.Errorf("wt shellenv --version-check = %q, want the protocol and the version", output)
+	}
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// supportedHooks lists the hook names wt knows how to fire.
+var supportedHooks = []string{"post-checkout", "pre-remove", "post-remove"}
+
+// hookScript is one executable found for a hook.
+type hookScript struct {
+	Hook  string
+	Scope string // "global" or "repo"
+	Path  string
+}
+
+// maxHookRuns is how many hook runs are remembered per worktree.
+const maxHookRuns = 20
+
+// hookRun records that a hook script ran for a worktree, for 'wt info'.
+type hookRun struct {
+	Hook   string    `json:"hook"`
+	Scope  string    `json:"scope"`
+	Script string    `json:"script"`
+	At     time.Time `json:"at"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// recordHookRun remembers a hook run for the worktree at path.
+func recordHookRun(path string, run hookRun) {
+	state := loadState()
+	key := resolvePath(path)
+	runs := append(state.HookRuns[key], run)
+	if len(runs) > maxHookRuns {
+		runs = runs[len(runs)-maxHookRuns:]
+	}
+	state.HookRuns[key] = runs
+	_ = state.save()
+}
+
+// gcHookRuns forgets the hook runs of worktrees that no longer exist.
+func gcHookRuns(_ time.Duration, dryRun bool) gcResult {
+	result := gcResult{Name: "hook runs", Unit: "worktrees"}
+	state := loadState()
+	for path := range state.HookRuns {
+		if _, err := os.Stat(longPath(path)); os.IsNotExist(err) {
+			delete(state.HookRuns, path)
+			result.Removed++
+		}
+	}
+	if result.Removed > 0 && !dryRun {
+		if err := state.save(); err != nil {
+			warnf("warning: failed to save state: %v\n", err)
+		}
+	}
+	return result
+}
+
+// hookContext describes the worktree a hook runs for.
+type hookContext struct {
+	Repo   repoInfo
+	Branch string
+	Path   string
+}
+
+// hookDirs returns the directories searched for hooks, in execution order:
+// global hooks first, then hooks committed to the repository, which only
+// run with trust-repo.
+func hookDirs(info repoInfo) []hookScript {
+	dirs := []hookScript{{Scope: "global", Path: filepath.Join(filepath.Dir(globalConfigPath()), "hooks")}}
+	if info.Main != "" {
+		dirs = append(dirs, hookScript{Scope: "repo", Path: filepath.Join(info.Main, ".wt", "hooks")})
+	}
+	return dirs
+}
+
+// findHooks returns the scripts configured for hook. On Windows a script may
+// carry an executable extension (post-checkout.ps1, post-checkout.cmd, ...).
+func findHooks(info repoInfo, hook string) []hookScript {
+	candidates := []string{hook}
+	if runtime.GOOS == "windows" {
+		for _, ext := range []string{".exe", ".cmd", ".bat", ".ps1"} {
+			candidates = append(candidates, hook+ext)
+		}
+	}
+
+	var scripts []hookScript
+	for _, dir := range hookDirs(info) {
+		for _, name := range candidates {
+			path := filepath.Join(dir.Path, name)
+			if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+				scripts = append(scripts, hookScript{Hook: hook, Scope: dir.Scope, Path: path})
+				break
+			}
+		}
+	}
+	return scripts
+}
+
+// validateHook returns the problems that would prevent script from running.
+func validateHook(script hookScript) []string {
+	var problems []string
+	stat, err := os.Stat(script.Path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if !stat.Mode().IsRegular() {
+		return []string{"not a regular file"}
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	if stat.Mode().Perm()&0o111 == 0 {
+		problems = append(problems, fmt.Sprintf("not executable (run: chmod +x %s)", script.Path))
+	}
+
+	f, err := os.Open(script.Path)
+	if err != nil {
+		return append(problems, err.Error())
+	}
+	defer f.Close()
+	head := make([]byte, 4)
+	n, _ := f.Read(head)
+	head = head[:n]
+	if !bytes.HasPrefix(head, []byte("#!")) && !bytes.HasPrefix(head, []byte("\x7fELF")) && !isMachO(head) {
+		problems = append(problems, "missing shebang line (e.g. #!/bin/sh)")
+	}
+	return problems
+}
+
+func isMachO(head []byte) bool {
+	for _, magic := range [][]byte{{0xcf, 0xfa, 0xed, 0xfe}, {0xce, 0xfa, 0xed, 0xfe}, {0xca, 0xfe, 0xba, 0xbe}} {
+		if bytes.Equal(head, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// hookCommand builds the command that runs script, going through an
+// interpreter for script types Windows cannot execute directly.
+func hookCommand(script hookScript) *exec.Cmd {
+	if runtime.GOOS == "windows" && strings.EqualFold(filepath.Ext(script.Path), ".ps1") {
+		return exec.Command("powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", script.Path)
+	}
+	return exec.Command(script.Path)
+}
+
+func hookEnv(hook string, ctx hookContext) []string {
+	env := append(os.Environ(),
+		"WT_HOOK="+hook,
+		"WT_BRANCH="+ctx.Branch,
+		"WT_PATH="+ctx.Path,
+		"WT_REPO="+ctx.Repo.Name,
+		"WT_MAIN="+ctx.Repo.Main,
+	)
+	if scratchDir != "" {
+		env = append(env, "WT_SCRATCH="+filepath.Join(ctx.Path, filepath.FromSlash(scratchDir)))
+	}
+	return env
+}
+
+// runHooks runs every script configured for hook inside the worktree. Hook
+// output goes to stderr so it never mixes with results on stdout.
+func runHooks(hook string, ctx hookContext) error {
+	trusted := loadConfig().trustsRepo()
+	for _, script := range findHooks(ctx.Repo, hook) {
+		if script.Scope == "repo" && !trusted {
+			warnf("warning: skipping repo hook %s; let repositories run theirs with 'wt config set trust-repo true'\n", script.Path)
+			continue
+		}
+		if problems := validateHook(script); len(problems) > 0 {
+			return fmt.Errorf("%s hook %s cannot run: %s", script.Scope, script.Path, strings.Join(problems, "; "))
+		}
+
+		infof("Running %s hook: %s\n", hook, script.Path)
+		cmd := hookCommand(script)
+		cmd.Dir = ctx.Path
+		if hook == "post-remove" {
+			// The worktree is gone by now.
+			cmd.Dir = ctx.Repo.Main
+		}
+		cmd.Env = hookEnv(hook, ctx)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		err := cmd.Run()
+		if !strings.HasSuffix(hook, "-remove") {
+			// Remembered for 'wt info'; a removed worktree has no use for it.
+			run := hookRun{Hook: hook, Scope: script.Scope, Script: script.Path, At: time.Now()}
+			if err != nil {
+				run.Error = err.Error()
+			}
+			recordHookRun(ctx.Path, run)
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook %s failed: %w", script.Scope, script.Path, err)
+		}
+	}
+	return nil
+}
+
+// runPostCheckoutHooks fires post-checkout after a worktree was created,
+// followed by the setup steps of the config and ensureGitHooks, and records
+// the creation in the audit log. The worktree exists at this point, so a
+// failing hook or step is reported but does not fail the command.
+func runPostCheckoutHooks(info repoInfo, branch, path string) {
+	recordAudit(branch, path, "", nil)
+	// Only commands that create worktrees write these, not list, status
+	// or completion.
+	registerRepo()
+	pinRepoName(info)
+	ctx := hookContext{Repo: info, Branch: branch, Path: path}
+	if err := runHooks("post-checkout", ctx); err != nil {
+		warnf("warning: %v\n", err)
+	}
+	if steps := trustedSetupSteps(loadConfig()); len(steps) > 0 {
+		if err := runSetupSteps(steps, setupContext{hookContext: ctx, Base: getDefaultBase()}); err != nil {
+			warnf("warning: %v\n", err)
+		}
+	}
+	// After the setup steps, which may install them (npm ci runs husky).
+	ensureGitHooks(info, path)
+}
+
+// runPreRemoveHooks fires pre-remove before a worktree is removed. A failing
+// hook stops the removal, unless force is set, in which case it is only
+// reported.
+func runPreRemoveHooks(info repoInfo, branch, path string, force bool) error {
+	err := runHooks("pre-remove", hookContext{Repo: info, Branch: branch, Path: path})
+	switch {
+	case err == nil:
+		return nil
+	case force:
+		warnf("warning: %v (removing anyway, --force)\n", err)
+		return nil
+	}
+	return fmt.Errorf("%w; %s was not removed (use --force to remove it anyway)", err, path)
+}
+
+// runPostRemoveHooks fires post-remove after a worktree was removed, which it
+// also records in the audit log. It runs in the main worktree; a failing hook
+// is reported but does not fail the command.
+func runPostRemoveHooks(info repoInfo, branch, path string) {
+	recordAudit(branch, path, "", nil)
+	if err := runHooks("post-remove", hookContext{Repo: info, Branch: branch, Path: path}); err != nil {
+		warnf("warning: %v\n", err)
+	}
+}
+
+func isSupportedHook(hook string) bool {
+	for _, h := range supportedHooks {
+		if h == hook {
+			return true
+		}
+	}
+	return false
+}
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Inspect, run and validate hooks",
+	Long: `Inspect, run and validate wt hooks.
+
+Hooks are executables named after the event they handle, looked up in:
+  global: hooks/<hook> next to the global config file
+          (e.g. ~/.config/wt/hooks/post-checkout)
+  repo:   <main worktree>/.wt/hooks/<hook>
+
+Global hooks run before repo hooks. Repo hooks come with every clone and
+branch, so they only run with 'wt config set trust-repo true'. Hooks run
+inside the worktree with
+WT_HOOK, WT_BRANCH, WT_PATH, WT_REPO and WT_MAIN set.
+
+Supported hooks:
+  post-checkout  after checkout/create/pr/mr created a worktree
+  pre-remove     before remove/cleanup removes a worktree, e.g. to stop
+                 services bound to its path; failing stops the removal
+                 (wt remove --force removes anyway)
+  post-remove    after remove/cleanup removed a worktree; runs in the main
+                 worktree, with WT_PATH set to the removed path`,
+}
+
+var hooksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show configured hooks",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info, _ := getRepoInfo()
+		cfg := loadConfig()
+		for _, dir := range hookDirs(info) {
+			fmt.Printf("%-6s %s\n", dir.Scope, dir.Path)
+		}
+		fmt.Println()
+		for _, hook := range supportedHooks {
+			scripts := findHooks(info, hook)
+			if len(scripts) == 0 {
+				fmt.Printf("%s: (none)\n", hook)
+				continue
+			}
+			fmt.Printf("%s:\n", hook)
+			for _, script := range scripts {
+				status := "ok"
+				if problems := validateHook(script); len(problems) > 0 {
+					status = strings.Join(problems, "; ")
+				}
+				if script.Scope == "repo" && !cfg.trustsRepo() {
+					status += ", skipped: trust-repo is off"
+				}
+				fmt.Printf("  [%s] %s (%s)\n", script.Scope, script.Path, status)
+			}
+		}
+		if len(cfg.Setup) > 0 {
+			fmt.Println("setup (config, after post-checkout):")
+			for _, step := range cfg.Setup {
+				when := ""
+				if len(step.When) > 0 {
+					when = " when " + strings.Join(step.When, ", ")
+				}
+				if step.Repo && !cfg.trustsRepo() {
+					when += ", skipped: trust-repo is off"
+				}
+				fmt.Printf("  %s%s (%s)\n", step.Run, when, step.Source)
+			}
+		}
+		return nil
+	},
+}
+
+var hooksRunCmd = &cobra.Command{
+	Use:   "run <hook> [branch]",
+	Short: "Run a hook manually against a worktree (default: current)",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hook := args[0]
+		if !isSupportedHook(hook) {
+			return fmt.Errorf("unknown hook %q (supported: %s)", hook, strings.Join(supportedHooks, ", "))
+		}
+		info, err := getRepoInfo()
+		if err != nil {
+			return err
+		}
+
+		ctx := hookContext{Repo: info}
+		if len(args) > 1 {
+			path, exists := worktreeExists(args[1])
+			if !exists {
+				return fmt.Errorf("no worktree found for branch: %s", args[1])
+			}
+			ctx.Branch, ctx.Path = args[1], path
+		} else {
+			output, err := gitCommand("rev-parse", "--show-toplevel").Output()
+			if err != nil {
+				return fmt.Errorf("not inside a worktree; pass a branch")
+			}
+			ctx.Path = strings.TrimSpace(string(output))
+			if output, err := gitCommand("branch", "--show-current").Output(); err == nil {
+				ctx.Branch = strings.TrimSpace(string(output))
+			}
+		}
+
+		if len(findHooks(info, hook)) == 0 {
+			return fmt.Errorf("no %s hook configured (see 'wt hooks list')", hook)
+		}
+		if err := runHooks(hook, ctx); err != nil {
+			return err
+		}
+		successf("Ran %s hook for %s", hook, ctx.Path)
+		return nil
+	},
+}
+
+var hooksTestCmd = &cobra.Command{
+	Use:   "test [hook]",
+	Short: "Check that hook scripts exist and are executable",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hooks := supportedHooks
+		if len(args) > 0 {
+			if !isSupportedHook(args[0]) {
+				return fmt.Errorf("unknown hook %q (supported: %s)", args[0], strings.Join(supportedHooks, ", "))
+			}
+			hooks = args[:1]
+		}
+
+		info, _ := getRepoInfo()
+		trusted := loadConfig().trustsRepo()
+		failures := 0
+		for _, hook := range hooks {
+			for _, script := range findHooks(info, hook) {
+				if problems := validateHook(script); len(problems) > 0 {
+					fmt.Printf("FAIL %s [%s] %s\n", hook, script.Scope, script.Path)
+					for _, p := range problems {
+						fmt.Printf("     %s\n", p)
+					}
+					failures++
+					continue
+				}
+				skipped := ""
+				if script.Scope == "repo" && !trusted {
+					skipped = " (skipped: trust-repo is off)"
+				}
+				fmt.Printf("ok   %s [%s] %s%s\n", hook, script.Scope, script.Path, skipped)
+			}
+		}
+		if failures > 0 {
+			return fmt.Errorf("%d hook(s) cannot run", failures)
+		}
+		return nil
+	},
+}
+
+func init() {
+	hooksCmd.AddCommand(hooksListCmd)
+	hooksCmd.AddCommand(hooksRunCmd)
+	hooksCmd.AddCommand(hooksTestCmd)
+	rootCmd.AddCommand(hooksCmd)
+	gcTasks = append(gcTasks, gcHookRuns)
+}
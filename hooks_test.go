@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writeHook(t *testing.T, dir, name, content string, mode os.FileMode) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create hook dir: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatalf("failed to write hook: %v", err)
+	}
+	return path
+}
+
+// trustRepos writes a global config under configHome that lets repo hooks
+// run.
+func trustRepos(t *testing.T, configHome string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(configHome, "wt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configHome, "wt", "config.yaml"), []byte("trust-repo: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindHooksOrder(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	repoDir := t.TempDir()
+
+	writeHook(t, filepath.Join(repoDir, ".wt", "hooks"), "post-checkout", "#!/bin/sh\n", 0o755)
+	writeHook(t, filepath.Join(configHome, "wt", "hooks"), "post-checkout", "#!/bin/sh\n", 0o755)
+
+	scripts := findHooks(repoInfo{Main: repoDir}, "post-checkout")
+	if len(scripts) != 2 {
+		t.Fatalf("findHooks() found %d scripts, want 2", len(scripts))
+	}
+	if scripts[0].Scope != "global" || scripts[1].Scope != "repo" {
+		t.Errorf("findHooks() order = %s, %s; want global, repo", scripts[0].Scope, scripts[1].Scope)
+	}
+}
+
+func TestValidateHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("executable bits and shebangs are not checked on Windows")
+	}
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+		problem string
+	}{
+		{"ok", "#!/bin/sh\necho hi\n", 0o755, ""},
+		{"not-executable", "#!/bin/sh\n", 0o644, "not executable"},
+		{"no-shebang", "echo hi\n", 0o755, "missing shebang"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeHook(t, dir, tt.name, tt.content, tt.mode)
+			problems := validateHook(hookScript{Path: path})
+			if tt.problem == "" {
+				if len(problems) != 0 {
+					t.Errorf("validateHook() = %v, want no problems", problems)
+				}
+				return
+			}
+			if !strings.Contains(strings.Join(problems, "; "), tt.problem) {
+				t.Errorf("validateHook() = %v, want %q", problems, tt.problem)
+			}
+		})
+	}
+}
+
+func TestRunHooksEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test hook is a shell script")
+	}
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	trustRepos(t, configHome)
+	repoDir := t.TempDir()
+	worktreeDir := t.TempDir()
+	outFile := filepath.Join(t.TempDir(), "env.txt")
+
+	writeHook(t, filepath.Join(repoDir, ".wt", "hooks"), "post-checkout",
+		"#!/bin/sh\necho \"$WT_HOOK $WT_BRANCH $WT_REPO $(pwd)\" > "+outFile+"\n", 0o755)
+
+	ctx := hookContext{Repo: repoInfo{Main: repoDir, Name: "myrepo"}, Branch: "feature", Path: worktreeDir}
+	if err := runHooks("post-checkout", ctx); err != nil {
+		t.Fatalf("runHooks() error: %v", err)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	resolved, _ := filepath.EvalSymlinks(worktreeDir)
+	if want := "post-checkout feature myrepo " + resolved; strings.TrimSpace(string(data)) != want {
+		t.Errorf("hook saw %q, want %q", strings.TrimSpace(string(data)), want)
+	}
+}
+
+func TestRunHooksFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test hook is a shell script")
+	}
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	trustRepos(t, configHome)
+	repoDir := t.TempDir()
+	writeHook(t, filepath.Join(repoDir, ".wt", "hooks"), "post-checkout", "#!/bin/sh\nexit 3\n", 0o755)
+
+	err := runHooks("post-checkout", hookContext{Repo: repoInfo{Main: repoDir}, Path: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("runHooks() error = %v, want hook failure", err)
+	}
+}
+
+func TestRemoveHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test hook is a shell script")
+	}
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	trustRepos(t, configHome)
+	repoDir := t.TempDir()
+	hooksDir := filepath.Join(repoDir, ".wt", "hooks")
+	info := repoInfo{Main: repoDir}
+	worktreeDir := filepath.Join(t.TempDir(), "gone")
+
+	writeHook(t, hooksDir, "pre-remove", "#!/bin/sh\nexit 1\n", 0o755)
+	if err := runPreRemoveHooks(info, "feature", worktreeDir, false); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("runPreRemoveHooks() = %v, want an abort that mentions --force", err)
+	}
+	if err := runPreRemoveHooks(info, "feature", worktreeDir, true); err != nil {
+		t.Errorf("runPreRemoveHooks() with force = %v, want nil", err)
+	}
+
+	// post-remove runs in the main worktree, since the worktree is gone.
+	outFile := filepath.Join(t.TempDir(), "out.txt")
+	writeHook(t, hooksDir, "post-remove", "#!/bin/sh\necho \"$WT_PATH $(pwd)\" > "+outFile+"\n", 0o755)
+	runPostRemoveHooks(info, "feature", worktreeDir)
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("post-remove hook did not run: %v", err)
+	}
+	resolved, _ := filepath.EvalSymlinks(repoDir)
+	if want := worktreeDir + " " + resolved; strings.TrimSpace(string(data)) != want {
+		t.Errorf("hook saw %q, want %q", strings.TrimSpace(string(data)), want)
+	}
+}
+
+func TestRunHooksSkipsUntrustedRepoHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test hook is a shell script")
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	repoDir := t.TempDir()
+	outFile := filepath.Join(t.TempDir(), "ran.txt")
+	writeHook(t, filepath.Join(repoDir, ".wt", "hooks"), "post-checkout", "#!/bin/sh\ntouch "+outFile+"\n", 0o755)
+
+	if err := runHooks("post-checkout", hookContext{Repo: repoInfo{Main: repoDir}, Path: t.TempDir()}); err != nil {
+		t.Fatalf("runHooks() error: %v", err)
+	}
+	if _, err := os.Stat(outFile); err == nil {
+		t.Error("repo hook ran without trust-repo")
+	}
+}
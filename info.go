@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var infoJSON bool
+
+// worktreeInfo is everything wt knows about one worktree, for 'wt info'.
+type worktreeInfo struct {
+	Path         string     `json:"path"`
+	Branch       string     `json:"branch,omitempty"`
+	Head         string     `json:"head,omitempty"`
+	Detached     bool       `json:"detached,omitempty"`
+	Parent       string     `json:"parent,omitempty"`
+	Upstream     string     `json:"upstream,omitempty"`
+	Ahead        int        `json:"ahead"`
+	Behind       int        `json:"behind"`
+	UpstreamGone bool       `json:"upstream_gone,omitempty"`
+	Staged       int        `json:"staged"`
+	Unstaged     int        `json:"unstaged"`
+	Untracked    int        `json:"untracked"`
+	Conflicted   int        `json:"conflicted"`
+	StatusError  string     `json:"status_error,omitempty"`
+	Locked       bool       `json:"locked"`
+	LockReason   string     `json:"lock_reason,omitempty"`
+	Prunable     bool       `json:"prunable,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Ticket       string     `json:"ticket,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	Expires      *time.Time `json:"expires,omitempty"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastCommit   *time.Time `json:"last_commit,omitempty"`
+	LastVisit    *time.Time `json:"last_visit,omitempty"`
+	HookRuns     []hookRun  `json:"hook_runs"`
+
+	status worktreeStatus
+}
+
+// findInfoWorktree returns the worktree of branch, or the one containing
+// the current directory for ".".
+func findInfoWorktree(target string) (worktreeEntry, error) {
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return worktreeEntry{}, err
+	}
+	if target == "." {
+		cwd, _ := os.Getwd()
+		found, best := -1, -1
+		for i, entry := range entries {
+			// Worktrees may be nested in the main worktree; the deepest wins.
+			if isWithin(entry.Path, cwd) && len(entry.Path) > best {
+				found, best = i, len(entry.Path)
+			}
+		}
+		if found < 0 {
+			return worktreeEntry{}, fmt.Errorf("the current directory is not inside a worktree")
+		}
+		return entries[found], nil
+	}
+
+	var branches []string
+	for _, entry := range entries {
+		if entry.Branch == target {
+			return entry, nil
+		}
+		if entry.Branch != "" {
+			branches = append(branches, entry.Branch)
+		}
+	}
+	return worktreeEntry{}, fmt.Errorf("no worktree found for branch '%s'%s", target, didYouMean(suggestNames(target, branches)))
+}
+
+// worktreeCreated approximates when a linked worktree was added by the age
+// of the commondir file git writes into its administrative directory. The
+// main worktree has no such file.
+func worktreeCreated(path string) *time.Time {
+	output, err := gitCommand("-C", path, "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return nil
+	}
+	stat, err := os.Stat(filepath.Join(strings.TrimSpace(string(output)), "commondir"))
+	if err != nil {
+		return nil
+	}
+	created := stat.ModTime()
+	return &created
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// collectWorktreeInfo gathers the details of one worktree.
+func collectWorktreeInfo(entry worktreeEntry) (*worktreeInfo, error) {
+	refs, err := snapshot.BranchRefs()
+	if err != nil {
+		return nil, err
+	}
+	st := worktreeStatus{worktreeEntry: entry}
+	if ref, ok := refs[entry.Branch]; ok {
+		st.Upstream = ref.Upstream
+		st.Ahead = ref.Ahead
+		st.Behind = ref.Behind
+		st.UpstreamGone = ref.UpstreamGone
+		st.LastCommit = ref.CommitTime
+	}
+	if !entry.Bare && !entry.Prunable {
+		output, err := gitCommand("-C", entry.Path, "status", "--porcelain=v2").Output()
+		if err != nil {
+			st.StatusError = err.Error()
+		} else {
+			parseStatusPorcelainV2(string(output), &st)
+		}
+	}
+
+	info := &worktreeInfo{
+		Path:         entry.Path,
+		Branch:       entry.Branch,
+		Head:         entry.Head,
+		Detached:     entry.Detached,
+		Upstream:     st.Upstream,
+		Ahead:        st.Ahead,
+		Behind:       st.Behind,
+		UpstreamGone: st.UpstreamGone,
+		Staged:       st.Staged,
+		Unstaged:     st.Unstaged,
+		Untracked:    st.Untracked,
+		Conflicted:   st.Conflicted,
+		StatusError:  st.StatusError,
+		Locked:       entry.Locked,
+		LockReason:   entry.LockReason,
+		Prunable:     entry.Prunable,
+		LastCommit:   timePtr(st.LastCommit),
+		status:       st,
+	}
+	if entry.Branch != "" {
+		meta := loadMeta().Branches[entry.Branch]
+		info.Description, info.Ticket, info.Tags, info.Expires = meta.Description, meta.Ticket, meta.Tags, meta.Expires
+		info.Parent, _ = branchParent(entry.Branch)
+	}
+	if !entry.Prunable {
+		info.Created = worktreeCreated(entry.Path)
+	}
+	state := loadState()
+	info.LastVisit = timePtr(state.lastVisit(entry.Path))
+	info.HookRuns = state.HookRuns[resolvePath(entry.Path)]
+	if info.HookRuns == nil {
+		info.HookRuns = []hookRun{}
+	}
+	return info, nil
+}
+
+// formatInfoTime renders a time with how long ago it was.
+func formatInfoTime(t *time.Time, now time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04") + " (" + formatAge(now.Sub(*t)) + ")"
+}
+
+// printWorktreeInfo renders info as "key: value" lines.
+func printWorktreeInfo(out io.Writer, info *worktreeInfo, now time.Time) error {
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	row := func(key, value string) { fmt.Fprintf(w, "%s:\t%s\n", key, value) }
+
+	row("Path", info.Path)
+	branch := branchLabel(info.status)
+	if info.Parent != "" {
+		branch += " (stacked on " + info.Parent + ")"
+	}
+	row("Branch", branch)
+	head := info.Head
+	if len(head) > 7 {
+		head = head[:7]
+	}
+	if head != "" {
+		row("HEAD", head)
+	}
+	row("Upstream", describeUpstream(info.status))
+	changes := describeChanges(info.status)
+	if info.StatusError != "" {
+		changes += ": " + info.StatusError
+	}
+	row("Changes", changes)
+	locked := "no"
+	if info.Locked {
+		locked = "yes"
+		if info.LockReason != "" {
+			locked += " (" + info.LockReason + ")"
+		}
+	}
+	row("Locked", locked)
+	note := worktreeMeta{Description: info.Description, Ticket: info.Ticket, Tags: info.Tags, Expires: info.Expires}.label()
+	if note == "" {
+		note = "-"
+	}
+	row("Note", note)
+	row("Created", formatInfoTime(info.Created, now))
+	row("Last commit", formatInfoTime(info.LastCommit, now))
+	row("Last visit", formatInfoTime(info.LastVisit, now))
+	if len(info.HookRuns) == 0 {
+		row("Hooks", "-")
+	}
+	for i, run := range info.HookRuns {
+		key := ""
+		if i == 0 {
+			key = "Hooks"
+		}
+		line := fmt.Sprintf("%s %s %s (%s)", formatInfoTime(&run.At, now), run.Hook, run.Script, run.Scope)
+		if run.Error != "" {
+			line += " failed: " + run.Error
+		}
+		if key == "" {
+			fmt.Fprintf(w, "\t%s\n", line)
+		} else {
+			row(key, line)
+		}
+	}
+	return w.Flush()
+}
+
+// runWorktreeInfo prints the details of the worktree of target.
+func runWorktreeInfo(target string) error {
+	entry, err := findInfoWorktree(target)
+	if err != nil {
+		return err
+	}
+	info, err := collectWorktreeInfo(entry)
+	if err != nil {
+		return err
+	}
+	if infoJSON {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	return printWorktreeInfo(os.Stdout, info, time.Now())
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorktreeInfo(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	root := t.TempDir()
+	repoDir := filepath.Join(root, "repo")
+	setupTestRepo(t, repoDir)
+	worktreeDir := filepath.Join(root, "feature")
+	runGitCommand(t, repoDir, "worktree", "add", "-b", "feature", worktreeDir)
+	runGitCommand(t, repoDir, "worktree", "lock", "--reason", "on a usb disk", worktreeDir)
+	if err := os.WriteFile(filepath.Join(worktreeDir, "new.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(worktreeDir); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.invalidate()
+
+	if err := editTags("feature", []string{"perf"}, true); err != nil {
+		t.Fatal(err)
+	}
+	recordHookRun(worktreeDir, hookRun{Hook: "post-checkout", Scope: "repo", Script: "/hooks/post-checkout", At: time.Now(), Error: "exit status 1"})
+
+	if _, err := findInfoWorktree("featur"); err == nil || !strings.Contains(err.Error(), "feature") {
+		t.Errorf("findInfoWorktree(featur) = %v, want a suggestion of feature", err)
+	}
+	entry, err := findInfoWorktree(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Branch != "feature" {
+		t.Fatalf("findInfoWorktree(.) = %q, want the worktree of feature, not the main one", entry.Branch)
+	}
+
+	info, err := collectWorktreeInfo(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Locked || info.LockReason != "on a usb disk" {
+		t.Errorf("lock = %v %q, want locked with its reason", info.Locked, info.LockReason)
+	}
+	if info.Untracked != 1 || strings.Join(info.Tags, ",") != "perf" {
+		t.Errorf("untracked = %d, tags = %v, want 1 and [perf]", info.Untracked, info.Tags)
+	}
+	if info.Created == nil {
+		t.Error("a linked worktree should have a creation time")
+	}
+	if len(info.HookRuns) != 1 || info.HookRuns[0].Hook != "post-checkout" {
+		t.Errorf("hook runs = %+v, want the recorded post-checkout run", info.HookRuns)
+	}
+
+	var out bytes.Buffer
+	if err := printWorktreeInfo(&out, info, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Branch:", "feature", "yes (on a usb disk)", "1 untracked", "#perf", "post-checkout /hooks/post-checkout (repo) failed: exit status 1"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRecordHookRunKeepsRecent(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	for i := 0; i < maxHookRuns+5; i++ {
+		recordHookRun(dir, hookRun{Hook: "post-checkout", At: time.Unix(int64(i), 0)})
+	}
+	runs := loadState().HookRuns[resolvePath(dir)]
+	if len(runs) != maxHookRuns || runs[0].At.Unix() != 5 {
+		t.Errorf("kept %d runs starting at %d, want the last %d", len(runs), runs[0].At.Unix(), maxHookRuns)
+	}
+
+	os.RemoveAll(dir)
+	if result := gcHookRuns(0, false); result.Removed != 1 {
+		t.Errorf("gcHookRuns() removed %d, want the missing worktree", result.Removed)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -16,13 +17,25 @@ var supportedShells = map[string]bool{
 	"zsh":        true,
 	"powershell": true,
 	"pwsh":       true, // alias for powershell
+	"tcsh":       true,
+	"csh":        true, // alias for tcsh
 }
 
 // Init command flags
 var (
-	initDryRun    bool
-	initUninstall bool
-	initNoPrompt  bool
+	initDryRun      bool
+	initUninstall   bool
+	initNoPrompt    bool
+	initLogin       bool
+	initInteractive bool
+	initAllHosts    bool
+)
+
+// PowerShell editions, named after the directories under Documents that
+// hold their profiles on Windows.
+const (
+	psDesktop = "WindowsPowerShell" // Windows PowerShell 5.1, powershell.exe
+	psCore    = "PowerShell"        // PowerShell 7+, pwsh
 )
 
 var initCmd = &cobra.Command{
@@ -30,51 +43,86 @@ var initCmd = &cobra.Command{
 	Short: "Initialize shell integration",
 	Long: `Add wt shell integration to your shell configuration.
 
-Automatically detects your shell and updates the appropriate config file:
-  - bash: ~/.bashrc
-  - zsh:  ~/.zshrc
-  - powershell: $PROFILE (Windows only)
+Automatically detects your shell and updates the config file it sources:
+  - bash: ~/.bashrc, or on macOS (where terminals start login shells)
+          ~/.bash_profile unless it already sources ~/.bashrc
+  - zsh:  ~/.zshrc (in $ZDOTDIR if set)
+  - powershell: the profile of the PowerShell wt is run from. On Windows,
+          Windows PowerShell 5.1 (Documents\WindowsPowerShell) and
+          PowerShell 7 (Documents\PowerShell) have separate profiles;
+          --all-hosts configures both. On macOS/Linux:
+          ~/.config/powershell/Microsoft.PowerShell_profile.ps1
+  - tcsh: ~/.tcshrc, or ~/.cshrc when only that exists (csh as well)
+
+Use --login or --interactive to pick the file for login shells
+(~/.bash_profile, ~/.zprofile, ~/.login) or interactive shells (~/.bashrc,
+~/.zshrc) explicitly. Run 'wt doctor' to check that new shells load the integration.
 
 The configuration is wrapped in markers so it can be safely updated or removed.
 
 Examples:
   wt init              # Auto-detect shell and configure
   wt init bash         # Configure for bash specifically
+  wt init zsh --login  # Configure ~/.zprofile instead of ~/.zshrc
+  wt init pwsh         # Configure PowerShell, also on macOS and Linux
+  wt init pwsh --all-hosts  # Windows PowerShell and PowerShell 7
+  wt init tcsh         # Configure ~/.tcshrc
   wt init --dry-run    # Preview changes without modifying files
   wt init --uninstall  # Remove wt configuration from shell`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		shell := detectShell(args)
 		if shell == "" {
-			fmt.Fprintln(os.Stderr, "Error: could not detect shell. Please specify: wt init bash|zsh|powershell")
+			fmt.Fprintln(os.Stderr, "Error: could not detect shell. Please specify: wt init bash|zsh|powershell|tcsh")
 			os.Exit(1)
 		}
 
-		// PowerShell init is only supported on Windows because wt shellenv
-		// only outputs PowerShell code when running on Windows
-		if shell == "powershell" && runtime.GOOS != "windows" {
-			fmt.Fprintln(os.Stderr, "Error: PowerShell shell integration is only supported on Windows.")
-			fmt.Fprintln(os.Stderr, "On macOS/Linux, use: wt init bash  or  wt init zsh")
+		if initLogin && initInteractive {
+			fmt.Fprintln(os.Stderr, "Error: --login and --interactive are mutually exclusive")
 			os.Exit(1)
 		}
+		mode := ""
+		if initLogin {
+			mode = "login"
+		} else if initInteractive {
+			mode = "interactive"
+		}
 
-		configPath := getShellConfigPath(shell)
-		if configPath == "" {
+		configPaths := []string{selectShellConfigPath(shell, mode)}
+		if shell == "powershell" && initAllHosts {
+			configPaths = allPowerShellProfiles()
+		} else if initAllHosts {
+			fmt.Fprintln(os.Stderr, "Error: --all-hosts only applies to powershell")
+			os.Exit(1)
+		}
+		if shell == "powershell" && !initAllHosts && os.Getenv("PROFILE") == "" {
+			if edition, sure := detectPowerShellEdition(); !sure {
+				warnf("Both Windows PowerShell and PowerShell 7 are installed and wt cannot tell which one runs it; configuring %s (--all-hosts configures both)\n", edition)
+			}
+		}
+		if configPaths[0] == "" {
 			fmt.Fprintf(os.Stderr, "Error: could not determine config file for %s\n", shell)
 			os.Exit(1)
 		}
 
-		if initUninstall {
-			if err := removeShellConfig(configPath, shell, initDryRun); err != nil {
+		for _, configPath := range configPaths {
+			if initUninstall {
+				if err := removeShellConfig(configPath, shell, initDryRun); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				continue
+			}
+
+			if err := installShellConfig(configPath, shell, initDryRun, initNoPrompt); err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 				os.Exit(1)
 			}
-			return
 		}
-
-		if err := installShellConfig(configPath, shell, initDryRun, initNoPrompt); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		if shell == "powershell" && !initUninstall && !initDryRun && !initNoPrompt {
+			// PowerShell started with -NoProfile, as some terminal profiles
+			// and editor tasks do, never reads the profile.
+			warnf("PowerShell sessions started with -NoProfile skip the profile; run this in them instead:\n  Invoke-Expression (& wt shellenv powershell | Out-String)\n")
 		}
 	},
 }
@@ -90,8 +138,11 @@ func detectShell(args []string) string {
 	if len(args) > 0 {
 		shell := strings.ToLower(args[0])
 		if supportedShells[shell] {
-			if shell == "pwsh" {
+			switch shell {
+			case "pwsh":
 				return "powershell"
+			case "csh":
+				return "tcsh"
 			}
 			return shell
 		}
@@ -105,12 +156,18 @@ func detectShell(args []string) string {
 
 	// 3. Check $SHELL environment variable
 	shellEnv := os.Getenv("SHELL")
+	if strings.Contains(shellEnv, "pwsh") {
+		return "powershell"
+	}
 	if strings.Contains(shellEnv, "zsh") {
 		return "zsh"
 	}
 	if strings.Contains(shellEnv, "bash") {
 		return "bash"
 	}
+	if strings.HasSuffix(shellEnv, "csh") {
+		return "tcsh"
+	}
 
 	// 4. Default to bash on Unix
 	return "bash"
@@ -118,6 +175,12 @@ func detectShell(args []string) string {
 
 // getShellConfigPath returns the path to the shell configuration file
 func getShellConfigPath(shell string) string {
+	return selectShellConfigPath(shell, "")
+}
+
+// selectShellConfigPath returns the config file to install into for mode
+// "login", "interactive", or "" to pick the file the user's shells source.
+func selectShellConfigPath(shell, mode string) string {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return ""
@@ -125,37 +188,139 @@ func getShellConfigPath(shell string) string {
 
 	switch shell {
 	case "bash":
-		// Prefer .bashrc, fall back to .bash_profile on macOS
 		bashrc := filepath.Join(home, ".bashrc")
-		if _, err := os.Stat(bashrc); err == nil {
+		login := bashLoginFile(home)
+		switch mode {
+		case "login":
+			return login
+		case "interactive":
 			return bashrc
 		}
 		if runtime.GOOS == "darwin" {
-			return filepath.Join(home, ".bash_profile")
+			// Terminal.app and iTerm start login shells, which read only the
+			// login file; use .bashrc only if the login file sources it.
+			if fileExists(bashrc) && sourcesFile(login, ".bashrc") {
+				return bashrc
+			}
+			return login
 		}
 		return bashrc
 	case "zsh":
-		return filepath.Join(home, ".zshrc")
+		dir := home
+		if zdotdir := os.Getenv("ZDOTDIR"); zdotdir != "" {
+			dir = zdotdir
+		}
+		// .zshrc is read by every interactive zsh, login or not.
+		if mode == "login" {
+			return filepath.Join(dir, ".zprofile")
+		}
+		return filepath.Join(dir, ".zshrc")
 	case "powershell":
 		// Check $PROFILE env var first (works for both Windows PowerShell 5.1 and PowerShell Core)
 		if profile := os.Getenv("PROFILE"); profile != "" {
 			return profile
 		}
-		if runtime.GOOS == "windows" {
-			// Default to Windows PowerShell 5.1 path (more common)
-			// Windows PowerShell 5.1: Documents\WindowsPowerShell\Microsoft.PowerShell_profile.ps1
-			// PowerShell Core 7+: Documents\PowerShell\Microsoft.PowerShell_profile.ps1
-			docs := filepath.Join(home, "Documents", "WindowsPowerShell")
-			if err := os.MkdirAll(docs, 0755); err == nil {
-				return filepath.Join(docs, "Microsoft.PowerShell_profile.ps1")
-			}
+		edition, _ := detectPowerShellEdition()
+		return powerShellProfile(home, edition)
+	case "tcsh":
+		// tcsh reads .tcshrc, or .cshrc when there is none; csh only .cshrc.
+		if mode == "login" {
+			return filepath.Join(home, ".login")
 		}
-		// Unix PowerShell Core
-		return filepath.Join(home, ".config", "powershell", "Microsoft.PowerShell_profile.ps1")
+		tcshrc := filepath.Join(home, ".tcshrc")
+		if cshrc := filepath.Join(home, ".cshrc"); !fileExists(tcshrc) && fileExists(cshrc) {
+			return cshrc
+		}
+		return tcshrc
 	}
 	return ""
 }
 
+// powerShellProfile returns the current user's profile of a PowerShell
+// edition for the console host.
+func powerShellProfile(home, edition string) string {
+	if runtime.GOOS != "windows" {
+		// Only PowerShell 7 runs on macOS and Linux.
+		return filepath.Join(home, ".config", "powershell", "Microsoft.PowerShell_profile.ps1")
+	}
+	return filepath.Join(home, "Documents", edition, "Microsoft.PowerShell_profile.ps1")
+}
+
+// allPowerShellProfiles returns the profiles of every PowerShell edition
+// of this OS.
+func allPowerShellProfiles() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return []string{""}
+	}
+	if runtime.GOOS != "windows" {
+		return []string{powerShellProfile(home, psCore)}
+	}
+	return []string{powerShellProfile(home, psDesktop), powerShellProfile(home, psCore)}
+}
+
+// detectPowerShellEdition tells which PowerShell runs wt: from the module
+// directories PowerShell puts in PSModulePath for the programs it starts,
+// or else the only edition found on PATH. When both are installed and
+// neither runs wt it guesses Windows PowerShell, which every Windows has,
+// and sure is false.
+func detectPowerShellEdition() (edition string, sure bool) {
+	if runtime.GOOS != "windows" {
+		return psCore, true
+	}
+	if edition := editionFromModulePath(os.Getenv("PSModulePath")); edition != "" {
+		return edition, true
+	}
+	_, pwshErr := exec.LookPath("pwsh")
+	_, desktopErr := exec.LookPath("powershell")
+	if pwshErr == nil && desktopErr != nil {
+		return psCore, true
+	}
+	return psDesktop, pwshErr != nil
+}
+
+// editionFromModulePath recognizes the PowerShell edition from a Windows
+// PSModulePath. Outside of PowerShell it holds only the machine-wide
+// Windows PowerShell directories, which tell nothing.
+func editionFromModulePath(modulePath string) string {
+	edition := ""
+	for _, dir := range strings.Split(modulePath, ";") {
+		dir = strings.ToLower(strings.TrimRight(strings.ReplaceAll(dir, `\`, "/"), "/"))
+		switch {
+		case strings.HasSuffix(dir, "/powershell/modules") || strings.Contains(dir, "/powershell/7"):
+			// Only PowerShell 7 adds these.
+			return psCore
+		case strings.HasSuffix(dir, "/documents/windowspowershell/modules"):
+			// The user's own module directory, added by Windows PowerShell.
+			edition = psDesktop
+		}
+	}
+	return edition
+}
+
+// bashLoginFile returns the file a bash login shell reads: the first of
+// .bash_profile, .bash_login and .profile that exists.
+func bashLoginFile(home string) string {
+	for _, name := range []string{".bash_profile", ".bash_login", ".profile"} {
+		if path := filepath.Join(home, name); fileExists(path) {
+			return path
+		}
+	}
+	return filepath.Join(home, ".bash_profile")
+}
+
+// sourcesFile reports whether the shell script at path mentions name, which
+// in practice means it sources it (e.g. '. ~/.bashrc').
+func sourcesFile(path, name string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.Contains(string(data), name)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
 // getShellConfigContent returns the shell configuration block to add
 func getShellConfigContent(shell string) string {
 	switch shell {
@@ -165,22 +330,19 @@ eval "$(wt shellenv)"
 %s`, markerStart, markerEnd)
 	case "powershell":
 		return fmt.Sprintf(`%s
-Invoke-Expression (& wt shellenv)
+Invoke-Expression (& wt shellenv powershell | Out-String)
+%s`, markerStart, markerEnd)
+	case "tcsh":
+		// csh cannot eval a script of several lines.
+		return fmt.Sprintf(`%s
+set __wt_init = `+"`mktemp -t wt.XXXXXX`"+`
+wt shellenv tcsh >! $__wt_init && source $__wt_init
+rm -f $__wt_init; unset __wt_init
 %s`, markerStart, markerEnd)
 	}
 	return ""
 }
 
-// successPrefix returns a checkmark or "[ok]" depending on terminal support
-func successPrefix() string {
-	// Check if we're in a terminal that likely supports Unicode
-	// Most modern terminals do, but CI environments and some Windows consoles may not
-	if os.Getenv("CI") != "" || os.Getenv("TERM") == "dumb" {
-		return "[ok]"
-	}
-	return "✓"
-}
-
 // installShellConfig adds or updates shell configuration
 func installShellConfig(configPath, shell string, dryRun, noPrompt bool) error {
 	content := getShellConfigContent(shell)
@@ -215,7 +377,7 @@ func installShellConfig(configPath, shell string, dryRun, noPrompt bool) error {
 			if err := os.WriteFile(configPath, []byte(newContent), 0644); err != nil {
 				return fmt.Errorf("failed to write %s: %v", configPath, err)
 			}
-			fmt.Printf("%s Updated wt configuration in %s\n", successPrefix(), configPath)
+			successf("Updated wt configuration in %s", configPath)
 			return nil
 		}
 	}
@@ -252,15 +414,13 @@ func installShellConfig(configPath, shell string, dryRun, noPrompt bool) error {
 		return fmt.Errorf("failed to write config: %v", err)
 	}
 
-	fmt.Printf("%s Added wt shell integration to %s\n", successPrefix(), configPath)
-	if !noPrompt {
+	successf("Added wt shell integration to %s", configPath)
+	if !noPrompt && !quiet {
 		fmt.Println()
 		fmt.Println("To activate, run:")
 		switch shell {
-		case "bash":
-			fmt.Println("  source ~/.bashrc")
-		case "zsh":
-			fmt.Println("  source ~/.zshrc")
+		case "bash", "zsh", "tcsh":
+			fmt.Printf("  source %s\n", configPath)
 		case "powershell":
 			fmt.Println("  . $PROFILE")
 		}
@@ -274,7 +434,7 @@ func installShellConfig(configPath, shell string, dryRun, noPrompt bool) error {
 func removeShellConfig(configPath, shell string, dryRun bool) error {
 	existing, err := os.ReadFile(configPath)
 	if os.IsNotExist(err) {
-		fmt.Println("No configuration found to remove.")
+		infof("No configuration found to remove.\n")
 		return nil
 	}
 	if err != nil {
@@ -284,7 +444,7 @@ func removeShellConfig(configPath, shell string, dryRun bool) error {
 	existingStr := string(existing)
 
 	if !strings.Contains(existingStr, markerStart) {
-		fmt.Println("No wt configuration found in", configPath)
+		infof("No wt configuration found in %s\n", configPath)
 		return nil
 	}
 
@@ -318,6 +478,6 @@ func removeShellConfig(configPath, shell string, dryRun bool) error {
 		return fmt.Errorf("failed to write %s: %v", configPath, err)
 	}
 
-	fmt.Printf("%s Removed wt configuration from %s\n", successPrefix(), configPath)
+	successf("Removed wt configuration from %s", configPath)
 	return nil
 }
@@ -35,6 +35,11 @@ func TestDetectShell(t *testing.T) {
 			args: []string{"pwsh"},
 			want: "powershell",
 		},
+		{
+			name: "csh alias returns tcsh",
+			args: []string{"csh"},
+			want: "tcsh",
+		},
 		{
 			name: "case insensitive",
 			args: []string{"BASH"},
@@ -52,6 +57,18 @@ func TestDetectShell(t *testing.T) {
 			envShell: "/bin/bash",
 			want:     "bash",
 		},
+		{
+			name:     "detect from SHELL env - tcsh",
+			args:     []string{},
+			envShell: "/bin/tcsh",
+			want:     "tcsh",
+		},
+		{
+			name:     "detect from SHELL env - pwsh",
+			args:     []string{},
+			envShell: "/usr/local/bin/pwsh",
+			want:     "powershell",
+		},
 	}
 
 	for _, tt := range tests {
@@ -78,7 +95,7 @@ func TestDetectShell(t *testing.T) {
 
 func TestSupportedShells(t *testing.T) {
 	// Verify all expected shells are in the map
-	expected := []string{"bash", "zsh", "powershell", "pwsh"}
+	expected := []string{"bash", "zsh", "powershell", "pwsh", "tcsh", "csh"}
 	for _, shell := range expected {
 		if !supportedShells[shell] {
 			t.Errorf("supportedShells missing %q", shell)
@@ -140,6 +157,11 @@ func TestGetShellConfigContent(t *testing.T) {
 			shell:    "powershell",
 			contains: []string{markerStart, markerEnd, "wt shellenv", "Invoke-Expression"},
 		},
+		{
+			name:     "tcsh content",
+			shell:    "tcsh",
+			contains: []string{markerStart, markerEnd, "wt shellenv tcsh >! $__wt_init", "source $__wt_init"},
+		},
 		{
 			name:  "unsupported shell returns empty",
 			shell: "fish",
@@ -363,3 +385,106 @@ func TestDryRun(t *testing.T) {
 		}
 	})
 }
+
+func TestSelectShellConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ZDOTDIR", "")
+
+	if got, want := selectShellConfigPath("bash", "login"), filepath.Join(home, ".bash_profile"); got != want {
+		t.Errorf("bash login without files = %q, want %q", got, want)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".profile"), []byte("# profile\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := selectShellConfigPath("bash", "login"), filepath.Join(home, ".profile"); got != want {
+		t.Errorf("bash login with ~/.profile = %q, want %q", got, want)
+	}
+	if got, want := selectShellConfigPath("bash", "interactive"), filepath.Join(home, ".bashrc"); got != want {
+		t.Errorf("bash interactive = %q, want %q", got, want)
+	}
+	if got, want := selectShellConfigPath("zsh", "login"), filepath.Join(home, ".zprofile"); got != want {
+		t.Errorf("zsh login = %q, want %q", got, want)
+	}
+
+	zdotdir := t.TempDir()
+	t.Setenv("ZDOTDIR", zdotdir)
+	if got, want := selectShellConfigPath("zsh", ""), filepath.Join(zdotdir, ".zshrc"); got != want {
+		t.Errorf("zsh with ZDOTDIR = %q, want %q", got, want)
+	}
+
+	if got, want := selectShellConfigPath("tcsh", ""), filepath.Join(home, ".tcshrc"); got != want {
+		t.Errorf("tcsh without files = %q, want %q", got, want)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".cshrc"), []byte("# cshrc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := selectShellConfigPath("tcsh", ""), filepath.Join(home, ".cshrc"); got != want {
+		t.Errorf("tcsh with only ~/.cshrc = %q, want %q", got, want)
+	}
+	if got, want := selectShellConfigPath("tcsh", "login"), filepath.Join(home, ".login"); got != want {
+		t.Errorf("tcsh login = %q, want %q", got, want)
+	}
+}
+
+func TestEditionFromModulePath(t *testing.T) {
+	tests := []struct {
+		name       string
+		modulePath string
+		want       string
+	}{
+		{
+			name:       "PowerShell 7",
+			modulePath: `C:\Users\me\Documents\PowerShell\Modules;C:\Program Files\PowerShell\Modules;c:\program files\powershell\7\Modules;C:\Program Files\WindowsPowerShell\Modules;C:\WINDOWS\system32\WindowsPowerShell\v1.0\Modules`,
+			want:       psCore,
+		},
+		{
+			name:       "Windows PowerShell",
+			modulePath: `C:\Users\me\Documents\WindowsPowerShell\Modules;C:\Program Files\WindowsPowerShell\Modules;C:\WINDOWS\system32\WindowsPowerShell\v1.0\Modules`,
+			want:       psDesktop,
+		},
+		{
+			name:       "not started from PowerShell",
+			modulePath: `C:\Program Files\WindowsPowerShell\Modules;C:\WINDOWS\system32\WindowsPowerShell\v1.0\Modules`,
+			want:       "",
+		},
+		{
+			name:       "unset",
+			modulePath: "",
+			want:       "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := editionFromModulePath(tt.modulePath); got != tt.want {
+				t.Errorf("editionFromModulePath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPowerShellProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("PROFILE", "")
+
+	profiles := allPowerShellProfiles()
+	if runtime.GOOS == "windows" {
+		want := []string{
+			filepath.Join(home, "Documents", "WindowsPowerShell", "Microsoft.PowerShell_profile.ps1"),
+			filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1"),
+		}
+		if strings.Join(profiles, "|") != strings.Join(want, "|") {
+			t.Errorf("allPowerShellProfiles() = %v, want %v", profiles, want)
+		}
+		return
+	}
+	want := filepath.Join(home, ".config", "powershell", "Microsoft.PowerShell_profile.ps1")
+	if len(profiles) != 1 || profiles[0] != want {
+		t.Errorf("allPowerShellProfiles() = %v, want [%s]", profiles, want)
+	}
+	if got := selectShellConfigPath("powershell", ""); got != want {
+		t.Errorf("selectShellConfigPath(powershell) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkoutForce checks out a branch that another worktree is using with a
+// detached HEAD at its commit.
+var checkoutForce bool
+
+// busyWorktree finds a worktree that git considers to have branch checked
+// out although 'git worktree list' shows it detached: one in the middle of
+// rebasing or bisecting the branch. git refuses to check the branch out
+// anywhere else until that is finished.
+func busyWorktree(branch string) (path, reason string, ok bool) {
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return "", "", false
+	}
+	probes := []struct{ file, reason string }{
+		{"rebase-merge/head-name", "rebase in progress"},
+		{"rebase-apply/head-name", "rebase in progress"},
+		{"BISECT_START", "bisect in progress"},
+	}
+	for _, entry := range entries {
+		if !entry.Detached || entry.Prunable {
+			continue
+		}
+		for _, probe := range probes {
+			output, err := gitCommand("-C", entry.Path, "rev-parse", "--git-path", probe.file).Output()
+			if err != nil {
+				continue
+			}
+			file := strings.TrimSpace(string(output))
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(entry.Path, file)
+			}
+			data, err := os.ReadFile(file)
+			if err == nil && strings.TrimPrefix(strings.TrimSpace(string(data)), "refs/heads/") == branch {
+				return entry.Path, probe.reason, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// branchBusyError explains that branch is checked out at path and what can
+// be done about it instead.
+func branchBusyError(branch, path, reason string) error {
+	return fmt.Errorf(`branch '%s' is already checked out at %s (%s); instead:
+  continue there:            cd %s
+  start a new branch on it:  wt checkout -b <new-branch> %s
+  use a detached HEAD:       wt checkout %s --force`,
+		branch, path, reason, path, branch, branch)
+}
+
+// checkoutBusyBranch handles a checkout of a branch another worktree is
+// using: with --force it checks out the branch's commit detached, otherwise
+// the user picks what to do, or learns the options in CI.
+func checkoutBusyBranch(info repoInfo, branch, busyPath, reason string) error {
+	if !checkoutForce {
+		options := []string{
+			fmt.Sprintf("Go to the worktree at %s", busyPath),
+			fmt.Sprintf("Create a new branch from %s", branch),
+			fmt.Sprintf("Check out %s with a detached HEAD", branch),
+		}
+		label := fmt.Sprintf("Branch '%s' is already checked out at %s (%s)", branch, busyPath, reason)
+		idx, _, err := selectPrompt(label, options)
+		if err != nil {
+			return branchBusyError(branch, busyPath, reason)
+		}
+		switch idx {
+		case 0:
+			printCDMarker(busyPath)
+			return nil
+		case 1:
+			name, err := inputPrompt("New branch name")
+			if err != nil {
+				return err
+			}
+			newBranch, err := newBranchName(name)
+			if err != nil {
+				return err
+			}
+			return checkoutWithPatch(newBranch, branch, "")
+		}
+	}
+
+	path, err := buildWorktreePath(info, branch)
+	if err != nil {
+		return err
+	}
+	// The busy worktree usually sits where the layout puts the branch.
+	if _, err := os.Lstat(longPath(path)); err == nil {
+		path += "-detached"
+	}
+	gitCmd := repoVCS().addWorktree(path, "--detach", branch)
+	gitCmd.Stdout = gitOutput()
+	gitCmd.Stderr = os.Stderr
+	if err := gitCmd.Run(); err != nil {
+		return fmt.Errorf("failed to create worktree: %w", err)
+	}
+	snapshot.invalidate()
+
+	successf("Worktree created at: %s (detached at %s)", path, branch)
+	prepareWorktree(path)
+	applyWorktreeGitConfig(info, path)
+	runPostCheckoutHooks(info, branch, path)
+	printCDMarker(path)
+	return nil
+}
+
+// mainWorktreeBranchError explains why the worktree of branch cannot be
+// removed when the branch is checked out in the main worktree.
+func mainWorktreeBranchError(branch, mainPath string) error {
+	err := fmt.Sprintf("branch '%s' is checked out in the main worktree at %s, which cannot be removed", branch, mainPath)
+	if base := detectDefaultBranch(); base != branch {
+		err += fmt.Sprintf("\nSwitch it to another branch first, e.g.: git -C %s switch %s", mainPath, base)
+	}
+	return fmt.Errorf("%s", err)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBusyBranchCheckout(t *testing.T) {
+	t.Setenv("CI", "true")
+	originalRoot, originalStrategy, originalPattern := worktreeRoot, worktreeStrategy, worktreePattern
+	originalForce := checkoutForce
+	t.Cleanup(func() {
+		worktreeRoot, worktreeStrategy, worktreePattern = originalRoot, originalStrategy, originalPattern
+		checkoutForce = originalForce
+	})
+	tmpDir := t.TempDir()
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	worktreeStrategy = "global"
+	worktreePattern = ""
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	featDir := filepath.Join(tmpDir, "feat")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "feat", featDir)
+	if err := os.WriteFile(filepath.Join(featDir, "f.txt"), []byte("f\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, featDir, "add", "f.txt")
+	runGitCommand(t, featDir, "commit", "-q", "-m", "f")
+	// Stop a rebase of feat halfway; the worktree shows as detached.
+	_ = exec.Command("git", "-C", featDir, "rebase", "-q", "--exec", "false", "HEAD~1").Run()
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, busy := busyWorktree("main"); busy {
+		t.Error("busyWorktree(main) = busy, want main to be free")
+	}
+	path, reason, busy := busyWorktree("feat")
+	if !busy || !samePath(path, featDir) || reason != "rebase in progress" {
+		t.Fatalf("busyWorktree(feat) = %q, %q, %v; want %s, rebase in progress", path, reason, busy, featDir)
+	}
+
+	info, err := getRepoInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkoutForce = false
+	if err := checkoutBusyBranch(info, "feat", path, reason); err == nil || !strings.Contains(err.Error(), "wt checkout feat --force") {
+		t.Errorf("checkoutBusyBranch() in CI = %v, want the options", err)
+	}
+	checkoutForce = true
+	if err := checkoutBusyBranch(info, "feat", path, reason); err != nil {
+		t.Fatal(err)
+	}
+	detached, err := buildWorktreePath(info, "feat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(detached, "f.txt")); err != nil {
+		t.Errorf("no detached worktree of feat at %s: %v", detached, err)
+	}
+}
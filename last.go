@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// startDir is the directory wt was started in, before any command changed
+// it: the worktree the shell is leaving when wt navigates elsewhere.
+var startDir, _ = os.Getwd()
+
+// sessionKey identifies the shell wt runs in. The shell wrapper passes an id
+// in WT_SESSION that is fixed for the life of the shell; without it, all
+// shells share one session.
+func sessionKey() string {
+	if key := os.Getenv("WT_SESSION"); key != "" {
+		return key
+	}
+	return "default"
+}
+
+// startWorktree returns the resolved root of the worktree wt was started
+// in, or "" outside of one.
+func startWorktree() string {
+	if startDir == "" {
+		return ""
+	}
+	output, err := gitCommand("-C", startDir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	return resolvePath(strings.TrimSpace(string(output)))
+}
+
+// enterWorktree records that a shell session navigated to path, coming
+// from the worktree from (empty when not in one). Like 'cd -', the previous
+// worktree is where the shell was, or else where wt last took it.
+func (s *wtState) enterWorktree(key, path, from string) {
+	session := s.Sessions[key]
+	switch {
+	case from != "" && from != path:
+		session.Previous = from
+	case session.Current != "" && session.Current != path:
+		session.Previous = session.Current
+	}
+	session.Current = path
+	session.Updated = time.Now()
+	s.Sessions[key] = session
+}
+
+// gcSessions drops shell sessions that did not use wt within the retention
+// period; most of them belong to shells that were closed long ago.
+func gcSessions(retention time.Duration, dryRun bool) gcResult {
+	result := gcResult{Name: "shell sessions", Unit: "entries"}
+	state := loadState()
+	cutoff := time.Now().Add(-retention)
+	for key, session := range state.Sessions {
+		if session.Updated.Before(cutoff) {
+			delete(state.Sessions, key)
+			result.Removed++
+		}
+	}
+	if result.Removed > 0 && !dryRun {
+		if err := state.save(); err != nil {
+			warnf("warning: failed to save state: %v\n", err)
+		}
+	}
+	return result
+}
+
+var lastCmd = &cobra.Command{
+	Use:   "last",
+	Short: "Go back to the previous worktree ('wt -' for short)",
+	Long: `Go back to the worktree this shell was in before wt last navigated, like
+'cd -'. Running it again returns, so 'wt -' toggles between two worktrees.
+
+Each shell keeps its own history through the shell integration (see
+'wt shellenv'); without it, all shells share one.
+
+Examples:
+  wt checkout feature-a
+  wt checkout feature-b
+  wt -              # Back in feature-a
+  wt -              # Back in feature-b`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		session := loadState().Sessions[sessionKey()]
+		if session.Previous == "" {
+			return fmt.Errorf("no previous worktree in this shell yet")
+		}
+		if _, err := os.Stat(longPath(session.Previous)); err != nil {
+			return fmt.Errorf("the previous worktree %s no longer exists", session.Previous)
+		}
+		printCDMarker(session.Previous)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lastCmd)
+	gcTasks = append(gcTasks, gcSessions)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnterWorktree(t *testing.T) {
+	state := &wtState{Sessions: make(map[string]shellSession)}
+
+	state.enterWorktree("s1", "/wt/a", "")
+	if got := state.Sessions["s1"]; got.Current != "/wt/a" || got.Previous != "" {
+		t.Fatalf("first visit = %+v, want current /wt/a and no previous", got)
+	}
+	state.enterWorktree("s1", "/wt/b", "/wt/a")
+	state.enterWorktree("s2", "/wt/c", "")
+	if got := state.Sessions["s1"]; got.Current != "/wt/b" || got.Previous != "/wt/a" {
+		t.Errorf("after a -> b = %+v, want current /wt/b, previous /wt/a", got)
+	}
+
+	// The shell left wt's last worktree on its own: it came from where it is.
+	state.enterWorktree("s1", "/wt/a", "/repo")
+	if got := state.Sessions["s1"]; got.Previous != "/repo" {
+		t.Errorf("previous = %q, want the worktree the shell was in (/repo)", got.Previous)
+	}
+	// Outside of any worktree, the previous is where wt last took the shell.
+	state.enterWorktree("s1", "/wt/b", "")
+	if got := state.Sessions["s1"]; got.Previous != "/wt/a" {
+		t.Errorf("previous = %q, want /wt/a", got.Previous)
+	}
+	// Navigating to where the shell already is keeps the previous worktree.
+	state.enterWorktree("s1", "/wt/b", "/wt/b")
+	if got := state.Sessions["s1"]; got.Previous != "/wt/a" {
+		t.Errorf("previous = %q after staying in /wt/b, want /wt/a", got.Previous)
+	}
+	if got := state.Sessions["s2"]; got.Previous != "" {
+		t.Errorf("session s2 = %+v, want it untouched by s1", got)
+	}
+}
+
+func TestGCSessions(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	state := loadState()
+	state.Sessions["recent"] = shellSession{Current: "/wt/a", Updated: time.Now()}
+	state.Sessions["old"] = shellSession{Current: "/wt/b", Updated: time.Now().Add(-100 * 24 * time.Hour)}
+	if err := state.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := gcSessions(defaultRetention, false); result.Removed != 1 {
+		t.Errorf("gcSessions() removed %d sessions, want 1", result.Removed)
+	}
+	if sessions := loadState().Sessions; len(sessions) != 1 || sessions["recent"].Current != "/wt/a" {
+		t.Errorf("sessions left = %v, want only recent", sessions)
+	}
+}
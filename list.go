@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultStaleAfter is how long a worktree may go without commits or visits
+// before it is considered stale, unless the stale setting says otherwise.
+const defaultStaleAfter = 30 * 24 * time.Hour
+
+var (
+	listSort   string
+	listFilter string
+	listBranch string
+	listStale  bool
+	listTag    string
+
+	listPorcelain bool
+	listZ         bool
+)
+
+// parseStaleAfter parses the stale setting: days ("14d"), weeks ("2w") or
+// a Go duration ("36h").
+func parseStaleAfter(value string) (time.Duration, error) {
+	return parsePeriod(value, "stale threshold")
+}
+
+// parsePeriod parses a period setting such as stale or retention, in days
+// ("14d"), weeks ("2w") or as a Go duration ("36h"); what names the setting
+// in errors.
+func parsePeriod(value, what string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(value, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(value, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	var d time.Duration
+	if unit != 0 {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q (use e.g. 14d, 2w or 36h)", what, value)
+		}
+		d = time.Duration(n) * unit
+	} else {
+		var err error
+		if d, err = time.ParseDuration(value); err != nil {
+			return 0, fmt.Errorf("invalid %s %q (use e.g. 14d, 2w or 36h)", what, value)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", what, value)
+	}
+	return d, nil
+}
+
+// staleAfter returns the configured stale threshold. An invalid setting
+// falls back to the default; 'wt config check' reports it.
+func staleAfter() time.Duration {
+	d, err := parseStaleAfter(loadConfig().get("stale"))
+	if err != nil {
+		return defaultStaleAfter
+	}
+	return d
+}
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List all worktrees",
+	Long: `List all worktrees of the current repository.
+
+Sort keys:
+  name       branch name (alphabetical)
+  age        last commit, newest first
+  last-used  last visit through wt, most recent first
+  size       disk usage, largest first
+
+Filters:
+  dirty      worktrees with uncommitted or untracked changes
+  clean      worktrees without local changes
+  merged     branches merged into the default base branch
+  stale      no commits or visits within the stale threshold (config key
+             stale, default 30d); --stale is short for --filter stale
+
+Stale worktrees are marked "stale" after the branch. Tickets, tags and
+descriptions noted with 'wt describe' and 'wt tag' follow in a last column;
+--tag shows only worktrees with a tag.
+
+--all-repos lists the worktrees of every repository wt created one in,
+from anywhere, grouped by repository with their count and disk usage.
+
+--submodule lists those of the submodule at that path instead.
+
+` + porcelainHelp + `
+
+Examples:
+  wt list --sort last-used
+  wt list --filter dirty
+  wt list --stale
+  wt list --tag experiment
+  wt list --branch 'feature/*' --sort size
+  wt list --all-repos
+  wt list --porcelain | sed -n 's/^worktree //p'   # Just the paths`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if listSubmodule != "" {
+			if listAllRepos {
+				return fmt.Errorf("--all-repos cannot be combined with --submodule")
+			}
+			if err := enterSubmodule(listSubmodule); err != nil {
+				return err
+			}
+		}
+		if listZ {
+			listPorcelain = true
+		}
+		if listAllRepos {
+			if listPorcelain {
+				return fmt.Errorf("--all-repos cannot be combined with --porcelain")
+			}
+			if listFilter != "" || listStale || listTag != "" {
+				return fmt.Errorf("--all-repos cannot be combined with --filter, --stale or --tag")
+			}
+			repos, err := collectAllRepos(listSort, listBranch)
+			if err != nil {
+				return err
+			}
+			return printAllRepos(os.Stdout, repos)
+		}
+		filter := listFilter
+		if listStale {
+			if filter != "" && filter != "stale" {
+				return fmt.Errorf("--stale cannot be combined with --filter %s", filter)
+			}
+			filter = "stale"
+		}
+		items, err := collectListItems(listSort, filter, listBranch)
+		if err != nil {
+			return err
+		}
+		if listPorcelain {
+			p := newPorcelainWriter(os.Stdout, listZ)
+			for _, item := range items {
+				if listTag == "" || item.Meta.hasTag(listTag) {
+					p.item(item, false)
+				}
+			}
+			return p.flush()
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		for _, item := range items {
+			if listTag != "" && !item.Meta.hasTag(listTag) {
+				continue
+			}
+			fmt.Fprintln(w, formatListLine(item))
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	listCmd.Flags().StringVar(&listSort, "sort", "", "Sort by: name, age, last-used, size")
+	listCmd.Flags().StringVar(&listFilter, "filter", "", "Only show worktrees that are: dirty, clean, merged, stale")
+	listCmd.Flags().StringVar(&listBranch, "branch", "", "Only show branches matching a glob pattern (e.g. 'feature/*')")
+	listCmd.Flags().BoolVar(&listStale, "stale", false, "Only show stale worktrees (same as --filter stale)")
+	listCmd.Flags().StringVar(&listTag, "tag", "", "Only show worktrees with this tag (see 'wt tag')")
+	listCmd.Flags().BoolVar(&listPorcelain, "porcelain", false, "Print a stable format for scripts: key-value lines per worktree")
+	listCmd.Flags().BoolVarP(&listZ, "null", "z", false, "With --porcelain, end lines with NUL instead of newline (implies --porcelain)")
+	listCmd.Flags().StringVar(&listSubmodule, "submodule", "", "List the worktrees of the submodule at this path instead of the superproject")
+	listCmd.Flags().BoolVar(&listAllRepos, "all-repos", false, "List the worktrees of every repository wt was used in, grouped by repository")
+}
+
+// listItem is a worktree with the extra data needed to sort and filter it.
+type listItem struct {
+	worktreeStatus
+	LastVisit time.Time
+	Size      int64
+	Stale     bool
+	Meta      worktreeMeta
+}
+
+// LastActivity is the most recent of the last commit and the last visit.
+func (i listItem) LastActivity() time.Time {
+	if i.LastVisit.After(i.LastCommit) {
+		return i.LastVisit
+	}
+	return i.LastCommit
+}
+
+func isStale(item listItem, now time.Time, staleAfter time.Duration) bool {
+	last := item.LastActivity()
+	return !last.IsZero() && now.Sub(last) > staleAfter
+}
+
+func collectListItems(sortBy, filter, branchGlob string) ([]listItem, error) {
+	switch sortBy {
+	case "", "name", "age", "last-used", "size":
+	default:
+		return nil, fmt.Errorf("invalid --sort value %q (use name, age, last-used or size)", sortBy)
+	}
+	switch filter {
+	case "", "dirty", "clean", "merged", "stale":
+	default:
+		return nil, fmt.Errorf("invalid --filter value %q (use dirty, clean, merged or stale)", filter)
+	}
+	if branchGlob != "" {
+		if _, err := path.Match(branchGlob, ""); err != nil {
+			return nil, fmt.Errorf("invalid --branch pattern %q: %w", branchGlob, err)
+		}
+	}
+
+	// Only run 'git status' in every worktree when the answer is needed.
+	needWorkingTree := filter == "dirty" || filter == "clean"
+	statuses, err := collectWorktreeStatus(needWorkingTree)
+	if err != nil {
+		return nil, err
+	}
+
+	var merged map[string]bool
+	if filter == "merged" {
+		branches, err := getMergedBranches(getDefaultBase())
+		if err != nil {
+			return nil, err
+		}
+		merged = make(map[string]bool)
+		for _, b := range branches {
+			merged[b] = true
+		}
+	}
+
+	state := loadState()
+	meta := loadMeta()
+	now := time.Now()
+	threshold := staleAfter()
+	var items []listItem
+	for _, st := range statuses {
+		item := listItem{worktreeStatus: st, LastVisit: state.lastVisit(st.Path), Meta: meta.Branches[st.Branch]}
+		item.Stale = !st.Bare && isStale(item, now, threshold)
+
+		if branchGlob != "" {
+			if ok, _ := path.Match(branchGlob, st.Branch); !ok {
+				continue
+			}
+		}
+		keep := true
+		switch filter {
+		case "dirty":
+			keep = st.Dirty()
+		case "clean":
+			keep = !st.Dirty() && !st.Bare && !st.Prunable && st.StatusError == ""
+		case "merged":
+			keep = merged[st.Branch]
+		case "stale":
+			keep = item.Stale
+		}
+		if keep {
+			items = append(items, item)
+		}
+	}
+
+	if sortBy == "size" {
+		var wg sync.WaitGroup
+		for i := range items {
+			if items[i].Bare || items[i].Prunable {
+				continue
+			}
+			wg.Add(1)
+			go func(item *listItem) {
+				defer wg.Done()
+				item.Size = dirSize(item.Path)
+			}(&items[i])
+		}
+		wg.Wait()
+	}
+
+	sortListItems(items, sortBy)
+	return items, nil
+}
+
+func sortListItems(items []listItem, sortBy string) {
+	less := map[string]func(a, b listItem) bool{
+		"name":      func(a, b listItem) bool { return branchLabel(a.worktreeStatus) < branchLabel(b.worktreeStatus) },
+		"age":       func(a, b listItem) bool { return a.LastCommit.After(b.LastCommit) },
+		"last-used": func(a, b listItem) bool { return a.LastVisit.After(b.LastVisit) },
+		"size":      func(a, b listItem) bool { return a.Size > b.Size },
+	}[sortBy]
+	if less == nil {
+		// Keep git's order: main worktree first, then by creation.
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool { return less(items[i], items[j]) })
+}
+
+// formatListLine renders an item like 'git worktree list' does, so scripts
+// written against the previous output keep working.
+func formatListLine(item listItem) string {
+	head := item.Head
+	if len(head) > 7 {
+		head = head[:7]
+	}
+
+	var fields []string
+	fields = append(fields, item.Path)
+	switch {
+	case item.Bare:
+		fields = append(fields, "(bare)")
+	case item.Branch != "":
+		fields = append(fields, head+" ["+item.Branch+"]")
+	default:
+		fields = append(fields, head+" (detached HEAD)")
+	}
+
+	var extra []string
+	if item.Size > 0 {
+		extra = append(extra, formatSize(item.Size))
+	}
+	if item.Stale {
+		extra = append(extra, "stale")
+	}
+	if item.Locked {
+		extra = append(extra, "locked")
+	}
+	if item.Prunable {
+		extra = append(extra, "prunable")
+	}
+	if item.VCS == vcsJJ {
+		extra = append(extra, "jj")
+	}
+	if len(extra) > 0 {
+		fields[len(fields)-1] += " " + strings.Join(extra, " ")
+	}
+	if label := item.Meta.label(); label != "" {
+		fields = append(fields, label)
+	}
+	return strings.Join(fields, "\t")
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSortListItems(t *testing.T) {
+	now := time.Now()
+	items := []listItem{
+		{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Branch: "b"}, LastCommit: now.Add(-2 * time.Hour)}, LastVisit: now.Add(-time.Minute), Size: 10},
+		{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Branch: "c"}, LastCommit: now.Add(-time.Hour)}, Size: 30},
+		{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Branch: "a"}, LastCommit: now.Add(-3 * time.Hour)}, LastVisit: now.Add(-time.Hour), Size: 20},
+	}
+
+	tests := []struct {
+		sortBy string
+		want   string
+	}{
+		{"", "b,c,a"},
+		{"name", "a,b,c"},
+		{"age", "c,b,a"},
+		{"last-used", "b,a,c"},
+		{"size", "c,a,b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			sorted := append([]listItem(nil), items...)
+			sortListItems(sorted, tt.sortBy)
+			var got []string
+			for _, item := range sorted {
+				got = append(got, item.Branch)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("sortListItems(%q) = %v, want %s", tt.sortBy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	now := time.Now()
+	old := listItem{worktreeStatus: worktreeStatus{LastCommit: now.Add(-60 * 24 * time.Hour)}}
+	if !isStale(old, now, defaultStaleAfter) {
+		t.Error("expected worktree without recent commits to be stale")
+	}
+
+	visited := old
+	visited.LastVisit = now.Add(-time.Hour)
+	if isStale(visited, now, defaultStaleAfter) {
+		t.Error("expected recently visited worktree not to be stale")
+	}
+
+	if isStale(listItem{}, now, defaultStaleAfter) {
+		t.Error("expected worktree without any activity data not to be stale")
+	}
+}
+
+func TestFormatListLineMatchesGitFormat(t *testing.T) {
+	item := listItem{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{
+		Path:   "/worktrees/repo/feature",
+		Head:   "0123456789abcdef",
+		Branch: "feature",
+	}}}
+	if got, want := formatListLine(item), "/worktrees/repo/feature\t0123456 [feature]"; got != want {
+		t.Errorf("formatListLine() = %q, want %q", got, want)
+	}
+
+	item.Branch = ""
+	item.Locked = true
+	if got, want := formatListLine(item), "/worktrees/repo/feature\t0123456 (detached HEAD) locked"; got != want {
+		t.Errorf("formatListLine() = %q, want %q", got, want)
+	}
+
+	item.Stale = true
+	if got, want := formatListLine(item), "/worktrees/repo/feature\t0123456 (detached HEAD) stale locked"; got != want {
+		t.Errorf("formatListLine() = %q, want %q", got, want)
+	}
+}
+
+func TestParseStaleAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"30d", 30 * 24 * time.Hour, true},
+		{"2w", 14 * 24 * time.Hour, true},
+		{"36h", 36 * time.Hour, true},
+		{" 7d ", 7 * 24 * time.Hour, true},
+		{"0d", 0, false},
+		{"-1h", 0, false},
+		{"soon", 0, false},
+		{"xd", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseStaleAfter(tt.value)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseStaleAfter(%q) = %v, %v; want %v, ok=%v", tt.value, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCollectListItemsFilters(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	for _, branch := range []string{"feature/clean", "feature/dirty", "bugfix/other"} {
+		runGitCommand(t, repoDir, "branch", branch)
+		runGitCommand(t, repoDir, "worktree", "add", filepath.Join(tmpDir, strings.ReplaceAll(branch, "/", "-")), branch)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "feature-dirty", "x.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+
+	branchesOf := func(items []listItem) string {
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Branch)
+		}
+		return strings.Join(names, ",")
+	}
+
+	items, err := collectListItems("name", "dirty", "")
+	if err != nil {
+		t.Fatalf("collectListItems() error: %v", err)
+	}
+	if got := branchesOf(items); got != "feature/dirty" {
+		t.Errorf("--filter dirty = %s, want feature/dirty", got)
+	}
+
+	items, err = collectListItems("name", "clean", "feature/*")
+	if err != nil {
+		t.Fatalf("collectListItems() error: %v", err)
+	}
+	if got := branchesOf(items); got != "feature/clean" {
+		t.Errorf("--filter clean --branch feature/* = %s, want feature/clean", got)
+	}
+
+	items, err = collectListItems("name", "merged", "")
+	if err != nil {
+		t.Fatalf("collectListItems() error: %v", err)
+	}
+	if got := branchesOf(items); got != "bugfix/other,feature/clean,feature/dirty" {
+		t.Errorf("--filter merged = %s", got)
+	}
+
+	if _, err := collectListItems("bogus", "", ""); err == nil {
+		t.Error("expected invalid sort key to fail")
+	}
+	if _, err := collectListItems("", "bogus", ""); err == nil {
+		t.Error("expected invalid filter to fail")
+	}
+}
+
+func TestRecordVisit(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := t.TempDir()
+
+	if !loadState().lastVisit(path).IsZero() {
+		t.Fatal("expected no visit before recordVisit")
+	}
+	recordVisit(path)
+	if visit := loadState().lastVisit(path); time.Since(visit) > time.Minute {
+		t.Errorf("lastVisit() = %v, want a recent time", visit)
+	}
+}
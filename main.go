@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
@@ -12,8 +13,8 @@ import (
 	"runtime"
 	"strings"
 	"text/template"
+	"time"
 
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 )
 
@@ -22,15 +23,32 @@ var (
 	worktreeRoot     string
 	worktreeStrategy string
 	worktreePattern  string
+	worktreeBase     string
+	// worktreeNamespace is a pattern for the per-repository directory under
+	// the root; see {.namespace}.
+	worktreeNamespace string
+	worktreeRepoName  string
 )
 
 func init() {
+	if isVersionCheck(os.Args[1:]) {
+		return
+	}
 	loadWorktreeConfig()
 	rootCmd.Long = buildRootCmdLong()
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	// cobra takes a lone "-" for an argument, not a command name.
+	if len(os.Args) > 1 && os.Args[1] == "-" {
+		os.Args[1] = lastCmd.Name()
+	}
+	if isVersionCheck(os.Args[1:]) {
+		printVersionCheck()
+		return
+	}
+	if cmd, err := rootCmd.ExecuteC(); err != nil {
+		auditFailure(cmd, os.Args[1:], err)
 		os.Exit(1)
 	}
 }
@@ -54,15 +72,44 @@ func init() {
 	rootCmd.AddCommand(pruneCmd)
 	rootCmd.AddCommand(cleanupCmd)
 	rootCmd.AddCommand(shellenvCmd)
+	shellenvCmd.Flags().BoolVar(&shellenvCompletions, "completions", false, "Include the full completion of commands, flags and branch names instead of the built-in one")
+	shellenvCmd.Flags().BoolVar(&shellenvVersionCheck, "version-check", false, "Print the shell integration protocol and version of this binary, for the integration's handshake")
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(infoCmd)
+	infoCmd.Flags().BoolVar(&infoJSON, "json", false, "With a branch, print the details as JSON")
+	checkoutCmd.Flags().BoolVar(&checkoutOrphan, "orphan", false, "Create a new branch without history in a worktree with an empty tree")
+	checkoutCmd.Flags().StringVarP(&checkoutNewBranch, "branch", "b", "", "Create this new branch from [base] instead of checking out an existing one")
+	checkoutCmd.Flags().StringVarP(&checkoutForceBranch, "force-branch", "B", "", "Like -b, but start an existing branch over from [base] (asks first; it must not be checked out)")
+	checkoutCmd.Flags().StringVar(&checkoutApply, "apply", "", "With -b or --after, apply this patch file ('-' for stdin) in the new worktree")
+	checkoutCmd.Flags().StringVar(&checkoutAfter, "after", "", "Create the new branch on top of this in-progress branch and record it as its parent")
+	checkoutCmd.Flags().BoolVar(&checkoutForce, "force", false, "Check out a branch another worktree is rebasing or bisecting with a detached HEAD")
+	checkoutCmd.Flags().BoolVar(&checkoutEphemeral, "ephemeral", false, "Mark the new worktree as temporary: 'wt cleanup' removes it once it expires (default after 1d)")
+	checkoutCmd.Flags().StringVar(&checkoutTTL, "ttl", "", "How long an ephemeral worktree lives, e.g. 2d, 1w or 8h (implies --ephemeral)")
+	checkoutCmd.Flags().StringVar(&checkoutSubmodule, "submodule", "", "Check out in a worktree of the submodule at this path instead of the superproject")
+	checkoutCmd.Flags().BoolVar(&checkoutTemp, "temp", false, "Check out any ref in a new directory under the system temp directory and print its path")
+	checkoutCmd.Flags().IntVar(&checkoutDepth, "depth", 0, "Fetch a branch that is not fetched yet with at most this many commits of history")
+	checkoutCmd.Flags().StringArrayVar(&branchFields, "field", nil, "With -b or --after, a branch template field as key=value (repeatable)")
+	createCmd.Flags().StringArrayVar(&branchFields, "field", nil, "A branch template field as key=value (repeatable)")
+	checkoutCmd.Flags().BoolVar(&checkoutFuzzy, "fuzzy", false, "Use the closest branch when the name doesn't match exactly and only one is close")
 	removeCmd.Flags().BoolVarP(&removeForce, "force", "f", false, "Force removal even if worktree has modifications")
+	removeCmd.Flags().BoolVarP(&removeDeleteBranch, "delete-branch", "d", false, "Also delete the branch, asking first when it has commits that are on no remote")
+	removeCmd.Flags().BoolVar(&removeBackup, "backup", false, "Back up a branch with commits that are on no remote to refs/wt/backup/ without asking")
+	removeCmd.Flags().BoolVar(&removeDiscardCommits, "discard-commits", false, "Remove a worktree whose branch has commits that are on no remote without asking")
+	removeCmd.Flags().BoolVar(&removeFuzzy, "fuzzy", false, "Use the closest worktree when the name doesn't match exactly and only one is close")
+	removeCmd.Flags().StringVar(&removeSubmodule, "submodule", "", "Remove a worktree of the submodule at this path instead of the superproject")
 	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "Preview what would be removed without making changes")
+	cleanupCmd.Flags().StringVar(&cleanupFree, "free", "", "Remove the fewest clean merged, expired or stale worktrees that free this much space, e.g. 20G")
 	cleanupCmd.Flags().BoolVarP(&cleanupForce, "force", "f", false, "Remove all merged worktrees without confirmation")
+	cleanupCmd.Flags().BoolVarP(&cleanupDeleteBranch, "delete-branch", "d", false, "Also delete the branches of the removed worktrees")
+	cleanupCmd.Flags().BoolVar(&cleanupJSON, "json", false, "Print what was removed or skipped, with sizes, as JSON")
+	cleanupCmd.Flags().StringVar(&cleanupReport, "report", "", "Print a shareable summary of the cleanup: markdown or html")
 	initCmd.Flags().BoolVar(&initDryRun, "dry-run", false, "Preview changes without modifying files")
 	initCmd.Flags().BoolVar(&initUninstall, "uninstall", false, "Remove wt configuration from shell")
 	initCmd.Flags().BoolVar(&initNoPrompt, "no-prompt", false, "Skip activation instructions (for automated installs)")
+	initCmd.Flags().BoolVar(&initLogin, "login", false, "Install into the login shell file (~/.bash_profile, ~/.zprofile)")
+	initCmd.Flags().BoolVar(&initAllHosts, "all-hosts", false, "PowerShell: configure the profiles of both Windows PowerShell and PowerShell 7")
+	initCmd.Flags().BoolVar(&initInteractive, "interactive", false, "Install into the interactive shell file (~/.bashrc, ~/.zshrc)")
 }
 
 // Helper functions
@@ -72,21 +119,32 @@ type repoInfo struct {
 	Host  string
 	Owner string
 	Name  string
+
+	// nameFromDir is set when Name was derived from the clone's directory,
+	// which changes if the clone is renamed.
+	nameFromDir bool
 }
 
 func loadWorktreeConfig() {
-	worktreeRoot = os.Getenv("WORKTREE_ROOT")
-	if worktreeRoot == "" {
-		home, _ := os.UserHomeDir()
-		worktreeRoot = filepath.Join(home, "dev", "worktrees")
+	cfg := loadConfig()
+	worktreeRoot = cfg.get("root")
+	worktreeStrategy = strings.ToLower(strings.TrimSpace(cfg.get("strategy")))
+	worktreePattern = strings.TrimSpace(cfg.get("pattern"))
+	worktreeBase = strings.TrimSpace(cfg.get("base"))
+	worktreeNamespace = strings.TrimSpace(cfg.get("namespace"))
+	worktreeRepoName = strings.TrimSpace(cfg.get("name"))
+	if timeout, err := parseGitTimeout(cfg.get("git-timeout")); err == nil {
+		gitTimeout = timeout
 	}
-
-	worktreeStrategy = strings.ToLower(strings.TrimSpace(os.Getenv("WORKTREE_STRATEGY")))
-	if worktreeStrategy == "" {
-		worktreeStrategy = "global"
+	confirmPolicies = cfg.Confirm
+	prefetchEvery, _ = prefetchInterval(cfg.get("prefetch"))
+	scratchDir, _ = scratchName(cfg.get("scratch"))
+	if dir := strings.TrimSpace(cfg.get("admin-dir")); dir != "" {
+		worktreeAdminDir, _ = filepath.Abs(expandHome(dir))
+	} else {
+		worktreeAdminDir = ""
 	}
-
-	worktreePattern = strings.TrimSpace(os.Getenv("WORKTREE_PATTERN"))
+	configProblems = cfg.Problems
 }
 
 func buildRootCmdLong() string {
@@ -105,15 +163,25 @@ Pattern:  %s
 Root:     %s
 
 Run 'wt info' to see available strategies and pattern variables.
-Set WORKTREE_ROOT, WORKTREE_STRATEGY, and WORKTREE_PATTERN to customize.`,
+Set WORKTREE_ROOT, WORKTREE_STRATEGY, and WORKTREE_PATTERN to customize,
+or see 'wt config --help' for config files.`,
 		worktreeStrategy,
 		pattern,
 		worktreeRoot,
 	)
 }
 
+// getDefaultBase returns the branch new branches start from and merges are
+// checked against: the configured base, else the remote's default branch.
 func getDefaultBase() string {
-	cmd := exec.Command("git", "symbolic-ref", "refs/remotes/origin/HEAD")
+	if worktreeBase != "" {
+		return worktreeBase
+	}
+	return detectDefaultBranch()
+}
+
+func detectDefaultBranch() string {
+	cmd := gitCommand("symbolic-ref", "refs/remotes/origin/HEAD")
 	output, err := cmd.Output()
 	if err != nil {
 		return "main"
@@ -123,118 +191,96 @@ func getDefaultBase() string {
 }
 
 func getRepoInfo() (repoInfo, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd := gitCommand("rev-parse", "--show-toplevel")
 	output, err := cmd.Output()
 	var repoRoot string
 	isBare := false
 	if err == nil {
 		repoRoot = strings.TrimSpace(string(output))
 	} else {
-		cmd = exec.Command("git", "rev-parse", "--is-bare-repository")
+		cmd = gitCommand("rev-parse", "--is-bare-repository")
 		output, err = cmd.Output()
 		if err != nil || strings.TrimSpace(string(output)) != "true" {
 			return repoInfo{}, fmt.Errorf("not in a git repository")
 		}
 		isBare = true
-		cmd = exec.Command("git", "rev-parse", "--absolute-git-dir")
+		cmd = gitCommand("rev-parse", "--absolute-git-dir")
 		output, err = cmd.Output()
 		if err != nil {
 			return repoInfo{}, fmt.Errorf("not in a git repository")
 		}
 		repoRoot = strings.TrimSpace(string(output))
 	}
-	repoName := ""
-	cmd = exec.Command("git", "remote", "get-url", "origin")
-	output, err = cmd.Output()
-	if err == nil {
+	// The name used in paths comes from, in order: a pinned wt.name, the
+	// origin URL, or the clone's directory name.
+	repoName := worktreeRepoName
+	nameFromDir := false
+	var remote repoInfo
+	if output, err := gitCommand("remote", "get-url", "origin").Output(); err == nil {
 		if parsed, ok := parseRemoteURL(strings.TrimSpace(string(output))); ok {
-			repoName = parsed.Name
+			remote = parsed
 		}
 	}
 	if repoName == "" {
+		repoName = remote.Name
+	}
+	if repoName == "" {
+		nameFromDir = true
 		repoName = strings.TrimSuffix(filepath.Base(repoRoot), ".git")
-		if cmd := exec.Command("git", "rev-parse", "--git-common-dir"); cmd != nil {
-			if output, err = cmd.Output(); err == nil {
-				commonDir := strings.TrimSpace(string(output))
-				if commonDir != "" {
-					if !filepath.IsAbs(commonDir) {
-						commonDir = filepath.Join(repoRoot, commonDir)
-					}
-					commonDir = filepath.Clean(commonDir)
-					base := filepath.Base(commonDir)
-					if base == ".git" {
-						repoName = filepath.Base(filepath.Dir(commonDir))
-					} else {
-						repoName = strings.TrimSuffix(base, ".git")
-					}
+		if output, err := gitCommand("rev-parse", "--git-common-dir").Output(); err == nil {
+			commonDir := strings.TrimSpace(string(output))
+			if commonDir != "" {
+				if !filepath.IsAbs(commonDir) {
+					commonDir = filepath.Join(repoRoot, commonDir)
+				}
+				commonDir = filepath.Clean(commonDir)
+				base := filepath.Base(commonDir)
+				if base == ".git" {
+					repoName = filepath.Base(filepath.Dir(commonDir))
+				} else {
+					repoName = strings.TrimSuffix(base, ".git")
 				}
 			}
 		}
 	}
 	info := repoInfo{
-		Main: getMainWorktreePath(getDefaultBase(), repoName, repoRoot, isBare),
-		Name: repoName,
-	}
-
-	if err == nil {
-		if parsed, ok := parseRemoteURL(strings.TrimSpace(string(output))); ok {
-			info.Host = parsed.Host
-			info.Owner = parsed.Owner
-		}
+		Main:        getMainWorktreePath(detectDefaultBranch(), repoName, repoRoot, isBare),
+		Host:        remote.Host,
+		Owner:       remote.Owner,
+		Name:        repoName,
+		nameFromDir: nameFromDir,
 	}
 
 	return info, nil
 }
 
 func getMainWorktreePath(defaultBranch, repoName, repoRoot string, isBare bool) string {
-	cmd := exec.Command("git", "worktree", "list", "--porcelain")
-	output, err := cmd.Output()
+	entries, err := snapshot.Worktrees()
 	if err == nil {
-		type entry struct {
-			path   string
-			branch string
-		}
-		var entries []entry
-		var current entry
-		for _, line := range strings.Split(string(output), "\n") {
-			line = strings.TrimSpace(line)
-			if line == "" {
-				continue
-			}
-			if strings.HasPrefix(line, "worktree ") {
-				if current.path != "" {
-					entries = append(entries, current)
-				}
-				current = entry{path: strings.TrimPrefix(line, "worktree ")}
-				continue
-			}
-			if strings.HasPrefix(line, "branch ") {
-				current.branch = strings.TrimPrefix(line, "branch ")
-			}
-		}
-		if current.path != "" {
-			entries = append(entries, current)
+		// git lists the worktree of the common git directory first, from
+		// whichever worktree it runs; only a bare repository has none.
+		if len(entries) > 0 && !entries[0].Bare {
+			return entries[0].Path
 		}
 		if defaultBranch != "" {
-			target := "refs/heads/" + defaultBranch
 			for _, e := range entries {
-				if e.branch == target {
-					return e.path
+				if e.Branch == defaultBranch {
+					return e.Path
 				}
 			}
 		}
 		for _, e := range entries {
-			if filepath.Base(e.path) == repoName {
-				return e.path
+			if filepath.Base(e.Path) == repoName {
+				return e.Path
 			}
 		}
 		for _, e := range entries {
-			if stat, err := os.Stat(filepath.Join(e.path, ".git")); err == nil && stat.IsDir() {
-				return e.path
+			if stat, err := os.Stat(filepath.Join(e.Path, ".git")); err == nil && stat.IsDir() {
+				return e.Path
 			}
 		}
 		if len(entries) > 0 {
-			return entries[0].path
+			return entries[0].Path
 		}
 	}
 
@@ -316,21 +362,17 @@ func getPRNumber(input string) (string, error) {
 }
 
 func worktreeExists(branch string) (string, bool) {
-	cmd := exec.Command("git", "worktree", "list")
+	cmd := gitCommand("worktree", "list", "--porcelain")
 	output, err := cmd.Output()
 	if err != nil {
 		return "", false
 	}
 
-	lines := strings.Split(string(output), "\n")
-	searchPattern := fmt.Sprintf("[%s]", branch)
-	for _, line := range lines {
-		if strings.Contains(line, searchPattern) {
-			// Extract the path (first field)
-			fields := strings.Fields(line)
-			if len(fields) > 0 {
-				return fields[0], true
-			}
+	entries := parseWorktreePorcelain(string(output))
+	fixSubmoduleMain(entries)
+	for _, entry := range entries {
+		if entry.Branch == branch {
+			return entry.Path, true
 		}
 	}
 	return "", false
@@ -338,17 +380,46 @@ func worktreeExists(branch string) (string, bool) {
 
 func branchExists(branch string) bool {
 	// Check local branch
-	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", fmt.Sprintf("refs/heads/%s", branch))
+	cmd := gitCommand("show-ref", "--verify", "--quiet", fmt.Sprintf("refs/heads/%s", branch))
 	if cmd.Run() == nil {
 		return true
 	}
 
 	// Check remote branch
-	cmd = exec.Command("git", "show-ref", "--verify", "--quiet", fmt.Sprintf("refs/remotes/origin/%s", branch))
+	cmd = gitCommand("show-ref", "--verify", "--quiet", fmt.Sprintf("refs/remotes/origin/%s", branch))
 	return cmd.Run() == nil
 }
 
 func buildWorktreePath(info repoInfo, branch string) (string, error) {
+	rendered, err := worktreePathFor(info, branch)
+	if err != nil {
+		return "", err
+	}
+	parent := filepath.Dir(rendered)
+	infoStat, err := os.Stat(longPath(parent))
+	switch {
+	case err == nil:
+		if !infoStat.IsDir() {
+			return "", fmt.Errorf("worktree path %s is not a directory", parent)
+		}
+		if err := checkNamespaceCollision(parent); err != nil {
+			return "", err
+		}
+	case os.IsNotExist(err):
+		if err := os.MkdirAll(longPath(parent), 0o755); err != nil {
+			return "", fmt.Errorf("failed to create worktree directory %s: %w", parent, err)
+		}
+	default:
+		return "", fmt.Errorf("failed to access worktree directory %s: %w", parent, err)
+	}
+
+	checkLongPathSupport(rendered)
+	return rendered, nil
+}
+
+// worktreePathFor returns where the layout puts the worktree of branch,
+// without creating or checking anything, e.g. for dry runs.
+func worktreePathFor(info repoInfo, branch string) (string, error) {
 	pattern, err := resolveWorktreePattern()
 	if err != nil {
 		return "", err
@@ -360,7 +431,41 @@ func buildWorktreePath(info repoInfo, branch string) (string, error) {
 		"branchSafe":   strings.TrimSpace(strings.ReplaceAll(strings.ReplaceAll(branch, "/", "-"), "\\", "-")),
 		"worktreeRoot": worktreeRoot,
 	}
+	namespace, err := renderNamespace(worktreeNamespace, context)
+	if err != nil {
+		return "", err
+	}
+	context["namespace"] = namespace
 
+	rendered, err := renderWorktreePattern(pattern, context)
+	if err != nil {
+		return "", err
+	}
+
+	rendered = filepath.FromSlash(rendered)
+	if !filepath.IsAbs(rendered) {
+		rendered = filepath.Join(worktreeRoot, rendered)
+	}
+
+	return filepath.Clean(rendered), nil
+}
+
+// pinRepoName records a directory-derived repo name in git config
+// (wt.name) once the repository gets worktrees, so renaming the clone later
+// does not scatter its worktrees over two directories.
+func pinRepoName(info repoInfo) {
+	if !info.nameFromDir || info.Name == "" || worktreeRepoName == info.Name {
+		return
+	}
+	if err := gitCommand("config", "--local", "wt.name", info.Name).Run(); err != nil {
+		return
+	}
+	worktreeRepoName = info.Name
+	infof("Pinned repository name %q (git config wt.name)\n", info.Name)
+}
+
+// renderWorktreePattern expands the {.var} placeholders of pattern.
+func renderWorktreePattern(pattern string, context map[string]any) (string, error) {
 	if pattern == "" {
 		return "", fmt.Errorf("worktree pattern cannot be empty")
 	}
@@ -377,30 +482,7 @@ func buildWorktreePath(info repoInfo, branch string) (string, error) {
 	if err := tpl.Execute(&renderedBuf, context); err != nil {
 		return "", fmt.Errorf("pattern variables missing values: %w", err)
 	}
-
-	rendered := renderedBuf.String()
-	rendered = filepath.FromSlash(rendered)
-	if !filepath.IsAbs(rendered) {
-		rendered = filepath.Join(worktreeRoot, rendered)
-	}
-
-	rendered = filepath.Clean(rendered)
-	parent := filepath.Dir(rendered)
-	infoStat, err := os.Stat(parent)
-	switch {
-	case err == nil:
-		if !infoStat.IsDir() {
-			return "", fmt.Errorf("worktree path %s is not a directory", parent)
-		}
-	case os.IsNotExist(err):
-		if err := os.MkdirAll(parent, 0o755); err != nil {
-			return "", fmt.Errorf("failed to create worktree directory %s: %w", parent, err)
-		}
-	default:
-		return "", fmt.Errorf("failed to access worktree directory %s: %w", parent, err)
-	}
-
-	return rendered, nil
+	return renderedBuf.String(), nil
 }
 
 func cleanupWorktreePath(worktreePath string) error {
@@ -408,41 +490,49 @@ func cleanupWorktreePath(worktreePath string) error {
 		return nil
 	}
 
-	if err := os.RemoveAll(worktreePath); err != nil && !os.IsNotExist(err) {
+	if err := os.RemoveAll(longPath(worktreePath)); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove worktree directory %s: %w", worktreePath, err)
 	}
 
-	absRoot, err := filepath.Abs(worktreeRoot)
-	if err != nil {
-		return nil
-	}
+	removeEmptyParents(worktreePath)
+	return nil
+}
 
+// removeEmptyParents removes the directories above a removed or moved
+// worktree that are left empty, up to the root; a namespace such as
+// host/owner/name spans several levels.
+func removeEmptyParents(worktreePath string) {
 	absWorktreePath, err := filepath.Abs(worktreePath)
 	if err != nil {
-		return nil
+		return
 	}
-
-	repoDir := filepath.Dir(absWorktreePath)
-	if strings.HasPrefix(repoDir, absRoot) {
-		if empty, err := isDirEmpty(repoDir); err == nil && empty {
-			_ = os.Remove(repoDir)
+	root := resolvePath(worktreeRoot)
+	for dir := filepath.Dir(absWorktreePath); isWithin(worktreeRoot, dir) && resolvePath(dir) != root; dir = filepath.Dir(dir) {
+		if empty, err := isDirEmpty(dir); err != nil || !empty {
+			break
+		}
+		if err := os.Remove(longPath(dir)); err != nil {
+			break
 		}
 	}
-
-	return nil
 }
 
 func resolveWorktreePattern() (string, error) {
 	if worktreePattern != "" {
 		return worktreePattern, nil
 	}
-	if worktreeStrategy == "custom" {
+	return patternForStrategy(worktreeStrategy)
+}
+
+// patternForStrategy returns the default pattern of a strategy.
+func patternForStrategy(strategy string) (string, error) {
+	if strategy == "custom" {
 		return "", fmt.Errorf("WORKTREE_PATTERN is required when WORKTREE_STRATEGY is 'custom'")
 	}
 
-	switch worktreeStrategy {
+	switch strategy {
 	case "global":
-		return "{.worktreeRoot}/{.repo.Name}/{.branch}", nil
+		return "{.worktreeRoot}/{.namespace}/{.branch}", nil
 	case "sibling-repo", "sibling":
 		return "{.repo.Main}/../{.repo.Name}-{.branchSafe}", nil
 	case "parent-worktrees", "parent-centered":
@@ -454,12 +544,22 @@ func resolveWorktreePattern() (string, error) {
 	case "inside-dotdir", "nested-local":
 		return "{.repo.Main}/.worktrees/{.branch}", nil
 	default:
-		return "", fmt.Errorf("unsupported WORKTREE_STRATEGY: %s", worktreeStrategy)
+		return "", fmt.Errorf("unsupported WORKTREE_STRATEGY: %s", strategy)
+	}
+}
+
+// worktreeAddArgs builds a 'git worktree add' invocation, silencing git's
+// progress output in quiet mode.
+func worktreeAddArgs(args ...string) []string {
+	gitArgs := []string{"worktree", "add"}
+	if quiet {
+		gitArgs = append(gitArgs, "--quiet")
 	}
+	return append(gitArgs, args...)
 }
 
 func isDirEmpty(path string) (bool, error) {
-	dir, err := os.Open(path)
+	dir, err := os.Open(longPath(path))
 	switch {
 	case os.IsNotExist(err):
 		return true, nil
@@ -475,13 +575,21 @@ func isDirEmpty(path string) (bool, error) {
 	return false, err
 }
 
+// printCDMarker tells the shell wrapper where to cd. In quiet mode only the
+// bare path is printed, since that is the result scripts are after.
 func printCDMarker(path string) {
-	fmt.Printf("wt navigating to: %s\n", path)
+	if quiet {
+		fmt.Println(path)
+	} else {
+		fmt.Printf("wt navigating to: %s\n", path)
+	}
+	writeCDFile(path)
+	recordVisit(path)
 }
 
 func getAvailableBranches() ([]string, error) {
 	// Get local and remote branches
-	cmd := exec.Command("git", "branch", "-a", "--format=%(refname:short)")
+	cmd := gitCommand("branch", "-a", "--format=%(refname:short)")
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -523,7 +631,7 @@ func getAvailableBranches() ([]string, error) {
 }
 
 func getExistingWorktreeBranches() ([]string, error) {
-	cmd := exec.Command("git", "worktree", "list")
+	cmd := gitCommand("worktree", "list")
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, err
@@ -544,7 +652,7 @@ func getExistingWorktreeBranches() ([]string, error) {
 }
 
 func getMergedBranches(base string) ([]string, error) {
-	cmd := exec.Command("git", "branch", "--merged", base, "--format=%(refname:short)")
+	cmd := gitCommand("branch", "--merged", base, "--format=%(refname:short)")
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get merged branches: %w", err)
@@ -579,8 +687,7 @@ func parsePROutput(output string) ([]string, []string) {
 }
 
 func getOpenPRs() ([]string, []string, error) {
-	cmd := exec.Command("gh", "pr", "list", "--json", "number,title", "--jq", ".[] | \"\\(.number)\\t\\(.title)\"")
-	output, err := cmd.Output()
+	output, err := githubProvider.output("open PRs", "pr", "list", "--json", "number,title", "--jq", ".[] | \"\\(.number)\\t\\(.title)\"")
 	if err != nil {
 		return nil, nil, err
 	}
@@ -604,8 +711,7 @@ func parseMROutput(output string) ([]string, []string) {
 }
 
 func getOpenMRs() ([]string, []string, error) {
-	cmd := exec.Command("glab", "mr", "list")
-	output, err := cmd.Output()
+	output, err := gitlabProvider.output("open MRs", "mr", "list")
 	if err != nil {
 		return nil, nil, err
 	}
@@ -617,11 +723,163 @@ func getOpenMRs() ([]string, []string, error) {
 // Commands
 
 var checkoutCmd = &cobra.Command{
-	Use:     "checkout [branch]",
+	Use:     "checkout [branch | -b|-B <branch> [base] | --after <parent> <new-branch>]",
 	Aliases: []string{"co"},
 	Short:   "Checkout existing branch in new worktree",
-	Args:    cobra.RangeArgs(0, 1),
+	Long: `Checkout an existing branch in a new worktree and cd into it.
+
+With --orphan, create a new branch without history in a worktree with an
+empty tree instead, e.g. for gh-pages style branches. Gits older than 2.42
+have no 'git worktree add --orphan'; wt emulates it there.
+
+In a shallow clone, which fetches only its default branch, a branch that
+is not fetched yet is fetched from origin with its tip only; --depth asks
+for more history. --depth works in full clones too, but git then records
+the clone as shallow ('git fetch --unshallow' undoes that). In a partial
+clone the files of the branch are fetched before the worktree is created.
+
+Unknown branch names get "did you mean" suggestions; with --fuzzy the
+closest branch is used when it is the only close one.
+
+With -b, create a new branch from base (default: main/master) instead, and
+with --apply apply a patch file in the new worktree ('-' reads stdin). Mails
+from 'git format-patch' are applied with 'git am' and keep their commits;
+plain diffs are applied with 'git apply' and left staged. When the patch
+conflicts, the worktree is kept and wt lists the files to resolve.
+
+With --force-branch (-B) instead of -b, an existing branch is reset to base
+to start over, e.g. on a ticket: wt refuses while the branch is checked out
+in a worktree, asks before resetting and names the old tip to restore it
+from. Commits found on no remote always need a yes, so in CI they stop it.
+
+With --after, create a new branch stacked on another in-progress branch:
+it starts from the tip of the parent, and the parent is recorded in git
+config (branch.<name>.wt-parent) so the stack can be rebased later.
+
+With --ephemeral or --ttl, the new worktree is temporary, e.g. for a quick
+review or a CI reproduction: 'wt cleanup' removes it once it expires, without
+checking whether the branch is merged and without asking. The branch itself
+is kept. 'wt describe <branch> --clear' makes the worktree permanent.
+
+With --submodule, the worktree is one of the submodule at that path (as in
+.gitmodules) rather than of the superproject, e.g. to work on a component
+that is vendored as a submodule. Its path and name come from the submodule;
+'wt remove --submodule' and 'wt list --submodule' find it again, and inside
+the new worktree wt works on the submodule by itself.
+
+With --temp, the worktree goes to a new directory under the system temp
+directory instead of WORKTREE_ROOT, e.g. for a CI job or script that needs
+a short-lived checkout. It prints only the path and does not cd. Any ref
+works: a branch no worktree has yet is checked out as the branch (so --ttl
+can expire it), anything else with a detached HEAD. Remove it with
+'git worktree remove <path>', or 'wt remove <branch>'.
+
+Examples:
+  wt checkout feature-x         # Existing local or remote branch
+  wt checkout                   # Pick a branch interactively
+  wt checkout --fuzzy feat-x    # Typos are fine if only feature-x is close
+  wt checkout --orphan gh-pages # New branch with no history and no files
+  wt checkout --depth 10 big-feature  # Fetch only recent history of the branch
+  wt checkout --apply fix.patch -b hotfix/issue-99 release-1.2
+  git diff | command wt checkout --apply - -b try-this
+  wt checkout --force-branch ticket-42           # Start ticket-42 over from main
+  wt checkout --after feature-x feature-x-part2  # Stacked on feature-x
+  wt checkout --submodule libs/auth feature/x    # Worktree of a submodule
+  wt checkout --ttl 2d pr-review-branch          # Removed by 'wt cleanup' in 2 days
+  dir=$(command wt checkout --temp v1.2.0)       # Throwaway checkout of a tag`,
+	Args: cobra.RangeArgs(0, 1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if checkoutSubmodule != "" {
+			if err := enterSubmodule(checkoutSubmodule); err != nil {
+				return err
+			}
+		}
+		ttl, err := ephemeralTTL()
+		if err != nil {
+			return err
+		}
+		if checkoutTemp {
+			if checkoutAfter != "" || checkoutNewBranch != "" || checkoutForceBranch != "" || checkoutOrphan || checkoutApply != "" {
+				return fmt.Errorf("--temp cannot be combined with -b, -B, --after, --orphan or --apply")
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("--temp needs a branch, tag or commit")
+			}
+			// Scripts capture the path; progress would only get in the way.
+			quiet = true
+			return checkoutTempWorktree(args[0], ttl)
+		}
+		if checkoutAfter != "" {
+			if checkoutOrphan {
+				return fmt.Errorf("--after cannot be combined with --orphan")
+			}
+			branch := checkoutNewBranch
+			switch {
+			case branch == "" && len(args) == 1:
+				branch = args[0]
+			case branch == "" || len(args) > 0:
+				return fmt.Errorf("--after takes the place of the base: wt checkout --after <parent> <new-branch>")
+			}
+			branch, err := newBranchName(branch)
+			if err != nil {
+				return err
+			}
+			if err := checkoutStacked(branch, checkoutAfter, checkoutApply); err != nil {
+				return err
+			}
+			setEphemeral(branch, ttl)
+			return nil
+		}
+		if checkoutForceBranch != "" {
+			if checkoutNewBranch != "" || checkoutOrphan {
+				return fmt.Errorf("--force-branch cannot be combined with -b or --orphan")
+			}
+			base := getDefaultBase()
+			if len(args) > 0 {
+				base = args[0]
+			}
+			branch, err := newBranchName(checkoutForceBranch)
+			if err != nil {
+				return err
+			}
+			if err := recreateBranch(branch, base, checkoutApply); err != nil {
+				return err
+			}
+			setEphemeral(branch, ttl)
+			return nil
+		}
+		if checkoutApply != "" && checkoutNewBranch == "" {
+			return fmt.Errorf("--apply needs a new branch: wt checkout --apply <patch> -b <branch> [base]")
+		}
+		if checkoutNewBranch != "" {
+			if checkoutOrphan {
+				return fmt.Errorf("-b cannot be combined with --orphan")
+			}
+			base := getDefaultBase()
+			if len(args) > 0 {
+				base = args[0]
+			}
+			branch, err := newBranchName(checkoutNewBranch)
+			if err != nil {
+				return err
+			}
+			if err := checkoutWithPatch(branch, base, checkoutApply); err != nil {
+				return err
+			}
+			setEphemeral(branch, ttl)
+			return nil
+		}
+		if checkoutOrphan {
+			if len(args) == 0 {
+				return fmt.Errorf("--orphan needs a branch name")
+			}
+			if err := checkoutOrphanBranch(args[0]); err != nil {
+				return err
+			}
+			setEphemeral(args[0], ttl)
+			return nil
+		}
+
 		var branch string
 
 		// Interactive selection if no branch provided
@@ -634,13 +892,9 @@ var checkoutCmd = &cobra.Command{
 				return fmt.Errorf("no available branches to checkout")
 			}
 
-			prompt := promptui.Select{
-				Label: "Select branch to checkout",
-				Items: branches,
-			}
-			_, result, err := prompt.Run()
+			_, result, err := selectPrompt("Select branch to checkout", branches)
 			if err != nil {
-				return fmt.Errorf("selection cancelled")
+				return err
 			}
 			branch = result
 		} else {
@@ -651,43 +905,94 @@ var checkoutCmd = &cobra.Command{
 			return err
 		}
 
+		fetchMissingBranch(branch)
+
+		// Check if branch exists
+		if !branchExists(branch) {
+			branches, _ := getAvailableBranches()
+			notFound := fmt.Errorf("branch '%s' does not exist\nUse 'wt create %s' to create a new branch", branch, branch)
+			if branch, err = resolveFuzzy(branch, branches, checkoutFuzzy, notFound); err != nil {
+				return err
+			}
+		}
+
 		// Check if worktree already exists
 		if existingPath, exists := worktreeExists(branch); exists {
-			fmt.Printf("✓ Worktree already exists: %s\n", existingPath)
+			successf("Worktree already exists: %s", existingPath)
+			if ttl > 0 {
+				warnf("The existing worktree is kept as it is, not made ephemeral\n")
+			}
 			printCDMarker(existingPath)
 			return nil
 		}
-
-		// Check if branch exists
-		if !branchExists(branch) {
-			return fmt.Errorf("branch '%s' does not exist\nUse 'wt create %s' to create a new branch", branch, branch)
+		if busyPath, reason, busy := busyWorktree(branch); busy {
+			return checkoutBusyBranch(info, branch, busyPath, reason)
 		}
 
 		path, err := buildWorktreePath(info, branch)
 		if err != nil {
 			return err
 		}
+		if err := prefetchObjects(checkoutRef(branch)); err != nil {
+			return err
+		}
 
 		// Create worktree
-		gitCmd := exec.Command("git", "worktree", "add", path, branch)
-		gitCmd.Stdout = os.Stdout
+		gitCmd := repoVCS().addWorktree(path, branch)
+		gitCmd.Stdout = gitOutput()
 		gitCmd.Stderr = os.Stderr
 		if err := gitCmd.Run(); err != nil {
 			return fmt.Errorf("failed to create worktree: %w", err)
 		}
 
-		fmt.Printf("✓ Worktree created at: %s\n", path)
+		successf("Worktree created at: %s", path)
+		setEphemeral(branch, ttl)
+		prepareWorktree(path)
+		applyWorktreeGitConfig(info, path)
+		runPostCheckoutHooks(info, branch, path)
 		printCDMarker(path)
 		return nil
 	},
 }
 
+// checkoutOrphanBranch creates branch as an orphan in a new worktree.
+func checkoutOrphanBranch(branch string) error {
+	info, err := getRepoInfo()
+	if err != nil {
+		return err
+	}
+	if existingPath, exists := worktreeExists(branch); exists {
+		return fmt.Errorf("branch '%s' is already checked out at %s", branch, existingPath)
+	}
+	if branchExists(branch) {
+		return fmt.Errorf("branch '%s' already exists\nUse 'wt checkout %s' to check it out", branch, branch)
+	}
+
+	path, err := buildWorktreePath(info, branch)
+	if err != nil {
+		return err
+	}
+	if err := addOrphanWorktree(path, branch); err != nil {
+		return fmt.Errorf("failed to create worktree: %w", err)
+	}
+
+	successf("Worktree created at: %s (orphan branch %s, empty tree)", path, branch)
+	prepareWorktree(path)
+	applyWorktreeGitConfig(info, path)
+	runPostCheckoutHooks(info, branch, path)
+	printCDMarker(path)
+	return nil
+}
+
 var createCmd = &cobra.Command{
 	Use:   "create <branch> [base-branch]",
 	Short: "Create new branch in worktree (default: main/master)",
 	Args:  cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		branch := args[0]
+		branch, err := newBranchName(args[0])
+		if err != nil {
+			return err
+		}
 		base := getDefaultBase()
 		if len(args) > 1 {
 			base = args[1]
@@ -700,7 +1005,7 @@ var createCmd = &cobra.Command{
 
 		// Check if worktree already exists
 		if existingPath, exists := worktreeExists(branch); exists {
-			fmt.Printf("✓ Worktree already exists: %s\n", existingPath)
+			successf("Worktree already exists: %s", existingPath)
 			printCDMarker(existingPath)
 			return nil
 		}
@@ -711,14 +1016,18 @@ var createCmd = &cobra.Command{
 		}
 
 		// Create new branch and worktree
-		gitCmd := exec.Command("git", "worktree", "add", path, "-b", branch, base)
-		gitCmd.Stdout = os.Stdout
+		gitCmd := repoVCS().addWorktree(path, "-b", branch, base)
+		gitCmd.Stdout = gitOutput()
 		gitCmd.Stderr = os.Stderr
 		if err := gitCmd.Run(); err != nil {
 			return fmt.Errorf("failed to create worktree: %w", err)
 		}
 
-		fmt.Printf("✓ Worktree created at: %s\n", path)
+		successf("Worktree created at: %s", path)
+		prepareWorktree(path)
+		applyWorktreeGitConfig(info, path)
+		setupBranchPush(path, branch)
+		runPostCheckoutHooks(info, branch, path)
 		printCDMarker(path)
 		return nil
 	},
@@ -744,19 +1053,15 @@ Examples:
 		if len(args) == 0 {
 			numbers, labels, err := getOpenPRs()
 			if err != nil {
-				return fmt.Errorf("failed to get PRs: %w (is 'gh' CLI installed?)", err)
+				return fmt.Errorf("failed to get PRs: %w", err)
 			}
 			if len(labels) == 0 {
 				return fmt.Errorf("no open PRs found")
 			}
 
-			prompt := promptui.Select{
-				Label: "Select Pull Request",
-				Items: labels,
-			}
-			idx, _, err := prompt.Run()
+			idx, _, err := selectPrompt("Select Pull Request", labels)
 			if err != nil {
-				return fmt.Errorf("selection cancelled")
+				return err
 			}
 			input = numbers[idx]
 		} else {
@@ -787,19 +1092,15 @@ Examples:
 		if len(args) == 0 {
 			numbers, labels, err := getOpenMRs()
 			if err != nil {
-				return fmt.Errorf("failed to get MRs: %w (is 'glab' CLI installed?)", err)
+				return fmt.Errorf("failed to get MRs: %w", err)
 			}
 			if len(labels) == 0 {
 				return fmt.Errorf("no open MRs found")
 			}
 
-			prompt := promptui.Select{
-				Label: "Select Merge Request",
-				Items: labels,
-			}
-			idx, _, err := prompt.Run()
+			idx, _, err := selectPrompt("Select Merge Request", labels)
 			if err != nil {
-				return fmt.Errorf("selection cancelled")
+				return err
 			}
 			input = numbers[idx]
 		} else {
@@ -822,18 +1123,19 @@ func checkoutPROrMR(input string, remoteType RemoteType) error {
 	case RemoteGitHub:
 		refSpec = fmt.Sprintf("pull/%s/head", prNumber)
 		prefix = "pr"
-		if _, err := exec.LookPath("gh"); err != nil {
-			return fmt.Errorf("'gh' CLI not found. Install it from https://cli.github.com")
-		}
 	case RemoteGitLab:
 		refSpec = fmt.Sprintf("merge-requests/%s/head", prNumber)
 		prefix = "mr"
-		if _, err := exec.LookPath("glab"); err != nil {
-			return fmt.Errorf("'glab' CLI not found. Install it from https://gitlab.com/gitlab-org/cli")
-		}
 	default:
 		return fmt.Errorf("invalid remote type")
 	}
+	p, err := providerFor(remoteType)
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath(p.CLI); err != nil {
+		return fmt.Errorf("'%s' CLI not found. Install it from %s", p.CLI, p.Install)
+	}
 
 	info, err := getRepoInfo()
 	if err != nil {
@@ -844,7 +1146,7 @@ func checkoutPROrMR(input string, remoteType RemoteType) error {
 
 	// Check if worktree already exists
 	if existingPath, exists := worktreeExists(branch); exists {
-		fmt.Printf("✓ Worktree already exists: %s\n", existingPath)
+		successf("Worktree already exists: %s", existingPath)
 		printCDMarker(existingPath)
 		return nil
 	}
@@ -854,32 +1156,36 @@ func checkoutPROrMR(input string, remoteType RemoteType) error {
 		return err
 	}
 
-	// Fetch the PR/MR
-	fetchCmd := exec.Command("git", "fetch", "origin", fmt.Sprintf("%s:%s", refSpec, branch))
-	fetchCmd.Stderr = os.Stderr
-	_ = fetchCmd.Run() // Ignore errors, branch might already exist
+	// Fetch the PR/MR. A failure is fine when the branch exists from an
+	// earlier checkout; otherwise say whether the PR/MR is missing or the
+	// credentials are wrong.
+	var fetchErr bytes.Buffer
+	if err := objectGit("", nil, io.MultiWriter(os.Stderr, &fetchErr), "fetch", "origin", fmt.Sprintf("%s:%s", refSpec, branch)); err != nil && !gitRefExists("refs/heads/"+branch) {
+		return p.classifyError(fmt.Sprintf("%s #%s", p.Kind, prNumber), fetchErr.String(), err)
+	}
 
 	if remoteType == RemoteGitHub {
 		linkGitHubPRBranch(prNumber, branch)
 	}
 
 	// Create worktree
-	gitCmd := exec.Command("git", "worktree", "add", path, branch)
-	gitCmd.Stdout = os.Stdout
+	gitCmd := repoVCS().addWorktree(path, branch)
+	gitCmd.Stdout = gitOutput()
 	gitCmd.Stderr = os.Stderr
 	if err := gitCmd.Run(); err != nil {
 		return fmt.Errorf("failed to create worktree: %w", err)
 	}
 
-	fmt.Printf("✓ %s #%s checked out at: %s\n", strings.ToUpper(prefix), prNumber, path)
+	successf("%s #%s checked out at: %s", strings.ToUpper(prefix), prNumber, path)
+	prepareWorktree(path)
+	applyWorktreeGitConfig(info, path)
+	runPostCheckoutHooks(info, branch, path)
 	printCDMarker(path)
 	return nil
 }
 
 func linkGitHubPRBranch(prNumber string, localBranch string) {
-	headRefCmd := exec.Command("gh", "pr", "view", prNumber, "--json", "headRefName", "--jq", ".headRefName")
-	headRefCmd.Stderr = os.Stderr
-	headRefOutput, err := headRefCmd.Output()
+	headRefOutput, err := githubProvider.output("PR #"+prNumber, "pr", "view", prNumber, "--json", "headRefName", "--jq", ".headRefName")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "warning: failed to read PR head ref: %v\n", err)
 		return
@@ -890,7 +1196,7 @@ func linkGitHubPRBranch(prNumber string, localBranch string) {
 		return
 	}
 
-	lsRemoteCmd := exec.Command("git", "ls-remote", "--heads", "origin", headRefName)
+	lsRemoteCmd := gitCommand("ls-remote", "--heads", "origin", headRefName)
 	lsRemoteCmd.Stderr = os.Stderr
 	lsRemoteOutput, err := lsRemoteCmd.Output()
 	if err != nil {
@@ -901,46 +1207,55 @@ func linkGitHubPRBranch(prNumber string, localBranch string) {
 		return
 	}
 
-	fetchHeadCmd := exec.Command("git", "fetch", "origin", headRefName)
-	fetchHeadCmd.Stdout = os.Stdout
-	fetchHeadCmd.Stderr = os.Stderr
-	if err := fetchHeadCmd.Run(); err != nil {
+	if err := objectGit("", gitOutput(), os.Stderr, "fetch", "origin", headRefName); err != nil {
 		fmt.Fprintf(os.Stderr, "warning: failed to fetch origin branch: %v\n", err)
 		return
 	}
 
-	setUpstreamCmd := exec.Command("git", "branch", "--set-upstream-to", fmt.Sprintf("origin/%s", headRefName), localBranch)
-	setUpstreamCmd.Stdout = os.Stdout
+	setUpstreamCmd := gitCommand("branch", "--set-upstream-to", fmt.Sprintf("origin/%s", headRefName), localBranch)
+	setUpstreamCmd.Stdout = gitOutput()
 	setUpstreamCmd.Stderr = os.Stderr
 	if err := setUpstreamCmd.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "warning: failed to set upstream: %v\n", err)
 	}
 }
 
-var listCmd = &cobra.Command{
-	Use:     "list",
-	Aliases: []string{"ls"},
-	Short:   "List all worktrees",
-	Run: func(cmd *cobra.Command, args []string) {
-		gitCmd := exec.Command("git", "worktree", "list")
-		gitCmd.Stdout = os.Stdout
-		gitCmd.Stderr = os.Stderr
-		_ = gitCmd.Run()
-	},
-}
-
 var (
-	removeForce   bool
-	cleanupDryRun bool
-	cleanupForce  bool
+	checkoutOrphan      bool
+	checkoutFuzzy       bool
+	removeForce         bool
+	removeFuzzy         bool
+	removeDeleteBranch  bool
+	cleanupDryRun       bool
+	cleanupForce        bool
+	cleanupDeleteBranch bool
 )
 
 var removeCmd = &cobra.Command{
 	Use:     "remove [branch]",
 	Aliases: []string{"rm"},
 	Short:   "Remove a worktree",
-	Args:    cobra.RangeArgs(0, 1),
+	Long: `Remove a worktree; its branch stays unless --delete-branch is given.
+
+A worktree with local changes needs --force. When the branch has commits
+that are on no remote and not in the base branch, which merge detection
+cannot protect, wt asks first and offers to keep them under
+refs/wt/backup/<branch>/ or in a bundle file in its state directory.
+--backup keeps such a ref without asking, --discard-commits removes without
+one. In CI mode removal stops instead of asking. Set confirm.remove_unpushed
+to never to turn the check off (see 'wt config --help').
+
+Examples:
+  wt remove feature-x                  # Asks if feature-x has unpushed work
+  wt remove feature-x --backup -d      # Keep a backup ref, delete the branch
+  git branch feature-x refs/wt/backup/feature-x/<time>  # Restore it`,
+	Args: cobra.RangeArgs(0, 1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if removeSubmodule != "" {
+			if err := enterSubmodule(removeSubmodule); err != nil {
+				return err
+			}
+		}
 		var branch string
 
 		// Interactive selection if no branch provided
@@ -953,13 +1268,9 @@ var removeCmd = &cobra.Command{
 				return fmt.Errorf("no worktrees to remove")
 			}
 
-			prompt := promptui.Select{
-				Label: "Select worktree to remove",
-				Items: branches,
-			}
-			_, result, err := prompt.Run()
+			_, result, err := selectPrompt("Select worktree to remove", branches)
 			if err != nil {
-				return fmt.Errorf("selection cancelled")
+				return err
 			}
 			branch = result
 		} else {
@@ -968,38 +1279,47 @@ var removeCmd = &cobra.Command{
 
 		existingPath, exists := worktreeExists(branch)
 		if !exists {
-			return fmt.Errorf("no worktree found for branch: %s", branch)
-		}
-
-		// Check if we're currently in the worktree being removed
-		cwd, err := os.Getwd()
-		inRemovedWorktree := err == nil && strings.HasPrefix(cwd, existingPath)
-
-		// Find the main worktree path (for cd after removal)
-		var mainWorktreePath string
-		if inRemovedWorktree {
-			listCmd := exec.Command("git", "worktree", "list")
-			output, err := listCmd.Output()
-			if err == nil {
-				lines := strings.Split(string(output), "\n")
-				if len(lines) > 0 {
-					// First line is always the main worktree
-					fields := strings.Fields(lines[0])
-					if len(fields) > 0 {
-						mainWorktreePath = fields[0]
-					}
-				}
+			if busyPath, reason, busy := busyWorktree(branch); busy {
+				return fmt.Errorf("branch '%s' is checked out at %s (%s); finish or abort that first", branch, busyPath, reason)
+			}
+			branches, _ := getExistingWorktreeBranches()
+			notFound := fmt.Errorf("no worktree found for branch: %s", branch)
+			var err error
+			if branch, err = resolveFuzzy(branch, branches, removeFuzzy, notFound); err != nil {
+				return err
 			}
+			existingPath, _ = worktreeExists(branch)
 		}
 
-		gitArgs := []string{"worktree", "remove"}
-		if removeForce {
-			gitArgs = append(gitArgs, "--force")
+		if entries, err := snapshot.Worktrees(); err == nil && len(entries) > 0 && !entries[0].Bare && samePath(entries[0].Path, existingPath) {
+			return mainWorktreeBranchError(branch, existingPath)
 		}
-		gitArgs = append(gitArgs, existingPath)
 
-		gitCmd := exec.Command("git", gitArgs...)
-		gitCmd.Stdout = os.Stdout
+		info, err := getRepoInfo()
+		if err != nil {
+			return err
+		}
+		agreed, err := protectUnpushed(info, branch)
+		if err != nil {
+			return err
+		}
+		force, err := confirmRemoveDirty(branch, existingPath, removeForce)
+		if err != nil {
+			return err
+		}
+		// Only once it is settled that the worktree goes, since the hooks
+		// may stop services bound to it.
+		if err := runPreRemoveHooks(info, branch, existingPath, removeForce); err != nil {
+			return err
+		}
+
+		// Removing the worktree we are in: continue from the main worktree
+		returnTo, err := leaveWorktrees(existingPath)
+		if err != nil {
+			return err
+		}
+		gitCmd := repoVCS().removeWorktree(existingPath, force)
+		gitCmd.Stdout = gitOutput()
 		gitCmd.Stderr = os.Stderr
 		if err := gitCmd.Run(); err != nil {
 			return fmt.Errorf("failed to remove worktree: %w", err)
@@ -1009,11 +1329,15 @@ var removeCmd = &cobra.Command{
 			return err
 		}
 
-		fmt.Printf("✓ Removed worktree: %s\n", existingPath)
+		successf("Removed worktree: %s", existingPath)
+		runPostRemoveHooks(info, branch, existingPath)
+		if removeDeleteBranch {
+			deleteBranch(branch, agreed)
+		}
 
 		// If we were in the removed worktree, navigate to main
-		if inRemovedWorktree && mainWorktreePath != "" {
-			printCDMarker(mainWorktreePath)
+		if returnTo != "" {
+			printCDMarker(returnTo)
 		}
 
 		return nil
@@ -1028,12 +1352,55 @@ var cleanupCmd = &cobra.Command{
 This command finds all worktrees whose branches have been merged into main/master,
 and removes them. Use --dry-run to preview what would be removed.
 
+Ephemeral worktrees ('wt checkout --ephemeral' or '--ttl') that expired are
+removed as well, merged or not and without confirmation.
+
+With --free, cleanup works towards a disk space goal instead, e.g. when the
+disk is full: it measures the clean, unlocked worktrees of merged, expired
+or stale branches, ranks them by size times days since last use, and
+proposes the fewest of them that free the space, with their sizes, before
+asking. Worktrees with local changes are never proposed; when the goal
+cannot be met, all candidates are, and wt says how much they free.
+
+With --delete-branch the branches go as well; one with commits that are on
+no remote and not in the base branch is only deleted once you agree. The
+confirm section of the config can make cleanup always or never ask (see
+'wt config --help').
+
+Worktrees with local changes are skipped, without asking or running their
+pre-remove hooks: git would refuse to remove them. --json prints what
+cleanup did, or would do with --dry-run, per worktree with its size;
+--report renders the same as a markdown or HTML summary to share, e.g. in
+a chat channel. Both replace the progress output on stdout.
+
 Examples:
   wt cleanup              # Interactive confirmation for each worktree
   wt cleanup --dry-run    # Preview what would be removed
-  wt cleanup --force      # Remove all without confirmation`,
+  wt cleanup --force      # Remove all without confirmation
+  wt cleanup -d           # Delete the merged branches too
+  wt cleanup --free 20G   # Remove the fewest worktrees that free 20 GiB
+  wt cleanup --force --report markdown > cleanup.md`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkCleanupOutput(); err != nil {
+			return err
+		}
+		reporting := cleanupJSON || cleanupReport != ""
+		if reporting {
+			// stdout carries the report
+			quiet = true
+		}
 		base := getDefaultBase()
+		hintOldFetch()
+		summary := cleanupSummary{Base: base, DryRun: cleanupDryRun, Time: time.Now(), Worktrees: []cleanupItem{}}
+		if info, err := getRepoInfo(); err == nil {
+			summary.Repo = info.Name
+		}
+		report := func() error {
+			if !reporting {
+				return nil
+			}
+			return writeCleanupOutput(os.Stdout, summary)
+		}
 
 		// Get merged branches
 		mergedBranches, err := getMergedBranches(base)
@@ -1053,76 +1420,252 @@ Examples:
 			mergedSet[b] = true
 		}
 
+		// Expired ephemeral worktrees go regardless of their branch
+		expiredSet := make(map[string]bool)
+		for _, b := range expiredEphemeral(time.Now()) {
+			expiredSet[b] = true
+		}
+
 		// Find worktrees that are for merged branches
 		var toRemove []string
 		for _, branch := range worktreeBranches {
-			if mergedSet[branch] {
+			if mergedSet[branch] || expiredSet[branch] {
 				toRemove = append(toRemove, branch)
 			}
 		}
 
+		// With a space goal, the plan decides instead
+		var goal int64
+		sizes := make(map[string]int64)
+		reasons := make(map[string]string)
+		if cleanupFree != "" {
+			if goal, err = parseSize(cleanupFree, "--free"); err != nil {
+				return err
+			}
+			candidates, err := collectFreeCandidates(mergedSet, expiredSet)
+			if err != nil {
+				return err
+			}
+			if len(candidates) == 0 {
+				infof("No clean worktrees of merged, stale or expired branches to remove\n")
+				return report()
+			}
+			now := time.Now()
+			plan, total := planFree(candidates, goal, now)
+			if total < goal {
+				warnf("Only %s can be freed by removing clean worktrees of merged, stale or expired branches\n", formatSize(total))
+			}
+			if !reporting {
+				fmt.Printf("Removing %d worktree(s) frees %s:\n", len(plan), formatSize(total))
+				if err := printFreePlan(os.Stdout, plan, now); err != nil {
+					return err
+				}
+			}
+			toRemove = nil
+			for _, c := range plan {
+				toRemove = append(toRemove, c.Branch)
+				sizes[c.Branch] = c.Size
+				reasons[c.Branch] = c.Reason
+			}
+			if cleanupDryRun && !reporting {
+				return nil
+			}
+		}
+
 		if len(toRemove) == 0 {
-			fmt.Println("No worktrees found for merged branches")
-			return nil
+			infof("No worktrees found for merged branches\n")
+			return report()
+		}
+		info, err := getRepoInfo()
+		if err != nil {
+			return err
+		}
+
+		// What the report says about each; sizes are only measured for it
+		newItem := func(branch, path string) cleanupItem {
+			item := cleanupItem{Branch: branch, Path: path, Reason: reasons[branch], Size: sizes[branch]}
+			switch {
+			case expiredSet[branch]:
+				item.Reason = "expired"
+			case mergedSet[branch]:
+				item.Reason, item.MergedInto = "merged", base
+			}
+			if _, ok := sizes[branch]; !ok && reporting {
+				item.Size = dirSize(path)
+			}
+			return item
 		}
 
 		// Dry run mode - just show what would be removed
 		if cleanupDryRun {
-			fmt.Printf("Would remove %d worktree(s) for merged branches:\n", len(toRemove))
+			if !reporting {
+				fmt.Printf("Would remove %d worktree(s) for merged branches:\n", len(toRemove))
+			}
 			for _, branch := range toRemove {
 				if path, exists := worktreeExists(branch); exists {
-					fmt.Printf("  - %s (%s)\n", branch, path)
+					item := newItem(branch, path)
+					item.Result = cleanupWouldRemove
+					if localChanges(path) > 0 {
+						item.Result = cleanupDirty
+					}
+					summary.Worktrees = append(summary.Worktrees, item)
+					switch {
+					case reporting:
+					case expiredSet[branch]:
+						fmt.Printf("  - %s (%s, expired ephemeral)\n", branch, path)
+					case item.Result == cleanupDirty:
+						fmt.Printf("  - %s (%s, has local changes: would be skipped)\n", branch, path)
+					default:
+						fmt.Printf("  - %s (%s)\n", branch, path)
+					}
 				}
 			}
-			return nil
+			return report()
+		}
+
+		// Standing in one of them: continue from the main worktree, and send
+		// the shell there if it goes
+		var paths []string
+		for _, branch := range toRemove {
+			if path, exists := worktreeExists(branch); exists {
+				paths = append(paths, path)
+			}
+		}
+		cwd, _ := os.Getwd()
+		returnTo, err := leaveWorktrees(paths...)
+		if err != nil {
+			return err
 		}
 
 		// Track results
 		removed := 0
 		skipped := 0
+		dirty := 0
+		failed := 0
+		skippedNoPrompt := 0
+		var freed int64
 
 		for _, branch := range toRemove {
 			existingPath, exists := worktreeExists(branch)
 			if !exists {
 				continue
 			}
+			item := newItem(branch, existingPath)
+
+			// git refuses to remove a worktree with local changes, so it is
+			// skipped before asking or running hooks
+			if changes := localChanges(existingPath); changes > 0 {
+				infof("  Skipped: %s (%d local change(s))\n", branch, changes)
+				item.Result = cleanupDirty
+				summary.Worktrees = append(summary.Worktrees, item)
+				dirty++
+				continue
+			}
 
-			// If not force mode, ask for confirmation
-			if !cleanupForce {
-				prompt := promptui.Prompt{
-					Label:     fmt.Sprintf("Remove worktree for merged branch '%s'", branch),
-					IsConfirm: true,
+			// Ask for confirmation, unless forced or set otherwise in the
+			// confirm section of the config
+			if !expiredSet[branch] {
+				label := fmt.Sprintf("Remove worktree for merged branch '%s'", branch)
+				if size, ok := sizes[branch]; ok {
+					label = fmt.Sprintf("Remove worktree of '%s' (%s)", branch, formatSize(size))
 				}
-				_, err := prompt.Run()
-				if err != nil {
-					fmt.Printf("  Skipped: %s\n", branch)
+				ok, err := confirmAction("cleanup", label, !cleanupForce)
+				if errors.Is(err, errPromptDisabled) && confirmPolicy("cleanup") == confirmAlways {
+					warnf("  Skipped: %s (confirmation required by confirm.cleanup)\n", branch)
+					item.Result, item.Error = cleanupSkipped, "confirmation required"
+					summary.Worktrees = append(summary.Worktrees, item)
+					skippedNoPrompt++
+					continue
+				}
+				if errors.Is(err, errPromptDisabled) {
+					warnf("  Skipped: %s (confirmation required, use --force)\n", branch)
+					item.Result, item.Error = cleanupSkipped, "confirmation required"
+					summary.Worktrees = append(summary.Worktrees, item)
+					skippedNoPrompt++
+					continue
+				}
+				if !ok {
+					infof("  Skipped: %s\n", branch)
+					item.Result = cleanupSkipped
+					summary.Worktrees = append(summary.Worktrees, item)
 					skipped++
 					continue
 				}
 			}
 
+			if err := runPreRemoveHooks(info, branch, existingPath, false); err != nil {
+				warnf("  Failed to remove %s: %v\n", branch, err)
+				recordAudit(branch, existingPath, "", err)
+				item.Result, item.Error = cleanupFailed, err.Error()
+				summary.Worktrees = append(summary.Worktrees, item)
+				failed++
+				continue
+			}
+
 			// Remove the worktree
-			gitCmd := exec.Command("git", "worktree", "remove", existingPath)
-			gitCmd.Stdout = os.Stdout
+			gitCmd := repoVCS().removeWorktree(existingPath, false)
+			gitCmd.Stdout = gitOutput()
 			gitCmd.Stderr = os.Stderr
 			if err := gitCmd.Run(); err != nil {
-				fmt.Printf("  Failed to remove %s: %v\n", branch, err)
+				warnf("  Failed to remove %s: %v\n", branch, err)
+				recordAudit(branch, existingPath, "", err)
+				item.Result, item.Error = cleanupFailed, err.Error()
+				summary.Worktrees = append(summary.Worktrees, item)
+				failed++
 				continue
 			}
 
 			if err := cleanupWorktreePath(existingPath); err != nil {
-				fmt.Printf("  Warning: failed to cleanup path for %s: %v\n", branch, err)
+				warnf("  Warning: failed to cleanup path for %s: %v\n", branch, err)
 			}
 
-			fmt.Printf("✓ Removed worktree: %s\n", branch)
+			if expiredSet[branch] {
+				successf("Removed expired ephemeral worktree: %s", branch)
+				setEphemeral(branch, 0)
+			} else {
+				successf("Removed worktree: %s", branch)
+			}
+			runPostRemoveHooks(info, branch, existingPath)
+			if cleanupDeleteBranch {
+				deleteBranch(branch, false)
+				item.BranchDeleted = !gitRefExists("refs/heads/" + branch)
+			}
+			item.Result = cleanupRemoved
+			summary.Worktrees = append(summary.Worktrees, item)
 			removed++
+			freed += sizes[branch]
 		}
 
 		// Run prune at the end
-		pruneGitCmd := exec.Command("git", "worktree", "prune")
+		pruneGitCmd := gitCommand("worktree", "prune")
 		_ = pruneGitCmd.Run()
+		if _, err := os.Stat(longPath(cwd)); returnTo != "" && os.IsNotExist(err) {
+			printCDMarker(returnTo)
+		}
 
-		fmt.Printf("\nCleanup complete: %d removed, %d skipped\n", removed, skipped)
+		switch {
+		case reporting:
+			if err := report(); err != nil {
+				return err
+			}
+		case ciMode():
+			fmt.Println(machineSummary("cleanup", "removed", removed, "skipped", skipped+dirty+skippedNoPrompt, "failed", failed))
+		default:
+			line := fmt.Sprintf("%d removed, %d skipped", removed, skipped+dirty)
+			if dirty > 0 {
+				line += fmt.Sprintf(" (%d with local changes)", dirty)
+			}
+			if failed > 0 {
+				line += fmt.Sprintf(", %d failed", failed)
+			}
+			infof("\nCleanup complete: %s\n", line)
+			if cleanupFree != "" {
+				infof("Freed %s of %s\n", formatSize(freed), formatSize(goal))
+			}
+		}
+		if skippedNoPrompt > 0 {
+			return fmt.Errorf("%d worktree(s) skipped because removal needs confirmation: %w", skippedNoPrompt, errPromptDisabled)
+		}
 		return nil
 	},
 }
@@ -1131,19 +1674,37 @@ var pruneCmd = &cobra.Command{
 	Use:   "prune",
 	Short: "Remove worktree administrative files",
 	Run: func(cmd *cobra.Command, args []string) {
-		gitCmd := exec.Command("git", "worktree", "prune")
-		gitCmd.Stdout = os.Stdout
+		gitCmd := gitCommand("worktree", "prune")
+		gitCmd.Stdout = gitOutput()
 		gitCmd.Stderr = os.Stderr
 		if err := gitCmd.Run(); err == nil {
-			fmt.Println("✓ Pruned stale worktree administrative files")
+			successf("Pruned stale worktree administrative files")
 		}
 	},
 }
 
 var infoCmd = &cobra.Command{
-	Use:   "info",
-	Short: "Show worktree location configuration",
+	Use:   "info [branch]",
+	Short: "Show a worktree's details, or the worktree location configuration",
+	Long: `Without arguments, show where wt puts worktrees and the available strategies.
+
+With a branch ('.' for the worktree you are in), show everything wt knows
+about its worktree: path, branch and stack parent, upstream with ahead/behind
+counts, local changes, lock, ticket, tags and description, when it was
+created, committed to and last visited, and the hooks that ran for it.
+
+Examples:
+  wt info                    # Worktree location configuration
+  wt info feature/foo
+  wt info . --json`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			return runWorktreeInfo(args[0])
+		}
+		if infoJSON {
+			return fmt.Errorf("--json needs a branch, e.g. 'wt info . --json'")
+		}
 		pattern, err := resolveWorktreePattern()
 		if err != nil {
 			pattern = worktreePattern
@@ -1152,12 +1713,13 @@ var infoCmd = &cobra.Command{
 			}
 		}
 
-		fmt.Printf(`Strategy: %s
-Pattern:  %s
-Root:     %s
+		fmt.Printf(`Strategy:  %s
+Pattern:   %s
+Root:      %s
+Namespace: %s
 
 Strategies:
-  global           -> {.worktreeRoot}/{.repo.Name}/{.branch}
+  global           -> {.worktreeRoot}/{.namespace}/{.branch}
   sibling-repo     -> {.repo.Main}/../{.repo.Name}-{.branchSafe}
   parent-branches  -> {.repo.Main}/../{.branch}
   parent-worktrees -> {.repo.Main}/../{.repo.Name}.worktrees/{.branch}
@@ -1165,58 +1727,193 @@ Strategies:
   inside-dotdir    -> {.repo.Main}/.worktrees/{.branch}
   custom           -> requires WORKTREE_PATTERN
 
-Pattern variables: {.repo.Name}, {.repo.Main}, {.repo.Owner}, {.repo.Host}, {.branch}, {.branchSafe}, {.worktreeRoot}
+Pattern variables: {.repo.Name}, {.repo.Main}, {.repo.Owner}, {.repo.Host}, {.branch}, {.branchSafe}, {.worktreeRoot}, {.namespace}
 Note: {.branchSafe} is sanitized for filesystem paths (slashes replaced).
-`, worktreeStrategy, pattern, worktreeRoot)
+{.namespace} is the per-repository directory (default {.repo.Name}); set it to
+e.g. '{.repo.Host}/{.repo.Owner}/{.repo.Name}' to separate same-named repos:
+  wt config set namespace '{.repo.Host}/{.repo.Owner}/{.repo.Name}'
+`, worktreeStrategy, pattern, worktreeRoot, worktreeNamespace)
 		return nil
 	},
 }
 
 var shellenvCmd = &cobra.Command{
-	Use:   "shellenv",
+	Use:   "shellenv [bash|zsh|powershell|tcsh]",
 	Short: "Output shell function for auto-cd (source this)",
 	Long: `Output shell integration code for automatic directory navigation.
 
+Without a shell argument, the output is PowerShell on Windows and bash/zsh
+everywhere else; pass the shell to choose, e.g. pwsh on macOS or Linux.
+
 Add this to the END of your ~/.bashrc or ~/.zshrc:
   source <(wt shellenv)
 
-For PowerShell, add this to your $PROFILE:
-  Invoke-Expression (& wt shellenv)
+For PowerShell (pwsh on any OS), add this to your $PROFILE:
+  Invoke-Expression (& wt shellenv powershell | Out-String)
+
+For tcsh or csh, which cannot eval a script of several lines, add this to
+~/.tcshrc (or let 'wt init tcsh' do it):
+  set __wt_init = ` + "`" + `mktemp -t wt.XXXXXX` + "`" + `
+  wt shellenv tcsh >! $__wt_init && source $__wt_init
+  rm -f $__wt_init; unset __wt_init
 
 Note: For zsh, place this AFTER compinit to enable tab completion.
 
 This enables:
 - Automatic cd to worktree after checkout/create/pr/mr commands
-- Tab completion for commands and branch names`,
-	Run: func(cmd *cobra.Command, args []string) {
-		// Output OS-specific shell integration
-		// On Windows, default to PowerShell. On Unix, output bash/zsh.
-		if runtime.GOOS == "windows" {
-			// PowerShell integration for Windows
-			fmt.Print(`# PowerShell integration (Windows)
-# Detected via runtime.GOOS, compatible with $PSVersionTable
-# NOTE: Requires wt.exe to be in PATH or current directory
+- 'wt -' back to the previous worktree, tracked per shell
+- Tab completion for commands and branch names
+- WT_BRANCH, WT_REPO and WT_WORKTREE exported while in a worktree wt
+  navigated to (unset again when leaving it, except in tcsh)
+
+csh has no functions, so in tcsh wt is an alias that has the binary write
+the directory to change to into a temporary file (WT_CD_FILE) instead of
+reading its output; it has no --completions and no version check.
+
+With --completions the built-in completion is replaced by the full one of
+'wt completion' (every command, flag and branch name), in the same stream,
+so a single line sets up everything without 'wt init':
+  eval "$(wt shellenv bash --completions)"
+  eval "$(wt shellenv zsh --completions)"     # after compinit
+  Invoke-Expression (& wt shellenv powershell --completions | Out-String)
+
+The integration checks once per shell that the wt binary is still there and
+not older than itself, using 'wt shellenv --version-check'. When the binary
+is missing it says so instead of failing in odd ways; when it is older, it
+runs wt without auto-cd and asks for an upgrade.`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"bash", "zsh", "powershell", "pwsh", "tcsh", "csh"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if shellenvVersionCheck {
+			printVersionCheck()
+			return nil
+		}
+		shell := ""
+		if len(args) > 0 {
+			shell = strings.ToLower(args[0])
+		}
+		switch shell {
+		case "":
+			// On Windows, default to PowerShell. Elsewhere, output bash/zsh.
+			if runtime.GOOS == "windows" {
+				shell = "powershell"
+			}
+		case "bash", "zsh":
+		case "powershell", "pwsh":
+			shell = "powershell"
+		case "tcsh", "csh":
+			if shellenvCompletions {
+				return fmt.Errorf("--completions is not available for %s", shell)
+			}
+			fmt.Print(tcshIntegration())
+			return nil
+		default:
+			return fmt.Errorf("unsupported shell %q (use bash, zsh, powershell or tcsh)", args[0])
+		}
+
+		if shell == "powershell" {
+			// PowerShell integration for Windows PowerShell and pwsh on any OS
+			fmt.Print(`# PowerShell integration, compatible with Windows PowerShell 5.1 and pwsh
+
+# The wt executable, which the wt function below would otherwise shadow
+# (wt.exe on Windows, wt on macOS and Linux)
+$global:WtExe = (Get-Command -Name wt -CommandType Application -ErrorAction SilentlyContinue | Select-Object -First 1).Source
+if (-not $global:WtExe) { $global:WtExe = 'wt.exe' }
+
+# Identifies this shell, so 'wt -' returns to where this shell was before
+if (-not $global:WtSession) { $global:WtSession = "$PID" }
+
+# The protocol of this integration; 'wt shellenv --version-check' prints the
+# one of the executable
+$global:WtProtocol = `, shellenvProtocol, `
+$global:WtChecked = $false
+
+# Makes sure the wt executable exists and is not older than this integration:
+# 'ok', 'missing' or 'older'. Runs once per session, and again after a failure.
+function Test-WtExe {
+    $exe = Get-Command -Name wt -CommandType Application -ErrorAction SilentlyContinue | Select-Object -First 1
+    if (-not $exe) {
+        Write-Warning "wt: the wt executable is not on PATH any more; reinstall it, or remove the wt line from your profile ($PROFILE)"
+        return 'missing'
+    }
+    $global:WtExe = $exe.Source
+    $reply = "$(& $global:WtExe shellenv --version-check 2>$null)"
+    if ($LASTEXITCODE -ne 0 -or $reply -notmatch '^(\d+)' -or [int]$Matches[1] -lt $global:WtProtocol) {
+        Write-Warning "wt: $($global:WtExe) is older than this shell integration; upgrade wt (running it without auto-cd for now)"
+        return 'older'
+    }
+    if ([int]$Matches[1] -gt $global:WtProtocol) {
+        Write-Warning "wt: this shell integration is older than wt; open a new shell to load the current one"
+    }
+    $global:WtChecked = $true
+    return 'ok'
+}
 
 function wt {
-    # Call wt.exe explicitly to avoid recursive function call
-    # PowerShell will find wt.exe in PATH or current directory
-    $output = & wt.exe @args
+    # Completion requests need neither output capture nor auto-cd
+    if ($args.Count -gt 0 -and "$($args[0])" -like '__complete*') {
+        & $global:WtExe @args
+        return
+    }
+    if (-not $global:WtChecked) {
+        switch (Test-WtExe) {
+            'missing' { $global:LASTEXITCODE = 127; return }
+            'older' { & $global:WtExe @args; return }
+        }
+    }
+    $env:WT_SESSION = $global:WtSession
+    # Call the executable explicitly to avoid a recursive function call
+    $output = & $global:WtExe @args
     $exitCode = $LASTEXITCODE
     Write-Output $output
-    if ($exitCode -eq 0) {
-        $cdPath = $output | Select-String -Pattern "^wt navigating to: " | ForEach-Object { $_.Line.Substring(18) }
-        if ($cdPath) {
-            Set-Location $cdPath
-        }
+    # Also after a failure: wt only navigates where there is work to do,
+    # e.g. a patch conflict to resolve.
+    $cdPath = $output | Select-String -Pattern "^wt navigating to: " | ForEach-Object { $_.Line.Substring(18) }
+    if ($cdPath) {
+        Set-Location $cdPath
+        Set-WtEnv
     }
     $global:LASTEXITCODE = $exitCode
 }
 
-# PowerShell completion
+# WT_BRANCH, WT_REPO and WT_WORKTREE describe the worktree wt navigated to,
+# for prompts, build scripts and tools. They are cleared once the shell leaves it.
+function Set-WtEnv {
+    Clear-WtEnv
+    & $global:WtExe __worktree-env 2>$null | ForEach-Object {
+        $name, $value = $_ -split '=', 2
+        if ($name -in @('WT_BRANCH', 'WT_REPO', 'WT_WORKTREE')) {
+            Set-Item -Path "env:$name" -Value $value
+        }
+    }
+}
+
+function Clear-WtEnv {
+    Remove-Item -Path env:WT_BRANCH, env:WT_REPO, env:WT_WORKTREE -ErrorAction SilentlyContinue
+}
+
+# Runs after every change of location, including plain Set-Location/cd
+if ($null -eq $ExecutionContext.InvokeCommand.LocationChangedAction) {
+    $ExecutionContext.InvokeCommand.LocationChangedAction = {
+        if ($env:WT_WORKTREE) {
+            $here = (Get-Location).ProviderPath.Replace('/', '\').TrimEnd('\') + '\'
+            $root = $env:WT_WORKTREE.Replace('/', '\').TrimEnd('\') + '\'
+            if (-not $here.StartsWith($root, [System.StringComparison]::OrdinalIgnoreCase)) {
+                Clear-WtEnv
+            }
+        }
+    }
+}
+
+`)
+			if shellenvCompletions {
+				return writeCobraCompletion(os.Stdout, shell)
+			}
+			fmt.Print(`# PowerShell completion
 Register-ArgumentCompleter -CommandName wt -ScriptBlock {
     param($commandName, $wordToComplete, $commandAst, $fakeBoundParameters)
 
-    $commands = @('checkout', 'co', 'create', 'pr', 'mr', 'list', 'ls', 'remove', 'rm', 'cleanup', 'prune', 'help', 'shellenv', 'init', 'version')
+    $commands = @('checkout', 'co', 'create', 'pr', 'mr', 'list', 'ls', 'status', 'fetch', 'info', 'remove', 'rm', 'move', 'restack', 'describe', 'tag', 'last', 'history', 'cleanup', 'prune', 'repair', 'clean', 'exec', 'foreach', 'snapshot', 'maintenance', 'gc', 'hooks', 'config', 'doctor', 'help', 'shellenv', 'init', 'version')
 
     # Get the position in the command line
     $position = $commandAst.CommandElements.Count - 1
@@ -1228,35 +1925,97 @@ Register-ArgumentCompleter -CommandName wt -ScriptBlock {
         }
     } elseif ($position -eq 1) {
         $subCommand = $commandAst.CommandElements[1].Value
-        if ($subCommand -in @('checkout', 'co', 'remove', 'rm')) {
-            # Complete branch names from worktree list
-            $branches = git worktree list 2>$null | Select-Object -Skip 1 | ForEach-Object {
-                if ($_ -match '\[([^\]]+)\]') { $matches[1] }
-            }
-            $branches | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
-                [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
-            }
+        $branches = @()
+        if ($subCommand -in @('checkout', 'co')) {
+            # Complete all branch names (cached by wt per repository)
+            $branches = & $global:WtExe __branches 2>$null
+        } elseif ($subCommand -in @('remove', 'rm', 'move', 'restack', 'describe', 'info', 'exec')) {
+            # Complete branch names that have a worktree
+            $branches = & $global:WtExe __branches --worktrees 2>$null
+        }
+        $branches | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
         }
     }
 }
 `)
-			return
+			return nil
 		}
 
-		// Bash/Zsh integration for Unix systems
-		fmt.Print(`wt() {
+		// Bash/Zsh integration; the script checks which of the two runs it
+		fmt.Print(`# Identifies this shell, so 'wt -' returns to where this shell was before
+__wt_session="${__wt_session:-$$.$RANDOM}"
+
+# The protocol of this integration; 'wt shellenv --version-check' prints the
+# one of the binary
+__wt_protocol=`, shellenvProtocol, `
+__wt_checked=
+
+# Makes sure the wt binary exists and is not older than this integration.
+# Returns 0 when it can be used, 1 when it is older and 126 or 127 when it
+# cannot run. Runs once per shell, and again after the binary went missing.
+__wt_check() {
+    local reply status protocol
+    reply=$(command wt shellenv --version-check 2>/dev/null)
+    status=$?
+    case $status in
+        127)
+            echo "wt: the wt binary is not on PATH any more; reinstall it, or remove the wt shellenv line from your shell config" >&2
+            return 127 ;;
+        126)
+            echo "wt: the wt binary cannot be executed; reinstall it, or make it executable" >&2
+            return 126 ;;
+    esac
+    protocol=${reply%% *}
+    case "$protocol" in ''|*[!0-9]*) protocol=0 ;; esac
+    if [ $status -ne 0 ] || [ "$protocol" -lt "$__wt_protocol" ]; then
+        echo "wt: the wt binary is older than this shell integration; upgrade wt (running it without auto-cd for now)" >&2
+        return 1
+    fi
+    if [ "$protocol" -gt "$__wt_protocol" ]; then
+        echo "wt: this shell integration is older than wt; open a new shell to load the current one" >&2
+    fi
+    __wt_checked=1
+}
+
+# Whether an executable wt is on PATH, found without running it. The shell
+# may remember a path that is gone by now, hence the -x.
+__wt_found() {
+    if [ -n "$ZSH_VERSION" ]; then
+        [ -x "$(whence -p wt)" ]
+    else
+        [ -x "$(type -P wt)" ]
+    fi
+}
+
+wt() {
+    # Completion requests need neither a PTY nor auto-cd
+    case "$1" in __complete*) command wt "$@"; return ;; esac
+
+    local log_file exit_code cd_path
+    # Check again once the binary is gone, e.g. uninstalled since the check
+    if [ -z "$__wt_checked" ] || ! __wt_found; then
+        __wt_checked=
+        __wt_check
+        exit_code=$?
+        case $exit_code in
+            0) ;;
+            1) command wt "$@"; return ;;
+            *) return $exit_code ;;
+        esac
+    fi
+
     # Use script(1) to provide a PTY for interactive commands (e.g., promptui menus)
     # Command substitution $(command wt) doesn't allocate a TTY, which breaks interactive prompts
-    local log_file exit_code cd_path
     log_file=$(mktemp -t wt.XXXXXX)
 
     # Detect OS to use correct script syntax (macOS vs Linux)
     if [ "$(uname)" = "Darwin" ]; then
         # macOS: script -q file command args
-        script -q "$log_file" /bin/sh -c 'command wt "$@"' wt "$@"
+        WT_SESSION="$__wt_session" script -q "$log_file" /bin/sh -c 'command wt "$@"' wt "$@"
     else
         # Linux: script -q -c "command wt $*" "$log_file"
-        script -q -c "command wt $*" "$log_file"
+        WT_SESSION="$__wt_session" script -q -c "command wt $*" "$log_file"
     fi
     exit_code=$?
 
@@ -1265,20 +2024,57 @@ Register-ArgumentCompleter -CommandName wt -ScriptBlock {
     rm -f "$log_file"
     cd_path=${cd_path%$'\r'}
 
-    if [ $exit_code -eq 0 ] && [ -n "$cd_path" ]; then
-        cd "$cd_path"
+    # Also after a failure: wt only navigates where there is work to do,
+    # e.g. a patch conflict to resolve.
+    if [ -n "$cd_path" ]; then
+        cd "$cd_path" && __wt_export_env
     fi
     return $exit_code
 }
 
-# Bash completion
+# WT_BRANCH, WT_REPO and WT_WORKTREE describe the worktree wt navigated to,
+# for prompts, Makefiles and scripts. They are unset once the shell leaves it.
+__wt_export_env() {
+    local line
+    unset WT_BRANCH WT_REPO WT_WORKTREE
+    while IFS= read -r line; do
+        case "$line" in
+            WT_BRANCH=*|WT_REPO=*|WT_WORKTREE=*) export "$line" ;;
+        esac
+    done <<WT_ENV
+$(command wt __worktree-env 2>/dev/null)
+WT_ENV
+}
+
+__wt_check_env() {
+    [ -n "$WT_WORKTREE" ] || return 0
+    # git reports the physical path, $PWD may go through a symlink
+    case "$PWD/" in "$WT_WORKTREE"/*) return 0 ;; esac
+    case "$(pwd -P)/" in "$WT_WORKTREE"/*) return 0 ;; esac
+    unset WT_BRANCH WT_REPO WT_WORKTREE
+}
+
+if [ -n "$ZSH_VERSION" ]; then
+    autoload -Uz add-zsh-hook && add-zsh-hook chpwd __wt_check_env
+elif [ -n "$BASH_VERSION" ]; then
+    case ";$PROMPT_COMMAND;" in
+        *";__wt_check_env;"*) ;;
+        *) PROMPT_COMMAND="__wt_check_env${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+    esac
+fi
+
+`)
+		if shellenvCompletions {
+			return writeCobraCompletion(os.Stdout, shell)
+		}
+		fmt.Print(`# Bash completion
 if [ -n "$BASH_VERSION" ]; then
     _wt_complete() {
         local cur prev commands
         COMPREPLY=()
         cur="${COMP_WORDS[COMP_CWORD]}"
         prev="${COMP_WORDS[COMP_CWORD-1]}"
-        commands="checkout co create pr mr list ls remove rm cleanup prune help shellenv init version"
+        commands="checkout co create pr mr list ls status fetch info remove rm move restack describe tag last history cleanup prune repair clean exec foreach snapshot maintenance gc hooks config doctor help shellenv init version"
 
         # Complete commands if first argument
         if [ $COMP_CWORD -eq 1 ]; then
@@ -1286,12 +2082,14 @@ if [ -n "$BASH_VERSION" ]; then
             return 0
         fi
 
-        # Complete branch names for checkout/remove/rm
+        # Complete branch names (cached by wt per repository)
         case "$prev" in
-            checkout|co|remove|rm)
-                local branches
-                branches=$(git worktree list 2>/dev/null | tail -n +2 | sed -n 's/.*\[\([^]]*\)\].*/\1/p')
-                COMPREPLY=( $(compgen -W "$branches" -- "$cur") )
+            checkout|co)
+                COMPREPLY=( $(compgen -W "$(command wt __branches 2>/dev/null)" -- "$cur") )
+                return 0
+                ;;
+            remove|rm|move|restack|describe|info|exec)
+                COMPREPLY=( $(compgen -W "$(command wt __branches --worktrees 2>/dev/null)" -- "$cur") )
                 return 0
                 ;;
         esac
@@ -1311,10 +2109,29 @@ if [ -n "$ZSH_VERSION" ]; then
             'mr:Checkout GitLab MR in worktree'
             'list:List all worktrees'
             'ls:List all worktrees'
+            'status:Show status of all worktrees'
+            'fetch:Fetch once for all worktrees and show how their upstreams moved'
+            'info:Show the details of one worktree'
             'remove:Remove a worktree'
             'rm:Remove a worktree'
+            'move:Move a worktree to a new path'
+            'restack:Rebase a stack of branches onto their parents'
+            'describe:Note why a worktree exists'
+            'tag:Tag worktrees to group and filter them'
+            'last:Go back to the previous worktree'
+            'history:Show the log of created, removed and moved worktrees'
             'cleanup:Remove worktrees for merged branches'
             'prune:Remove worktree administrative files'
+            'repair:Fix the links between worktrees and their repository'
+            'clean:Remove build artifacts from worktrees'
+            'exec:Run a command in one or every worktree'
+            'foreach:Run a command in every worktree'
+            'snapshot:Export and restore the set of worktrees'
+            'maintenance:Set up git maintenance for the repository'
+            'gc:Expire old state and caches of wt'
+            'hooks:Inspect, run and validate hooks'
+            'config:Inspect and edit wt configuration'
+            'doctor:Check that git and the shell integration work'
             'help:Show help'
             'shellenv:Output shell function for auto-cd'
             'init:Initialize shell integration'
@@ -1325,8 +2142,12 @@ if [ -n "$ZSH_VERSION" ]; then
             _describe 'command' commands
         elif (( CURRENT == 3 )); then
             case "$words[2]" in
-                checkout|co|remove|rm)
-                    branches=(${(f)"$(git worktree list 2>/dev/null | tail -n +2 | sed -n 's/.*\[\([^]]*\)\].*/\1/p')"})
+                checkout|co)
+                    branches=(${(f)"$(command wt __branches 2>/dev/null)"})
+                    _describe 'branch' branches
+                    ;;
+                remove|rm|move|restack|describe|info|exec)
+                    branches=(${(f)"$(command wt __branches --worktrees 2>/dev/null)"})
                     _describe 'branch' branches
                     ;;
             esac
@@ -1338,6 +2159,7 @@ if [ -n "$ZSH_VERSION" ]; then
     fi
 fi
 `)
+		return nil
 	},
 }
 
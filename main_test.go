@@ -7,6 +7,22 @@ import (
 	"testing"
 )
 
+// TestMain keeps wt's per-user state and config (e.g. global hooks) out of
+// the real home directory, including for wt binaries spawned by the tests.
+func TestMain(m *testing.M) {
+	tmpDir, err := os.MkdirTemp("", "wt-test-home-*")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_STATE_HOME", filepath.Join(tmpDir, "state"))
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+	os.Unsetenv("WT_CONFIG")
+
+	code := m.Run()
+	os.RemoveAll(tmpDir)
+	os.Exit(code)
+}
+
 func TestGetPRNumber(t *testing.T) {
 	tests := []struct {
 		name    string
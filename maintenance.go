@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// maintenanceMinGit is the first git version with 'git maintenance start'.
+var maintenanceMinGit = [2]int{2, 30}
+
+// Thresholds above which the doctor calls the object store badly packed;
+// they are git's own defaults for gc.auto and gc.autoPackLimit.
+const (
+	looseObjectsLimit = 6700
+	packFilesLimit    = 50
+)
+
+var maintenanceNoSchedule bool
+
+// mainRepoDir is the directory git maintenance is registered for: the main
+// worktree, or the repository itself when it is bare. Registering from a
+// linked worktree would record a path that goes away with the worktree.
+func mainRepoDir() (string, error) {
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return "", err
+	}
+	if filepath.Base(commonDir) == ".git" {
+		return filepath.Dir(commonDir), nil
+	}
+	return commonDir, nil
+}
+
+// maintenanceRegistered reports whether dir is in the maintenance.repo list
+// that scheduled 'git maintenance run' works through.
+func maintenanceRegistered(dir string) bool {
+	output, _ := gitCommand("config", "--get-all", "maintenance.repo").Output()
+	for _, repo := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if repo != "" && samePath(repo, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func samePath(a, b string) bool {
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return ra == rb
+}
+
+// maintenanceCoverage checks that every worktree uses the object store of
+// the maintained repository and sees the incremental strategy, i.e. that
+// maintenance of the main repository covers it too. Worktrees that are not
+// covered are returned as problems, with the reason.
+func maintenanceCoverage(commonDir string) (covered int, problems []string, err error) {
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, e := range entries {
+		if e.Bare || e.Prunable {
+			continue
+		}
+		output, err := gitCommand("-C", e.Path, "rev-parse", "--git-common-dir").Output()
+		dir := strings.TrimSpace(string(output))
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(e.Path, dir)
+		}
+		if err != nil || !samePath(dir, commonDir) {
+			problems = append(problems, fmt.Sprintf("%s: does not share the object store", e.Path))
+			continue
+		}
+		strategy, _ := gitCommand("-C", e.Path, "config", "maintenance.strategy").Output()
+		if s := strings.TrimSpace(string(strategy)); s != "incremental" {
+			problems = append(problems, fmt.Sprintf("%s: maintenance.strategy is %q", e.Path, s))
+			continue
+		}
+		covered++
+	}
+	return covered, problems, nil
+}
+
+// parseCountObjects parses 'git count-objects -v'.
+func parseCountObjects(output string) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			counts[strings.TrimSpace(key)] = n
+		}
+	}
+	return counts
+}
+
+// checkObjectStore warns when the object store all worktrees share has
+// piled up loose objects or packs; every worktree pays for that in speed.
+func checkObjectStore() []doctorResult {
+	const name = "object store"
+	dir, err := mainRepoDir()
+	if err != nil {
+		return []doctorResult{{Name: name, Status: doctorSkip, Message: "not in a git repository"}}
+	}
+	output, err := gitCommand("count-objects", "-v").Output()
+	if err != nil {
+		return []doctorResult{{Name: name, Status: doctorWarn, Message: "git count-objects failed"}}
+	}
+	counts := parseCountObjects(string(output))
+	worktrees := 0
+	if entries, err := snapshot.Worktrees(); err == nil {
+		worktrees = len(entries)
+	}
+
+	message := fmt.Sprintf("%d loose objects, %d packs, shared by %d worktree(s)", counts["count"], counts["packs"], worktrees)
+	registered := maintenanceRegistered(dir)
+	if registered {
+		message += "; git maintenance enabled"
+	}
+	if counts["count"] <= looseObjectsLimit && counts["packs"] <= packFilesLimit {
+		return []doctorResult{{Name: name, Status: doctorOK, Message: message}}
+	}
+
+	hint := "wt maintenance enable"
+	if registered {
+		hint = "git maintenance run --task=gc"
+	}
+	return []doctorResult{{Name: name, Status: doctorWarn, Message: message + " (badly packed)", Hint: hint}}
+}
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Set up git maintenance for the repository",
+	Long: `Set up background git maintenance for the repository all worktrees share.
+
+Worktrees share one object store, so every worktree pays for a neglected
+repository. 'wt maintenance enable' runs 'git maintenance start' on the main
+repository, which schedules git's incremental strategy (hourly prefetch and
+commit-graph, daily loose-objects and incremental-repack), and then checks
+that every worktree uses that object store. 'wt doctor' warns when the
+object store is badly packed.
+
+Examples:
+  wt maintenance enable                # Register and schedule
+  wt maintenance enable --no-schedule  # Register only, e.g. without cron/launchd
+  wt maintenance disable`,
+}
+
+var maintenanceEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Enable scheduled git maintenance for the repository",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !gitAtLeast(maintenanceMinGit[0], maintenanceMinGit[1]) {
+			return fmt.Errorf("git maintenance needs git %d.%d or newer", maintenanceMinGit[0], maintenanceMinGit[1])
+		}
+		dir, err := mainRepoDir()
+		if err != nil {
+			return err
+		}
+		commonDir, err := gitCommonDir()
+		if err != nil {
+			return err
+		}
+
+		subcommand := "start"
+		if maintenanceNoSchedule {
+			subcommand = "register"
+		}
+		gitCmd := gitCommand("-C", dir, "maintenance", subcommand)
+		gitCmd.Stdout = gitOutput()
+		gitCmd.Stderr = os.Stderr
+		if err := gitCmd.Run(); err != nil {
+			return fmt.Errorf("git maintenance %s failed: %w", subcommand, err)
+		}
+		if maintenanceNoSchedule {
+			successf("Registered %s for git maintenance (not scheduled)", dir)
+		} else {
+			successf("Scheduled git maintenance for %s", dir)
+		}
+
+		covered, problems, err := maintenanceCoverage(commonDir)
+		if err != nil {
+			return err
+		}
+		for _, problem := range problems {
+			warnf("warning: %s\n", problem)
+		}
+		infof("%d worktree(s) share the maintained object store\n", covered)
+		return nil
+	},
+}
+
+var maintenanceDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Stop git maintenance for the repository",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := mainRepoDir()
+		if err != nil {
+			return err
+		}
+		if !maintenanceRegistered(dir) {
+			infof("git maintenance is not enabled for %s\n", dir)
+			return nil
+		}
+		gitCmd := gitCommand("-C", dir, "maintenance", "unregister")
+		gitCmd.Stdout = gitOutput()
+		gitCmd.Stderr = os.Stderr
+		if err := gitCmd.Run(); err != nil {
+			return fmt.Errorf("git maintenance unregister failed: %w", err)
+		}
+		successf("Disabled git maintenance for %s", dir)
+		return nil
+	},
+}
+
+func init() {
+	maintenanceEnableCmd.Flags().BoolVar(&maintenanceNoSchedule, "no-schedule", false, "Only register the repository; don't install a scheduler")
+	maintenanceCmd.AddCommand(maintenanceEnableCmd)
+	maintenanceCmd.AddCommand(maintenanceDisableCmd)
+	rootCmd.AddCommand(maintenanceCmd)
+	doctorChecks = append(doctorChecks, checkObjectStore)
+}
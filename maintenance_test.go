@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCountObjects(t *testing.T) {
+	output := "count: 12\nsize: 48\nin-pack: 3400\npacks: 2\nsize-pack: 1024\nprune-packable: 0\ngarbage: 0\nsize-garbage: 0\n"
+	counts := parseCountObjects(output)
+	if counts["count"] != 12 || counts["packs"] != 2 || counts["in-pack"] != 3400 {
+		t.Errorf("parseCountObjects() = %v", counts)
+	}
+}
+
+func TestMaintenanceRegistrationAndCoverage(t *testing.T) {
+	if !gitAtLeast(maintenanceMinGit[0], maintenanceMinGit[1]) {
+		t.Skip("git maintenance not available")
+	}
+	tmpDir := t.TempDir()
+	// 'git maintenance register' writes to the global config.
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(tmpDir, "gitconfig"))
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	worktree := filepath.Join(tmpDir, "feature")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "feature", worktree)
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	// Run from the linked worktree: registration must still be for the main one.
+	if err := os.Chdir(worktree); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := mainRepoDir()
+	if err != nil || !samePath(dir, repoDir) {
+		t.Fatalf("mainRepoDir() = %q, %v; want %s", dir, err, repoDir)
+	}
+	if maintenanceRegistered(dir) {
+		t.Fatal("repository registered before enabling")
+	}
+
+	maintenanceNoSchedule = true
+	t.Cleanup(func() { maintenanceNoSchedule = false })
+	if err := maintenanceEnableCmd.RunE(maintenanceEnableCmd, nil); err != nil {
+		t.Fatalf("maintenance enable error: %v", err)
+	}
+	if !maintenanceRegistered(repoDir) {
+		t.Error("main repository not registered for maintenance")
+	}
+
+	commonDir, _ := gitCommonDir()
+	covered, problems, err := maintenanceCoverage(commonDir)
+	if err != nil || covered != 2 || len(problems) != 0 {
+		t.Errorf("maintenanceCoverage() = %d, %v, %v; want both worktrees covered", covered, problems, err)
+	}
+
+	if err := maintenanceDisableCmd.RunE(maintenanceDisableCmd, nil); err != nil {
+		t.Fatalf("maintenance disable error: %v", err)
+	}
+	if maintenanceRegistered(repoDir) {
+		t.Error("repository still registered after disable")
+	}
+}
+
+func TestCheckObjectStore(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	results := checkObjectStore()
+	if len(results) != 1 || results[0].Status != doctorOK {
+		t.Errorf("checkObjectStore() = %+v, want ok for a fresh repository", results)
+	}
+}
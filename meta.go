@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// worktreeMeta is what the user noted about a branch's worktree.
+type worktreeMeta struct {
+	Description string   `json:"description,omitempty"`
+	Ticket      string   `json:"ticket,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// Expires is set for worktrees checked out with --ephemeral.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+func (m worktreeMeta) empty() bool {
+	return m.Description == "" && m.Ticket == "" && len(m.Tags) == 0 && m.Expires == nil
+}
+
+func (m worktreeMeta) hasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// label renders the metadata on one line: ticket, #tags, description and
+// the expiry of an ephemeral worktree.
+func (m worktreeMeta) label() string {
+	var parts []string
+	if m.Ticket != "" {
+		parts = append(parts, m.Ticket)
+	}
+	for _, tag := range m.Tags {
+		parts = append(parts, "#"+tag)
+	}
+	if m.Description != "" {
+		parts = append(parts, m.Description)
+	}
+	if m.Expires != nil {
+		parts = append(parts, "(ephemeral until "+m.Expires.Local().Format("2006-01-02 15:04")+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// metaStore holds the metadata of a repository's worktrees by branch, so it
+// stays with a worktree that is moved or checked out again.
+type metaStore struct {
+	Branches map[string]worktreeMeta `json:"branches"`
+}
+
+// metaPath is the repository's metadata file. It lives in the common git
+// directory, which every worktree shares and git leaves alone.
+func metaPath() (string, error) {
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(commonDir, "wt-metadata.json"), nil
+}
+
+// loadMeta reads the metadata of the current repository. Outside of a
+// repository or without a file it is empty.
+func loadMeta() *metaStore {
+	store := &metaStore{}
+	if path, err := metaPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			_ = json.Unmarshal(data, store)
+		}
+	}
+	if store.Branches == nil {
+		store.Branches = make(map[string]worktreeMeta)
+	}
+	return store
+}
+
+func (s *metaStore) save() error {
+	path, err := metaPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (s *metaStore) set(branch string, meta worktreeMeta) {
+	if meta.empty() {
+		delete(s.Branches, branch)
+		return
+	}
+	s.Branches[branch] = meta
+}
+
+// gcMetadata drops the metadata of branches that were deleted.
+func gcMetadata(_ time.Duration, dryRun bool) gcResult {
+	result := gcResult{Name: "worktree metadata", Unit: "entries"}
+	if _, err := metaPath(); err != nil {
+		return result
+	}
+	store := loadMeta()
+	for branch := range store.Branches {
+		if !gitRefExists("refs/heads/" + branch) {
+			delete(store.Branches, branch)
+			result.Removed++
+		}
+	}
+	if result.Removed > 0 && !dryRun {
+		if err := store.save(); err != nil {
+			warnf("warning: failed to save worktree metadata: %v\n", err)
+		}
+	}
+	return result
+}
+
+func requireLocalBranch(branch string) error {
+	if gitRefExists("refs/heads/" + branch) {
+		return nil
+	}
+	var names []string
+	if refs, err := snapshot.BranchRefs(); err == nil {
+		for name := range refs {
+			names = append(names, name)
+		}
+	}
+	return fmt.Errorf("branch '%s' does not exist locally%s", branch, didYouMean(suggestNames(branch, names)))
+}
+
+var (
+	describeTicket string
+	describeClear  bool
+)
+
+var describeCmd = &cobra.Command{
+	Use:   "describe <branch> [description]",
+	Short: "Note why a worktree exists",
+	Long: `Attach a description and a ticket to a branch's worktree, or show them.
+'wt list' and 'wt status' show them next to the worktree; see also 'wt tag'.
+
+Metadata is kept per repository, by branch, in the shared git directory
+(wt-metadata.json); 'wt gc' drops it once the branch is deleted.
+
+Examples:
+  wt describe feature/foo "spike on new cache"
+  wt describe feature/foo --ticket JIRA-123
+  wt describe feature/foo            # Show what is noted
+  wt describe feature/foo --clear    # Forget description, ticket and tags`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		branch := args[0]
+		if err := requireLocalBranch(branch); err != nil {
+			return err
+		}
+		store := loadMeta()
+		meta := store.Branches[branch]
+
+		if len(args) == 1 && !describeClear && !cmd.Flags().Changed("ticket") {
+			if meta.empty() {
+				infof("Nothing noted for %s\n", branch)
+				return nil
+			}
+			fmt.Println(meta.label())
+			return nil
+		}
+
+		if describeClear {
+			meta = worktreeMeta{}
+		}
+		if len(args) > 1 {
+			meta.Description = strings.TrimSpace(args[1])
+		}
+		if cmd.Flags().Changed("ticket") {
+			meta.Ticket = strings.TrimSpace(describeTicket)
+		}
+		store.set(branch, meta)
+		if err := store.save(); err != nil {
+			return fmt.Errorf("failed to save worktree metadata: %w", err)
+		}
+		if meta.empty() {
+			successf("Cleared the metadata of %s", branch)
+		} else {
+			successf("%s: %s", branch, meta.label())
+		}
+		return nil
+	},
+}
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Tag worktrees to group and filter them",
+	Long: `Tag branches' worktrees, e.g. to mark experiments, and filter 'wt list'
+and 'wt status' by tag with --tag.
+
+Examples:
+  wt tag add feature/foo experiment perf
+  wt tag remove feature/foo perf
+  wt tag list
+  wt list --tag experiment`,
+}
+
+// editTags adds or removes tags of a branch and saves the result.
+func editTags(branch string, tags []string, add bool) error {
+	if err := requireLocalBranch(branch); err != nil {
+		return err
+	}
+	store := loadMeta()
+	meta := store.Branches[branch]
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+		if tag == "" || strings.ContainsAny(tag, " \t") {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+		if add && !meta.hasTag(tag) {
+			meta.Tags = append(meta.Tags, tag)
+		}
+		if !add {
+			kept := meta.Tags[:0]
+			for _, t := range meta.Tags {
+				if t != tag {
+					kept = append(kept, t)
+				}
+			}
+			meta.Tags = kept
+		}
+	}
+	sort.Strings(meta.Tags)
+	store.set(branch, meta)
+	if err := store.save(); err != nil {
+		return fmt.Errorf("failed to save worktree metadata: %w", err)
+	}
+	if len(meta.Tags) == 0 {
+		successf("%s has no tags", branch)
+	} else {
+		successf("%s: #%s", branch, strings.Join(meta.Tags, " #"))
+	}
+	return nil
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <branch> <tag>...",
+	Short: "Add tags to a branch's worktree",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editTags(args[0], args[1:], true)
+	},
+}
+
+var tagRemoveCmd = &cobra.Command{
+	Use:     "remove <branch> <tag>...",
+	Aliases: []string{"rm"},
+	Short:   "Remove tags from a branch's worktree",
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editTags(args[0], args[1:], false)
+	},
+}
+
+var tagListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tags and the branches that have them",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		byTag := make(map[string][]string)
+		for branch, meta := range loadMeta().Branches {
+			for _, tag := range meta.Tags {
+				byTag[tag] = append(byTag[tag], branch)
+			}
+		}
+		tags := make([]string, 0, len(byTag))
+		for tag := range byTag {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			sort.Strings(byTag[tag])
+			fmt.Printf("%s: %s\n", tag, strings.Join(byTag[tag], " "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	describeCmd.Flags().StringVar(&describeTicket, "ticket", "", "Ticket or issue the worktree is for (empty to unset)")
+	describeCmd.Flags().BoolVar(&describeClear, "clear", false, "Forget the description, ticket and tags")
+	tagCmd.AddCommand(tagAddCmd)
+	tagCmd.AddCommand(tagRemoveCmd)
+	tagCmd.AddCommand(tagListCmd)
+	rootCmd.AddCommand(describeCmd)
+	rootCmd.AddCommand(tagCmd)
+	gcTasks = append(gcTasks, gcMetadata)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorktreeMetaLabel(t *testing.T) {
+	meta := worktreeMeta{Description: "spike on new cache", Ticket: "JIRA-123", Tags: []string{"experiment", "perf"}}
+	if got, want := meta.label(), "JIRA-123 #experiment #perf spike on new cache"; got != want {
+		t.Errorf("label() = %q, want %q", got, want)
+	}
+	if (worktreeMeta{}).label() != "" || !(worktreeMeta{}).empty() {
+		t.Error("empty metadata should have no label")
+	}
+}
+
+func TestMetaStore(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "branch", "feature-a")
+	runGitCommand(t, repoDir, "branch", "feature-b")
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := editTags("feature-a", []string{"perf", "#experiment"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := editTags("feature-b", []string{"experiment"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := editTags("feature-b", []string{"experiment"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := editTags("feature-a", []string{"two words"}, true); err == nil {
+		t.Error("editTags() accepted a tag with a space")
+	}
+	if err := editTags("feature-c", []string{"x"}, true); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("editTags() of a missing branch = %v, want a does not exist error", err)
+	}
+
+	store := loadMeta()
+	if got := store.Branches["feature-a"].Tags; strings.Join(got, ",") != "experiment,perf" {
+		t.Errorf("feature-a tags = %v, want [experiment perf]", got)
+	}
+	if _, ok := store.Branches["feature-b"]; ok {
+		t.Error("feature-b without tags should have no metadata entry")
+	}
+
+	runGitCommand(t, repoDir, "branch", "-D", "feature-a")
+	if result := gcMetadata(0, true); result.Removed != 1 {
+		t.Errorf("dry run removed %d entries, want 1", result.Removed)
+	}
+	if len(loadMeta().Branches) != 1 {
+		t.Fatal("dry run changed the metadata")
+	}
+	gcMetadata(0, false)
+	if n := len(loadMeta().Branches); n != 0 {
+		t.Errorf("%d entries left after gc, want 0", n)
+	}
+}
+
+func TestFormatListLineMeta(t *testing.T) {
+	item := listItem{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Path: "/wt/a", Head: "abcdef123", Branch: "a"}}}
+	if got := formatListLine(item); strings.Count(got, "\t") != 1 {
+		t.Errorf("formatListLine() without metadata = %q, want two fields", got)
+	}
+	item.Meta = worktreeMeta{Tags: []string{"experiment"}}
+	if got, want := formatListLine(item), "/wt/a\tabcdef1 [a]\t#experiment"; got != want {
+		t.Errorf("formatListLine() = %q, want %q", got, want)
+	}
+}
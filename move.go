@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	moveAll    bool
+	moveDryRun bool
+)
+
+// relocateState carries wt's per-worktree bookkeeping over to the new path
+// of a moved worktree. oldKey is the resolved old path, taken before the
+// move since symlinks in it can't be resolved afterwards.
+func relocateState(oldKey, newPath string) {
+	state := loadState()
+	newKey := resolvePath(newPath)
+	changed := false
+	if visit, ok := state.Visits[oldKey]; ok {
+		delete(state.Visits, oldKey)
+		state.Visits[newKey] = visit
+		changed = true
+	}
+	if runs, ok := state.HookRuns[oldKey]; ok {
+		delete(state.HookRuns, oldKey)
+		state.HookRuns[newKey] = runs
+		changed = true
+	}
+	for key, session := range state.Sessions {
+		if session.Current == oldKey || session.Previous == oldKey {
+			if session.Current == oldKey {
+				session.Current = newKey
+			}
+			if session.Previous == oldKey {
+				session.Previous = newKey
+			}
+			state.Sessions[key] = session
+			changed = true
+		}
+	}
+	if changed {
+		_ = state.save()
+	}
+}
+
+// moveWorktree moves the worktree at entry.Path to dst with 'git worktree
+// move' and tidies up after it. It returns the new path of the current
+// directory when that was inside the moved worktree, else "".
+func moveWorktree(entry worktreeEntry, dst string) (string, error) {
+	if _, err := os.Lstat(longPath(dst)); err == nil {
+		return "", fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.MkdirAll(longPath(filepath.Dir(dst)), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+	}
+
+	var newCwd string
+	if cwd, err := os.Getwd(); err == nil && isWithin(entry.Path, cwd) {
+		if rel, err := filepath.Rel(resolvePath(entry.Path), resolvePath(cwd)); err == nil {
+			newCwd = filepath.Join(dst, rel)
+		}
+		// The current directory is about to disappear; git runs from the
+		// main worktree instead.
+		if _, err := leaveWorktrees(entry.Path); err != nil {
+			return "", err
+		}
+	}
+
+	oldKey := resolvePath(entry.Path)
+	var stderr bytes.Buffer
+	gitCmd := repoVCS().moveWorktree(entry.Path, dst)
+	gitCmd.Stdout = gitOutput()
+	gitCmd.Stderr = &stderr
+	err := gitCmd.Run()
+	if err != nil && strings.Contains(stderr.String(), "cross-device") {
+		// git only renames, which fails between filesystems, e.g. from a
+		// network share to a local disk.
+		err = copyWorktree(entry.Path, dst)
+	} else {
+		os.Stderr.Write(stderr.Bytes())
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to move worktree %s: %w", entry.Path, err)
+		recordAudit(entry.Branch, dst, "from "+entry.Path, err)
+		return "", err
+	}
+	snapshot.invalidate()
+	recordAudit(entry.Branch, dst, "from "+entry.Path, nil)
+
+	relocateState(oldKey, dst)
+	removeEmptyParents(entry.Path)
+	return newCwd, nil
+}
+
+// copyWorktree moves the worktree at src to dst on another filesystem: it
+// copies the files, lets git point its administrative files at the copy and
+// removes the original.
+func copyWorktree(src, dst string) error {
+	infof("%s is on another filesystem; copying the worktree\n", dst)
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(longPath(dst))
+		return err
+	}
+	if output, err := gitCommand("worktree", "repair", dst).CombinedOutput(); err != nil {
+		os.RemoveAll(longPath(dst))
+		return fmt.Errorf("git worktree repair: %s", strings.TrimSpace(string(output)))
+	}
+	return os.RemoveAll(longPath(src))
+}
+
+// moveTarget is where a worktree moves to: the given path, or else where
+// the current layout puts its branch. A dry run creates nothing.
+func moveTarget(info repoInfo, entry worktreeEntry, path string) (string, error) {
+	if path != "" {
+		return filepath.Abs(expandHome(path))
+	}
+	if entry.Branch == "" {
+		return "", fmt.Errorf("%s is detached; give the new path explicitly", entry.Path)
+	}
+	if moveDryRun {
+		return worktreePathFor(info, entry.Branch)
+	}
+	return buildWorktreePath(info, entry.Branch)
+}
+
+var moveCmd = &cobra.Command{
+	Use:   "move <branch> [new-path] | --all",
+	Short: "Move a worktree to a new path",
+	Long: `Move a worktree with 'git worktree move' and keep wt's bookkeeping (such as
+last visits) with it.
+
+Without a new path the worktree moves to where the current configuration
+puts it, e.g. after changing WORKTREE_ROOT or the strategy. With --all every
+worktree that is not at that place moves there.
+
+If the current directory is inside a moved worktree, the shell integration
+follows it to the new location.
+
+Examples:
+  wt move feature-x ~/scratch/feature-x  # Move to an explicit path
+  wt move feature-x                      # Back to where the layout wants it
+  wt move --all --dry-run                # After changing the root: what moves?
+  wt move --all`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if moveAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.RangeArgs(1, 2)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info, err := getRepoInfo()
+		if err != nil {
+			return err
+		}
+		entries, err := snapshot.Worktrees()
+		if err != nil {
+			return err
+		}
+
+		type move struct {
+			entry worktreeEntry
+			dst   string
+		}
+		var moves []move
+		if moveAll {
+			for i, e := range entries {
+				if i == 0 || e.Bare || e.Prunable || e.Branch == "" {
+					continue
+				}
+				dst, err := moveTarget(info, e, "")
+				if err != nil {
+					return err
+				}
+				if resolvePath(dst) != resolvePath(e.Path) {
+					moves = append(moves, move{e, dst})
+				}
+			}
+			if len(moves) == 0 {
+				infof("All worktrees are where the layout puts them\n")
+				return nil
+			}
+		} else {
+			branch := args[0]
+			index := -1
+			for i, e := range entries {
+				if e.Branch == branch {
+					index = i
+				}
+			}
+			if index == -1 {
+				return fmt.Errorf("no worktree found for branch: %s", branch)
+			}
+			if index == 0 {
+				return fmt.Errorf("the main worktree cannot be moved")
+			}
+			path := ""
+			if len(args) > 1 {
+				path = args[1]
+			}
+			dst, err := moveTarget(info, entries[index], path)
+			if err != nil {
+				return err
+			}
+			if resolvePath(dst) == resolvePath(entries[index].Path) {
+				infof("%s is already at %s\n", branch, dst)
+				return nil
+			}
+			moves = append(moves, move{entries[index], dst})
+		}
+
+		if !moveDryRun && (moveAll || len(args) < 2) {
+			// The layout now holds worktrees under the repository's name.
+			pinRepoName(info)
+		}
+		cdPath := ""
+		failed := 0
+		for _, m := range moves {
+			if moveDryRun {
+				fmt.Printf("Would move %s: %s -> %s\n", m.entry.Branch, m.entry.Path, m.dst)
+				continue
+			}
+			newCwd, err := moveWorktree(m.entry, m.dst)
+			if err != nil {
+				warnf("%v\n", err)
+				failed++
+				continue
+			}
+			successf("Moved %s to %s", m.entry.Branch, m.dst)
+			if newCwd != "" {
+				cdPath = newCwd
+			}
+		}
+		if cdPath != "" {
+			printCDMarker(cdPath)
+		}
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d worktree(s) could not be moved", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	moveCmd.Flags().BoolVar(&moveAll, "all", false, "Move every worktree that is not where the current layout puts it")
+	moveCmd.Flags().BoolVar(&moveDryRun, "dry-run", false, "Show what would move without moving anything")
+	rootCmd.AddCommand(moveCmd)
+}
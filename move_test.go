@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMoveWorktreeToLayout(t *testing.T) {
+	originalRoot, originalStrategy, originalPattern := worktreeRoot, worktreeStrategy, worktreePattern
+	t.Cleanup(func() {
+		worktreeRoot, worktreeStrategy, worktreePattern = originalRoot, originalStrategy, originalPattern
+	})
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	worktreeStrategy = "global"
+	worktreePattern = ""
+
+	repo := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repo)
+	oldRoot := filepath.Join(tmpDir, "old-root")
+	oldPath := filepath.Join(oldRoot, "repo", "feature")
+	runGitCommand(t, repo, "worktree", "add", "-q", "-b", "feature", oldPath)
+
+	visited := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	state := loadState()
+	state.Visits[resolvePath(oldPath)] = visited
+	if err := state.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(oldPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// The root changed since the worktree was created.
+	worktreeRoot = filepath.Join(tmpDir, "new-root")
+	info, err := getRepoInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := entries[1]
+
+	// A dry run leaves the new root alone.
+	moveDryRun = true
+	planned, err := moveTarget(info, entry, "")
+	moveDryRun = false
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(worktreeRoot); !os.IsNotExist(err) {
+		t.Errorf("dry run created %s: %v", worktreeRoot, err)
+	}
+
+	dst, err := moveTarget(info, entry, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(worktreeRoot, "repo", "feature"); dst != want {
+		t.Fatalf("moveTarget() = %s, want %s", dst, want)
+	}
+	if planned != dst {
+		t.Errorf("moveTarget() in a dry run = %s, want %s", planned, dst)
+	}
+
+	newCwd, err := moveWorktree(entry, dst)
+	if err != nil {
+		t.Fatalf("moveWorktree() error: %v", err)
+	}
+	if newCwd != dst {
+		t.Errorf("new current directory = %q, want %q", newCwd, dst)
+	}
+	if path, ok := worktreeExists("feature"); !ok || resolvePath(path) != resolvePath(dst) {
+		t.Errorf("feature is at %q, want %q", path, dst)
+	}
+	visits := loadState().Visits
+	if _, ok := visits[resolvePath(oldPath)]; ok {
+		t.Errorf("visit of the old path was kept")
+	}
+	if got := visits[resolvePath(dst)]; !got.Equal(visited) {
+		t.Errorf("visit of the new path = %v, want %v", got, visited)
+	}
+
+	// A worktree is not moved onto an existing path.
+	entries, _ = snapshot.Worktrees()
+	if _, err := moveWorktree(entries[1], repo); err == nil {
+		t.Error("moveWorktree() onto an existing directory succeeded")
+	}
+}
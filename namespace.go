@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// defaultNamespace keeps worktrees of a repository under its name.
+const defaultNamespace = "{.repo.Name}"
+
+// renderNamespace expands the namespace pattern into the per-repository
+// directory used by {.namespace}. Empty segments (e.g. a missing owner for
+// repositories without a remote) are dropped.
+func renderNamespace(pattern string, context map[string]any) (string, error) {
+	if pattern == "" {
+		pattern = defaultNamespace
+	}
+	rendered, err := renderWorktreePattern(pattern, context)
+	if err != nil {
+		return "", fmt.Errorf("invalid namespace: %w", err)
+	}
+
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(rendered), "/") {
+		if part != "" && part != "." && part != ".." {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("namespace %q renders to an empty path", pattern)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// worktreeCommonDir returns the git common directory of the checkout at
+// dir, read from its .git entry without running git. It returns "" if dir
+// is not a git checkout.
+func worktreeCommonDir(dir string) string {
+	dotGit := filepath.Join(dir, ".git")
+	stat, err := os.Stat(longPath(dotGit))
+	if err != nil {
+		return ""
+	}
+	if stat.IsDir() {
+		return resolvePath(dotGit)
+	}
+
+	data, err := os.ReadFile(longPath(dotGit))
+	if err != nil {
+		return ""
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	if common, err := os.ReadFile(longPath(filepath.Join(gitDir, "commondir"))); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		return resolvePath(commonDir)
+	}
+	return resolvePath(gitDir)
+}
+
+// checkNamespaceCollision refuses to put a worktree into a directory under
+// the worktree root that already holds worktrees of another repository,
+// which happens when two repositories share a name.
+func checkNamespaceCollision(parent string) error {
+	if !isWithin(worktreeRoot, parent) || resolvePath(parent) == resolvePath(worktreeRoot) {
+		return nil
+	}
+	ours, err := gitCommonDir()
+	if err != nil {
+		return nil
+	}
+	ours = resolvePath(ours)
+
+	entries, err := os.ReadDir(longPath(parent))
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		theirs := worktreeCommonDir(filepath.Join(parent, entry.Name()))
+		if theirs == "" || sameDir(theirs, ours) {
+			continue
+		}
+		return fmt.Errorf(`%s already holds worktrees of another repository (%s)
+Two repositories with the same name share this directory. Give each its own namespace, e.g.:
+  wt config set namespace '{.repo.Host}/{.repo.Owner}/{.repo.Name}'`, parent, theirs)
+	}
+	return nil
+}
+
+func sameDir(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
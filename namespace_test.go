@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderNamespace(t *testing.T) {
+	context := map[string]any{
+		"repo": repoInfo{Host: "github.com", Owner: "org", Name: "api"},
+	}
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"", "api"},
+		{"{.repo.Host}/{.repo.Owner}/{.repo.Name}", "github.com/org/api"},
+		{"{.repo.Owner}-{.repo.Name}", "org-api"},
+	}
+	for _, tt := range tests {
+		got, err := renderNamespace(tt.pattern, context)
+		if err != nil || got != tt.want {
+			t.Errorf("renderNamespace(%q) = %q, %v; want %q", tt.pattern, got, err, tt.want)
+		}
+	}
+
+	noRemote := map[string]any{"repo": repoInfo{Name: "api"}}
+	if got, _ := renderNamespace("{.repo.Host}/{.repo.Owner}/{.repo.Name}", noRemote); got != "api" {
+		t.Errorf("renderNamespace() without remote = %q, want api", got)
+	}
+	if _, err := renderNamespace("../{.repo.Missing}", context); err == nil {
+		t.Error("expected unknown variable to fail")
+	}
+}
+
+func TestNamespaceCollisionDetected(t *testing.T) {
+	originalRoot, originalStrategy, originalPattern, originalNamespace := worktreeRoot, worktreeStrategy, worktreePattern, worktreeNamespace
+	t.Cleanup(func() {
+		worktreeRoot, worktreeStrategy, worktreePattern, worktreeNamespace = originalRoot, originalStrategy, originalPattern, originalNamespace
+	})
+
+	tmpDir := t.TempDir()
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	worktreeStrategy, worktreePattern, worktreeNamespace = "global", "", ""
+
+	// Two different clones that are both called "api".
+	first := filepath.Join(tmpDir, "org1", "api")
+	second := filepath.Join(tmpDir, "org2", "api")
+	setupTestRepo(t, first)
+	setupTestRepo(t, second)
+	runGitCommand(t, first, "worktree", "add", "-b", "feature-a", filepath.Join(worktreeRoot, "api", "feature-a"))
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+
+	if err := os.Chdir(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buildWorktreePath(repoInfo{Main: first, Name: "api"}, "feature-b"); err != nil {
+		t.Errorf("buildWorktreePath() for the owning repository failed: %v", err)
+	}
+
+	if err := os.Chdir(second); err != nil {
+		t.Fatal(err)
+	}
+	_, err := buildWorktreePath(repoInfo{Main: second, Name: "api"}, "feature-a")
+	if err == nil || !strings.Contains(err.Error(), "another repository") {
+		t.Fatalf("buildWorktreePath() error = %v, want namespace collision", err)
+	}
+
+	worktreeNamespace = "{.repo.Owner}/{.repo.Name}"
+	path, err := buildWorktreePath(repoInfo{Main: second, Owner: "org2", Name: "api"}, "feature-a")
+	if err != nil {
+		t.Fatalf("buildWorktreePath() with namespace error: %v", err)
+	}
+	if want := filepath.Join(worktreeRoot, "org2", "api", "feature-a"); path != want {
+		t.Errorf("buildWorktreePath() = %s, want %s", path, want)
+	}
+}
+
+func TestCleanupWorktreePathRemovesEmptyNamespace(t *testing.T) {
+	originalRoot := worktreeRoot
+	t.Cleanup(func() { worktreeRoot = originalRoot })
+	worktreeRoot = t.TempDir()
+
+	worktree := filepath.Join(worktreeRoot, "github.com", "org", "api", "feature")
+	if err := os.MkdirAll(worktree, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := cleanupWorktreePath(worktree); err != nil {
+		t.Fatalf("cleanupWorktreePath() error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(worktreeRoot, "github.com")); !os.IsNotExist(err) {
+		t.Errorf("expected empty namespace directories to be removed, stat error: %v", err)
+	}
+	if _, err := os.Stat(worktreeRoot); err != nil {
+		t.Errorf("worktree root must be kept: %v", err)
+	}
+}
+
+func TestRepoNamePinnedWhenDerivedFromDirectory(t *testing.T) {
+	originalRoot, originalStrategy, originalPattern, originalName := worktreeRoot, worktreeStrategy, worktreePattern, worktreeRepoName
+	t.Cleanup(func() {
+		worktreeRoot, worktreeStrategy, worktreePattern, worktreeRepoName = originalRoot, originalStrategy, originalPattern, originalName
+	})
+	tmpDir := t.TempDir()
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	worktreeStrategy, worktreePattern, worktreeRepoName = "global", "", ""
+
+	repoDir := filepath.Join(tmpDir, "my-clone")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := getRepoInfo()
+	if err != nil {
+		t.Fatalf("getRepoInfo() error: %v", err)
+	}
+	if info.Name != "my-clone" || !info.nameFromDir {
+		t.Fatalf("getRepoInfo() = %+v, want directory-derived name my-clone", info)
+	}
+	if _, err := buildWorktreePath(info, "feature"); err != nil {
+		t.Fatalf("buildWorktreePath() error: %v", err)
+	}
+	// Working out paths, as list and completion do, leaves git config alone.
+	if output, _ := exec.Command("git", "-C", repoDir, "config", "wt.name").Output(); len(output) > 0 {
+		t.Errorf("buildWorktreePath() pinned wt.name = %s", output)
+	}
+	pinRepoName(info)
+	output, err := exec.Command("git", "-C", repoDir, "config", "wt.name").Output()
+	if got := strings.TrimSpace(string(output)); err != nil || got != "my-clone" {
+		t.Errorf("git config wt.name = %q (%v), want my-clone", got, err)
+	}
+
+	// A pinned name wins over the (renamed) directory.
+	worktreeRepoName = "my-clone"
+	renamed := filepath.Join(tmpDir, "renamed-clone")
+	if err := os.Rename(repoDir, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(renamed); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := getRepoInfo(); info.Name != "my-clone" || info.nameFromDir {
+		t.Errorf("getRepoInfo() after rename = %+v, want pinned name my-clone", info)
+	}
+}
+
+func TestRepoNameFromRemote(t *testing.T) {
+	originalName := worktreeRepoName
+	t.Cleanup(func() { worktreeRepoName = originalName })
+	worktreeRepoName = ""
+
+	repoDir := filepath.Join(t.TempDir(), "local-dir")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "remote", "add", "origin", "git@github.com:org/api.git")
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := getRepoInfo()
+	if err != nil {
+		t.Fatalf("getRepoInfo() error: %v", err)
+	}
+	if info.Name != "api" || info.Owner != "org" || info.Host != "github.com" || info.nameFromDir {
+		t.Errorf("getRepoInfo() = %+v, want github.com/org/api from origin", info)
+	}
+}
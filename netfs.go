@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// networkLockScale is how much longer wt waits for locks in a repository on
+// a network filesystem, where lock files linger in attribute caches and
+// every git command takes longer.
+const networkLockScale = 4
+
+// worktreeAdminDir is the admin-dir setting: a local directory for git's
+// administrative files of new worktrees of repositories on a network
+// filesystem.
+var worktreeAdminDir string
+
+// filesystemKind names the network filesystem path is on, e.g. "nfs", or
+// returns "" for a local one. It is a variable so tests can fake a share.
+var filesystemKind = statFSKind
+
+var (
+	networkFSMu    sync.Mutex
+	networkFSCache = map[string]string{}
+)
+
+// networkFS returns the kind of network filesystem path is on, or "" when
+// it is local or that cannot be told. A path that does not exist yet is
+// looked up through its nearest existing parent.
+func networkFS(path string) string {
+	networkFSMu.Lock()
+	defer networkFSMu.Unlock()
+	if kind, ok := networkFSCache[path]; ok {
+		return kind
+	}
+	kind := ""
+	for p := path; ; p = filepath.Dir(p) {
+		if _, err := os.Stat(longPath(p)); err == nil {
+			kind = filesystemKind(p)
+			break
+		}
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	networkFSCache[path] = kind
+	return kind
+}
+
+// lockScale is the factor lock timeouts and backoffs of the repository are
+// stretched by.
+func lockScale() time.Duration {
+	if commonDir, err := gitCommonDir(); err == nil && networkFS(commonDir) != "" {
+		return networkLockScale
+	}
+	return 1
+}
+
+// prepareWorktree adapts a new worktree at path to network filesystems: it
+// points out that git is slow on a share, and with admin-dir set moves the
+// worktree's administrative files off a share that holds the repository.
+// It also creates the scratch directory, if one is configured.
+func prepareWorktree(path string) {
+	createScratchDir(path)
+	if kind := networkFS(path); kind != "" {
+		infof("%s is on a network filesystem (%s): git status and checkouts are slower there; see 'wt doctor'\n", path, kind)
+	}
+	if err := localizeWorktreeAdmin(path); err != nil {
+		warnf("warning: git's files of %s stay on the network filesystem: %v\n", path, err)
+	}
+}
+
+// adminDirName names the local directory of a worktree's administrative
+// files after the one git made, which is unique per repository and host.
+func adminDirName(gitDir string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(gitDir)))[:12] + "-" + filepath.Base(gitDir)
+}
+
+// localizeWorktreeAdmin moves the administrative files git keeps for the
+// worktree at path (HEAD, index, logs; the index is written by every git
+// status) from the repository's git directory on a network filesystem to
+// admin-dir, and leaves a symlink where git looks for them. 'git worktree
+// remove' empties the local directory and 'wt gc' removes it.
+func localizeWorktreeAdmin(path string) error {
+	if worktreeAdminDir == "" || runtime.GOOS == "windows" {
+		return nil
+	}
+	commonDir, err := gitCommonDir()
+	if err != nil || networkFS(commonDir) == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(path, ".git"))
+	if err != nil {
+		return err
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(data)), "gitdir:"))
+	if info, err := os.Lstat(gitDir); err != nil || !info.IsDir() {
+		// Already a link, or not a worktree wt knows how to handle.
+		return err
+	}
+
+	local := filepath.Join(worktreeAdminDir, adminDirName(gitDir))
+	if err := copyTree(gitDir, local); err != nil {
+		os.RemoveAll(local)
+		return err
+	}
+	// git wrote it relative to gitDir, which the link would resolve wrongly.
+	if err := os.WriteFile(filepath.Join(local, "commondir"), []byte(commonDir+"\n"), 0o644); err != nil {
+		os.RemoveAll(local)
+		return err
+	}
+	old := gitDir + ".wt-old"
+	if err := os.Rename(gitDir, old); err != nil {
+		os.RemoveAll(local)
+		return err
+	}
+	if err := os.Symlink(local, gitDir); err != nil {
+		os.Rename(old, gitDir)
+		os.RemoveAll(local)
+		return err
+	}
+	os.RemoveAll(old)
+	infof("Keeping git's files of this worktree in %s\n", local)
+	return nil
+}
+
+// copyTree copies the directory src to dst, which must not exist, keeping
+// file modes and symlinks. It is what a move becomes between filesystems.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(longPath(target), info.Mode().Perm()|0o700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		// Sockets and the like are not worth keeping.
+		return nil
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(longPath(src))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(longPath(dst), os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// gcAdminDirs removes the local administrative files of worktrees that are
+// gone: git empties the directory when it removes a worktree, and leaves
+// it alone when the worktree was deleted by hand.
+func gcAdminDirs(retention time.Duration, dryRun bool) gcResult {
+	result := gcResult{Name: "local worktree git files", Unit: "directories"}
+	if worktreeAdminDir == "" {
+		return result
+	}
+	dirs, _ := filepath.Glob(filepath.Join(worktreeAdminDir, "*"))
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "gitdir"))
+		if err == nil {
+			// gitdir names the worktree's .git file.
+			if _, err := os.Stat(strings.TrimSpace(string(data))); err == nil {
+				continue
+			}
+		}
+		result.Removed++
+		result.Bytes += dirSize(dir)
+		if !dryRun {
+			os.RemoveAll(dir)
+		}
+	}
+	return result
+}
+
+// checkNetworkFS warns when the worktree root or the repository is on a
+// network filesystem, where git is slow and some failures are specific.
+func checkNetworkFS() []doctorResult {
+	const name = "network filesystem"
+	var on []string
+	if kind := networkFS(worktreeRoot); kind != "" {
+		on = append(on, fmt.Sprintf("root %s (%s)", worktreeRoot, kind))
+	}
+	repoOnShare := false
+	if commonDir, err := gitCommonDir(); err == nil {
+		if kind := networkFS(commonDir); kind != "" {
+			on = append(on, fmt.Sprintf("repository %s (%s)", commonDir, kind))
+			repoOnShare = true
+		}
+	}
+	if len(on) == 0 {
+		return []doctorResult{{Name: name, Status: doctorOK, Message: "worktree root and repository are local"}}
+	}
+	message := strings.Join(on, " and ") + " on a network filesystem: git status and checkouts are slower, wt waits longer for locks"
+	switch {
+	case !repoOnShare:
+		return []doctorResult{{Name: name, Status: doctorWarn, Message: message, Hint: "keep worktrees on a local disk if you can (wt config set root <dir>)"}}
+	case worktreeAdminDir != "":
+		return []doctorResult{{Name: name, Status: doctorWarn, Message: message + "; git's files of new worktrees go to " + worktreeAdminDir}}
+	}
+	return []doctorResult{{Name: name, Status: doctorWarn, Message: message, Hint: "keep git's files of new worktrees local: wt config set admin-dir ~/.cache/wt/admin"}}
+}
+
+func init() {
+	gcTasks = append(gcTasks, gcAdminDirs)
+	doctorChecks = append(doctorChecks, checkNetworkFS)
+}
@@ -0,0 +1,24 @@
+package main
+
+import "syscall"
+
+// networkTypes are the macOS filesystem types of network shares.
+var networkTypes = map[string]bool{"nfs": true, "smbfs": true, "afpfs": true, "webdav": true, "cifs": true}
+
+func statFSKind(path string) string {
+	var st syscall.Statfs_t
+	if syscall.Statfs(path, &st) != nil {
+		return ""
+	}
+	var name []byte
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	if networkTypes[string(name)] {
+		return string(name)
+	}
+	return ""
+}
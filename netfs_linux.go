@@ -0,0 +1,25 @@
+package main
+
+import "syscall"
+
+// networkMagic maps the statfs magic numbers of network filesystems to
+// their names.
+var networkMagic = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x5346414f: "afs",
+	0x01021997: "9p",
+	0x00c36400: "ceph",
+	0x0bd00bd0: "lustre",
+	0x47504653: "gpfs",
+}
+
+func statFSKind(path string) string {
+	var st syscall.Statfs_t
+	if syscall.Statfs(path, &st) != nil {
+		return ""
+	}
+	return networkMagic[uint32(st.Type)]
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+// statFSKind cannot tell network filesystems apart on this platform.
+func statFSKind(path string) string {
+	return ""
+}
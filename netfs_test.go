@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeNetworkFS makes every path under dir look like an NFS mount.
+func fakeNetworkFS(t *testing.T, dir string) {
+	original := filesystemKind
+	t.Cleanup(func() {
+		filesystemKind = original
+		networkFSCache = map[string]string{}
+	})
+	networkFSCache = map[string]string{}
+	filesystemKind = func(path string) string {
+		if isWithin(dir, path) {
+			return "nfs"
+		}
+		return ""
+	}
+}
+
+func TestNetworkFS(t *testing.T) {
+	share := t.TempDir()
+	fakeNetworkFS(t, share)
+	if got := networkFS(filepath.Join(share, "not", "yet")); got != "nfs" {
+		t.Errorf("networkFS() of a missing path on the share = %q, want nfs", got)
+	}
+	if got := networkFS(t.TempDir()); got != "" {
+		t.Errorf("networkFS() of a local path = %q", got)
+	}
+
+	path := filepath.Join(share, "state.json")
+	if err := writeFileAtomic(path, []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "two" {
+		t.Errorf("content = %q, want two", data)
+	}
+	if entries, _ := os.ReadDir(share); len(entries) != 1 {
+		t.Errorf("share holds %d files, want 1", len(entries))
+	}
+}
+
+func TestHolderGone(t *testing.T) {
+	hostname, _ := os.Hostname()
+	dead := 1<<22 + 1
+	if !holderGone(dead, "") || !holderGone(dead, hostname) {
+		t.Error("dead holder on this host is not gone")
+	}
+	if holderGone(dead, "other-"+hostname) {
+		t.Error("holder on another host was judged by its pid")
+	}
+	if holderGone(os.Getpid(), hostname) {
+		t.Error("live holder is gone")
+	}
+}
+
+func TestLocalizeWorktreeAdmin(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() {
+		os.Chdir(origDir)
+		worktreeAdminDir = ""
+	})
+	chdirFamily(t, repoDir)
+	fakeNetworkFS(t, tmpDir)
+	worktreeAdminDir = filepath.Join(t.TempDir(), "admin")
+
+	wtPath := filepath.Join(tmpDir, "feature")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "feature", wtPath)
+	if err := localizeWorktreeAdmin(wtPath); err != nil {
+		t.Fatal(err)
+	}
+	gitDir := filepath.Join(repoDir, ".git", "worktrees", "feature")
+	if info, err := os.Lstat(gitDir); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("%s is not a link: %v", gitDir, err)
+	}
+	if _, err := os.Stat(gitDir + ".wt-old"); !os.IsNotExist(err) {
+		t.Errorf("original admin directory was kept: %v", err)
+	}
+	// A second call leaves the link alone.
+	if err := localizeWorktreeAdmin(wtPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(wtPath, "new.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, wtPath, "add", "new.txt")
+	runGitCommand(t, wtPath, "commit", "-q", "-m", "on the share")
+	output, err := gitCommand("worktree", "list", "--porcelain").Output()
+	if err != nil || !strings.Contains(string(output), "branch refs/heads/feature") {
+		t.Errorf("git worktree list = %s, %v", output, err)
+	}
+	if result := gcAdminDirs(0, false); result.Removed != 0 {
+		t.Errorf("gcAdminDirs() removed %d directories of a live worktree", result.Removed)
+	}
+
+	runGitCommand(t, repoDir, "worktree", "remove", wtPath)
+	if result := gcAdminDirs(0, false); result.Removed != 1 {
+		t.Errorf("gcAdminDirs() after removal = %d, want 1", result.Removed)
+	}
+	if entries, _ := os.ReadDir(worktreeAdminDir); len(entries) != 0 {
+		t.Errorf("admin dir still holds %d entries", len(entries))
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "copy")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "run.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/run.sh", filepath.Join(src, "link")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if err := copyTree(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "sub", "run.sh")); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("copied script = %v, %v; want executable", info, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "sub/run.sh" {
+		t.Errorf("copied link = %q, %v", link, err)
+	}
+}
+
+func TestCheckNetworkFS(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	originalRoot := worktreeRoot
+	t.Cleanup(func() {
+		os.Chdir(origDir)
+		worktreeRoot = originalRoot
+	})
+	chdirFamily(t, repoDir)
+	worktreeRoot = filepath.Join(t.TempDir(), "worktrees")
+
+	fakeNetworkFS(t, filepath.Join(tmpDir, "elsewhere"))
+	if results := checkNetworkFS(); results[0].Status != doctorOK {
+		t.Errorf("local setup = %+v", results)
+	}
+	fakeNetworkFS(t, tmpDir)
+	results := checkNetworkFS()
+	if results[0].Status != doctorWarn || !strings.Contains(results[0].Message, "(nfs)") || !strings.Contains(results[0].Hint, "admin-dir") {
+		t.Errorf("repository on a share = %+v", results)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const driveRemote = 4 // DRIVE_REMOTE
+
+var getDriveType = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDriveTypeW")
+
+// statFSKind tells UNC paths and mapped network drives apart from local
+// ones; Windows does not say which protocol a share uses.
+func statFSKind(path string) string {
+	volume := filepath.VolumeName(path)
+	if strings.HasPrefix(volume, `\\`) && !strings.HasPrefix(volume, `\\?\`) {
+		return "smb"
+	}
+	root, err := syscall.UTF16PtrFromString(volume + `\`)
+	if err != nil || volume == "" {
+		return ""
+	}
+	if kind, _, _ := getDriveType.Call(uintptr(unsafe.Pointer(root))); kind == driveRemote {
+		return "smb"
+	}
+	return ""
+}
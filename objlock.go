@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// A lock older than this is left over from a wt process that died
+	// without cleaning up, even if its pid was reused since.
+	objectLockStale = 2 * time.Hour
+	// gitLockRetries is how often a git command that found one of git's
+	// own lock files taken is retried, with doubling pauses in between.
+	gitLockRetries = 5
+)
+
+// These are variables so tests run quickly.
+var (
+	// objectLockTimeout bounds how long wt waits for another wt process
+	// that is fetching into the same repository.
+	objectLockTimeout = 10 * time.Minute
+	objectLockPoll    = 200 * time.Millisecond
+	gitLockBackoff    = 250 * time.Millisecond
+)
+
+// gitLockRe matches git's messages about lock files held by another
+// process; gitLockFileRe picks the lock file out of them.
+var (
+	gitLockRe     = regexp.MustCompile(`\.lock': File exists|cannot lock ref|unable to lock|shallow file has changed since we read it`)
+	gitLockFileRe = regexp.MustCompile(`Unable to create '([^']+\.lock)': File exists`)
+)
+
+// errObjectLockTimeout is returned when another wt process holds the
+// repository lock for longer than objectLockTimeout.
+var errObjectLockTimeout = errors.New("timed out waiting for the repository lock")
+
+// objectLockPath is wt's lock for the object store and refs shared by all
+// worktrees of the repository.
+func objectLockPath() (string, error) {
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(commonDir, "wt-objects.lock"), nil
+}
+
+// lockHolder reads the pid, purpose and host a lock file was written with.
+// Lock files of older wt versions have no host.
+func lockHolder(path string) (pid int, what, host string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, "", ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	pid, _ = strconv.Atoi(lines[0])
+	if len(lines) > 1 {
+		what = strings.TrimSpace(lines[1])
+	}
+	if len(lines) > 2 {
+		host = strings.TrimSpace(lines[2])
+	}
+	return pid, what, host
+}
+
+// holderGone reports whether the process that wrote a lock file is known to
+// be gone. On a network filesystem the holder may run on another machine,
+// where its pid says nothing, so only locks of this host are checked.
+func holderGone(pid int, host string) bool {
+	if pid <= 0 {
+		return false
+	}
+	if hostname, _ := os.Hostname(); host != "" && host != hostname {
+		return false
+	}
+	return !processAlive(pid)
+}
+
+// acquireObjectLock takes wt's repository lock for what (e.g. "fetch
+// origin"), waiting while another live wt process holds it. The returned
+// function releases it.
+func acquireObjectLock(what string) (func(), error) {
+	path, err := objectLockPath()
+	if err != nil {
+		// Outside of a repository git will report the real problem.
+		return func() {}, nil
+	}
+	deadline := time.Now().Add(objectLockTimeout * lockScale())
+	hostname, _ := os.Hostname()
+	announced := false
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n%s\n%s\n", os.Getpid(), what, hostname)
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create %s: %w", path, err)
+		}
+
+		pid, holding, host := lockHolder(path)
+		if info, err := os.Stat(path); err == nil && (holderGone(pid, host) || time.Since(info.ModTime()) > objectLockStale) {
+			// The holder is gone; take over its lock.
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: wt (pid %d, %s) still holds %s\nIf that process is gone, remove the file", errObjectLockTimeout, pid, holding, path)
+		}
+		if !announced {
+			infof("Waiting for another wt process (pid %d, %s) to finish with this repository...\n", pid, holding)
+			announced = true
+		}
+		time.Sleep(objectLockPoll)
+	}
+}
+
+// objectGit runs a git command that writes to the object store or refs all
+// worktrees share, such as fetch. It holds wt's repository lock, so wt
+// processes working in several worktrees at once take turns, and retries
+// with backoff while git finds one of its own lock files taken by a git
+// process wt does not know about. stdin may be empty; git's output goes to
+// stdout and stderr, which may be nil.
+func objectGit(stdin string, stdout, stderr io.Writer, args ...string) error {
+	release, err := acquireObjectLock(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	backoff := gitLockBackoff * lockScale()
+	for attempt := 1; ; attempt++ {
+		var captured bytes.Buffer
+		cmd := gitCommand(args...)
+		if stdin != "" {
+			cmd.Stdin = strings.NewReader(stdin)
+		}
+		cmd.Stdout = stdout
+		if stderr != nil {
+			cmd.Stderr = io.MultiWriter(stderr, &captured)
+		} else {
+			cmd.Stderr = &captured
+		}
+		err := cmd.Run()
+		if err == nil {
+			return nil
+		}
+		if !gitLockRe.Match(captured.Bytes()) {
+			return err
+		}
+		if attempt == gitLockRetries {
+			if match := gitLockFileRe.FindSubmatch(captured.Bytes()); match != nil {
+				return fmt.Errorf("another git process holds %s\nWait for it to finish, or remove the file if no git process is running", match[1])
+			}
+			return fmt.Errorf("another git process keeps this repository locked: %w", err)
+		}
+		warnf("git found the repository locked, retrying in %s\n", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
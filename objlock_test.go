@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireObjectLock(t *testing.T) {
+	originalTimeout, originalPoll := objectLockTimeout, objectLockPoll
+	t.Cleanup(func() { objectLockTimeout, objectLockPoll = originalTimeout, originalPoll })
+	objectLockTimeout, objectLockPoll = 50*time.Millisecond, 5*time.Millisecond
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	lockPath, err := objectLockPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := acquireObjectLock("fetch origin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostname, _ := os.Hostname()
+	if pid, what, host := lockHolder(lockPath); pid != os.Getpid() || what != "fetch origin" || host != hostname {
+		t.Errorf("lock holder = %d, %q, %q; want this process, fetch origin, %s", pid, what, host, hostname)
+	}
+	// A second taker waits for the live holder and gives up with a hint.
+	if _, err := acquireObjectLock("fetch other"); !errors.Is(err, errObjectLockTimeout) || !strings.Contains(err.Error(), lockPath) {
+		t.Errorf("acquireObjectLock() while held = %v, want a timeout naming %s", err, lockPath)
+	}
+	release()
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("lock file still exists after release: %v", err)
+	}
+
+	// The lock of a process that is gone is taken over.
+	if err := os.WriteFile(lockPath, []byte(fmt.Sprintf("%d\nfetch origin\n", 1<<22+1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	release, err = acquireObjectLock("fetch origin")
+	if err != nil {
+		t.Fatalf("acquireObjectLock() over a dead holder = %v", err)
+	}
+	release()
+}
+
+func TestProcessAlive(t *testing.T) {
+	if !processAlive(os.Getpid()) {
+		t.Error("processAlive(this process) = false")
+	}
+	exited := exec.Command("git", "--version")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if processAlive(exited.ProcessState.Pid()) {
+		t.Errorf("processAlive(%d) = true for a process that exited", exited.ProcessState.Pid())
+	}
+}
+
+func TestObjectGitRetriesGitLocks(t *testing.T) {
+	originalBackoff := gitLockBackoff
+	t.Cleanup(func() { gitLockBackoff = originalBackoff })
+	gitLockBackoff = time.Millisecond
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	refLock := filepath.Join(repoDir, ".git", "refs", "heads", "locked.lock")
+	if err := os.WriteFile(refLock, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := objectGit("", nil, nil, "update-ref", "refs/heads/locked", "HEAD")
+	if err == nil || !strings.Contains(err.Error(), "another git process holds") || !strings.Contains(err.Error(), "locked.lock") {
+		t.Errorf("objectGit() with a held ref lock = %v, want the lock file named", err)
+	}
+
+	os.Remove(refLock)
+	if err := objectGit("", nil, nil, "update-ref", "refs/heads/locked", "HEAD"); err != nil {
+		t.Errorf("objectGit() = %v, want success once the lock is gone", err)
+	}
+}
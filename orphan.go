@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// orphanWorktreeMinGit is the first git version with 'git worktree add --orphan'.
+var orphanWorktreeMinGit = [2]int{2, 42}
+
+var gitVersionRegex = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// parseGitVersion extracts major and minor from 'git version' output, e.g.
+// "git version 2.39.5 (Apple Git-154)".
+func parseGitVersion(output string) (major, minor int, ok bool) {
+	matches := gitVersionRegex.FindStringSubmatch(output)
+	if matches == nil {
+		return 0, 0, false
+	}
+	major, _ = strconv.Atoi(matches[1])
+	minor, _ = strconv.Atoi(matches[2])
+	return major, minor, true
+}
+
+// gitAtLeast reports whether the installed git is at least major.minor.
+func gitAtLeast(major, minor int) bool {
+	output, err := gitCommand("version").Output()
+	if err != nil {
+		return false
+	}
+	gotMajor, gotMinor, ok := parseGitVersion(string(output))
+	if !ok {
+		return false
+	}
+	return gotMajor > major || (gotMajor == major && gotMinor >= minor)
+}
+
+// addOrphanWorktree creates a worktree at path on a new orphan branch with
+// an empty index and no files. Gits without 'worktree add --orphan' get a
+// detached worktree without checkout, whose HEAD is then pointed at the
+// unborn branch.
+func addOrphanWorktree(path, branch string) error {
+	if err := gitCommand("check-ref-format", "--branch", branch).Run(); err != nil {
+		return fmt.Errorf("invalid branch name: %s", branch)
+	}
+
+	if gitAtLeast(orphanWorktreeMinGit[0], orphanWorktreeMinGit[1]) {
+		gitCmd := repoVCS().addWorktree(path, "--orphan", "-b", branch)
+		gitCmd.Stdout = gitOutput()
+		gitCmd.Stderr = os.Stderr
+		return gitCmd.Run()
+	}
+
+	gitCmd := repoVCS().addWorktree(path, "--no-checkout", "--detach")
+	gitCmd.Stdout = gitOutput()
+	gitCmd.Stderr = os.Stderr
+	if err := gitCmd.Run(); err != nil {
+		return err
+	}
+	for _, args := range [][]string{
+		{"symbolic-ref", "HEAD", "refs/heads/" + branch},
+		{"read-tree", "--empty"},
+	} {
+		output, err := gitCommand(append([]string{"-C", path}, args...)...).CombinedOutput()
+		if err != nil {
+			// Don't leave a half-made worktree behind
+			_ = repoVCS().removeWorktree(path, true).Run()
+			return fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGitVersion(t *testing.T) {
+	tests := []struct {
+		output       string
+		major, minor int
+		ok           bool
+	}{
+		{"git version 2.42.0\n", 2, 42, true},
+		{"git version 2.39.5 (Apple Git-154)", 2, 39, true},
+		{"git version 2.45.1.windows.1", 2, 45, true},
+		{"not git", 0, 0, false},
+	}
+	for _, tt := range tests {
+		major, minor, ok := parseGitVersion(tt.output)
+		if major != tt.major || minor != tt.minor || ok != tt.ok {
+			t.Errorf("parseGitVersion(%q) = %d, %d, %v; want %d, %d, %v", tt.output, major, minor, ok, tt.major, tt.minor, tt.ok)
+		}
+	}
+}
+
+func TestAddOrphanWorktree(t *testing.T) {
+	originalMin := orphanWorktreeMinGit
+	t.Cleanup(func() { orphanWorktreeMinGit = originalMin })
+
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	if err := os.WriteFile(filepath.Join(repoDir, "README.md"), []byte("main"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, repoDir, "add", "README.md")
+	runGitCommand(t, repoDir, "commit", "-m", "add readme")
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// The installed git decides the native path; force the emulation too.
+	for name, minGit := range map[string][2]int{"installed": originalMin, "emulated": {999, 0}} {
+		t.Run(name, func(t *testing.T) {
+			orphanWorktreeMinGit = minGit
+			branch := "docs-" + name
+			path := filepath.Join(tmpDir, branch)
+			if err := addOrphanWorktree(path, branch); err != nil {
+				t.Fatalf("addOrphanWorktree() error: %v", err)
+			}
+
+			head, err := exec.Command("git", "-C", path, "symbolic-ref", "HEAD").Output()
+			if err != nil || strings.TrimSpace(string(head)) != "refs/heads/"+branch {
+				t.Errorf("HEAD = %q, %v; want refs/heads/%s", head, err, branch)
+			}
+			if err := exec.Command("git", "-C", path, "rev-parse", "--verify", "--quiet", "HEAD").Run(); err == nil {
+				t.Error("orphan branch already has a commit")
+			}
+			status, _ := exec.Command("git", "-C", path, "status", "--porcelain").Output()
+			if len(status) != 0 {
+				t.Errorf("orphan worktree is not clean:\n%s", status)
+			}
+			if _, err := os.Stat(filepath.Join(path, "README.md")); !os.IsNotExist(err) {
+				t.Error("orphan worktree contains files from main")
+			}
+		})
+	}
+
+	if err := addOrphanWorktree(filepath.Join(tmpDir, "bad"), "bad..name"); err == nil {
+		t.Error("expected invalid branch name to be rejected")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	checkoutNewBranch string
+	checkoutApply     string
+)
+
+// readPatch reads a patch from file, or from stdin for "-", and checks that
+// git can parse it before any worktree is created.
+func readPatch(file string) ([]byte, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w", err)
+	}
+
+	check := gitCommand("apply", "--stat")
+	check.Stdin = bytes.NewReader(data)
+	if output, err := check.CombinedOutput(); err != nil || len(bytes.TrimSpace(output)) == 0 {
+		return nil, fmt.Errorf("%s does not contain a patch: %s", patchName(file), strings.TrimSpace(string(output)))
+	}
+	return data, nil
+}
+
+func patchName(file string) string {
+	if file == "-" {
+		return "stdin"
+	}
+	return file
+}
+
+// isMailboxPatch reports whether data is a mail from 'git format-patch' (or
+// a mailbox of them), which carries author and message for 'git am'.
+func isMailboxPatch(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("From ")) || bytes.HasPrefix(data, []byte("From: "))
+}
+
+// conflictedFiles lists the unmerged paths of the worktree at dir.
+func conflictedFiles(dir string) []string {
+	output, err := gitCommand("-C", dir, "diff", "--name-only", "--diff-filter=U").Output()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(output))
+}
+
+// applyPatch applies data in the worktree at dir. Mails from 'git
+// format-patch' go through 'git am' and become commits; plain diffs are
+// applied with 'git apply' and left staged. Both fall back to a three-way
+// merge when the patch does not apply as is.
+func applyPatch(dir string, data []byte) error {
+	run := func(args ...string) (string, error) {
+		cmd := gitCommand(append([]string{"-C", dir}, args...)...)
+		cmd.Stdin = bytes.NewReader(data)
+		output, err := cmd.CombinedOutput()
+		return strings.TrimSpace(string(output)), err
+	}
+
+	if isMailboxPatch(data) {
+		output, err := run("am", "--3way")
+		if err == nil {
+			return nil
+		}
+		return patchConflictError(dir, output, "resolve them, 'git add' the files and run 'git am --continue' (or 'git am --abort')")
+	}
+
+	if _, err := run("apply", "--index"); err == nil {
+		return nil
+	}
+	output, err := run("apply", "--3way")
+	if err == nil {
+		warnf("Patch applied with a three-way merge\n")
+		return nil
+	}
+	return patchConflictError(dir, output, "resolve them and 'git add' the files")
+}
+
+func patchConflictError(dir, output, next string) error {
+	msg := "patch did not apply cleanly"
+	if files := conflictedFiles(dir); len(files) > 0 {
+		msg += "\nConflicts in:\n  " + strings.Join(files, "\n  ") + "\nIn " + dir + ", " + next
+	} else if output != "" {
+		msg += ":\n" + output
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// checkoutWithPatch creates branch from base in a new worktree and, when
+// patchFile is set, applies the patch in it. A patch that conflicts leaves
+// the worktree in place for resolving.
+func checkoutWithPatch(branch, base, patchFile string) error {
+	if branchExists(branch) {
+		return fmt.Errorf("branch '%s' already exists\nUse 'wt checkout %s' to check it out, or --force-branch to start it over from %s", branch, branch, base)
+	}
+	return addBranchWorktree(branch, base, patchFile, "-b")
+}
+
+// addBranchWorktree creates branch from base in a new worktree with
+// 'git worktree add <newBranchFlag>', which is -b for a new branch or -B to
+// reset an existing one, and applies patchFile if given.
+func addBranchWorktree(branch, base, patchFile, newBranchFlag string) error {
+	info, err := getRepoInfo()
+	if err != nil {
+		return err
+	}
+
+	var patch []byte
+	if patchFile != "" {
+		if patch, err = readPatch(patchFile); err != nil {
+			return err
+		}
+	}
+
+	path, err := buildWorktreePath(info, branch)
+	if err != nil {
+		return err
+	}
+	if err := prefetchObjects(base); err != nil {
+		return err
+	}
+	gitCmd := repoVCS().addWorktree(path, newBranchFlag, branch, base)
+	gitCmd.Stdout = gitOutput()
+	gitCmd.Stderr = os.Stderr
+	if err := gitCmd.Run(); err != nil {
+		return fmt.Errorf("failed to create worktree: %w", err)
+	}
+	snapshot.invalidate()
+	successf("Worktree created at: %s", path)
+	prepareWorktree(path)
+	applyWorktreeGitConfig(info, path)
+
+	var patchErr error
+	if patch != nil {
+		if patchErr = applyPatch(path, patch); patchErr == nil {
+			successf("Applied %s", patchName(patchFile))
+		}
+	}
+
+	// A conflict is resolved in the worktree, so it is set up and the shell
+	// taken there all the same.
+	setupBranchPush(path, branch)
+	runPostCheckoutHooks(info, branch, path)
+	printCDMarker(path)
+	if patchErr != nil {
+		return fmt.Errorf("worktree created at %s, but the %w", path, patchErr)
+	}
+	return nil
+}
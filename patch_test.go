@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	writeAndCommit := func(dir, content, message string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		runGitCommand(t, dir, "add", "a.txt")
+		runGitCommand(t, dir, "commit", "-m", message)
+	}
+	writeAndCommit(repoDir, "one\n", "add a")
+
+	runGitCommand(t, repoDir, "checkout", "-q", "-b", "src")
+	writeAndCommit(repoDir, "two\n", "change a")
+	runGitCommand(t, repoDir, "checkout", "-q", "main")
+
+	diff, err := exec.Command("git", "-C", repoDir, "diff", "main", "src").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mail, err := exec.Command("git", "-C", repoDir, "format-patch", "-1", "src", "--stdout").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isMailboxPatch(diff) || !isMailboxPatch(mail) {
+		t.Fatalf("isMailboxPatch() misdetected: diff=%v mail=%v", isMailboxPatch(diff), isMailboxPatch(mail))
+	}
+
+	addWorktree := func(name string) string {
+		dir := filepath.Join(tmpDir, name)
+		runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", name, dir, "main")
+		return dir
+	}
+	subject := func(dir string) string {
+		output, _ := exec.Command("git", "-C", dir, "log", "-1", "--format=%s").Output()
+		return strings.TrimSpace(string(output))
+	}
+
+	t.Run("diff is staged", func(t *testing.T) {
+		dir := addWorktree("from-diff")
+		if err := applyPatch(dir, diff); err != nil {
+			t.Fatalf("applyPatch() error: %v", err)
+		}
+		staged, _ := exec.Command("git", "-C", dir, "diff", "--cached", "--name-only").Output()
+		if strings.TrimSpace(string(staged)) != "a.txt" {
+			t.Errorf("staged = %q, want a.txt", staged)
+		}
+		if got := subject(dir); got != "add a" {
+			t.Errorf("HEAD = %q, want no new commit", got)
+		}
+	})
+
+	t.Run("mail is committed", func(t *testing.T) {
+		dir := addWorktree("from-mail")
+		if err := applyPatch(dir, mail); err != nil {
+			t.Fatalf("applyPatch() error: %v", err)
+		}
+		if got := subject(dir); got != "change a" {
+			t.Errorf("HEAD = %q, want the patch's commit", got)
+		}
+	})
+
+	t.Run("conflict lists files", func(t *testing.T) {
+		dir := addWorktree("conflicting")
+		writeAndCommit(dir, "three\n", "diverge")
+		err := applyPatch(dir, diff)
+		if err == nil || !strings.Contains(err.Error(), "Conflicts in:\n  a.txt") {
+			t.Errorf("applyPatch() error = %v, want conflict in a.txt", err)
+		}
+	})
+}
+
+func TestReadPatchRejectsNonPatch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(file, []byte("just some notes\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPatch(file); err == nil || !strings.Contains(err.Error(), "does not contain a patch") {
+		t.Errorf("readPatch() error = %v, want not a patch", err)
+	}
+}
+
+func TestCheckoutWithConflictingPatch(t *testing.T) {
+	originalRoot, originalStrategy, originalPattern := worktreeRoot, worktreeStrategy, worktreePattern
+	t.Cleanup(func() {
+		worktreeRoot, worktreeStrategy, worktreePattern = originalRoot, originalStrategy, originalPattern
+	})
+	tmpDir := t.TempDir()
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	worktreeStrategy, worktreePattern = "global", ""
+	t.Setenv("WT_CONFIG", filepath.Join(tmpDir, "config.yaml"))
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	cdFile := filepath.Join(tmpDir, "cd")
+	t.Setenv("WT_CD_FILE", cdFile)
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repoDir, "a.txt"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("one\n")
+	runGitCommand(t, repoDir, "add", "a.txt")
+	runGitCommand(t, repoDir, "commit", "-m", "add a")
+	write("two\n")
+	diff, err := exec.Command("git", "-C", repoDir, "diff").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("three\n")
+	runGitCommand(t, repoDir, "commit", "-am", "diverge")
+	patchFile := filepath.Join(tmpDir, "change.patch")
+	if err := os.WriteFile(patchFile, diff, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.invalidate()
+
+	err = checkoutWithPatch("review", "main", patchFile)
+	if err == nil || !strings.Contains(err.Error(), "a.txt") {
+		t.Fatalf("checkoutWithPatch() = %v, want a conflict in a.txt", err)
+	}
+	path := filepath.Join(worktreeRoot, "repo", "review")
+	// The worktree to resolve the conflict in is set up like any other.
+	if data, _ := os.ReadFile(cdFile); !strings.Contains(string(data), path) {
+		t.Errorf("shell not taken to %s: %q", path, data)
+	}
+	records, err := readAudit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 || records[len(records)-1].Branch != "review" {
+		t.Errorf("creation of review not audited: %+v", records)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Windows refuses most file operations on paths of MAX_PATH (260) characters
+// or more unless they use the \\?\ extended-length form. Directory creation
+// is stricter still: the limit is 248 to leave room for an 8.3 file name.
+const (
+	windowsMaxPath    = 260
+	windowsMaxDirPath = 248
+)
+
+// longPath returns a form of path that is safe to pass to os functions on
+// Windows even when it exceeds MAX_PATH. On other platforms it is a no-op.
+func longPath(path string) string {
+	if runtime.GOOS != "windows" || len(path) < windowsMaxDirPath {
+		return path
+	}
+	return toExtendedLengthPath(path)
+}
+
+// toExtendedLengthPath converts an absolute Windows path to its \\?\ form.
+// Relative paths and paths that already use a device prefix are returned as is.
+func toExtendedLengthPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) || strings.HasPrefix(path, `\\.\`) {
+		return path
+	}
+	path = strings.ReplaceAll(path, "/", `\`)
+	if strings.HasPrefix(path, `\\`) {
+		// UNC path: \\server\share\dir -> \\?\UNC\server\share\dir
+		return `\\?\UNC\` + strings.TrimPrefix(path, `\\`)
+	}
+	if len(path) >= 3 && path[1] == ':' && path[2] == '\\' {
+		return `\\?\` + path
+	}
+	return path
+}
+
+// fromExtendedLengthPath undoes toExtendedLengthPath: \\?\UNC\server\share
+// becomes \\server\share again rather than the relative server\share.
+func fromExtendedLengthPath(path string) string {
+	if strings.HasPrefix(path, `\\?\UNC\`) {
+		return `\\` + strings.TrimPrefix(path, `\\?\UNC\`)
+	}
+	return strings.TrimPrefix(path, `\\?\`)
+}
+
+// resolvePath returns the absolute, symlink-free form of path. Symlinked or
+// junctioned directories (a common way to relocate WORKTREE_ROOT) resolve to
+// their target so that paths reported by git and the OS can be compared.
+// Path components that do not exist yet are kept as given.
+func resolvePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	existing := abs
+	var rest []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(longPath(existing)); err == nil {
+			resolved = fromExtendedLengthPath(resolved)
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs
+		}
+		rest = append([]string{filepath.Base(existing)}, rest...)
+		existing = parent
+	}
+}
+
+// isWithin reports whether path is root itself or located below it, after
+// resolving symlinks and junctions on both sides.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(resolvePath(root), resolvePath(path))
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		rel = strings.ToLower(rel)
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// checkLongPathSupport warns when a worktree path is long enough that git on
+// Windows needs core.longpaths to check files out into it.
+func checkLongPathSupport(path string) {
+	if runtime.GOOS != "windows" || len(path) < windowsMaxPath-60 {
+		return
+	}
+	output, err := gitCommand("config", "--bool", "core.longpaths").Output()
+	if err == nil && strings.TrimSpace(string(output)) == "true" {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: worktree path is %d characters long; files inside it may exceed the Windows 260 character limit\n", len(path))
+	fmt.Fprintln(os.Stderr, "  enable long path support with: git config --global core.longpaths true")
+}
+
+// dirSize returns the total size in bytes of the regular files below path.
+// Symlinks are not followed and unreadable entries are skipped.
+func dirSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(longPath(path), func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// formatSize renders a byte count with a binary unit suffix.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(size)/float64(div), "KMGTPE"[exp])
+}
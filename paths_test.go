@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestToExtendedLengthPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"drive path", `C:\Users\me\dev\worktrees\repo\branch`, `\\?\C:\Users\me\dev\worktrees\repo\branch`},
+		{"forward slashes", `C:/Users/me/dev`, `\\?\C:\Users\me\dev`},
+		{"unc path", `\\server\share\worktrees`, `\\?\UNC\server\share\worktrees`},
+		{"already extended", `\\?\C:\dev`, `\\?\C:\dev`},
+		{"device path", `\\.\pipe\foo`, `\\.\pipe\foo`},
+		{"relative path", `dev\worktrees`, `dev\worktrees`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toExtendedLengthPath(tt.path); got != tt.want {
+				t.Errorf("toExtendedLengthPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromExtendedLengthPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"drive path", `\\?\C:\Users\me\dev`, `C:\Users\me\dev`},
+		{"unc path", `\\?\UNC\server\share\worktrees`, `\\server\share\worktrees`},
+		{"plain path", `C:\dev`, `C:\dev`},
+		{"plain unc path", `\\server\share`, `\\server\share`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fromExtendedLengthPath(tt.path); got != tt.want {
+				t.Errorf("fromExtendedLengthPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+			if got := fromExtendedLengthPath(toExtendedLengthPath(tt.want)); got != tt.want {
+				t.Errorf("round trip of %q = %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLongPathLeavesShortPathsAlone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "short")
+	if got := longPath(path); got != path {
+		t.Errorf("longPath(%q) = %q, want unchanged", path, got)
+	}
+
+	long := filepath.Join(t.TempDir(), strings.Repeat("a", 300))
+	got := longPath(long)
+	if runtime.GOOS == "windows" {
+		if !strings.HasPrefix(got, `\\?\`) {
+			t.Errorf("longPath() = %q, want extended-length prefix", got)
+		}
+	} else if got != long {
+		t.Errorf("longPath() = %q, want unchanged on %s", got, runtime.GOOS)
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"root itself", root, true},
+		{"child", filepath.Join(root, "repo", "branch"), true},
+		{"sibling with shared prefix", root + "2", false},
+		{"parent", filepath.Dir(root), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isWithin(root, tt.path); got != tt.want {
+				t.Errorf("isWithin(%q, %q) = %v, want %v", root, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsWithinResolvesSymlinkedRoot(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "real-root")
+	if err := os.MkdirAll(filepath.Join(target, "repo"), 0o755); err != nil {
+		t.Fatalf("failed to create target: %v", err)
+	}
+	link := filepath.Join(tmpDir, "linked-root")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if !isWithin(link, filepath.Join(target, "repo")) {
+		t.Error("expected path under symlink target to be within the symlinked root")
+	}
+	if !isWithin(target, filepath.Join(link, "repo", "missing-branch")) {
+		t.Error("expected not-yet-existing path under symlinked root to be within the target")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// porcelainHelp documents the --porcelain format of list and status. It is
+// a stable interface for scripts: keys are only ever added, so readers
+// should skip the ones they do not know.
+const porcelainHelp = `--porcelain prints a block of "key value" lines (or a bare key) per
+worktree, each block followed by an empty line. With -z, lines end with a
+NUL instead of a newline and blocks with an extra NUL, so that paths may
+contain any character; without it line breaks in values become spaces.
+Keys are only ever added, so skip the ones you do not know. They come in
+this order, when they apply:
+
+  worktree <path>              always first
+  HEAD <commit>
+  branch <name>, detached or bare
+  locked [<reason>], prunable, stale
+  last-commit <unix time>, last-visit <unix time>
+  size <bytes>                 measured for --sort size
+  ticket <ticket>, tag <tag> (one line per tag), description <text>
+  expires <unix time>          ephemeral worktrees
+  vcs jj                       main worktree of a jj repository`
+
+// porcelainStatusHelp documents the keys that status adds.
+const porcelainStatusHelp = `  upstream <ref>, then upstream-gone or ahead <n> and behind <n>
+  staged <n>, unstaged <n>, untracked <n> and conflicted <n>, or
+  status-error <message> when git status failed`
+
+// porcelainWriter writes the --porcelain format.
+type porcelainWriter struct {
+	w *bufio.Writer
+	// z ends lines with NUL instead of a newline, for -z.
+	z bool
+}
+
+func newPorcelainWriter(out io.Writer, z bool) *porcelainWriter {
+	return &porcelainWriter{w: bufio.NewWriter(out), z: z}
+}
+
+func (p *porcelainWriter) eol() {
+	if p.z {
+		p.w.WriteByte(0)
+	} else {
+		p.w.WriteByte('\n')
+	}
+}
+
+// field writes a line; without a value only the key. Without -z, line
+// breaks in values would end the line early and become spaces.
+func (p *porcelainWriter) field(key string, value ...string) {
+	p.w.WriteString(key)
+	if len(value) > 0 {
+		v := value[0]
+		if !p.z {
+			v = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(v)
+		}
+		p.w.WriteString(" " + v)
+	}
+	p.eol()
+}
+
+func (p *porcelainWriter) time(key string, t time.Time) {
+	if !t.IsZero() {
+		p.field(key, strconv.FormatInt(t.Unix(), 10))
+	}
+}
+
+func (p *porcelainWriter) count(key string, n int) {
+	p.field(key, strconv.Itoa(n))
+}
+
+// item writes the block of a worktree; withStatus adds what 'wt status'
+// knows about its upstream and local changes.
+func (p *porcelainWriter) item(item listItem, withStatus bool) {
+	p.field("worktree", item.Path)
+	if item.Head != "" {
+		p.field("HEAD", item.Head)
+	}
+	switch {
+	case item.Bare:
+		p.field("bare")
+	case item.Branch != "":
+		p.field("branch", item.Branch)
+	default:
+		p.field("detached")
+	}
+	if item.Locked {
+		if item.LockReason != "" {
+			p.field("locked", item.LockReason)
+		} else {
+			p.field("locked")
+		}
+	}
+	if item.Prunable {
+		p.field("prunable")
+	}
+	if item.Stale {
+		p.field("stale")
+	}
+	p.time("last-commit", item.LastCommit)
+	p.time("last-visit", item.LastVisit)
+	if item.Size > 0 {
+		p.field("size", strconv.FormatInt(item.Size, 10))
+	}
+	if item.Meta.Ticket != "" {
+		p.field("ticket", item.Meta.Ticket)
+	}
+	for _, tag := range item.Meta.Tags {
+		p.field("tag", tag)
+	}
+	if item.Meta.Description != "" {
+		p.field("description", item.Meta.Description)
+	}
+	if item.Meta.Expires != nil {
+		p.time("expires", *item.Meta.Expires)
+	}
+	if item.VCS == vcsJJ {
+		p.field("vcs", vcsJJ)
+	}
+
+	if withStatus && !item.Bare && !item.Prunable {
+		if item.Upstream != "" {
+			p.field("upstream", item.Upstream)
+			if item.UpstreamGone {
+				p.field("upstream-gone")
+			} else {
+				p.count("ahead", item.Ahead)
+				p.count("behind", item.Behind)
+			}
+		}
+		if item.StatusError != "" {
+			p.field("status-error", item.StatusError)
+		} else {
+			p.count("staged", item.Staged)
+			p.count("unstaged", item.Unstaged)
+			p.count("untracked", item.Untracked)
+			p.count("conflicted", item.Conflicted)
+		}
+	}
+	p.eol()
+}
+
+func (p *porcelainWriter) flush() error {
+	return p.w.Flush()
+}
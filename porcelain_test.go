@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPorcelainWriter(t *testing.T) {
+	expires := time.Unix(1700000000, 0)
+	item := listItem{
+		worktreeStatus: worktreeStatus{
+			worktreeEntry: worktreeEntry{Path: "/wt/feature", Head: "abc123", Branch: "feature", Locked: true},
+			Upstream:      "origin/feature",
+			Ahead:         2,
+			Untracked:     1,
+			LastCommit:    time.Unix(1600000000, 0),
+		},
+		Stale: true,
+		Meta:  worktreeMeta{Tags: []string{"a", "b"}, Description: "two\nlines", Expires: &expires},
+	}
+
+	var out strings.Builder
+	p := newPorcelainWriter(&out, false)
+	p.item(item, true)
+	p.item(listItem{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Path: "/repo.git", Bare: true}}}, true)
+	if err := p.flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := `worktree /wt/feature
+HEAD abc123
+branch feature
+locked
+stale
+last-commit 1600000000
+tag a
+tag b
+description two lines
+expires 1700000000
+upstream origin/feature
+ahead 2
+behind 0
+staged 0
+unstaged 0
+untracked 1
+conflicted 0
+
+worktree /repo.git
+bare
+
+`
+	if out.String() != want {
+		t.Errorf("porcelain output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	p = newPorcelainWriter(&out, true)
+	p.item(listItem{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Path: "/wt/new\nline", Head: "abc123"}}}, false)
+	p.flush()
+	if want := "worktree /wt/new\nline\x00HEAD abc123\x00detached\x00\x00"; out.String() != want {
+		t.Errorf("-z output = %q, want %q", out.String(), want)
+	}
+}
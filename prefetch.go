@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// prefetchStamp is touched in the git directory when wt starts a
+// background prefetch, so wt commands running in the meantime don't start
+// another one.
+const prefetchStamp = "wt-prefetch.stamp"
+
+// prefetchEvery is the prefetch setting; 0 when it is off.
+var prefetchEvery time.Duration
+
+// prefetchLog keeps what the last background prefetch printed, for when
+// it does not seem to work.
+const prefetchLog = "wt-prefetch.log"
+
+// prefetchInterval parses the prefetch setting: how old the last fetch
+// may be before a wt command fetches in the background. Empty turns
+// prefetching off.
+func prefetchInterval(value string) (time.Duration, error) {
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	d, err := parsePeriod(value, "prefetch interval")
+	if err == nil && d < time.Minute {
+		return 0, fmt.Errorf("prefetch interval %q is too short (at least 1m)", value)
+	}
+	return d, err
+}
+
+// prefetchDue reports whether the repository at commonDir was fetched, or
+// a prefetch started, longer than interval ago.
+func prefetchDue(commonDir string, interval time.Duration, now time.Time) bool {
+	last := lastFetch(commonDir)
+	if info, err := os.Stat(filepath.Join(commonDir, prefetchStamp)); err == nil && info.ModTime().After(last) {
+		last = info.ModTime()
+	}
+	return now.Sub(last) >= interval
+}
+
+// maybePrefetch starts a detached 'wt __prefetch' when the prefetch setting
+// is on and the last fetch of the current repository is older than it, so
+// that remote branches are at hand when a checkout needs them. It never
+// waits for the fetch and never fails the command it runs for.
+func maybePrefetch(cmd *cobra.Command) {
+	if cmd.Hidden || cmd == fetchCmd || ciMode() || os.Getenv("WT_NO_PREFETCH") != "" {
+		return
+	}
+	if prefetchEvery == 0 {
+		return
+	}
+	commonDir, err := gitCommonDir()
+	if err != nil || !prefetchDue(commonDir, prefetchEvery, time.Now()) || !hasRemotes() {
+		return
+	}
+	if lockPath, err := objectLockPath(); err == nil {
+		if _, err := os.Stat(lockPath); err == nil {
+			// Another wt process is fetching already.
+			return
+		}
+	}
+	stamp := filepath.Join(commonDir, prefetchStamp)
+	if err := os.WriteFile(stamp, nil, 0o644); err != nil {
+		return
+	}
+	now := time.Now()
+	os.Chtimes(stamp, now, now)
+
+	self, err := os.Executable()
+	if err != nil {
+		return
+	}
+	background := exec.Command(self, "__prefetch")
+	background.Dir, _ = os.Getwd()
+	// Credentials prompts would have no terminal to go to.
+	background.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "WT_NO_PREFETCH=1")
+	if os.Getenv("GIT_SSH_COMMAND") == "" {
+		background.Env = append(background.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	detach(background)
+	if background.Start() == nil {
+		background.Process.Release()
+	}
+}
+
+// prefetchCmd is what maybePrefetch starts in the background. It fetches
+// the remotes of the repository with wt's repository lock, and gives up
+// right away rather than wait when someone else holds it.
+var prefetchCmd = &cobra.Command{
+	Use:    "__prefetch",
+	Short:  "Fetch the remotes in the background",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		commonDir, err := gitCommonDir()
+		if err != nil {
+			return err
+		}
+		log, err := os.Create(filepath.Join(commonDir, prefetchLog))
+		if err != nil {
+			return err
+		}
+		defer log.Close()
+		fmt.Fprintf(log, "%s wt prefetch\n", time.Now().Format(time.RFC3339))
+		// Waiting in the background for a fetch in the foreground would
+		// only fetch the same again.
+		objectLockTimeout = 0
+		// FETCH_HEAD, which fetch writes, tells later commands when it ran.
+		if err := objectGit("", log, log, "fetch", "--all", "--prune", "--quiet"); err != nil {
+			fmt.Fprintf(log, "failed: %v\n", err)
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(prefetchCmd)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrefetchInterval(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": 0, "15m": 15 * time.Minute, "1d": 24 * time.Hour} {
+		if got, err := prefetchInterval(value); err != nil || got != want {
+			t.Errorf("prefetchInterval(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"10s", "soon"} {
+		if _, err := prefetchInterval(value); err == nil {
+			t.Errorf("prefetchInterval(%q) succeeded", value)
+		}
+	}
+}
+
+func TestPrefetchDue(t *testing.T) {
+	commonDir := t.TempDir()
+	now := time.Now()
+	if !prefetchDue(commonDir, time.Hour, now) {
+		t.Error("never fetched repository is not due")
+	}
+
+	fetchHead := filepath.Join(commonDir, "FETCH_HEAD")
+	if err := os.WriteFile(fetchHead, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(fetchHead, now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	if !prefetchDue(commonDir, time.Hour, now) {
+		t.Error("repository fetched 2h ago is not due after 1h")
+	}
+	if prefetchDue(commonDir, 3*time.Hour, now) {
+		t.Error("repository fetched 2h ago is due after 3h")
+	}
+
+	// A prefetch that started counts, even before its fetch finished.
+	stamp := filepath.Join(commonDir, prefetchStamp)
+	if err := os.WriteFile(stamp, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(stamp, now.Add(-time.Minute), now.Add(-time.Minute))
+	if prefetchDue(commonDir, time.Hour, now) {
+		t.Error("repository with a prefetch a minute ago is due")
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+const (
+	createNewProcessGroup = 0x00000200 // CREATE_NEW_PROCESS_GROUP
+	detachedProcess       = 0x00000008 // DETACHED_PROCESS
+)
+
+// detach makes cmd outlive wt, without a console of its own.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: createNewProcessGroup | detachedProcess}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "syscall"
+
+const (
+	processQueryLimitedInformation = 0x1000 // PROCESS_QUERY_LIMITED_INFORMATION
+	stillActive                    = 259    // STILL_ACTIVE
+)
+
+// processAlive reports whether a process with pid is still running. A
+// process that has exited can still be opened while something holds a
+// handle to it, so its exit code decides.
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// It exists, but belongs to someone we may not ask.
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(handle)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// provider is a code host wt talks to through its CLI for pull or merge
+// requests, with the places its token may come from.
+type provider struct {
+	Name string
+	// CLI is the provider's command line tool; wt passes the token on to it.
+	CLI     string
+	Install string
+	// TokenEnv are the environment variables the CLI reads a token from,
+	// the one wt sets first.
+	TokenEnv []string
+	// TokenArgs make the CLI print the token it stored at login.
+	TokenArgs []string
+	// ConfigKey names a setting with a command that prints a token, e.g.
+	// from a password manager.
+	ConfigKey string
+	// Kind is what the provider calls a change request.
+	Kind string
+}
+
+var (
+	githubProvider = provider{
+		Name:      "GitHub",
+		CLI:       "gh",
+		Install:   "https://cli.github.com",
+		TokenEnv:  []string{"GH_TOKEN", "GITHUB_TOKEN"},
+		TokenArgs: []string{"auth", "token"},
+		ConfigKey: "github-token-command",
+		Kind:      "PR",
+	}
+	gitlabProvider = provider{
+		Name:      "GitLab",
+		CLI:       "glab",
+		Install:   "https://gitlab.com/gitlab-org/cli",
+		TokenEnv:  []string{"GITLAB_TOKEN", "GITLAB_ACCESS_TOKEN"},
+		TokenArgs: []string{"config", "get", "token"},
+		ConfigKey: "gitlab-token-command",
+		Kind:      "MR",
+	}
+)
+
+func providerFor(remoteType RemoteType) (provider, error) {
+	switch remoteType {
+	case RemoteGitHub:
+		return githubProvider, nil
+	case RemoteGitLab:
+		return gitlabProvider, nil
+	}
+	return provider{}, fmt.Errorf("invalid remote type")
+}
+
+// providerToken is a token and where it came from, e.g. "$GH_TOKEN".
+type providerToken struct {
+	Value  string
+	Source string
+}
+
+// providerTokens caches tokens per provider for one wt invocation, since a
+// token command may ask a password manager.
+var providerTokens = map[string]*providerToken{}
+
+// shellCommand runs a command line from the configuration with the
+// platform's shell.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// shellPlaceholder is a placeholder such as {branch} in a command line,
+// whose value is exported as the variable Env.
+type shellPlaceholder struct{ Name, Env string }
+
+// cmdSyntax are the characters cmd.exe can read as syntax in a value it
+// expands from a "%VAR%" reference.
+const cmdSyntax = `"&|^%<>`
+
+// shellReferences replaces the placeholders in a command line for
+// shellCommand with quoted references to their variables, so the shell
+// never reads a value, such as a branch name with $( or ; in it, as syntax.
+// values are those of placeholders, in the same order.
+func shellReferences(command string, placeholders []shellPlaceholder, values []string) (string, error) {
+	return shellReferencesOn(runtime.GOOS, command, placeholders, values)
+}
+
+// shellReferencesOn is shellReferences for the shell of goos. cmd.exe
+// expands variables before it parses the line, so a quoted reference does
+// not protect a value there; a used placeholder whose value has one of
+// cmdSyntax in it is refused instead.
+func shellReferencesOn(goos, command string, placeholders []shellPlaceholder, values []string) (string, error) {
+	pairs := make([]string, 0, 2*len(placeholders))
+	for i, p := range placeholders {
+		ref := `"$` + p.Env + `"`
+		if goos == "windows" {
+			if strings.Contains(command, p.Name) && strings.ContainsAny(values[i], cmdSyntax) {
+				return "", fmt.Errorf("%s is %q, which cmd would read as syntax (it has one of %s in it)", p.Name, values[i], cmdSyntax)
+			}
+			ref = `"%` + p.Env + `%"`
+		}
+		pairs = append(pairs, p.Name, ref)
+	}
+	return strings.NewReplacer(pairs...).Replace(command), nil
+}
+
+// token finds the provider's token: from the configured command, else the
+// environment, else the CLI's own login. No token is not an error; the CLI
+// may still manage without one, e.g. for public repositories.
+func (p provider) token() (providerToken, error) {
+	if cached, ok := providerTokens[p.Name]; ok {
+		return *cached, nil
+	}
+	var found providerToken
+	command := strings.TrimSpace(loadConfig().get(p.ConfigKey))
+	if command != "" {
+		cmd := shellCommand(command)
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return providerToken{}, fmt.Errorf("%s token command %q (config %s) failed: %w", p.Name, command, p.ConfigKey, err)
+		}
+		found = providerToken{strings.TrimSpace(string(output)), "config " + p.ConfigKey}
+		if found.Value == "" {
+			return providerToken{}, fmt.Errorf("%s token command %q (config %s) printed no token", p.Name, command, p.ConfigKey)
+		}
+	}
+	for _, env := range p.TokenEnv {
+		if found.Value != "" {
+			break
+		}
+		if value := strings.TrimSpace(os.Getenv(env)); value != "" {
+			found = providerToken{value, "$" + env}
+		}
+	}
+	if found.Value == "" {
+		if output, err := exec.Command(p.CLI, p.TokenArgs...).Output(); err == nil {
+			if value := strings.TrimSpace(string(output)); value != "" {
+				found = providerToken{value, fmt.Sprintf("'%s' login", p.CLI)}
+			}
+		}
+	}
+	providerTokens[p.Name] = &found
+	return found, nil
+}
+
+// command returns the provider's CLI with args, given the token wt found
+// when it did not come from the CLI's environment already.
+func (p provider) command(args ...string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath(p.CLI); err != nil {
+		return nil, fmt.Errorf("'%s' CLI not found. Install it from %s", p.CLI, p.Install)
+	}
+	tok, err := p.token()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(p.CLI, args...)
+	if tok.Source == "config "+p.ConfigKey {
+		cmd.Env = append(os.Environ(), p.TokenEnv[0]+"="+tok.Value)
+	}
+	return cmd, nil
+}
+
+// output runs the provider's CLI with args and returns what it printed;
+// failures are classified by classifyError. what names the request.
+func (p provider) output(what string, args ...string) ([]byte, error) {
+	cmd, err := p.command(args...)
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, p.classifyError(what, stderr.String(), err)
+	}
+	return output, nil
+}
+
+var (
+	errProviderAuth     = errors.New("authentication failed")
+	errProviderNotFound = errors.New("not found")
+
+	providerAuthRe     = regexp.MustCompile(`(?i)HTTP 40[13]|401 Unauthorized|403 Forbidden|bad credentials|authentication (failed|required)|auth login|not logged in|could not read Username|Permission denied \(publickey\)|invalid token|token.*(expired|revoked)`)
+	providerNotFoundRe = regexp.MustCompile(`(?i)HTTP 404|404 Not Found|could not resolve to a PullRequest|couldn't find remote ref|no (pull|merge) requests? (found|match)`)
+)
+
+// classifyError tells an authentication problem from a missing pull or
+// merge request in the output of the CLI or git, so the user knows whether
+// to log in or to check the number. what names the request, e.g. "PR #12".
+func (p provider) classifyError(what, output string, err error) error {
+	output = strings.TrimSpace(output)
+	detail := output
+	if detail == "" {
+		detail = err.Error()
+	}
+	switch {
+	case providerAuthRe.MatchString(output):
+		source := "no token found"
+		if tok, tokErr := p.token(); tokErr == nil && tok.Source != "" {
+			source = "token from " + tok.Source
+		}
+		return fmt.Errorf("%w for %s (%s): %s\nLog in with '%s auth login', set $%s, or configure a token command: wt config set %s '<command>'",
+			errProviderAuth, p.Name, source, detail, p.CLI, p.TokenEnv[0], p.ConfigKey)
+	case providerNotFoundRe.MatchString(output):
+		return fmt.Errorf("%s %w on %s: %s", what, errProviderNotFound, p.Name, detail)
+	}
+	return fmt.Errorf("%s: %s", what, detail)
+}
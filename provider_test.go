@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestProviderClassifyError(t *testing.T) {
+	providerTokens = map[string]*providerToken{"GitHub": {Value: "t", Source: "$GH_TOKEN"}}
+	t.Cleanup(func() { providerTokens = map[string]*providerToken{} })
+	failed := errors.New("exit status 1")
+
+	tests := []struct {
+		name   string
+		output string
+		want   error
+		text   string
+	}{
+		{"gh bad credentials", "HTTP 401: Bad credentials (https://api.github.com/graphql)", errProviderAuth, "token from $GH_TOKEN"},
+		{"gh not logged in", "To get started with GitHub CLI, please run:  gh auth login", errProviderAuth, "gh auth login"},
+		{"git https", "fatal: could not read Username for 'https://github.com': terminal prompts disabled", errProviderAuth, "github-token-command"},
+		{"gh missing PR", "GraphQL: Could not resolve to a PullRequest with the number of 999. (repository.pullRequest)", errProviderNotFound, "PR #999 not found on GitHub"},
+		{"git missing ref", "fatal: couldn't find remote ref pull/999/head", errProviderNotFound, "PR #999 not found"},
+		{"other", "fatal: unable to access 'https://github.com/': Could not resolve host", nil, "Could not resolve host"},
+		{"no output", "", nil, "exit status 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := githubProvider.classifyError("PR #999", tt.output, failed)
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && (errors.Is(err, errProviderAuth) || errors.Is(err, errProviderNotFound)) {
+				t.Errorf("error = %v, want it unclassified", err)
+			}
+			if !strings.Contains(err.Error(), tt.text) {
+				t.Errorf("error = %q, want it to mention %q", err, tt.text)
+			}
+		})
+	}
+}
+
+func TestProviderTokenPrecedence(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as gh")
+	}
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("WT_CONFIG", "")
+	// A gh without a login, so the real one does not leak into the test.
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "gh"), []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "env-token")
+
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+	fresh := func() { providerTokens = map[string]*providerToken{} }
+	t.Cleanup(fresh)
+
+	fresh()
+	if tok, err := githubProvider.token(); err != nil || tok != (providerToken{"env-token", "$GITHUB_TOKEN"}) {
+		t.Errorf("token() = %+v, %v, want env-token from $GITHUB_TOKEN", tok, err)
+	}
+
+	// A token command in .wt.yaml is ignored.
+	if err := os.WriteFile(filepath.Join(repoDir, ".wt.yaml"), []byte("github-token-command: echo repo-token\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fresh()
+	if tok, _ := githubProvider.token(); tok.Value != "env-token" {
+		t.Errorf("token() with repo file command = %+v, want env-token", tok)
+	}
+
+	runGitCommand(t, repoDir, "config", "wt.github-token-command", "echo cmd-token")
+	fresh()
+	if tok, err := githubProvider.token(); err != nil || tok != (providerToken{"cmd-token", "config github-token-command"}) {
+		t.Errorf("token() = %+v, %v, want cmd-token from the configured command", tok, err)
+	}
+
+	runGitCommand(t, repoDir, "config", "wt.github-token-command", "true")
+	fresh()
+	if _, err := githubProvider.token(); err == nil || !strings.Contains(err.Error(), "printed no token") {
+		t.Errorf("token() with silent command: err = %v, want printed no token", err)
+	}
+}
+
+func TestShellReferencesOn(t *testing.T) {
+	placeholders := []shellPlaceholder{{"{branch}", "WT_BRANCH"}, {"{path}", "WT_PATH"}}
+	values := []string{`x"&calc&"`, "/wt/x"}
+
+	got, err := shellReferencesOn("linux", "echo {branch} {path}", placeholders, values)
+	if err != nil || got != `echo "$WT_BRANCH" "$WT_PATH"` {
+		t.Errorf("linux = %q, %v", got, err)
+	}
+
+	// cmd expands %WT_BRANCH% before parsing, so the value would break out
+	// of its quotes.
+	if _, err := shellReferencesOn("windows", "echo {branch}", placeholders, values); err == nil || !strings.Contains(err.Error(), "{branch}") {
+		t.Errorf("windows with a value cmd reads as syntax: err = %v", err)
+	}
+	for _, value := range []string{"a|b", "a^b", "a%PATH%", "a<b", "a>b"} {
+		if _, err := shellReferencesOn("windows", "echo {branch}", placeholders, []string{value, "/wt/x"}); err == nil {
+			t.Errorf("windows accepted %q", value)
+		}
+	}
+	// Only placeholders the command uses matter.
+	got, err = shellReferencesOn("windows", "echo {path}", placeholders, values)
+	if err != nil || got != `echo "%WT_PATH%"` {
+		t.Errorf("windows = %q, %v", got, err)
+	}
+}
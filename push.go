@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// parsePushOnCreate parses the push-on-create setting; empty is false.
+func parsePushOnCreate(value string) (bool, error) {
+	return parseBoolSetting("push-on-create", value)
+}
+
+// setupBranchPush prepares a branch wt just created in the worktree at path
+// for the push-remote and push-on-create settings. With push-on-create the
+// branch is pushed to the push remote (default origin) with --set-upstream
+// right away, so CI sees it. With only push-remote set, the first plain
+// 'git push' in the worktree goes there and sets the upstream: the branch
+// drops the upstream it got from its base, gets the remote as pushRemote,
+// and the worktree gets push.autoSetupRemote (git 2.37 or later). Failures
+// are warnings; the worktree exists already.
+func setupBranchPush(path, branch string) {
+	cfg := loadConfig()
+	remote := strings.TrimSpace(cfg.get("push-remote"))
+	pushNow, _ := parsePushOnCreate(cfg.get("push-on-create"))
+	if remote == "" && !pushNow {
+		return
+	}
+	if remote == "" {
+		remote = "origin"
+	}
+	if err := gitCommand("remote", "get-url", remote).Run(); err != nil {
+		warnf("warning: remote '%s' (push-remote) does not exist; '%s' was not set up for pushing\n", remote, branch)
+		return
+	}
+
+	if pushNow {
+		infof("Pushing '%s' to %s\n", branch, remote)
+		gitCmd := gitCommand("-C", path, "push", "--set-upstream", remote, branch)
+		gitCmd.Stdout = gitOutput()
+		gitCmd.Stderr = os.Stderr
+		if err := gitCmd.Run(); err != nil {
+			warnf("warning: failed to push '%s' to %s: %v\nPush it with: git push --set-upstream %s %s\n", branch, remote, err, remote, branch)
+			return
+		}
+		snapshot.invalidate()
+		return
+	}
+
+	// Without an upstream of its own name, a plain 'git push' refuses.
+	gitCommand("branch", "--unset-upstream", branch).Run()
+	if output, err := gitCommand("config", "branch."+branch+".pushRemote", remote).CombinedOutput(); err != nil {
+		warnf("warning: failed to set the push remote of '%s': %s\n", branch, strings.TrimSpace(string(output)))
+		return
+	}
+	if err := enableWorktreeConfig(); err != nil {
+		warnf("warning: the first push of '%s' will not set its upstream: %v\n", branch, err)
+		return
+	}
+	if output, err := gitCommand("-C", path, "config", "--worktree", "push.autoSetupRemote", "true").CombinedOutput(); err != nil {
+		warnf("warning: the first push of '%s' will not set its upstream: %s\n", branch, strings.TrimSpace(string(output)))
+		return
+	}
+	infof("'git push' in the worktree pushes '%s' to %s and sets the upstream\n", branch, remote)
+}
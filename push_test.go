@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePushOnCreate(t *testing.T) {
+	for value, want := range map[string]bool{"": false, "false": false, "true": true, " 1 ": true} {
+		if got, err := parsePushOnCreate(value); err != nil || got != want {
+			t.Errorf("parsePushOnCreate(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := parsePushOnCreate("maybe"); err == nil {
+		t.Error("parsePushOnCreate(maybe) succeeded")
+	}
+}
+
+func TestSetupBranchPush(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	t.Setenv("WT_CONFIG", configFile)
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	chdirFamily(t, repoDir)
+
+	origin := filepath.Join(tmpDir, "origin.git")
+	runGitCommand(t, tmpDir, "init", "-q", "--bare", origin)
+	runGitCommand(t, repoDir, "remote", "add", "origin", origin)
+	runGitCommand(t, repoDir, "push", "-q", "-u", "origin", "main")
+	gitValue := func(args ...string) string {
+		output, _ := gitCommand(args...).Output()
+		return strings.TrimSpace(string(output))
+	}
+
+	// Nothing configured: git's behavior is left alone.
+	plain := filepath.Join(tmpDir, "plain")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "plain", plain, "origin/main")
+	setupBranchPush(plain, "plain")
+	if got := gitValue("rev-parse", "--abbrev-ref", "plain@{u}"); got != "origin/main" {
+		t.Errorf("upstream without settings = %q, want origin/main", got)
+	}
+
+	// push-remote: the first push goes there and sets the upstream.
+	if err := os.WriteFile(configFile, []byte("push-remote: origin\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := filepath.Join(tmpDir, "later")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "later", later, "origin/main")
+	setupBranchPush(later, "later")
+	if got := gitValue("config", "branch.later.pushRemote"); got != "origin" {
+		t.Errorf("pushRemote = %q, want origin", got)
+	}
+	if got := gitValue("config", "branch.later.merge"); got != "" {
+		t.Errorf("later still tracks %s", got)
+	}
+	runGitCommand(t, later, "push", "-q")
+	if got := gitValue("rev-parse", "--abbrev-ref", "later@{u}"); got != "origin/later" {
+		t.Errorf("upstream after the first push = %q, want origin/later", got)
+	}
+
+	// push-on-create: pushed right away.
+	if err := os.WriteFile(configFile, []byte("push-on-create: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	now := filepath.Join(tmpDir, "now")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "now", now)
+	setupBranchPush(now, "now")
+	if got := gitValue("rev-parse", "--abbrev-ref", "now@{u}"); got != "origin/now" {
+		t.Errorf("upstream after push-on-create = %q, want origin/now", got)
+	}
+}
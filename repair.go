@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var repairDryRun bool
+
+// repairSearchDepth is how deep 'wt repair' looks below the directories the
+// layout puts worktrees in for worktrees that were moved by hand.
+const repairSearchDepth = 3
+
+// repairIssue is something wrong between a worktree and the administrative
+// files git keeps for it in the repository (.git/worktrees/<id>).
+type repairIssue struct {
+	Branch  string
+	Path    string // where the worktree is, or was for a missing one
+	Moved   string // where git still thinks a moved worktree is
+	Problem string
+	// Fixable is set when 'git worktree repair <Path>' fixes the issue.
+	Fixable bool
+}
+
+// readGitFile returns the administrative directory the .git file of the
+// worktree at dir points to, made absolute, or "" when dir has no .git file.
+func readGitFile(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, ".git"))
+	if err != nil {
+		return ""
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	gitDir = filepath.FromSlash(strings.TrimSpace(gitDir))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	return filepath.Clean(gitDir)
+}
+
+// adminWorktree is what git records about a linked worktree in its
+// administrative directory.
+type adminWorktree struct {
+	ID     string
+	Dir    string // the administrative directory
+	Path   string // the worktree, from the gitdir backlink
+	Branch string
+}
+
+// readAdminWorktrees lists the administrative directories of the linked
+// worktrees of the repository at commonDir.
+func readAdminWorktrees(commonDir string) []adminWorktree {
+	dirs, _ := filepath.Glob(filepath.Join(commonDir, "worktrees", "*"))
+	var admins []adminWorktree
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(dir, "gitdir"))
+		if err != nil {
+			continue
+		}
+		backlink := filepath.FromSlash(strings.TrimSpace(string(data)))
+		if !filepath.IsAbs(backlink) {
+			backlink = filepath.Join(dir, backlink)
+		}
+		admin := adminWorktree{ID: filepath.Base(dir), Dir: dir, Path: filepath.Dir(filepath.Clean(backlink))}
+		if head, err := os.ReadFile(filepath.Join(dir, "HEAD")); err == nil {
+			admin.Branch, _ = strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+			if admin.Branch == strings.TrimSpace(string(head)) {
+				// Detached
+				admin.Branch = ""
+			}
+		}
+		admins = append(admins, admin)
+	}
+	return admins
+}
+
+// findWorktreeDirs returns the directories with a .git file below roots, at
+// most repairSearchDepth levels deep, mapped to where their .git file
+// points. It does not descend into worktrees.
+func findWorktreeDirs(roots []string) map[string]string {
+	found := map[string]string{}
+	for _, root := range roots {
+		base := strings.Count(filepath.Clean(root), string(filepath.Separator))
+		filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if gitDir := readGitFile(p); gitDir != "" {
+				found[p] = gitDir
+				return filepath.SkipDir
+			}
+			if fileExists(filepath.Join(p, ".git")) || strings.Count(p, string(filepath.Separator))-base >= repairSearchDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+	return found
+}
+
+// findRepairIssues compares the administrative directories of the
+// repository at commonDir with the worktrees they belong to:
+//
+//   - a worktree whose directory is gone from where git has it, but which
+//     is found below roots with a .git file pointing back, was moved by hand;
+//   - a worktree whose .git file points to a directory that does not exist,
+//     or to another one, lost its link, e.g. after the repository moved;
+//   - a worktree that is gone and not found is left to 'wt prune'.
+func findRepairIssues(commonDir string, roots []string) []repairIssue {
+	dirs := findWorktreeDirs(roots)
+	var issues []repairIssue
+	for _, admin := range readAdminWorktrees(commonDir) {
+		if _, err := os.Stat(admin.Path); err != nil {
+			issue := repairIssue{Branch: admin.Branch, Path: admin.Path, Problem: "directory is gone"}
+			for dir, gitDir := range dirs {
+				if sameDir(resolvePath(gitDir), resolvePath(admin.Dir)) || (!fileExists(gitDir) && filepath.Base(gitDir) == admin.ID) {
+					issue = repairIssue{Branch: admin.Branch, Path: dir, Moved: admin.Path, Problem: "moved from " + admin.Path, Fixable: true}
+					delete(dirs, dir)
+					break
+				}
+			}
+			issues = append(issues, issue)
+			continue
+		}
+		gitDir := readGitFile(admin.Path)
+		switch {
+		case gitDir == "":
+			issues = append(issues, repairIssue{Branch: admin.Branch, Path: admin.Path, Problem: "has no .git file"})
+		case !fileExists(gitDir):
+			issues = append(issues, repairIssue{Branch: admin.Branch, Path: admin.Path, Problem: ".git points to " + gitDir + ", which does not exist", Fixable: true})
+		case !sameDir(resolvePath(gitDir), resolvePath(admin.Dir)):
+			issues = append(issues, repairIssue{Branch: admin.Branch, Path: admin.Path, Problem: ".git points to " + gitDir + " instead of " + admin.Dir, Fixable: true})
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	return issues
+}
+
+// repairRoots are the directories searched for moved worktrees: the ones
+// the layout puts worktrees of the repository in, and the given paths.
+func repairRoots(info repoInfo, paths []string) []string {
+	var roots []string
+	if path, err := worktreePathFor(info, "wt-repair"); err == nil {
+		roots = append(roots, filepath.Dir(path))
+	}
+	for _, p := range paths {
+		if abs, err := filepath.Abs(expandHome(p)); err == nil {
+			roots = append(roots, abs)
+		}
+	}
+	return roots
+}
+
+// brokenWorktreeHint explains, when the current directory is a worktree
+// whose link to its repository is broken, where to run 'wt repair' instead.
+func brokenWorktreeHint() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		if gitDir := readGitFile(dir); gitDir != "" {
+			if fileExists(gitDir) {
+				return ""
+			}
+			return fmt.Sprintf("%s is a worktree whose .git file points to %s, which does not exist\nRun 'wt repair %s' in the main worktree of its repository", dir, gitDir, dir)
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+var repairCmd = &cobra.Command{
+	Use:   "repair [path...]",
+	Short: "Fix the links between worktrees and their repository",
+	Long: `Find and fix worktrees that git lost track of:
+  - a worktree directory that was moved by hand, without 'wt move'
+  - a .git file that points to an administrative directory that does not
+    exist, or to the wrong one
+  - stale links after the main repository itself was moved
+
+Worktrees that moved are looked for in the directories the layout puts
+worktrees of the repository in, and below the given paths. The fixes go
+through 'git worktree repair'; wt's own bookkeeping (last visits, hook runs)
+follows moved worktrees. Worktrees that are gone for good are left to
+'wt prune'.
+
+Run it in the main worktree: a linked worktree with a broken link is not
+recognized as part of the repository.
+
+Examples:
+  wt repair --dry-run          # Show what is broken and how it would be fixed
+  wt repair                    # Fix it
+  wt repair ~/elsewhere        # Also look for moved worktrees there`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		commonDir, err := gitCommonDir()
+		if err != nil {
+			if hint := brokenWorktreeHint(); hint != "" {
+				return fmt.Errorf("%s", hint)
+			}
+			return err
+		}
+		info, err := getRepoInfo()
+		if err != nil {
+			return err
+		}
+
+		issues := findRepairIssues(commonDir, repairRoots(info, args))
+		if len(issues) == 0 {
+			infof("All worktrees are linked to the repository\n")
+			return nil
+		}
+
+		failed := 0
+		for _, issue := range issues {
+			name := issue.Branch
+			if name == "" {
+				name = "(detached)"
+			}
+			if !issue.Fixable {
+				fmt.Printf("%s: %s %s; 'wt prune' removes what git has of it\n", name, issue.Path, issue.Problem)
+				continue
+			}
+			if repairDryRun {
+				fmt.Printf("Would repair %s at %s: %s\n", name, issue.Path, issue.Problem)
+				continue
+			}
+			output, err := gitCommand("worktree", "repair", issue.Path).CombinedOutput()
+			if err != nil {
+				err = fmt.Errorf("%s", strings.TrimSpace(string(output)))
+				warnf("Failed to repair %s at %s: %v\n", name, issue.Path, err)
+				recordAudit(issue.Branch, issue.Path, issue.Problem, err)
+				failed++
+				continue
+			}
+			recordAudit(issue.Branch, issue.Path, issue.Problem, nil)
+			if issue.Moved != "" {
+				relocateState(issue.Moved, issue.Path)
+			}
+			successf("Repaired %s at %s (%s)", name, issue.Path, issue.Problem)
+			if issue.Branch != "" {
+				if want, err := worktreePathFor(info, issue.Branch); err == nil && !sameDir(resolvePath(want), resolvePath(issue.Path)) {
+					infof("  It is not where the layout puts it; 'wt move %s' moves it to %s\n", issue.Branch, want)
+				}
+			}
+		}
+		snapshot.invalidate()
+		if failed > 0 {
+			return fmt.Errorf("%d worktree(s) could not be repaired", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	repairCmd.Flags().BoolVar(&repairDryRun, "dry-run", false, "Show what is broken and what would be done, without changing anything")
+	rootCmd.AddCommand(repairCmd)
+}
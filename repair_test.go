@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadGitFile(t *testing.T) {
+	dir := t.TempDir()
+	if got := readGitFile(dir); got != "" {
+		t.Errorf("readGitFile() without .git = %q", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".git"), []byte("gitdir: ../repo/.git/worktrees/feat\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(filepath.Dir(dir), "repo", ".git", "worktrees", "feat")
+	if got := readGitFile(dir); got != want {
+		t.Errorf("readGitFile() = %q, want %q", got, want)
+	}
+}
+
+func TestFindRepairIssues(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	worktrees := filepath.Join(tmpDir, "worktrees")
+	for _, branch := range []string{"moved", "gone", "fine"} {
+		runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", branch, filepath.Join(worktrees, branch))
+	}
+	if issues := findRepairIssues(filepath.Join(repoDir, ".git"), []string{worktrees}); len(issues) != 0 {
+		t.Fatalf("healthy worktrees have issues: %+v", issues)
+	}
+
+	if err := os.Rename(filepath.Join(worktrees, "moved"), filepath.Join(worktrees, "elsewhere")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(worktrees, "gone")); err != nil {
+		t.Fatal(err)
+	}
+	issues := findRepairIssues(filepath.Join(repoDir, ".git"), []string{worktrees})
+	if len(issues) != 2 {
+		t.Fatalf("issues = %+v, want the moved and the gone worktree", issues)
+	}
+	for _, issue := range issues {
+		switch issue.Branch {
+		case "moved":
+			if !issue.Fixable || filepath.Base(issue.Path) != "elsewhere" || filepath.Base(issue.Moved) != "moved" {
+				t.Errorf("moved worktree = %+v", issue)
+			}
+		case "gone":
+			if issue.Fixable || issue.Problem != "directory is gone" {
+				t.Errorf("gone worktree = %+v", issue)
+			}
+		default:
+			t.Errorf("unexpected issue %+v", issue)
+		}
+	}
+
+	// The repository moves: every worktree loses its link to it.
+	runGitCommand(t, repoDir, "worktree", "repair", filepath.Join(worktrees, "elsewhere"))
+	runGitCommand(t, repoDir, "worktree", "prune")
+	movedRepo := filepath.Join(tmpDir, "repo2")
+	if err := os.Rename(repoDir, movedRepo); err != nil {
+		t.Fatal(err)
+	}
+	issues = findRepairIssues(filepath.Join(movedRepo, ".git"), []string{worktrees})
+	if len(issues) != 2 {
+		t.Fatalf("issues after moving the repository = %+v", issues)
+	}
+	for _, issue := range issues {
+		if !issue.Fixable || !strings.Contains(issue.Problem, "does not exist") {
+			t.Errorf("issue after moving the repository = %+v", issue)
+		}
+		runGitCommand(t, movedRepo, "worktree", "repair", issue.Path)
+	}
+	if issues := findRepairIssues(filepath.Join(movedRepo, ".git"), []string{worktrees}); len(issues) != 0 {
+		t.Errorf("issues after git worktree repair: %+v", issues)
+	}
+}
+
+func TestRepairRootsCreatesNothing(t *testing.T) {
+	originalRoot, originalStrategy, originalPattern := worktreeRoot, worktreeStrategy, worktreePattern
+	t.Cleanup(func() {
+		worktreeRoot, worktreeStrategy, worktreePattern = originalRoot, originalStrategy, originalPattern
+	})
+	worktreeRoot = filepath.Join(t.TempDir(), "worktrees")
+	worktreeStrategy = "global"
+	worktreePattern = ""
+
+	roots := repairRoots(repoInfo{Main: t.TempDir(), Name: "repo"}, nil)
+	if want := filepath.Join(worktreeRoot, "repo"); len(roots) != 1 || roots[0] != want {
+		t.Errorf("repairRoots() = %v, want %v", roots, want)
+	}
+	if _, err := os.Stat(worktreeRoot); !os.IsNotExist(err) {
+		t.Errorf("repairRoots() created %s: %v", worktreeRoot, err)
+	}
+}
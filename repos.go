@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// repoSeenInterval is how often the last use of a known repository is
+// written to the state file.
+const repoSeenInterval = 24 * time.Hour
+
+var (
+	listAllRepos   bool
+	repoRegistered bool
+)
+
+// registerRepo adds the current repository to the ones wt knows, for
+// 'wt list --all-repos'. It is keyed by the common git directory, which is
+// the same from every worktree.
+func registerRepo() {
+	if repoRegistered {
+		return
+	}
+	repoRegistered = true
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return
+	}
+	key := resolvePath(commonDir)
+	state := loadState()
+	if seen, ok := state.Repos[key]; ok && time.Since(seen) < repoSeenInterval {
+		return
+	}
+	state.Repos[key] = time.Now()
+	_ = state.save()
+}
+
+// gcRepos forgets repositories that no longer exist. Known ones are kept
+// however long they were not used: they still have worktrees to show.
+func gcRepos(_ time.Duration, dryRun bool) gcResult {
+	result := gcResult{Name: "known repositories", Unit: "entries"}
+	state := loadState()
+	for dir := range state.Repos {
+		if _, err := os.Stat(longPath(dir)); os.IsNotExist(err) {
+			delete(state.Repos, dir)
+			result.Removed++
+		}
+	}
+	if result.Removed > 0 && !dryRun {
+		if err := state.save(); err != nil {
+			warnf("warning: failed to save state: %v\n", err)
+		}
+	}
+	return result
+}
+
+// repoListing is one known repository with its worktrees.
+type repoListing struct {
+	CommonDir string
+	Main      string
+	Items     []listItem
+	// Size is the disk usage of all worktrees, counting worktrees nested
+	// in another one once.
+	Size int64
+	Err  error
+}
+
+// collectRepoListing lists the worktrees of the repository at commonDir.
+func collectRepoListing(commonDir string, state *wtState, branchGlob string) repoListing {
+	repo := repoListing{CommonDir: commonDir, Main: commonDir}
+	if _, err := os.Stat(longPath(commonDir)); err != nil {
+		repo.Err = fmt.Errorf("missing, 'wt gc' forgets it")
+		return repo
+	}
+	output, err := gitCommand("-C", commonDir, "worktree", "list", "--porcelain").Output()
+	if err != nil {
+		repo.Err = fmt.Errorf("git worktree list failed: %v", err)
+		return repo
+	}
+	entries := parseWorktreePorcelain(string(output))
+	fixSubmoduleMain(entries)
+	if len(entries) > 0 {
+		repo.Main = entries[0].Path
+	}
+	for _, entry := range entries {
+		if branchGlob != "" {
+			if ok, _ := path.Match(branchGlob, entry.Branch); !ok {
+				continue
+			}
+		}
+		st := worktreeStatus{worktreeEntry: entry}
+		repo.Items = append(repo.Items, listItem{worktreeStatus: st, LastVisit: state.lastVisit(entry.Path)})
+	}
+
+	var wg sync.WaitGroup
+	for i := range repo.Items {
+		if repo.Items[i].Bare || repo.Items[i].Prunable {
+			continue
+		}
+		wg.Add(1)
+		go func(item *listItem) {
+			defer wg.Done()
+			item.Size = dirSize(item.Path)
+		}(&repo.Items[i])
+	}
+	wg.Wait()
+
+	for i, item := range repo.Items {
+		nested := false
+		for j, other := range repo.Items {
+			if i != j && other.Size > 0 && !samePath(other.Path, item.Path) && isWithin(other.Path, item.Path) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			repo.Size += item.Size
+		}
+	}
+	return repo
+}
+
+// collectAllRepos lists the worktrees of every known repository, ordered
+// by the path of their main worktree.
+func collectAllRepos(sortBy, branchGlob string) ([]repoListing, error) {
+	switch sortBy {
+	case "", "name", "last-used", "size":
+	default:
+		return nil, fmt.Errorf("invalid --sort value %q with --all-repos (use name, last-used or size)", sortBy)
+	}
+	if branchGlob != "" {
+		if _, err := path.Match(branchGlob, ""); err != nil {
+			return nil, fmt.Errorf("invalid --branch pattern %q: %w", branchGlob, err)
+		}
+	}
+
+	state := loadState()
+	var repos []repoListing
+	for dir := range state.Repos {
+		repo := collectRepoListing(dir, state, branchGlob)
+		sortListItems(repo.Items, sortBy)
+		repos = append(repos, repo)
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Main < repos[j].Main })
+	return repos, nil
+}
+
+// printAllRepos renders the repositories grouped, each with its worktree
+// count and disk usage, followed by the totals.
+func printAllRepos(out io.Writer, repos []repoListing) error {
+	if len(repos) == 0 {
+		fmt.Fprintln(out, "No repositories known yet; wt learns them as you use it in them")
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	var worktrees int
+	var size int64
+	for i, repo := range repos {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if repo.Err != nil {
+			fmt.Fprintf(w, "%s (%v)\n", repo.Main, repo.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%s (%d worktree(s), %s)\n", repo.Main, len(repo.Items), formatSize(repo.Size))
+		for _, item := range repo.Items {
+			fmt.Fprintln(w, "  "+formatListLine(item))
+		}
+		worktrees += len(repo.Items)
+		size += repo.Size
+	}
+	fmt.Fprintf(w, "\nTotal: %d repositories, %d worktree(s), %s\n", len(repos), worktrees, formatSize(size))
+	return w.Flush()
+}
+
+func init() {
+	gcTasks = append(gcTasks, gcRepos)
+}
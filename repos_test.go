@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAllRepos(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+
+	var repos []string
+	for _, name := range []string{"api", "web", "gone"} {
+		repoDir := filepath.Join(tmpDir, name)
+		setupTestRepo(t, repoDir)
+		if err := os.Chdir(repoDir); err != nil {
+			t.Fatal(err)
+		}
+		repoRegistered = false
+		if _, err := getRepoInfo(); err != nil {
+			t.Fatal(err)
+		}
+		if len(loadState().Repos) != len(repos) {
+			t.Fatalf("getRepoInfo() registered %s", repoDir)
+		}
+		registerRepo()
+		repos = append(repos, repoDir)
+	}
+	runGitCommand(t, repos[0], "worktree", "add", "-b", "feature", filepath.Join(tmpDir, "api-feature"))
+	if err := os.WriteFile(filepath.Join(tmpDir, "api-feature", "data"), make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(repos[2]); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(loadState().Repos); got != 3 {
+		t.Fatalf("known repositories = %d, want 3", got)
+	}
+
+	listings, err := collectAllRepos("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listings) != 3 {
+		t.Fatalf("collectAllRepos() = %d repositories, want 3", len(listings))
+	}
+	api := listings[0]
+	if !samePath(api.Main, repos[0]) || len(api.Items) != 2 || api.Err != nil {
+		t.Fatalf("first listing = %s with %d worktrees (%v), want %s with 2", api.Main, len(api.Items), api.Err, repos[0])
+	}
+	if api.Size < 4096 {
+		t.Errorf("api size = %d, want at least the 4096 bytes of the feature worktree", api.Size)
+	}
+	// Ordered by path: .../gone/.git comes before .../web.
+	if listings[1].Err == nil {
+		t.Errorf("listing of the removed repository has no error")
+	}
+
+	var out strings.Builder
+	if err := printAllRepos(&out, listings); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"(2 worktree(s), ", "[feature]", "missing", "Total: 3 repositories, 3 worktree(s)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, out.String())
+		}
+	}
+
+	listings, err = collectAllRepos("size", "feat*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listings[0].Items) != 1 || listings[0].Items[0].Branch != "feature" || len(listings[2].Items) != 0 {
+		t.Errorf("--branch feat* kept %d and %d worktrees, want 1 and 0", len(listings[0].Items), len(listings[2].Items))
+	}
+	if _, err := collectAllRepos("age", ""); err == nil {
+		t.Error("collectAllRepos(age) succeeded, want an error")
+	}
+
+	if result := gcRepos(0, false); result.Removed != 1 {
+		t.Errorf("gcRepos removed %d, want 1", result.Removed)
+	}
+	if got := len(loadState().Repos); got != 2 {
+		t.Errorf("known repositories after gc = %d, want 2", got)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// scratchDir is the scratch setting: a directory, relative to the worktree,
+// that wt creates in every new worktree and keeps out of git status; ""
+// when it is off.
+var scratchDir string
+
+// scratchName checks the scratch setting and returns it in the slash form
+// used for matching, without a trailing slash. Empty turns it off.
+func scratchName(value string) (string, error) {
+	name := strings.TrimSuffix(filepath.ToSlash(strings.TrimSpace(value)), "/")
+	if name == "" {
+		return "", nil
+	}
+	if path.IsAbs(name) || filepath.IsAbs(value) || filepath.VolumeName(value) != "" {
+		return "", fmt.Errorf("scratch %q must be relative to the worktree", value)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." || part == "." || part == "" || part == ".git" {
+			return "", fmt.Errorf("scratch %q must be a plain path inside the worktree", value)
+		}
+	}
+	return name, nil
+}
+
+// excludeScratch adds the scratch directory to info/exclude of the
+// repository at commonDir, which applies to all of its worktrees.
+func excludeScratch(commonDir, name string) error {
+	return addExclude(commonDir, "/"+name+"/", "wt scratch directory")
+}
+
+// hasExclude reports whether info/exclude of the repository at commonDir
+// lists pattern.
+func hasExclude(commonDir, pattern string) bool {
+	data, _ := os.ReadFile(filepath.Join(commonDir, "info", "exclude"))
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// addExclude adds pattern to info/exclude of the repository at commonDir,
+// after a comment saying why, unless it is listed already.
+func addExclude(commonDir, pattern, comment string) error {
+	exclude := filepath.Join(commonDir, "info", "exclude")
+	data, err := os.ReadFile(exclude)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if hasExclude(commonDir, pattern) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(exclude), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(exclude, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	prefix := ""
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		prefix = "\n"
+	}
+	_, err = fmt.Fprintf(f, "%s# %s\n%s\n", prefix, comment, pattern)
+	return err
+}
+
+// createScratchDir creates the scratch directory in the new worktree at
+// path, for tools that need a place of their own per branch, and makes git
+// ignore it. Failing to is only worth a warning.
+func createScratchDir(path string) {
+	if scratchDir == "" {
+		return
+	}
+	commonDir, err := gitCommonDir()
+	if err == nil {
+		err = excludeScratch(commonDir, scratchDir)
+	}
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(path, filepath.FromSlash(scratchDir)), 0o755)
+	}
+	if err != nil {
+		warnf("warning: failed to create the scratch directory in %s: %v\n", path, err)
+	}
+}
+
+// scratchContents returns what is in the scratch directory of the worktree
+// at root, which 'wt clean' wipes while keeping the directory itself.
+func scratchContents(root string) []artifact {
+	if scratchDir == "" {
+		return nil
+	}
+	dir := filepath.Join(root, filepath.FromSlash(scratchDir))
+	entries, err := os.ReadDir(longPath(dir))
+	if err != nil {
+		return nil
+	}
+	var found []artifact
+	for _, e := range entries {
+		item := artifact{Path: filepath.Join(dir, e.Name())}
+		if e.IsDir() {
+			item.Size = dirSize(item.Path)
+		} else if info, err := e.Info(); err == nil {
+			item.Size = info.Size()
+		}
+		found = append(found, item)
+	}
+	return found
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScratchName(t *testing.T) {
+	for value, want := range map[string]string{"": "", ".wt-scratch": ".wt-scratch", "tmp/scratch/": "tmp/scratch"} {
+		if got, err := scratchName(value); err != nil || got != want {
+			t.Errorf("scratchName(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"/tmp/scratch", "../scratch", ".git", "a/../b"} {
+		if _, err := scratchName(value); err == nil {
+			t.Errorf("scratchName(%q) succeeded", value)
+		}
+	}
+}
+
+func TestExcludeScratch(t *testing.T) {
+	commonDir := t.TempDir()
+	exclude := filepath.Join(commonDir, "info", "exclude")
+	if err := os.MkdirAll(filepath.Dir(exclude), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(exclude, []byte("*.swp"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := excludeScratch(commonDir, ".wt-scratch"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "/.wt-scratch/\n"); got != 1 {
+		t.Errorf("exclude lists the scratch directory %d times:\n%s", got, data)
+	}
+	if !strings.HasPrefix(string(data), "*.swp\n") {
+		t.Errorf("exclude lost its own patterns:\n%s", data)
+	}
+}
+
+func TestScratchContents(t *testing.T) {
+	root := t.TempDir()
+	defer func() { scratchDir = "" }()
+	scratchDir = ".wt-scratch"
+
+	files := map[string]string{
+		".wt-scratch/notes.txt":      "todo",
+		".wt-scratch/cache/blob":     "data",
+		".wt-scratch/target/out.bin": "build output in scratch",
+		"target/app":                 "build output",
+	}
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for _, item := range scratchContents(root) {
+		got = append(got, filepath.Base(item.Path))
+	}
+	if strings.Join(got, ",") != "cache,notes.txt,target" {
+		t.Errorf("scratchContents() = %v, want cache, notes.txt and target", got)
+	}
+
+	// Artifacts inside the scratch directory go with its contents.
+	found, err := findArtifacts(root, []string{"target/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Path != filepath.Join(root, "target") {
+		t.Errorf("findArtifacts() = %v, want only %s", found, filepath.Join(root, "target"))
+	}
+}
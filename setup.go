@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setupStep is a command the setup section runs in every new worktree,
+// after the post-checkout hooks, when all of its conditions hold.
+type setupStep struct {
+	Run    string
+	When   []string
+	Source string
+	// Repo is set for steps of the repo file, which only run with trust-repo.
+	Repo bool
+}
+
+// setupPlaceholders are replaced in the commands and conditions of setup
+// steps, and exported as the variable after them. In a command a
+// placeholder becomes a reference to its variable, so the shell never sees
+// a value, such as a branch name with $( or ; in it, as syntax.
+var setupPlaceholders = []shellPlaceholder{
+	{"{branch}", "WT_BRANCH"},
+	{"{branch_slug}", "WT_BRANCH_SLUG"},
+	{"{base}", "WT_BASE"},
+	{"{path}", "WT_PATH"},
+	{"{repo}", "WT_REPO"},
+	{"{main}", "WT_MAIN"},
+}
+
+// setupConditionRegex matches a condition such as file_exists(package.json)
+// or !branch_matches(release/*).
+var setupConditionRegex = regexp.MustCompile(`^(!?)\s*([a-z_]+)\((.*)\)$`)
+
+// setupConditions are the functions a condition can call:
+//
+//	file_exists(glob)     a file matching glob, relative to the worktree
+//	branch_matches(glob)  the branch name matches glob
+//	env(NAME)             the environment variable is set and not empty
+var setupConditions = []string{"file_exists", "branch_matches", "env"}
+
+func isSetupCondition(name string) bool {
+	for _, c := range setupConditions {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readSetupSteps parses the setup section of a config file:
+//
+//	setup:
+//	  - run: npm ci
+//	    when: file_exists(package.json)
+//	  - cp {main}/.env .env
+//
+// Syntax errors of the file itself are left to readConfigFile.
+func readSetupSteps(file string) (steps []setupStep, problems []string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil
+	}
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "setup" {
+			continue
+		}
+		section := root.Content[i+1]
+		if section.Kind != yaml.SequenceNode {
+			return nil, []string{fmt.Sprintf("%s:%d: setup must be a list of commands", file, section.Line)}
+		}
+		for _, item := range section.Content {
+			step, problem := parseSetupStep(file, item)
+			if problem != "" {
+				problems = append(problems, problem)
+				continue
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps, problems
+}
+
+func parseSetupStep(file string, item *yaml.Node) (setupStep, string) {
+	step := setupStep{Source: fmt.Sprintf("%s:%d", file, item.Line)}
+	switch item.Kind {
+	case yaml.ScalarNode:
+		step.Run = item.Value
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			key, value := item.Content[i], item.Content[i+1]
+			switch key.Value {
+			case "run":
+				if value.Kind != yaml.ScalarNode {
+					return step, fmt.Sprintf("%s:%d: run must be a command string", file, value.Line)
+				}
+				step.Run = value.Value
+			case "when":
+				conditions := []*yaml.Node{value}
+				if value.Kind == yaml.SequenceNode {
+					conditions = value.Content
+				}
+				for _, c := range conditions {
+					if c.Kind != yaml.ScalarNode {
+						return step, fmt.Sprintf("%s:%d: when must be a condition or a list of them", file, c.Line)
+					}
+					if problem := checkSetupCondition(c.Value); problem != "" {
+						return step, fmt.Sprintf("%s:%d: %s", file, c.Line, problem)
+					}
+					step.When = append(step.When, strings.TrimSpace(c.Value))
+				}
+			default:
+				return step, fmt.Sprintf("%s:%d: unknown setup key %q (known keys: run, when)", file, key.Line, key.Value)
+			}
+		}
+	default:
+		return step, fmt.Sprintf("%s: setup step must be a command or a mapping with run and when", step.Source)
+	}
+	if strings.TrimSpace(step.Run) == "" {
+		return step, fmt.Sprintf("%s: setup step has no command", step.Source)
+	}
+	return step, ""
+}
+
+// checkSetupCondition returns what is wrong with a condition, or "".
+func checkSetupCondition(condition string) string {
+	match := setupConditionRegex.FindStringSubmatch(strings.TrimSpace(condition))
+	if match == nil {
+		return fmt.Sprintf("invalid condition %q (want e.g. file_exists(package.json))", condition)
+	}
+	if !isSetupCondition(match[2]) {
+		return fmt.Sprintf("unknown condition %s (known: %s)", match[2], strings.Join(setupConditions, ", "))
+	}
+	if match[2] == "branch_matches" {
+		if _, err := path.Match(match[3], ""); err != nil {
+			return fmt.Sprintf("invalid pattern %q: %v", match[3], err)
+		}
+	}
+	return ""
+}
+
+// setupContext is the worktree setup steps run for.
+type setupContext struct {
+	hookContext
+	Base string
+}
+
+// values returns the values of the placeholders, in the order of
+// setupPlaceholders.
+func (ctx setupContext) values() []string {
+	return []string{ctx.Branch, slugify(ctx.Branch), ctx.Base, ctx.Path, ctx.Repo.Name, ctx.Repo.Main}
+}
+
+// expand replaces the placeholders in s with their values, for conditions
+// and messages; commands go through command.
+func (ctx setupContext) expand(s string) string {
+	values := ctx.values()
+	pairs := make([]string, 0, 2*len(values))
+	for i, p := range setupPlaceholders {
+		pairs = append(pairs, p.Name, values[i])
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// command replaces the placeholders in the command of a step with quoted
+// references to their variables, which env exports.
+func (ctx setupContext) command(run string) (string, error) {
+	return shellReferences(run, setupPlaceholders, ctx.values())
+}
+
+// env is the environment of a step: that of the hooks plus the variables
+// of all placeholders.
+func (ctx setupContext) env() []string {
+	env := hookEnv("setup", ctx.hookContext)
+	values := ctx.values()
+	for i, p := range setupPlaceholders {
+		env = append(env, p.Env+"="+values[i])
+	}
+	return env
+}
+
+// holds evaluates a condition, which checkSetupCondition accepted, for
+// the worktree.
+func (ctx setupContext) holds(condition string) bool {
+	match := setupConditionRegex.FindStringSubmatch(condition)
+	if match == nil {
+		return false
+	}
+	arg := strings.TrimSpace(ctx.expand(match[3]))
+	var result bool
+	switch match[2] {
+	case "file_exists":
+		if !filepath.IsAbs(arg) {
+			arg = filepath.Join(ctx.Path, arg)
+		}
+		found, _ := filepath.Glob(arg)
+		result = len(found) > 0
+	case "branch_matches":
+		result, _ = path.Match(arg, ctx.Branch)
+	case "env":
+		result = os.Getenv(arg) != ""
+	}
+	return result != (match[1] == "!")
+}
+
+// applies reports whether all conditions of step hold, and otherwise the
+// first one that does not.
+func (ctx setupContext) applies(step setupStep) (bool, string) {
+	for _, condition := range step.When {
+		if !ctx.holds(condition) {
+			return false, condition
+		}
+	}
+	return true, ""
+}
+
+// trustedSetupSteps returns the setup steps of cfg that may run: without
+// trust-repo those of the repo file are left out, with a warning.
+func trustedSetupSteps(cfg worktreeConfig) []setupStep {
+	if cfg.trustsRepo() {
+		return cfg.Setup
+	}
+	var steps []setupStep
+	skipped := 0
+	for _, step := range cfg.Setup {
+		if step.Repo {
+			skipped++
+			continue
+		}
+		steps = append(steps, step)
+	}
+	if skipped > 0 {
+		warnf("warning: skipping %d setup step(s) of the repo file; run them yourself, or let repositories run theirs with 'wt config set trust-repo true'\n", skipped)
+	}
+	return steps
+}
+
+// runSetupSteps runs the configured setup steps whose conditions hold in a
+// new worktree, global ones first. Like post-checkout hooks they run
+// inside the worktree with the WT_* variables set, plus WT_BASE and
+// WT_BRANCH_SLUG, and their output goes to stderr. The first failing step
+// stops the rest, which usually depend on it.
+func runSetupSteps(steps []setupStep, ctx setupContext) error {
+	for _, step := range steps {
+		if ok, failed := ctx.applies(step); !ok {
+			infof("Skipping setup step (%s does not hold): %s\n", failed, step.Run)
+			continue
+		}
+		shown := ctx.expand(step.Run)
+		infof("Running setup step: %s\n", shown)
+		command, err := ctx.command(step.Run)
+		if err != nil {
+			return fmt.Errorf("setup step %q (%s) cannot run: %w", shown, step.Source, err)
+		}
+		cmd := shellCommand(command)
+		cmd.Dir = ctx.Path
+		cmd.Env = ctx.env()
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+		run := hookRun{Hook: "setup", Scope: "config", Script: shown, At: time.Now()}
+		if err != nil {
+			run.Error = err.Error()
+		}
+		recordHookRun(ctx.Path, run)
+		if err != nil {
+			return fmt.Errorf("setup step %q (%s) failed: %w", shown, step.Source, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestReadSetupSteps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `setup:
+  - run: npm ci
+    when: file_exists(package.json)
+  - run: go mod download
+    when: [file_exists(go.mod), "!env(OFFLINE)"]
+  - cp {main}/.env .env
+  - run: make
+    when: exists(Makefile)
+  - run: make
+    if: true
+  - when: env(CI)
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	steps, problems := readSetupSteps(path)
+	if len(steps) != 3 || steps[0].Run != "npm ci" || len(steps[1].When) != 2 || steps[1].When[1] != "!env(OFFLINE)" || steps[2].Run != "cp {main}/.env .env" {
+		t.Errorf("readSetupSteps() = %+v", steps)
+	}
+	if steps[0].Source != path+":2" {
+		t.Errorf("source = %q", steps[0].Source)
+	}
+	if len(problems) != 3 || !strings.Contains(problems[0], ":8: unknown condition exists") ||
+		!strings.Contains(problems[1], `:10: unknown setup key "if"`) || !strings.Contains(problems[2], ":11: setup step has no command") {
+		t.Errorf("problems = %q", problems)
+	}
+	// The section is not an unknown key to the settings reader.
+	if _, _, problems := readConfigFile(path); len(problems) != 0 {
+		t.Errorf("readConfigFile() problems = %q", problems)
+	}
+}
+
+func TestSetupConditions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WT_TEST_SET", "1")
+	ctx := setupContext{hookContext: hookContext{Repo: repoInfo{Name: "mono", Main: "/src/mono"}, Branch: "feat/api", Path: dir}, Base: "main"}
+	for condition, want := range map[string]bool{
+		"file_exists(package.json)":     true,
+		"file_exists(*.json)":           true,
+		"file_exists(go.mod)":           false,
+		"!file_exists(go.mod)":          true,
+		"branch_matches(feat/*)":        true,
+		"branch_matches(release/*)":     false,
+		"env(WT_TEST_SET)":              true,
+		"env(WT_TEST_UNSET)":            false,
+		"file_exists({path}/package.*)": true,
+	} {
+		if got := ctx.holds(condition); got != want {
+			t.Errorf("holds(%s) = %v, want %v", condition, got, want)
+		}
+	}
+	if got := ctx.expand("cp {main}/.env {path} # {repo} {branch_slug} from {base}"); got != "cp /src/mono/.env "+dir+" # mono feat-api from main" {
+		t.Errorf("expand() = %q", got)
+	}
+}
+
+func TestRunSetupSteps(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := setupContext{hookContext: hookContext{Repo: repoInfo{Name: "mono"}, Branch: "feat", Path: dir}, Base: "main"}
+	steps := []setupStep{
+		{Run: "echo node > ran-node", When: []string{"file_exists(package.json)"}},
+		{Run: `echo {branch} "$WT_BASE" > ran-go`, When: []string{"file_exists(go.mod)"}},
+		{Run: "exit 3", Source: "repo.yaml:7"},
+		{Run: "touch after-failure"},
+	}
+	err := runSetupSteps(steps, ctx)
+	if err == nil || !strings.Contains(err.Error(), "repo.yaml:7") {
+		t.Errorf("runSetupSteps() = %v, want the failing step", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ran-node")); !os.IsNotExist(err) {
+		t.Error("step whose condition does not hold ran")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "ran-go")); string(data) != "feat main\n" {
+		t.Errorf("ran-go = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "after-failure")); !os.IsNotExist(err) {
+		t.Error("step after a failing one ran")
+	}
+	if runs := loadState().HookRuns[resolvePath(dir)]; len(runs) != 2 || runs[1].Error == "" {
+		t.Errorf("recorded runs = %+v", runs)
+	}
+}
+
+func TestSetupCommandQuotesValues(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	// Branch names may contain what the shell reads as syntax.
+	branch := "x;touch pwned;$(touch pwned2)`touch pwned3`'"
+	ctx := setupContext{hookContext: hookContext{Repo: repoInfo{Name: "mono"}, Branch: branch, Path: dir}, Base: "main"}
+	if err := runSetupSteps([]setupStep{{Run: "printf %s {branch} > out"}}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "out")); string(data) != branch {
+		t.Errorf("out = %q, want the branch name", data)
+	}
+	for _, name := range []string{"pwned", "pwned2", "pwned3"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("the branch name ran %s", name)
+		}
+	}
+}
+
+func TestTrustedSetupSteps(t *testing.T) {
+	cfg := worktreeConfig{
+		Values: map[string]configValue{"trust-repo": {Value: "false"}},
+		Setup:  []setupStep{{Run: "global"}, {Run: "repo", Repo: true}},
+	}
+	if steps := trustedSetupSteps(cfg); len(steps) != 1 || steps[0].Run != "global" {
+		t.Errorf("trustedSetupSteps() without trust = %+v", steps)
+	}
+	cfg.Values["trust-repo"] = configValue{Value: "true"}
+	if steps := trustedSetupSteps(cfg); len(steps) != 2 {
+		t.Errorf("trustedSetupSteps() with trust = %+v", steps)
+	}
+}
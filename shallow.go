@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var checkoutDepth int
+
+// isShallowRepo reports whether the repository is a shallow clone. Those are
+// single-branch by default, so other branches have to be fetched by name.
+func isShallowRepo() bool {
+	output, err := gitCommand("rev-parse", "--is-shallow-repository").Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// promisorRemote returns the remote a partial clone fetches missing objects
+// from, or "" when the repository is complete.
+func promisorRemote() string {
+	output, _ := gitCommand("config", "--get-regexp", `^remote\..*\.promisor$`).Output()
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if ok && value == "true" {
+			return strings.TrimSuffix(strings.TrimPrefix(key, "remote."), ".promisor")
+		}
+	}
+	return ""
+}
+
+// fetchesAllBranches reports whether origin's fetch refspec covers every
+// branch, which single-branch clones (and so shallow ones) don't.
+func fetchesAllBranches() bool {
+	output, _ := gitCommand("config", "--get-all", "remote.origin.fetch").Output()
+	for _, refspec := range strings.Fields(string(output)) {
+		if strings.HasPrefix(strings.TrimPrefix(refspec, "+"), "refs/heads/*:") {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchBranch fetches branch from origin into its remote-tracking branch,
+// with at most depth commits of history when depth > 0. In a single-branch
+// clone the branch is added to the fetched ones first; git only sets up
+// tracking for branches the refspec covers.
+func fetchBranch(branch string, depth int) error {
+	if !fetchesAllBranches() {
+		if output, err := gitCommand("remote", "set-branches", "--add", "origin", branch).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to add %s to the fetched branches: %s", branch, strings.TrimSpace(string(output)))
+		}
+	}
+	args := []string{"fetch", "--no-tags"}
+	if depth > 0 {
+		args = append(args, fmt.Sprintf("--depth=%d", depth))
+	}
+	args = append(args, "origin", fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", branch, branch))
+	var output bytes.Buffer
+	if err := objectGit("", &output, &output, args...); err != nil {
+		if output.Len() == 0 {
+			return fmt.Errorf("failed to fetch %s from origin: %w", branch, err)
+		}
+		return fmt.Errorf("failed to fetch %s from origin: %s", branch, strings.TrimSpace(output.String()))
+	}
+	snapshot.invalidate()
+	return nil
+}
+
+// fetchMissingBranch fetches a branch the clone doesn't know about when it
+// is shallow or --depth is given: a shallow clone only fetches its default
+// branch, and fetching the whole branch would undo the point of it. Shallow
+// clones get the tip only unless --depth asks for more.
+func fetchMissingBranch(branch string) {
+	shallow := isShallowRepo()
+	if branchExists(branch) || (checkoutDepth == 0 && !shallow) {
+		return
+	}
+	depth := checkoutDepth
+	if depth == 0 {
+		depth = 1
+	}
+	infof("Fetching %s from origin (depth %d)\n", branch, depth)
+	if !shallow {
+		warnf("note: git records the repository as shallow from now on; 'git fetch --unshallow' undoes this\n")
+	}
+	if err := fetchBranch(branch, depth); err != nil {
+		warnf("warning: %v\n", err)
+	}
+}
+
+// missingObjects lists the objects of the tree of ref that a partial clone
+// has not fetched yet.
+func missingObjects(ref string) ([]string, error) {
+	output, err := gitCommand("rev-list", "--objects", "--no-walk", "--missing=print", ref).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the objects of %s", ref)
+	}
+	var missing []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if oid, ok := strings.CutPrefix(line, "?"); ok {
+			missing = append(missing, strings.TrimSpace(oid))
+		}
+	}
+	return missing, nil
+}
+
+// prefetchObjects fetches the blobs a checkout of ref needs in a partial
+// clone in one go, like git's own lazy fetch does, so an unreachable remote
+// is reported before a half checked out worktree exists.
+func prefetchObjects(ref string) error {
+	remote := promisorRemote()
+	// 'git fetch --stdin' is new in git 2.29; older gits fetch lazily.
+	if remote == "" || !gitAtLeast(2, 29) {
+		return nil
+	}
+	missing, err := missingObjects(ref)
+	if err != nil || len(missing) == 0 {
+		return nil
+	}
+	infof("Fetching %d missing object(s) for %s from %s (partial clone)\n", len(missing), ref, remote)
+	err = objectGit(strings.Join(missing, "\n")+"\n", gitOutput(), os.Stderr,
+		"-c", "fetch.negotiationAlgorithm=noop", "fetch", remote,
+		"--no-tags", "--no-write-fetch-head", "--recurse-submodules=no", "--filter=blob:none", "--stdin")
+	if err != nil {
+		return fmt.Errorf("this is a partial clone and fetching the files of %s from %s failed: %w\nCheck the connection to %s and try again", ref, remote, err, remote)
+	}
+	return nil
+}
+
+// checkoutRef is the ref 'git worktree add' checks out for branch: the local
+// branch, or else the remote-tracking branch it is created from.
+func checkoutRef(branch string) string {
+	if gitRefExists("refs/heads/" + branch) {
+		return "refs/heads/" + branch
+	}
+	return "refs/remotes/origin/" + branch
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShallowAndPartialClones(t *testing.T) {
+	tmpDir := t.TempDir()
+	upstream := filepath.Join(tmpDir, "upstream")
+	setupTestRepo(t, upstream)
+	runGitCommand(t, upstream, "config", "uploadpack.allowFilter", "true")
+	runGitCommand(t, upstream, "config", "uploadpack.allowAnySHA1InWant", "true")
+	runGitCommand(t, upstream, "checkout", "-q", "-b", "other")
+	if err := os.WriteFile(filepath.Join(upstream, "other.txt"), []byte("other\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGitCommand(t, upstream, "add", "other.txt")
+	runGitCommand(t, upstream, "commit", "-q", "-m", "other")
+	runGitCommand(t, upstream, "checkout", "-q", "main")
+	url := "file://" + filepath.ToSlash(upstream)
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	originalDepth := checkoutDepth
+	t.Cleanup(func() { checkoutDepth = originalDepth })
+	checkoutDepth = 0
+
+	t.Run("shallow", func(t *testing.T) {
+		clone := filepath.Join(tmpDir, "shallow")
+		runGitCommand(t, tmpDir, "clone", "-q", "--depth", "1", url, clone)
+		if err := os.Chdir(clone); err != nil {
+			t.Fatal(err)
+		}
+		if !isShallowRepo() || fetchesAllBranches() {
+			t.Fatalf("isShallowRepo() = %v, fetchesAllBranches() = %v; want a shallow single-branch clone", isShallowRepo(), fetchesAllBranches())
+		}
+		if branchExists("other") {
+			t.Fatal("other exists before it was fetched")
+		}
+		fetchMissingBranch("other")
+		if !gitRefExists("refs/remotes/origin/other") {
+			t.Fatal("other was not fetched")
+		}
+		if gitRefExists("refs/remotes/origin/other~1") {
+			t.Error("other was fetched with more than its tip")
+		}
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		clone := filepath.Join(tmpDir, "partial")
+		runGitCommand(t, tmpDir, "clone", "-q", "--filter=blob:none", url, clone)
+		if err := os.Chdir(clone); err != nil {
+			t.Fatal(err)
+		}
+		if remote := promisorRemote(); remote != "origin" {
+			t.Fatalf("promisorRemote() = %q, want origin", remote)
+		}
+		missing, err := missingObjects(checkoutRef("other"))
+		if err != nil || len(missing) == 0 {
+			t.Fatalf("missingObjects() = %v, %v; want the blob of other.txt", missing, err)
+		}
+		if err := prefetchObjects(checkoutRef("other")); err != nil {
+			t.Fatalf("prefetchObjects() error: %v", err)
+		}
+		if missing, _ := missingObjects(checkoutRef("other")); len(missing) != 0 {
+			t.Errorf("objects still missing after prefetch: %v", missing)
+		}
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		if err := os.Chdir(upstream); err != nil {
+			t.Fatal(err)
+		}
+		if isShallowRepo() || promisorRemote() != "" {
+			t.Error("a complete repository is reported as shallow or partial")
+		}
+	})
+}
@@ -108,3 +108,52 @@ func TestShellenvZshCompdefError(t *testing.T) {
 		t.Log("Warning: Shell function should be defined even when compdef is not available")
 	}
 }
+
+// TestShellenvExplicitShell tests that the shell argument picks the
+// integration regardless of the OS wt runs on, e.g. pwsh on Linux.
+func TestShellenvExplicitShell(t *testing.T) {
+	tests := []struct {
+		shell, want, notWant string
+	}{
+		{"pwsh", "function wt {", "wt() {"},
+		{"powershell", "Register-ArgumentCompleter", "compdef"},
+		{"bash", "wt() {", "function wt {"},
+		{"zsh", "compdef _wt_complete_zsh wt", "Register-ArgumentCompleter"},
+		{"tcsh", "alias wt '", "wt() {"},
+		{"csh", "WT_CD_FILE=$__wt_cd", "function wt {"},
+	}
+	for _, tt := range tests {
+		output, err := exec.Command("go", "run", ".", "shellenv", tt.shell).Output()
+		if err != nil {
+			t.Fatalf("wt shellenv %s failed: %v", tt.shell, err)
+		}
+		if !strings.Contains(string(output), tt.want) || strings.Contains(string(output), tt.notWant) {
+			t.Errorf("wt shellenv %s: want %q and not %q in output", tt.shell, tt.want, tt.notWant)
+		}
+	}
+
+	if err := exec.Command("go", "run", ".", "shellenv", "fish").Run(); err == nil {
+		t.Error("wt shellenv fish should fail")
+	}
+}
+
+// TestShellenvCompletions tests that --completions swaps the built-in
+// completion for cobra's, after the same wrapper.
+func TestShellenvCompletions(t *testing.T) {
+	tests := []struct {
+		shell, want, notWant string
+	}{
+		{"bash", "__start_wt", "_wt_complete()"},
+		{"zsh", "if (( $+functions[compdef] )); then\n#compdef wt", "_wt_complete_zsh"},
+		{"pwsh", "__wtCompleterBlock", "$commands = @("},
+	}
+	for _, tt := range tests {
+		output, err := exec.Command("go", "run", ".", "shellenv", tt.shell, "--completions").Output()
+		if err != nil {
+			t.Fatalf("wt shellenv %s --completions failed: %v", tt.shell, err)
+		}
+		if !strings.Contains(string(output), "__complete") || !strings.Contains(string(output), tt.want) || strings.Contains(string(output), tt.notWant) {
+			t.Errorf("wt shellenv %s --completions: want %q and not %q in output", tt.shell, tt.want, tt.notWant)
+		}
+	}
+}
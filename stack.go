@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var checkoutAfter string
+
+// The parent of a stacked branch is recorded in the repository's git
+// config, next to the branch's upstream: the parent branch and the commit
+// of it the branch was started from, which is what a restack rebases off.
+const (
+	parentConfigKey     = "wt-parent"
+	parentBaseConfigKey = "wt-parent-base"
+)
+
+// branchParent returns the recorded parent of branch and the parent commit
+// it was based on, or "" when branch is not stacked.
+func branchParent(branch string) (parent, base string) {
+	return gitConfigGet("branch." + branch + "." + parentConfigKey), gitConfigGet("branch." + branch + "." + parentBaseConfigKey)
+}
+
+// setBranchParent records parent as the parent of branch, based at commit.
+func setBranchParent(branch, parent, commit string) error {
+	for key, value := range map[string]string{parentConfigKey: parent, parentBaseConfigKey: commit} {
+		if output, err := gitCommand("config", "branch."+branch+"."+key, value).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to record the parent of %s: %s", branch, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// checkoutStacked creates branch from the tip of parent in a new worktree
+// and records parent, so the branch can be restacked when parent moves.
+func checkoutStacked(branch, parent, patchFile string) error {
+	if branchExists(branch) {
+		return fmt.Errorf("branch '%s' already exists\nUse 'wt checkout %s' to check it out", branch, branch)
+	}
+	if !gitRefExists("refs/heads/" + parent) {
+		var names []string
+		if refs, err := snapshot.BranchRefs(); err == nil {
+			for name := range refs {
+				names = append(names, name)
+			}
+		}
+		return fmt.Errorf("parent branch '%s' does not exist locally%s", parent, didYouMean(suggestNames(parent, names)))
+	}
+	output, err := gitCommand("rev-parse", "refs/heads/"+parent).Output()
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", parent, err)
+	}
+	commit := strings.TrimSpace(string(output))
+
+	err = checkoutWithPatch(branch, parent, patchFile)
+	// A conflicting patch keeps the new branch; it is stacked all the same.
+	if gitRefExists("refs/heads/" + branch) {
+		if recordErr := setBranchParent(branch, parent, commit); recordErr != nil && err == nil {
+			return recordErr
+		}
+	}
+	return err
+}
+
+var (
+	restackOnto     string
+	restackContinue bool
+	restackAbort    bool
+)
+
+// restackState is what a restack stopped by a conflict leaves behind for
+// --continue: the branch being rebased first, then the ones still to do.
+type restackState struct {
+	Branches []string `json:"branches"`
+}
+
+func restackStatePath() (string, error) {
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(commonDir, "wt-restack.json"), nil
+}
+
+func loadRestackState() (*restackState, error) {
+	path, err := restackStatePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &restackState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return state, nil
+}
+
+// saveRestackState writes the state, or removes it once nothing is left.
+func saveRestackState(state *restackState) error {
+	path, err := restackStatePath()
+	if err != nil {
+		return err
+	}
+	if state == nil || len(state.Branches) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// stackParents maps every stacked branch to its recorded parent.
+func stackParents() map[string]string {
+	parents := make(map[string]string)
+	output, _ := gitCommand("config", "--get-regexp", `^branch\..*\.`+parentConfigKey+`$`).Output()
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		branch := strings.TrimSuffix(strings.TrimPrefix(key, "branch."), "."+parentConfigKey)
+		parents[branch] = value
+	}
+	return parents
+}
+
+// stackOrder returns branch, when it has a parent, followed by every branch
+// stacked on it, directly or not, each after its parent.
+func stackOrder(branch string, parents map[string]string) []string {
+	children := make(map[string][]string)
+	for child, parent := range parents {
+		children[parent] = append(children[parent], child)
+	}
+	var order []string
+	if _, ok := parents[branch]; ok {
+		order = append(order, branch)
+	}
+	seen := map[string]bool{branch: true}
+	queue := []string{branch}
+	for len(queue) > 0 {
+		next := children[queue[0]]
+		queue = queue[1:]
+		sort.Strings(next)
+		for _, child := range next {
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			order = append(order, child)
+			queue = append(queue, child)
+		}
+	}
+	return order
+}
+
+// branchWorktree returns the path of the worktree branch is checked out in.
+func branchWorktree(branch string) (string, bool) {
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return "", false
+	}
+	for _, e := range entries {
+		if e.Branch == branch {
+			return e.Path, true
+		}
+	}
+	return "", false
+}
+
+func revParse(ref string) (string, error) {
+	output, err := gitCommand("rev-parse", "--verify", "--quiet", ref+"^{commit}").Output()
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %s", ref)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// rebaseInProgress reports whether the worktree at dir is in the middle of
+// a rebase.
+func rebaseInProgress(dir string) bool {
+	for _, name := range []string{"rebase-merge", "rebase-apply"} {
+		output, err := gitCommand("-C", dir, "rev-parse", "--git-path", name).Output()
+		if err != nil {
+			continue
+		}
+		path := strings.TrimSpace(string(output))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// restackBranch rebases branch onto the tip of its parent in its worktree.
+// Only the commits made since the recorded parent base are moved, so
+// commits the parent dropped or rewrote don't come back.
+func restackBranch(branch string) error {
+	parent, base := branchParent(branch)
+	dir, ok := branchWorktree(branch)
+	if !ok {
+		return fmt.Errorf("%s has no worktree; check it out with 'wt checkout %s' to restack it", branch, branch)
+	}
+	tip, err := revParse("refs/heads/" + parent)
+	if err != nil {
+		return fmt.Errorf("parent %s of %s: %w", parent, branch, err)
+	}
+	if base == tip {
+		infof("%s is up to date with %s\n", branch, parent)
+		return nil
+	}
+
+	args := []string{"-C", dir, "rebase"}
+	if base != "" && gitCommand("cat-file", "-e", base+"^{commit}").Run() == nil {
+		args = append(args, "--onto", tip, base)
+	} else {
+		args = append(args, tip)
+	}
+	infof("Rebasing %s onto %s\n", branch, parent)
+	gitCmd := gitCommand(args...)
+	gitCmd.Stdout = gitOutput()
+	gitCmd.Stderr = os.Stderr
+	if err := gitCmd.Run(); err != nil {
+		return restackStopped(branch, dir)
+	}
+	return setBranchParent(branch, parent, tip)
+}
+
+func restackStopped(branch, dir string) error {
+	if !rebaseInProgress(dir) {
+		return fmt.Errorf("failed to rebase %s in %s", branch, dir)
+	}
+	msg := fmt.Sprintf("conflict while rebasing %s in %s", branch, dir)
+	if files := conflictedFiles(dir); len(files) > 0 {
+		msg += "\nConflicts in:\n  " + strings.Join(files, "\n  ")
+	}
+	return fmt.Errorf("%s\nResolve them, 'git add' the files and run 'wt restack --continue' (or 'wt restack --abort')", msg)
+}
+
+// runRestack rebases branches in order, saving the rest when one stops.
+func runRestack(branches []string) error {
+	for i, branch := range branches {
+		if err := restackBranch(branch); err != nil {
+			if dir, ok := branchWorktree(branch); ok && rebaseInProgress(dir) {
+				if saveErr := saveRestackState(&restackState{Branches: branches[i:]}); saveErr != nil {
+					warnf("warning: failed to save restack state: %v\n", saveErr)
+				}
+			}
+			return err
+		}
+	}
+	if err := saveRestackState(nil); err != nil {
+		return err
+	}
+	successf("Restacked %d branch(es)", len(branches))
+	return nil
+}
+
+// continueRestack finishes the rebase that stopped and restacks the rest.
+func continueRestack(state *restackState) error {
+	branch := state.Branches[0]
+	parent, _ := branchParent(branch)
+	dir, ok := branchWorktree(branch)
+	if !ok {
+		return fmt.Errorf("%s has no worktree anymore; 'wt restack --abort' to give up", branch)
+	}
+	if rebaseInProgress(dir) {
+		gitCmd := gitCommand("-C", dir, "rebase", "--continue")
+		gitCmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+		gitCmd.Stdout = gitOutput()
+		gitCmd.Stderr = os.Stderr
+		if err := gitCmd.Run(); err != nil {
+			return restackStopped(branch, dir)
+		}
+	}
+	tip, err := revParse("refs/heads/" + parent)
+	if err != nil {
+		return err
+	}
+	if err := setBranchParent(branch, parent, tip); err != nil {
+		return err
+	}
+	return runRestack(state.Branches[1:])
+}
+
+var restackCmd = &cobra.Command{
+	Use:   "restack [branch]",
+	Short: "Rebase a stack of branches onto their parents",
+	Long: `Rebase a branch onto its parent and every branch stacked on it onto theirs,
+parents first, each in its own worktree.
+
+Parents are recorded by 'wt checkout --after'; --onto sets (or changes) the
+parent of the branch. Without a branch, the current one is restacked.
+
+Only the commits a branch added on top of its parent are rebased. When a
+rebase conflicts, wt stops in that worktree; resolve the conflicts, 'git
+add' the files and run 'wt restack --continue', or 'wt restack --abort'.
+
+Examples:
+  wt restack                          # After amending the current branch
+  wt restack feature-x                # Everything stacked on feature-x
+  wt restack feature-x-2 --onto main  # Move feature-x-2 off feature-x
+  wt restack --continue`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := loadRestackState()
+		if err != nil {
+			return err
+		}
+		if restackContinue || restackAbort {
+			if len(args) > 0 || restackOnto != "" {
+				return fmt.Errorf("--continue and --abort take no other arguments")
+			}
+			if state == nil {
+				return fmt.Errorf("no restack in progress")
+			}
+			cmd.SilenceUsage = true
+			if restackContinue {
+				return continueRestack(state)
+			}
+			if dir, ok := branchWorktree(state.Branches[0]); ok && rebaseInProgress(dir) {
+				gitCmd := gitCommand("-C", dir, "rebase", "--abort")
+				gitCmd.Stdout = gitOutput()
+				gitCmd.Stderr = os.Stderr
+				if err := gitCmd.Run(); err != nil {
+					return fmt.Errorf("failed to abort the rebase in %s: %w", dir, err)
+				}
+			}
+			if err := saveRestackState(nil); err != nil {
+				return err
+			}
+			successf("Aborted restacking %s", state.Branches[0])
+			return nil
+		}
+		if state != nil {
+			return fmt.Errorf("a restack is in progress at %s\nUse 'wt restack --continue' or 'wt restack --abort'", state.Branches[0])
+		}
+
+		var branch string
+		if len(args) > 0 {
+			branch = args[0]
+		} else {
+			output, err := gitCommand("branch", "--show-current").Output()
+			if branch = strings.TrimSpace(string(output)); err != nil || branch == "" {
+				return fmt.Errorf("not on a branch; name the branch to restack")
+			}
+		}
+		if !gitRefExists("refs/heads/" + branch) {
+			return fmt.Errorf("branch '%s' does not exist locally", branch)
+		}
+
+		if restackOnto != "" {
+			if !gitRefExists("refs/heads/" + restackOnto) {
+				return fmt.Errorf("branch '%s' does not exist locally", restackOnto)
+			}
+			// Keep the old base so only the branch's own commits move; for a
+			// branch without one, that's where it forked off the new parent.
+			_, base := branchParent(branch)
+			if base == "" {
+				output, err := gitCommand("merge-base", restackOnto, branch).Output()
+				if err != nil {
+					return fmt.Errorf("%s and %s have no common history", branch, restackOnto)
+				}
+				base = strings.TrimSpace(string(output))
+			}
+			if err := setBranchParent(branch, restackOnto, base); err != nil {
+				return err
+			}
+		}
+
+		branches := stackOrder(branch, stackParents())
+		if len(branches) == 0 {
+			return fmt.Errorf("%s has no recorded parent and nothing is stacked on it\nUse --onto <parent>, or create stacked branches with 'wt checkout --after'", branch)
+		}
+		cmd.SilenceUsage = true
+		return runRestack(branches)
+	},
+}
+
+func init() {
+	restackCmd.Flags().StringVar(&restackOnto, "onto", "", "Make this branch the parent of the restacked branch")
+	restackCmd.Flags().BoolVar(&restackContinue, "continue", false, "Continue after resolving a conflict")
+	restackCmd.Flags().BoolVar(&restackAbort, "abort", false, "Abort the rebase that stopped and end the restack")
+	rootCmd.AddCommand(restackCmd)
+}
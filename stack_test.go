@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckoutStacked(t *testing.T) {
+	originalRoot, originalStrategy, originalPattern := worktreeRoot, worktreeStrategy, worktreePattern
+	t.Cleanup(func() {
+		worktreeRoot, worktreeStrategy, worktreePattern = originalRoot, originalStrategy, originalPattern
+	})
+	tmpDir := t.TempDir()
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	worktreeStrategy = "global"
+	worktreePattern = ""
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "checkout", "-q", "-b", "part1")
+	runGitCommand(t, repoDir, "commit", "--allow-empty", "-m", "part 1")
+	runGitCommand(t, repoDir, "checkout", "-q", "main")
+	tip, err := exec.Command("git", "-C", repoDir, "rev-parse", "part1").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkoutStacked("part2", "part1", ""); err != nil {
+		t.Fatalf("checkoutStacked() error: %v", err)
+	}
+	parent, base := branchParent("part2")
+	if parent != "part1" || base != strings.TrimSpace(string(tip)) {
+		t.Errorf("branchParent(part2) = %q, %q; want part1, %s", parent, base, tip)
+	}
+	head, _ := exec.Command("git", "rev-parse", "part2").Output()
+	if string(head) != string(tip) {
+		t.Errorf("part2 starts at %s, want the tip of part1 %s", head, tip)
+	}
+
+	if err := checkoutStacked("part2", "main", ""); err == nil {
+		t.Error("checkoutStacked() of an existing branch succeeded")
+	}
+	if parent, _ := branchParent("part2"); parent != "part1" {
+		t.Errorf("parent of part2 changed to %q by a failed checkout", parent)
+	}
+	if err := checkoutStacked("part3", "prat1", ""); err == nil || !strings.Contains(err.Error(), "part1") {
+		t.Errorf("checkoutStacked() with a misspelled parent = %v, want a suggestion of part1", err)
+	}
+}
+
+func TestStackOrder(t *testing.T) {
+	parents := map[string]string{"b": "a", "c": "b", "d": "b", "x": "main"}
+	if got := strings.Join(stackOrder("a", parents), " "); got != "b c d" {
+		t.Errorf("stackOrder(a) = %q, want %q", got, "b c d")
+	}
+	if got := strings.Join(stackOrder("b", parents), " "); got != "b c d" {
+		t.Errorf("stackOrder(b) = %q, want %q", got, "b c d")
+	}
+	if got := stackOrder("c", map[string]string{}); len(got) != 0 {
+		t.Errorf("stackOrder() without parents = %v, want none", got)
+	}
+}
+
+func TestRestack(t *testing.T) {
+	originalRoot, originalStrategy, originalPattern := worktreeRoot, worktreeStrategy, worktreePattern
+	t.Cleanup(func() {
+		worktreeRoot, worktreeStrategy, worktreePattern = originalRoot, originalStrategy, originalPattern
+	})
+	tmpDir := t.TempDir()
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	worktreeStrategy = "global"
+	worktreePattern = ""
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	commit := func(dir, file, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		runGitCommand(t, dir, "add", file)
+		runGitCommand(t, dir, "commit", "-q", "-m", "change "+file)
+	}
+	runGitCommand(t, repoDir, "checkout", "-q", "-b", "part1")
+	commit(repoDir, "one.txt", "1\n")
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkoutStacked("part2", "part1", ""); err != nil {
+		t.Fatal(err)
+	}
+	part2, _ := branchWorktree("part2")
+	commit(part2, "two.txt", "2\n")
+	if err := checkoutStacked("part3", "part2", ""); err != nil {
+		t.Fatal(err)
+	}
+	part3, _ := branchWorktree("part3")
+	commit(part3, "three.txt", "3\n")
+
+	isAncestor := func(ancestor, branch string) bool {
+		return exec.Command("git", "merge-base", "--is-ancestor", ancestor, branch).Run() == nil
+	}
+
+	// part1 moves on; the whole stack follows.
+	commit(repoDir, "one.txt", "1 amended\n")
+	if err := runRestack(stackOrder("part1", stackParents())); err != nil {
+		t.Fatalf("runRestack() error: %v", err)
+	}
+	if !isAncestor("part1", "part2") || !isAncestor("part2", "part3") {
+		t.Error("stack was not rebased onto the new tip of part1")
+	}
+	tip, _ := revParse("refs/heads/part1")
+	if _, base := branchParent("part2"); base != tip {
+		t.Errorf("parent base of part2 = %s, want the new tip of part1 %s", base, tip)
+	}
+
+	// A conflict stops the restack until it is resolved.
+	commit(repoDir, "two.txt", "conflicting\n")
+	err := runRestack(stackOrder("part1", stackParents()))
+	if err == nil || !strings.Contains(err.Error(), "two.txt") {
+		t.Fatalf("runRestack() = %v, want a conflict in two.txt", err)
+	}
+	state, err := loadRestackState()
+	if err != nil || state == nil || strings.Join(state.Branches, " ") != "part2 part3" {
+		t.Fatalf("restack state = %+v, %v; want part2 part3", state, err)
+	}
+	commit(part2, "two.txt", "resolved\n")
+	if err := continueRestack(state); err != nil {
+		t.Fatalf("continueRestack() error: %v", err)
+	}
+	if state, _ := loadRestackState(); state != nil {
+		t.Errorf("restack state %+v left after finishing", state)
+	}
+	if !isAncestor("part1", "part2") || !isAncestor("part2", "part3") {
+		t.Error("stack was not rebased after continuing")
+	}
+}
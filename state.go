@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// wtState is wt's own bookkeeping that does not belong in any repository,
+// such as when a worktree was last visited through wt.
+type wtState struct {
+	Visits   map[string]time.Time    `json:"visits"`
+	Sessions map[string]shellSession `json:"sessions,omitempty"`
+	HookRuns map[string][]hookRun    `json:"hook_runs,omitempty"`
+	// Repos are the common git directories of the repositories wt was used
+	// in, with when that was last noted.
+	Repos map[string]time.Time `json:"repos,omitempty"`
+}
+
+// shellSession is where one shell went through wt: the worktree it is in and
+// the one it came from, for 'wt -'.
+type shellSession struct {
+	Current  string    `json:"current"`
+	Previous string    `json:"previous,omitempty"`
+	Updated  time.Time `json:"updated"`
+}
+
+// stateDir returns the directory for wt's persistent per-user state.
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "wt")
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+			return filepath.Join(dir, "wt")
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "wt-state")
+	}
+	return filepath.Join(home, ".local", "state", "wt")
+}
+
+func statePath() string {
+	return filepath.Join(stateDir(), "state.json")
+}
+
+// loadState reads the state file. A missing or unreadable file yields an
+// empty state: losing it only costs history, never correctness.
+func loadState() *wtState {
+	state := &wtState{}
+	if data, err := os.ReadFile(statePath()); err == nil {
+		_ = json.Unmarshal(data, state)
+	}
+	if state.Visits == nil {
+		state.Visits = make(map[string]time.Time)
+	}
+	if state.Sessions == nil {
+		state.Sessions = make(map[string]shellSession)
+	}
+	if state.HookRuns == nil {
+		state.HookRuns = make(map[string][]hookRun)
+	}
+	if state.Repos == nil {
+		state.Repos = make(map[string]time.Time)
+	}
+	return state
+}
+
+// save writes the state atomically so concurrent wt processes never observe
+// a half-written file.
+func (s *wtState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(statePath(), data)
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory, named after path, and a rename. On a network filesystem,
+// where a rename over an existing file is not always atomic or allowed
+// (SMB refuses it while another client has the file open), the data is
+// synced first and a failed rename falls back to writing path in place.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	ext := filepath.Ext(path)
+	tmp, err := os.CreateTemp(filepath.Dir(path), strings.TrimSuffix(filepath.Base(path), ext)+"-*"+ext)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	network := networkFS(filepath.Dir(path)) != ""
+	if network {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil && network {
+		os.Remove(tmp.Name())
+		return os.WriteFile(path, data, 0o644)
+	}
+	return err
+}
+
+// recordVisit remembers that the user navigated to path through wt.
+func recordVisit(path string) {
+	state := loadState()
+	state.Visits[resolvePath(path)] = time.Now()
+	state.enterWorktree(sessionKey(), resolvePath(path), startWorktree())
+	_ = state.save()
+}
+
+// lastVisit returns when path was last visited through wt.
+func (s *wtState) lastVisit(path string) time.Time {
+	return s.Visits[resolvePath(path)]
+}
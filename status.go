@@ -0,0 +1,529 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// worktreeEntry is one record of `git worktree list --porcelain`.
+type worktreeEntry struct {
+	Path       string
+	Head       string
+	Branch     string // short branch name, empty when detached or bare
+	Bare       bool
+	Detached   bool
+	Locked     bool
+	LockReason string
+	Prunable   bool
+	VCS        string // "jj" for the main worktree of a jj repository, see vcs
+}
+
+// branchRef holds what a single `git for-each-ref` call reports about a branch.
+type branchRef struct {
+	Name         string
+	Upstream     string
+	Ahead        int
+	Behind       int
+	UpstreamGone bool
+	CommitTime   time.Time
+}
+
+// worktreeStatus combines a worktree with its branch and working tree state.
+type worktreeStatus struct {
+	worktreeEntry
+	Upstream     string
+	Ahead        int
+	Behind       int
+	UpstreamGone bool
+	LastCommit   time.Time
+	Staged       int
+	Unstaged     int
+	Untracked    int
+	Conflicted   int
+	StatusError  string
+}
+
+// Dirty reports whether the worktree has any local changes.
+func (s worktreeStatus) Dirty() bool {
+	return s.Staged+s.Unstaged+s.Untracked+s.Conflicted > 0
+}
+
+// repoSnapshot caches parsed git output for the duration of one wt
+// invocation, so commands that need the same data repeatedly (or from
+// several helpers) only spawn each git process once.
+type repoSnapshot struct {
+	mu        sync.Mutex
+	dir       string
+	worktrees []worktreeEntry
+	refs      map[string]branchRef
+}
+
+var snapshot repoSnapshot
+
+// current returns the snapshot, discarding cached data if the working
+// directory changed since it was filled.
+func (s *repoSnapshot) current() *repoSnapshot {
+	cwd, _ := os.Getwd()
+	if s.dir != cwd {
+		s.dir = cwd
+		s.worktrees = nil
+		s.refs = nil
+	}
+	return s
+}
+
+// invalidate drops cached data after wt changed the repository.
+func (s *repoSnapshot) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.worktrees = nil
+	s.refs = nil
+}
+
+// Worktrees returns all worktrees of the repository, main worktree first.
+func (s *repoSnapshot) Worktrees() ([]worktreeEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current()
+	if s.worktrees != nil {
+		return s.worktrees, nil
+	}
+
+	output, err := gitCommand("worktree", "list", "--porcelain").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees: %w", err)
+	}
+	s.worktrees = parseWorktreePorcelain(string(output))
+	fixSubmoduleMain(s.worktrees)
+	markVCS(s.worktrees)
+	return s.worktrees, nil
+}
+
+// BranchRefs returns upstream tracking and commit data for all local branches.
+func (s *repoSnapshot) BranchRefs() (map[string]branchRef, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current()
+	if s.refs != nil {
+		return s.refs, nil
+	}
+
+	output, err := gitCommand("for-each-ref",
+		"--format=%(refname:short)%00%(upstream:short)%00%(upstream:track)%00%(committerdate:unix)",
+		"refs/heads").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read branches: %w", err)
+	}
+	s.refs = parseBranchRefs(string(output))
+	return s.refs, nil
+}
+
+func parseWorktreePorcelain(output string) []worktreeEntry {
+	var entries []worktreeEntry
+	var current *worktreeEntry
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "worktree":
+			entries = append(entries, worktreeEntry{Path: filepath.FromSlash(value)})
+			current = &entries[len(entries)-1]
+		case "HEAD":
+			if current != nil {
+				current.Head = value
+			}
+		case "branch":
+			if current != nil {
+				current.Branch = strings.TrimPrefix(value, "refs/heads/")
+			}
+		case "bare":
+			if current != nil {
+				current.Bare = true
+			}
+		case "detached":
+			if current != nil {
+				current.Detached = true
+			}
+		case "locked":
+			if current != nil {
+				current.Locked = true
+				current.LockReason = value
+			}
+		case "prunable":
+			if current != nil {
+				current.Prunable = true
+			}
+		}
+	}
+	return entries
+}
+
+func parseBranchRefs(output string) map[string]branchRef {
+	refs := make(map[string]branchRef)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\x00")
+		if len(fields) != 4 || fields[0] == "" {
+			continue
+		}
+		ref := branchRef{Name: fields[0], Upstream: fields[1]}
+		ref.Ahead, ref.Behind, ref.UpstreamGone = parseUpstreamTrack(fields[2])
+		if ts, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+			ref.CommitTime = time.Unix(ts, 0)
+		}
+		refs[ref.Name] = ref
+	}
+	return refs
+}
+
+// parseUpstreamTrack parses %(upstream:track) values such as
+// "[ahead 2, behind 1]" or "[gone]".
+func parseUpstreamTrack(track string) (ahead, behind int, gone bool) {
+	track = strings.Trim(strings.TrimSpace(track), "[]")
+	if track == "gone" {
+		return 0, 0, true
+	}
+	for _, part := range strings.Split(track, ",") {
+		name, count, ok := strings.Cut(strings.TrimSpace(part), " ")
+		if !ok {
+			continue
+		}
+		n, _ := strconv.Atoi(count)
+		switch name {
+		case "ahead":
+			ahead = n
+		case "behind":
+			behind = n
+		}
+	}
+	return ahead, behind, gone
+}
+
+// parseStatusPorcelainV2 counts changes in `git status --porcelain=v2` output.
+func parseStatusPorcelainV2(output string, st *worktreeStatus) {
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case '1', '2':
+			// "1 XY ..." where X is the staged and Y the unstaged state
+			if len(line) < 4 {
+				continue
+			}
+			if line[2] != '.' {
+				st.Staged++
+			}
+			if line[3] != '.' {
+				st.Unstaged++
+			}
+		case 'u':
+			st.Conflicted++
+		case '?':
+			st.Untracked++
+		}
+	}
+}
+
+// collectWorktreeStatus gathers status for all worktrees using one
+// `worktree list`, one `for-each-ref` and one `status` per worktree, with the
+// status calls running in parallel.
+func collectWorktreeStatus(withWorkingTree bool) ([]worktreeStatus, error) {
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := snapshot.BranchRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]worktreeStatus, len(entries))
+	for i, entry := range entries {
+		st := worktreeStatus{worktreeEntry: entry}
+		if ref, ok := refs[entry.Branch]; ok {
+			st.Upstream = ref.Upstream
+			st.Ahead = ref.Ahead
+			st.Behind = ref.Behind
+			st.UpstreamGone = ref.UpstreamGone
+			st.LastCommit = ref.CommitTime
+		}
+		statuses[i] = st
+	}
+
+	if !withWorkingTree {
+		return statuses, nil
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				st := &statuses[i]
+				output, err := gitCommand("-C", st.Path, "status", "--porcelain=v2").Output()
+				if err != nil {
+					st.StatusError = err.Error()
+					continue
+				}
+				parseStatusPorcelainV2(string(output), st)
+			}
+		}()
+	}
+	for i, st := range statuses {
+		// Bare and missing worktrees have no working tree to inspect.
+		if st.Bare || st.Prunable {
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return statuses, nil
+}
+
+// formatAge renders a duration the way humans talk about branch age.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 14*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	case d < 60*24*time.Hour:
+		return fmt.Sprintf("%dw ago", int(d.Hours()/(24*7)))
+	default:
+		return fmt.Sprintf("%dmo ago", int(d.Hours()/(24*30)))
+	}
+}
+
+func describeChanges(st worktreeStatus) string {
+	switch {
+	case st.Bare:
+		return "bare"
+	case st.Prunable:
+		return "missing"
+	case st.StatusError != "":
+		return "error"
+	case !st.Dirty():
+		return "clean"
+	}
+	var parts []string
+	if st.Conflicted > 0 {
+		parts = append(parts, fmt.Sprintf("%d conflicted", st.Conflicted))
+	}
+	if st.Staged > 0 {
+		parts = append(parts, fmt.Sprintf("%d staged", st.Staged))
+	}
+	if st.Unstaged > 0 {
+		parts = append(parts, fmt.Sprintf("%d modified", st.Unstaged))
+	}
+	if st.Untracked > 0 {
+		parts = append(parts, fmt.Sprintf("%d untracked", st.Untracked))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func describeUpstream(st worktreeStatus) string {
+	switch {
+	case st.UpstreamGone:
+		return st.Upstream + " (gone)"
+	case st.Upstream == "":
+		return "-"
+	case st.Ahead == 0 && st.Behind == 0:
+		return st.Upstream + " (up to date)"
+	}
+	return fmt.Sprintf("%s (+%d/-%d)", st.Upstream, st.Ahead, st.Behind)
+}
+
+func branchLabel(st worktreeStatus) string {
+	switch {
+	case st.Branch != "":
+		return st.Branch
+	case st.Bare:
+		return "(bare)"
+	case len(st.Head) >= 7:
+		return "(detached " + st.Head[:7] + ")"
+	}
+	return "(detached)"
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show status of all worktrees",
+	Long: `Show branch, local changes, upstream and last commit for every worktree.
+
+Data is gathered with a single 'git worktree list' and 'git for-each-ref'
+call plus one 'git status' per worktree, run in parallel.
+
+Worktrees without commits or visits within the stale threshold (config key
+stale, default 30d) are marked "(stale)"; --stale shows only those. Tickets,
+tags and descriptions noted with 'wt describe' and 'wt tag' are shown as a
+NOTE; --tag shows only worktrees with a tag.
+
+--checks adds a CI column with the state of the checks (gh) or the latest
+pipeline (glab) of each branch's head, for an origin on GitHub or GitLab:
+passed, failed, running, no checks, or not pushed when the head is not on
+the remote. The code host is asked about all heads at once, and the answers
+are cached: finished results for 10 minutes, others for a minute.
+
+--watch keeps the table on screen as a dashboard, e.g. in a tmux pane, until
+Ctrl-C. It refreshes as soon as git records a commit, checkout, 'git add'
+or worktree change, and every --interval (default 2s) for edits that are
+not staged yet.
+
+` + porcelainHelp + `
+
+status adds:
+` + porcelainStatusHelp + `
+
+Examples:
+  wt status --stale
+  wt status --checks                # Which branches are green?
+  wt status --watch --checks
+  wt status --porcelain | awk '/^worktree / {path = substr($0, 10)} /^untracked [1-9]/ {print path}'`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if statusZ {
+			statusPorcelain = true
+		}
+		if statusWatch {
+			if statusPorcelain {
+				return fmt.Errorf("--watch cannot be combined with --porcelain")
+			}
+			return watchStatus()
+		}
+		if statusChecks && statusPorcelain {
+			return fmt.Errorf("--checks cannot be combined with --porcelain")
+		}
+		items, err := collectStatusItems()
+		if err != nil {
+			return err
+		}
+		if statusChecks {
+			commonDir, err := gitCommonDir()
+			if err != nil {
+				return err
+			}
+			checks, err := newCIChecks(commonDir)
+			if err != nil {
+				return err
+			}
+			states := checks.get(items, time.Now())
+			if checks.err != nil {
+				warnf("warning: CI: %v\n", checks.err)
+			}
+			return printStatusTable(os.Stdout, items, states, time.Now())
+		}
+		if statusPorcelain {
+			p := newPorcelainWriter(os.Stdout, statusZ)
+			for _, item := range items {
+				p.item(item, true)
+			}
+			return p.flush()
+		}
+		return printStatusTable(os.Stdout, items, nil, time.Now())
+	},
+}
+
+// collectStatusItems gathers the status of every worktree that --stale and
+// --tag let through.
+func collectStatusItems() ([]listItem, error) {
+	statuses, err := collectWorktreeStatus(true)
+	if err != nil {
+		return nil, err
+	}
+
+	state := loadState()
+	meta := loadMeta()
+	threshold := staleAfter()
+	now := time.Now()
+	var items []listItem
+	for _, st := range statuses {
+		item := listItem{worktreeStatus: st, LastVisit: state.lastVisit(st.Path), Meta: meta.Branches[st.Branch]}
+		item.Stale = !st.Bare && isStale(item, now, threshold)
+		if statusStale && !item.Stale {
+			continue
+		}
+		if statusTag != "" && !item.Meta.hasTag(statusTag) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// printStatusTable renders the status table; checks adds a CI column with
+// the state of each branch's checks when it is not nil.
+func printStatusTable(out io.Writer, items []listItem, checks map[string]string, now time.Time) error {
+	var rows [][]string
+	withNotes := false
+	for _, item := range items {
+		age := "-"
+		if !item.LastCommit.IsZero() {
+			age = formatAge(now.Sub(item.LastCommit))
+		}
+		if item.Stale {
+			age += " (stale)"
+		}
+		withNotes = withNotes || !item.Meta.empty()
+		ci := checks[item.Branch]
+		if ci == "" {
+			ci = "-"
+		}
+		rows = append(rows, []string{branchLabel(item.worktreeStatus), describeChanges(item.worktreeStatus), describeUpstream(item.worktreeStatus), ci, age, item.Meta.label(), item.Path})
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := []string{"BRANCH", "CHANGES", "UPSTREAM", "CI", "LAST COMMIT", "NOTE", "PATH"}
+	for _, row := range append([][]string{header}, rows...) {
+		if withNotes && row[5] == "" {
+			row[5] = "-"
+		}
+		var cols []string
+		for i, col := range row {
+			if (i == 3 && checks == nil) || (i == 5 && !withNotes) {
+				continue
+			}
+			cols = append(cols, col)
+		}
+		fmt.Fprintln(w, strings.Join(cols, "\t"))
+	}
+	return w.Flush()
+}
+
+var (
+	statusStale     bool
+	statusTag       string
+	statusPorcelain bool
+	statusZ         bool
+	statusWatch     bool
+	statusInterval  time.Duration
+	statusChecks    bool
+)
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusStale, "stale", false, "Only show stale worktrees")
+	statusCmd.Flags().StringVar(&statusTag, "tag", "", "Only show worktrees with this tag (see 'wt tag')")
+	statusCmd.Flags().BoolVar(&statusPorcelain, "porcelain", false, "Print a stable format for scripts: key-value lines per worktree")
+	statusCmd.Flags().BoolVarP(&statusZ, "null", "z", false, "With --porcelain, end lines with NUL instead of newline (implies --porcelain)")
+	statusCmd.Flags().BoolVarP(&statusWatch, "watch", "w", false, "Keep the table on screen and refresh it, e.g. in a tmux pane (Ctrl-C to stop)")
+	statusCmd.Flags().DurationVar(&statusInterval, "interval", 2*time.Second, "With --watch, how often to refresh at the latest")
+	statusCmd.Flags().BoolVar(&statusChecks, "checks", false, "Add a CI column with the checks of each branch's head on GitHub or GitLab (needs gh or glab)")
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseWorktreePorcelain(t *testing.T) {
+	output := `worktree /src/repo
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /worktrees/repo/feature/x
+HEAD 2222222222222222222222222222222222222222
+branch refs/heads/feature/x
+locked
+
+worktree /worktrees/repo/detached
+HEAD 3333333333333333333333333333333333333333
+detached
+prunable gitdir file points to non-existent location
+`
+	entries := parseWorktreePorcelain(output)
+	if len(entries) != 3 {
+		t.Fatalf("parseWorktreePorcelain() returned %d entries, want 3", len(entries))
+	}
+	if entries[0].Branch != "main" || entries[0].Path != filepath.FromSlash("/src/repo") {
+		t.Errorf("entries[0] = %+v", entries[0])
+	}
+	if entries[1].Branch != "feature/x" || !entries[1].Locked {
+		t.Errorf("entries[1] = %+v", entries[1])
+	}
+	if !entries[2].Detached || !entries[2].Prunable || entries[2].Branch != "" {
+		t.Errorf("entries[2] = %+v", entries[2])
+	}
+}
+
+func TestParseUpstreamTrack(t *testing.T) {
+	tests := []struct {
+		track                 string
+		wantAhead, wantBehind int
+		wantGone              bool
+	}{
+		{"", 0, 0, false},
+		{"[ahead 2]", 2, 0, false},
+		{"[behind 3]", 0, 3, false},
+		{"[ahead 1, behind 4]", 1, 4, false},
+		{"[gone]", 0, 0, true},
+	}
+	for _, tt := range tests {
+		ahead, behind, gone := parseUpstreamTrack(tt.track)
+		if ahead != tt.wantAhead || behind != tt.wantBehind || gone != tt.wantGone {
+			t.Errorf("parseUpstreamTrack(%q) = %d, %d, %v; want %d, %d, %v",
+				tt.track, ahead, behind, gone, tt.wantAhead, tt.wantBehind, tt.wantGone)
+		}
+	}
+}
+
+func TestParseBranchRefs(t *testing.T) {
+	output := "main\x00origin/main\x00[behind 1]\x001700000000\n" +
+		"feature\x00\x00\x001700000100\n"
+	refs := parseBranchRefs(output)
+	if len(refs) != 2 {
+		t.Fatalf("parseBranchRefs() returned %d refs, want 2", len(refs))
+	}
+	if refs["main"].Upstream != "origin/main" || refs["main"].Behind != 1 {
+		t.Errorf("refs[main] = %+v", refs["main"])
+	}
+	if !refs["feature"].CommitTime.Equal(time.Unix(1700000100, 0)) {
+		t.Errorf("refs[feature].CommitTime = %v", refs["feature"].CommitTime)
+	}
+}
+
+func TestParseStatusPorcelainV2(t *testing.T) {
+	output := "1 M. N... 100644 100644 100644 abc abc staged.txt\n" +
+		"1 .M N... 100644 100644 100644 abc abc modified.txt\n" +
+		"1 MM N... 100644 100644 100644 abc abc both.txt\n" +
+		"u UU N... 100644 100644 100644 100644 a b c conflict.txt\n" +
+		"? untracked.txt\n"
+	var st worktreeStatus
+	parseStatusPorcelainV2(output, &st)
+	if st.Staged != 2 || st.Unstaged != 2 || st.Conflicted != 1 || st.Untracked != 1 {
+		t.Errorf("parseStatusPorcelainV2() = %+v", st)
+	}
+	if !st.Dirty() {
+		t.Error("expected worktree with changes to be dirty")
+	}
+}
+
+func TestCollectWorktreeStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "branch", "feature")
+	featurePath := filepath.Join(tmpDir, "feature")
+	runGitCommand(t, repoDir, "worktree", "add", featurePath, "feature")
+	if err := os.WriteFile(filepath.Join(featurePath, "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+
+	statuses, err := collectWorktreeStatus(true)
+	if err != nil {
+		t.Fatalf("collectWorktreeStatus() error: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("collectWorktreeStatus() returned %d worktrees, want 2", len(statuses))
+	}
+	if statuses[0].Branch != "main" || statuses[0].Dirty() {
+		t.Errorf("main worktree status = %+v", statuses[0])
+	}
+	if statuses[1].Branch != "feature" || statuses[1].Untracked != 1 {
+		t.Errorf("feature worktree status = %+v", statuses[1])
+	}
+	if statuses[1].LastCommit.IsZero() {
+		t.Error("expected last commit time to be populated from for-each-ref")
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "just now"},
+		{5 * time.Minute, "5m ago"},
+		{3 * time.Hour, "3h ago"},
+		{2 * 24 * time.Hour, "2d ago"},
+		{21 * 24 * time.Hour, "3w ago"},
+		{90 * 24 * time.Hour, "3mo ago"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	checkoutSubmodule string
+	removeSubmodule   string
+	listSubmodule     string
+)
+
+// enterSubmodule makes the submodule checked out at path the repository wt
+// works on, for --submodule: the superproject's worktrees are not touched,
+// and the submodule's own config applies. path is relative to the current
+// directory or to the top of the superproject, as in .gitmodules.
+func enterSubmodule(path string) error {
+	dir := path
+	if !filepath.IsAbs(dir) {
+		if _, err := os.Stat(longPath(dir)); err != nil {
+			output, err := gitCommand("rev-parse", "--show-toplevel").Output()
+			if err != nil {
+				return fmt.Errorf("not in a git repository")
+			}
+			dir = filepath.Join(strings.TrimSpace(string(output)), path)
+		}
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	notSubmodule := fmt.Errorf("%s is not a checked out submodule\nUse 'git submodule update --init %s' to check it out", path, path)
+	output, err := gitCommand("-C", dir, "rev-parse", "--show-superproject-working-tree", "--show-toplevel").Output()
+	if err != nil {
+		return notSubmodule
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	// A plain directory of the superproject has no superproject of its own.
+	if len(lines) != 2 || !samePath(lines[1], dir) {
+		return notSubmodule
+	}
+
+	if err := os.Chdir(longPath(dir)); err != nil {
+		return err
+	}
+	snapshot.invalidate()
+	loadWorktreeConfig()
+	return applyGitTimeoutFlag()
+}
+
+// submoduleWorktree returns the checkout of the submodule whose git
+// directory is gitDir. git keeps submodule repositories in the
+// superproject's .git/modules and points them at their checkout with
+// core.worktree; 'git worktree list' names the git directory instead.
+func submoduleWorktree(gitDir string) (string, bool) {
+	for _, name := range []string{"HEAD", "config"} {
+		if _, err := os.Stat(filepath.Join(gitDir, name)); err != nil {
+			return "", false
+		}
+	}
+	output, err := gitCommand("config", "--file", filepath.Join(gitDir, "config"), "core.worktree").Output()
+	if err != nil {
+		return "", false
+	}
+	dir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitDir, dir)
+	}
+	return filepath.Clean(dir), true
+}
+
+// fixSubmoduleMain replaces the git directory git lists as the main
+// worktree of a submodule with its checkout.
+func fixSubmoduleMain(entries []worktreeEntry) {
+	if len(entries) == 0 || entries[0].Bare {
+		return
+	}
+	if dir, ok := submoduleWorktree(entries[0].Path); ok {
+		entries[0].Path = dir
+	}
+}
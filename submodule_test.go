@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubmoduleWorktrees(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	authDir := filepath.Join(tmpDir, "auth")
+	setupTestRepo(t, authDir)
+	runGitCommand(t, authDir, "branch", "feature/x")
+	superDir := filepath.Join(tmpDir, "super")
+	setupTestRepo(t, superDir)
+	runGitCommand(t, superDir, "-c", "protocol.file.allow=always", "submodule", "add", authDir, "libs/auth")
+	runGitCommand(t, superDir, "commit", "-m", "add auth")
+	subDir := filepath.Join(superDir, "libs", "auth")
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() {
+		os.Chdir(origDir)
+		snapshot.invalidate()
+		loadWorktreeConfig()
+	})
+
+	chdirFamily(t, filepath.Join(superDir, "libs"))
+	if err := enterSubmodule("."); err == nil {
+		t.Error("enterSubmodule() of a plain directory succeeded")
+	}
+	if err := enterSubmodule("missing"); err == nil {
+		t.Error("enterSubmodule() of a missing path succeeded")
+	}
+	// Relative to the superproject, from one of its directories.
+	if err := enterSubmodule("libs/auth"); err != nil {
+		t.Fatalf("enterSubmodule(): %v", err)
+	}
+	if cwd, _ := os.Getwd(); !samePath(cwd, subDir) {
+		t.Errorf("current directory = %s, want %s", cwd, subDir)
+	}
+
+	wtPath := filepath.Join(tmpDir, "wt-x")
+	runGitCommand(t, subDir, "worktree", "add", wtPath, "feature/x")
+	for _, dir := range []string{subDir, wtPath} {
+		chdirFamily(t, dir)
+		info, err := getRepoInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Not the git directory in the superproject's .git/modules.
+		if !samePath(info.Main, subDir) {
+			t.Errorf("main worktree from %s = %s, want %s", dir, info.Main, subDir)
+		}
+		if got := auditRepo(); !samePath(got, subDir) {
+			t.Errorf("auditRepo() from %s = %s, want %s", dir, got, subDir)
+		}
+		if path, ok := worktreeExists("main"); !ok || !samePath(path, subDir) {
+			t.Errorf("worktreeExists(main) from %s = %s, %v, want %s", dir, path, ok, subDir)
+		}
+	}
+}
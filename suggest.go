@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions bounds the "did you mean" list.
+const maxSuggestions = 5
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// suggestNames returns the candidates close to name: those containing it
+// and those within a few edits, case-insensitively.
+// Substring matches come first, then by distance and name.
+func suggestNames(name string, candidates []string) []string {
+	type match struct {
+		name      string
+		substring bool
+		distance  int
+	}
+	needle := strings.ToLower(name)
+	// Allow about one typo per three characters, but at least two.
+	maxDistance := max(2, len([]rune(needle))/3)
+
+	seen := make(map[string]bool)
+	var matches []match
+	for _, candidate := range candidates {
+		if candidate == "" || candidate == name || seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		hay := strings.ToLower(candidate)
+		m := match{
+			name:      candidate,
+			substring: len(needle) >= 2 && strings.Contains(hay, needle),
+			distance:  editDistance(needle, hay),
+		}
+		if m.substring || m.distance <= maxDistance {
+			matches = append(matches, m)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.substring != b.substring {
+			return a.substring
+		}
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		return a.name < b.name
+	})
+	var names []string
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		names = append(names, matches[i].name)
+	}
+	return names
+}
+
+// didYouMean formats suggestions as an error suffix, or "" without any.
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return "\n\nDid you mean:\n  " + strings.Join(suggestions, "\n  ")
+}
+
+// resolveFuzzy picks the candidate meant by name when --fuzzy is set and
+// exactly one candidate is close; otherwise it returns the error of
+// notFound with the suggestions appended.
+func resolveFuzzy(name string, candidates []string, fuzzy bool, notFound error) (string, error) {
+	suggestions := suggestNames(name, candidates)
+	if fuzzy && len(suggestions) == 1 {
+		infof("No exact match for '%s', using '%s'\n", name, suggestions[0])
+		return suggestions[0], nil
+	}
+	return "", fmt.Errorf("%w%s", notFound, didYouMean(suggestions))
+}
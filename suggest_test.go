@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"main", "main", 0},
+		{"mian", "main", 2},
+		{"feature", "featrue", 2},
+		{"fix", "fox", 1},
+		{"", "abc", 3},
+		{"ünï", "uni", 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestNames(t *testing.T) {
+	candidates := []string{"main", "feature/login", "feature/logout", "bugfix/login-crash", "release-1.0"}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"mian", []string{"main"}},
+		{"LOGIN", []string{"feature/login", "bugfix/login-crash"}},
+		{"feature/logni", []string{"feature/login", "feature/logout"}},
+		{"release-1.1", []string{"release-1.0"}},
+		{"unrelated", nil},
+		{"main", nil},
+	}
+	for _, tt := range tests {
+		got := suggestNames(tt.name, candidates)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("suggestNames(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveFuzzy(t *testing.T) {
+	notFound := errors.New("branch 'logni' does not exist")
+	candidates := []string{"login", "logout"}
+
+	if _, err := resolveFuzzy("logni", candidates, false, notFound); err == nil || !errors.Is(err, notFound) || !strings.Contains(err.Error(), "Did you mean:\n  login") {
+		t.Errorf("resolveFuzzy() without --fuzzy = %v, want suggestions", err)
+	}
+	if got, err := resolveFuzzy("logni", candidates, true, notFound); err != nil || got != "login" {
+		t.Errorf("resolveFuzzy() = %q, %v; want login", got, err)
+	}
+	// Ambiguous: both are close, so --fuzzy must not guess.
+	if _, err := resolveFuzzy("log", candidates, true, notFound); err == nil {
+		t.Error("resolveFuzzy() picked a branch for an ambiguous name")
+	}
+	if _, err := resolveFuzzy("zzz", candidates, true, notFound); err == nil || strings.Contains(err.Error(), "Did you mean") {
+		t.Errorf("resolveFuzzy() = %v, want plain not found", err)
+	}
+}
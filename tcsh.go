@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// csh has no functions, so the tcsh integration is an alias around the
+// binary. It cannot read the output of a command running in the terminal,
+// so instead of the navigation marker it passes the name of a temporary
+// file in WT_CD_FILE, into which wt writes csh commands that change to the
+// worktree; the alias sources the file once wt exited.
+
+// tcshCommands are completed as the first word in tcsh.
+const tcshCommands = "checkout co create pr mr list ls status fetch info remove rm move restack describe tag last history cleanup prune repair clean exec foreach snapshot maintenance gc hooks config doctor help shellenv init version"
+
+// cshQuote quotes s as one word for csh. Backslashes do not escape inside
+// single quotes there, so a quote ends the quoted part and is escaped.
+func cshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeCDFile writes the commands that take the tcsh integration to path,
+// when wt runs from it.
+func writeCDFile(path string) {
+	file := os.Getenv("WT_CD_FILE")
+	if file == "" {
+		return
+	}
+	script := fmt.Sprintf("cd %s && eval \"`env wt __worktree-env --csh`\"\n", cshQuote(path))
+	if err := os.WriteFile(file, []byte(script), 0o600); err != nil {
+		warnf("Could not pass the directory to the shell: %v\n", err)
+	}
+}
+
+// cshEnv formats the worktree variables as csh commands on one line, since
+// the integration evals them from a command substitution.
+func cshEnv(vars [][2]string) string {
+	names := make([]string, len(vars))
+	commands := make([]string, 0, len(vars)+1)
+	for i, v := range vars {
+		names[i] = v[0]
+	}
+	commands = append(commands, "unsetenv "+strings.Join(names, " "))
+	for _, v := range vars {
+		commands = append(commands, fmt.Sprintf("setenv %s %s", v[0], cshQuote(v[1])))
+	}
+	return strings.Join(commands, "; ")
+}
+
+// tcshIntegration returns the tcsh/csh integration. csh cannot eval a
+// script of several lines, so it is written to a file and sourced.
+func tcshIntegration() string {
+	var branches []string
+	for _, c := range []struct{ names, flags string }{
+		{"checkout co describe", ""},
+		{"remove rm move restack info exec", " --worktrees"},
+	} {
+		for _, name := range strings.Fields(c.names) {
+			branches = append(branches, fmt.Sprintf("'n/%s/`env wt __branches%s`/'", name, c.flags))
+		}
+	}
+	return `# tcsh/csh integration. csh has no functions, so wt is an alias: it runs
+# the binary with WT_CD_FILE naming a temporary file, into which wt writes
+# the cd to the worktree it navigated to, and sources that file afterwards.
+# wt runs in the terminal itself, so its prompts work as they are.
+#
+# Load it from ~/.tcshrc (csh cannot eval a script of several lines):
+#   set __wt_init = ` + "`mktemp -t wt.XXXXXX`" + `
+#   wt shellenv tcsh >! $__wt_init && source $__wt_init
+#   rm -f $__wt_init; unset __wt_init
+
+# Identifies this shell, so 'wt -' returns to where this shell was before
+if ( ! $?__wt_session ) set __wt_session = $$
+
+alias wt 'set __wt_cd = ` + "`mktemp -t wt.XXXXXX`" + `; env WT_SESSION=$__wt_session WT_CD_FILE=$__wt_cd wt \!*; set __wt_status = $status; if ( ! -z $__wt_cd ) source $__wt_cd; rm -f $__wt_cd; unset __wt_cd; sh -c "exit $__wt_status"'
+
+# Completion of commands and branch names; csh itself has none
+if ( $?tcsh ) then
+    complete wt 'p/1/(` + tcshCommands + `)/' \
+        ` + strings.Join(branches, " \\\n        ") + `
+endif
+`
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCshQuote(t *testing.T) {
+	tests := map[string]string{
+		"/home/me/wt":   "'/home/me/wt'",
+		"/tmp/a b":      "'/tmp/a b'",
+		"/tmp/it's":     `'/tmp/it'\''s'`,
+		"$HOME/`x`":     "'$HOME/`x`'",
+		"feature/x-1.2": "'feature/x-1.2'",
+	}
+	for in, want := range tests {
+		if got := cshQuote(in); got != want {
+			t.Errorf("cshQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestCshEnv(t *testing.T) {
+	got := cshEnv([][2]string{{"WT_BRANCH", ""}, {"WT_REPO", "repo"}, {"WT_WORKTREE", "/tmp/it's"}})
+	want := `unsetenv WT_BRANCH WT_REPO WT_WORKTREE; setenv WT_BRANCH ''; setenv WT_REPO 'repo'; setenv WT_WORKTREE '/tmp/it'\''s'`
+	if got != want {
+		t.Errorf("cshEnv() = %s, want %s", got, want)
+	}
+}
+
+func TestWriteCDFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cd")
+	t.Setenv("WT_CD_FILE", "")
+	writeCDFile("/tmp/wt")
+	if _, err := os.Stat(file); err == nil {
+		t.Fatal("writeCDFile() wrote without WT_CD_FILE")
+	}
+
+	t.Setenv("WT_CD_FILE", file)
+	writeCDFile("/tmp/first")
+	writeCDFile("/tmp/a b")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last navigation wins.
+	if !strings.HasPrefix(string(data), "cd '/tmp/a b' && eval ") || strings.Contains(string(data), "first") {
+		t.Errorf("cd file = %q", data)
+	}
+}
+
+func TestTcshIntegration(t *testing.T) {
+	script := tcshIntegration()
+	for _, want := range []string{
+		"WT_CD_FILE=$__wt_cd wt \\!*",
+		"'p/1/(checkout co ",
+		"'n/rm/`env wt __branches --worktrees`/'",
+		"'n/co/`env wt __branches`/'",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("tcsh integration lacks %q", want)
+		}
+	}
+	// Each command in the alias must stay on one line.
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(line, "alias wt ") && strings.Count(line, "'") != 2 {
+			t.Errorf("alias is not one quoted word: %s", line)
+		}
+	}
+	if strings.Contains(script, "wt() {") {
+		t.Error("tcsh integration contains the bash function")
+	}
+}
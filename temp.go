@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var checkoutTemp bool
+
+// tempDirName is the name pattern of the directory of a --temp checkout,
+// e.g. wt-api-v1.2-* for tag v1.2 of api; os.MkdirTemp fills in the *.
+func tempDirName(info repoInfo, ref string) string {
+	safe := strings.NewReplacer("/", "-", "\\", "-", ":", "-").Replace(ref)
+	return "wt-" + info.Name + "-" + safe + "-*"
+}
+
+// checkoutTempWorktree checks out ref in a new directory under the system
+// temp directory instead of WORKTREE_ROOT and prints its path. A branch that
+// no worktree has yet is checked out as the branch, so --ttl can expire it;
+// any other ref, or a branch checked out elsewhere, gets a detached HEAD.
+func checkoutTempWorktree(ref string, ttl time.Duration) error {
+	info, err := getRepoInfo()
+	if err != nil {
+		return err
+	}
+	fetchMissingBranch(ref)
+
+	branch := ""
+	if branchExists(ref) {
+		_, exists := worktreeExists(ref)
+		_, _, busy := busyWorktree(ref)
+		if !exists && !busy {
+			branch = ref
+		}
+	}
+	if branch == "" {
+		if gitCommand("rev-parse", "--verify", "--quiet", ref+"^{commit}").Run() != nil {
+			return fmt.Errorf("'%s' is not a branch, tag or commit", ref)
+		}
+		if ttl > 0 {
+			return fmt.Errorf("--ttl needs a branch that is not checked out; remove the worktree of '%s' with 'git worktree remove' instead", ref)
+		}
+	}
+
+	path, err := os.MkdirTemp("", tempDirName(info, ref))
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	path = resolvePath(path)
+	args := []string{ref}
+	if branch == "" {
+		args = []string{"--detach", ref}
+	} else if err := prefetchObjects(checkoutRef(branch)); err != nil {
+		os.Remove(path)
+		return err
+	}
+	gitCmd := repoVCS().addWorktree(path, args...)
+	gitCmd.Stdout = gitOutput()
+	gitCmd.Stderr = os.Stderr
+	if err := gitCmd.Run(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to create worktree: %w", err)
+	}
+
+	if branch != "" {
+		setEphemeral(branch, ttl)
+	}
+	prepareWorktree(path)
+	applyWorktreeGitConfig(info, path)
+	runPostCheckoutHooks(info, branch, path)
+	printCDMarker(path)
+	return nil
+}
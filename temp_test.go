@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTempDirName(t *testing.T) {
+	info := repoInfo{Name: "api"}
+	if got := tempDirName(info, "feature/login"); got != "wt-api-feature-login-*" {
+		t.Errorf("tempDirName() = %q, want wt-api-feature-login-*", got)
+	}
+}
+
+func TestCheckoutTempWorktree(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	runGitCommand(t, repoDir, "tag", "v1")
+	runGitCommand(t, repoDir, "branch", "feature")
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() {
+		os.Chdir(origDir)
+		snapshot.invalidate()
+	})
+	chdirFamily(t, repoDir)
+
+	for _, ref := range []string{"v1", "feature", "main"} {
+		if err := checkoutTempWorktree(ref, 0); err != nil {
+			t.Fatalf("checkoutTempWorktree(%s): %v", ref, err)
+		}
+	}
+	snapshot.invalidate()
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d worktrees, want 4", len(entries))
+	}
+	branches := map[string]bool{}
+	for _, entry := range entries[1:] {
+		if !isWithin(resolvePath(tmp), entry.Path) || !strings.HasPrefix(filepath.Base(entry.Path), "wt-repo-") {
+			t.Errorf("worktree %s is not in the temp directory %s", entry.Path, tmp)
+		}
+		branches[entry.Branch] = true
+	}
+	// main is checked out in the clone, so it gets a detached HEAD like v1.
+	if !branches["feature"] || branches["main"] {
+		t.Errorf("branches of temp worktrees = %v, want feature and detached ones", branches)
+	}
+
+	if err := checkoutTempWorktree("nope", 0); err == nil {
+		t.Error("checkoutTempWorktree(nope) succeeded")
+	}
+	if err := checkoutTempWorktree("v1", time.Hour); err == nil {
+		t.Error("checkoutTempWorktree(v1) with a ttl succeeded without a branch")
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// quiet suppresses everything but results and errors, for use in scripts.
+	quiet bool
+	// ciFlag forces the CI profile even when $CI is not set.
+	ciFlag bool
+)
+
+// errPromptDisabled is returned when an action needs the user's input but
+// prompting is not allowed.
+var errPromptDisabled = errors.New("interactive prompts are disabled in CI mode")
+
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print results (e.g. paths) and errors; no progress or success messages")
+	rootCmd.PersistentFlags().BoolVar(&ciFlag, "ci", false, "Non-interactive CI profile (default when $CI is set): no prompts, plain ASCII, machine-readable summaries")
+	rootCmd.PersistentFlags().StringVar(&gitTimeoutFlag, "git-timeout", "", "Stop git commands that run longer than this, e.g. 90s or 10m; 0 for no limit (config: git-timeout, default 0)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if quiet {
+			// A usage dump is noise when a script passes a bad argument.
+			cmd.SilenceUsage = true
+		}
+		warnConfigProblems(cmd)
+		auditCommand = commandName(cmd)
+		if err := applyGitTimeoutFlag(); err != nil {
+			return err
+		}
+		maybePrefetch(cmd)
+		return nil
+	}
+}
+
+// ciMode reports whether wt runs under the CI profile. All behavior that
+// differs in CI (prompts, glyphs, summaries) is switched on this.
+func ciMode() bool {
+	if ciFlag {
+		return true
+	}
+	switch strings.ToLower(os.Getenv("CI")) {
+	case "", "0", "false", "no":
+		return false
+	}
+	return true
+}
+
+// successPrefix returns a checkmark or "[ok]" depending on terminal support
+func successPrefix() string {
+	// Check if we're in a terminal that likely supports Unicode
+	// Most modern terminals do, but CI environments and some Windows consoles may not
+	if ciMode() || os.Getenv("TERM") == "dumb" {
+		return "[ok]"
+	}
+	return "✓"
+}
+
+// successf reports a completed action, prefixed with a checkmark.
+func successf(format string, args ...any) {
+	if quiet {
+		return
+	}
+	fmt.Printf("%s %s\n", successPrefix(), fmt.Sprintf(format, args...))
+}
+
+// infof prints progress and hints that scripts do not need.
+func infof(format string, args ...any) {
+	if quiet {
+		return
+	}
+	fmt.Printf(format, args...)
+}
+
+// warnf prints a non-fatal problem to stderr. Warnings are shown in quiet
+// mode too, since they go to stderr and may explain a later failure.
+func warnf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// gitOutput is where output of git subcommands run on the user's behalf
+// goes; it is discarded in quiet mode so stdout only carries results.
+func gitOutput() io.Writer {
+	if quiet {
+		return io.Discard
+	}
+	return os.Stdout
+}
+
+// selectPrompt asks the user to pick one of items. It fails with
+// errPromptDisabled in CI mode instead of waiting for input.
+func selectPrompt(label string, items []string) (int, string, error) {
+	if ciMode() {
+		return -1, "", fmt.Errorf("%s: %w (pass the value as an argument)", label, errPromptDisabled)
+	}
+	prompt := promptui.Select{
+		Label: label,
+		Items: items,
+	}
+	idx, result, err := prompt.Run()
+	if err != nil {
+		return -1, "", fmt.Errorf("selection cancelled")
+	}
+	return idx, result, nil
+}
+
+// confirmPrompt asks a yes/no question. A declined or cancelled prompt
+// returns false; in CI mode it returns errPromptDisabled without asking.
+func confirmPrompt(label string) (bool, error) {
+	if ciMode() {
+		return false, errPromptDisabled
+	}
+	prompt := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}
+	_, err := prompt.Run()
+	return err == nil, nil
+}
+
+// inputPrompt asks the user for a non-empty value. It fails with
+// errPromptDisabled in CI mode instead of waiting for input.
+func inputPrompt(label string) (string, error) {
+	if ciMode() {
+		return "", fmt.Errorf("%s: %w", label, errPromptDisabled)
+	}
+	prompt := promptui.Prompt{
+		Label: label,
+		Validate: func(input string) error {
+			if strings.TrimSpace(input) == "" {
+				return errors.New("a value is required")
+			}
+			return nil
+		},
+	}
+	result, err := prompt.Run()
+	if err != nil {
+		return "", fmt.Errorf("input cancelled")
+	}
+	return strings.TrimSpace(result), nil
+}
+
+// machineSummary formats a one-line, key=value summary of an action that
+// CI logs and scripts can grep, e.g. "cleanup: removed=2 skipped=0".
+func machineSummary(action string, keyValues ...any) string {
+	var sb strings.Builder
+	sb.WriteString(action + ":")
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", keyValues[i], keyValues[i+1])
+	}
+	return sb.String()
+}
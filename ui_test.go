@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns everything fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	fn()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestQuietSuppressesMessages(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	origQuiet := quiet
+	t.Cleanup(func() { quiet = origQuiet })
+
+	quiet = false
+	out := captureStdout(t, func() {
+		successf("Worktree created at: %s", "/tmp/wt")
+		infof("some progress\n")
+		printCDMarker("/tmp/wt")
+	})
+	for _, want := range []string{"Worktree created at: /tmp/wt", "some progress", "wt navigating to: /tmp/wt"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	quiet = true
+	out = captureStdout(t, func() {
+		successf("Worktree created at: %s", "/tmp/wt")
+		infof("some progress\n")
+		printCDMarker("/tmp/wt")
+	})
+	if out != "/tmp/wt\n" {
+		t.Errorf("quiet output = %q, want only the path", out)
+	}
+}
+
+func TestWorktreeAddArgsQuiet(t *testing.T) {
+	origQuiet := quiet
+	t.Cleanup(func() { quiet = origQuiet })
+
+	quiet = false
+	if got := strings.Join(worktreeAddArgs("/p", "b"), " "); got != "worktree add /p b" {
+		t.Errorf("worktreeAddArgs() = %q", got)
+	}
+	quiet = true
+	if got := strings.Join(worktreeAddArgs("/p", "b"), " "); got != "worktree add --quiet /p b" {
+		t.Errorf("worktreeAddArgs() in quiet mode = %q", got)
+	}
+}
+
+func TestQuietFlagRegistered(t *testing.T) {
+	flag := rootCmd.PersistentFlags().Lookup("quiet")
+	if flag == nil {
+		t.Fatal("root command missing persistent --quiet flag")
+	}
+	if flag.Shorthand != "q" {
+		t.Errorf("--quiet shorthand = %q, want q", flag.Shorthand)
+	}
+}
+
+func TestCIMode(t *testing.T) {
+	origFlag := ciFlag
+	t.Cleanup(func() { ciFlag = origFlag })
+	ciFlag = false
+
+	tests := []struct {
+		env  string
+		want bool
+	}{
+		{"", false},
+		{"false", false},
+		{"0", false},
+		{"true", true},
+		{"1", true},
+		{"woodpecker", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("CI", tt.env)
+		if got := ciMode(); got != tt.want {
+			t.Errorf("ciMode() with CI=%q = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+
+	t.Setenv("CI", "")
+	ciFlag = true
+	if !ciMode() {
+		t.Error("expected --ci to enable CI mode")
+	}
+	if successPrefix() != "[ok]" {
+		t.Errorf("successPrefix() in CI mode = %q, want [ok]", successPrefix())
+	}
+}
+
+func TestPromptsDisabledInCIMode(t *testing.T) {
+	origFlag := ciFlag
+	t.Cleanup(func() { ciFlag = origFlag })
+	ciFlag = true
+
+	if _, _, err := selectPrompt("Select branch", []string{"a", "b"}); !errors.Is(err, errPromptDisabled) {
+		t.Errorf("selectPrompt() error = %v, want errPromptDisabled", err)
+	}
+	if ok, err := confirmPrompt("Remove?"); ok || !errors.Is(err, errPromptDisabled) {
+		t.Errorf("confirmPrompt() = %v, %v; want false, errPromptDisabled", ok, err)
+	}
+}
+
+func TestMachineSummary(t *testing.T) {
+	got := machineSummary("cleanup", "removed", 2, "skipped", 0)
+	if want := "cleanup: removed=2 skipped=0"; got != want {
+		t.Errorf("machineSummary() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	removeBackup         bool
+	removeDiscardCommits bool
+)
+
+// backupRefPrefix is where wt keeps the tips of branches whose worktrees
+// were removed with commits that are on no remote.
+const backupRefPrefix = "refs/wt/backup/"
+
+// hasRemotes reports whether the repository has any remote. Without one,
+// no commit is pushed, and nothing is learned from counting them.
+func hasRemotes() bool {
+	output, err := gitCommand("remote").Output()
+	return err == nil && strings.TrimSpace(string(output)) != ""
+}
+
+// backupBranch saves the tip of branch under refs/wt/backup/, so its
+// commits stay reachable whatever happens to the branch.
+func backupBranch(branch string) (string, error) {
+	ref := backupRefPrefix + branch + "/" + time.Now().Format("20060102-150405")
+	if output, err := gitCommand("update-ref", ref, "refs/heads/"+branch).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to back up %s: %s", branch, strings.TrimSpace(string(output)))
+	}
+	return ref, nil
+}
+
+// bundleBranch writes the commits of branch that are on no remote to a
+// bundle file in wt's state directory, from which 'git fetch <file>
+// <branch>' restores them, even into another clone.
+func bundleBranch(info repoInfo, branch string) (string, error) {
+	dir := filepath.Join(stateDir(), "backups")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%s-%s.bundle", info.Name, slugify(branch), time.Now().Format("20060102-150405"))
+	path := filepath.Join(dir, name)
+	output, err := gitCommand("bundle", "create", path, "refs/heads/"+branch, "--not", "--remotes").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to bundle %s: %s", branch, strings.TrimSpace(string(output)))
+	}
+	return path, nil
+}
+
+// protectUnpushed guards the commits of branch that are on no remote and
+// not in the base branch before its worktree is removed: merged detection
+// does not see work that was never pushed. Unless --discard-commits or
+// --backup settles it, or confirm.remove_unpushed is never, the user picks
+// a backup ref, a bundle, removal without a backup, or keeping the
+// worktree; in CI mode removal stops. It reports whether the user agreed
+// to the commits leaving with the worktree, so --delete-branch does not
+// ask again.
+func protectUnpushed(info repoInfo, branch string) (bool, error) {
+	policy := confirmPolicy("remove_unpushed")
+	if policy == confirmNever || !gitRefExists("refs/heads/"+branch) || !hasRemotes() {
+		return false, nil
+	}
+	base := getDefaultBase()
+	unpushed, err := unpushedCommits(branch, base)
+	if err != nil || unpushed == 0 {
+		return false, nil
+	}
+	if removeBackup {
+		ref, err := backupBranch(branch)
+		if err != nil {
+			return false, err
+		}
+		infof("Backed up '%s' to %s\n", branch, ref)
+		return true, nil
+	}
+	if removeDiscardCommits && policy != confirmAlways {
+		return true, nil
+	}
+
+	const (
+		keepRef    = "Back up to a ref under refs/wt/backup/ and remove"
+		keepBundle = "Save a bundle file and remove"
+		discard    = "Remove without a backup"
+		cancel     = "Keep the worktree"
+	)
+	_, choice, err := selectPrompt(fmt.Sprintf("'%s' has %d commit(s) that are on no remote and not in %s", branch, unpushed, base),
+		[]string{keepRef, keepBundle, discard, cancel})
+	if errors.Is(err, errPromptDisabled) {
+		return false, fmt.Errorf("branch '%s' has %d commit(s) that are on no remote and not in %s\nPush them, or remove with --backup or --discard-commits: %w", branch, unpushed, base, err)
+	}
+	if err != nil {
+		return false, err
+	}
+	switch choice {
+	case keepRef:
+		ref, err := backupBranch(branch)
+		if err != nil {
+			return false, err
+		}
+		infof("Backed up '%s' to %s\n", branch, ref)
+	case keepBundle:
+		path, err := bundleBranch(info, branch)
+		if err != nil {
+			return false, err
+		}
+		infof("Saved the commits of '%s' to %s\n", branch, path)
+	case cancel:
+		return false, fmt.Errorf("kept worktree '%s'", branch)
+	}
+	return true, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProtectUnpushed(t *testing.T) {
+	t.Cleanup(func() {
+		confirmPolicies = nil
+		removeBackup, removeDiscardCommits = false, false
+	})
+	t.Setenv("CI", "true")
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	chdirFamily(t, repoDir)
+	runGitCommand(t, repoDir, "config", "wt.base", "main")
+	loadWorktreeConfig()
+	info := repoInfo{Name: "repo"}
+
+	runGitCommand(t, repoDir, "checkout", "-q", "-b", "work")
+	runGitCommand(t, repoDir, "commit", "-q", "--allow-empty", "-m", "work")
+	runGitCommand(t, repoDir, "checkout", "-q", "main")
+
+	// Without a remote nothing is pushed, so nothing is asked.
+	if agreed, err := protectUnpushed(info, "work"); agreed || err != nil {
+		t.Errorf("without remotes = %v, %v", agreed, err)
+	}
+	runGitCommand(t, tmpDir, "init", "-q", "--bare", filepath.Join(tmpDir, "origin.git"))
+	runGitCommand(t, repoDir, "remote", "add", "origin", filepath.Join(tmpDir, "origin.git"))
+
+	if _, err := protectUnpushed(info, "work"); !errors.Is(err, errPromptDisabled) || !strings.Contains(err.Error(), "1 commit(s)") {
+		t.Errorf("in CI = %v, want a prompt error", err)
+	}
+	if agreed, err := protectUnpushed(info, "main"); agreed || err != nil {
+		t.Errorf("branch without own commits = %v, %v", agreed, err)
+	}
+
+	removeDiscardCommits = true
+	if agreed, err := protectUnpushed(info, "work"); !agreed || err != nil {
+		t.Errorf("--discard-commits = %v, %v", agreed, err)
+	}
+	confirmPolicies = map[string]confirmSetting{"remove_unpushed": {Policy: confirmAlways}}
+	if _, err := protectUnpushed(info, "work"); !errors.Is(err, errPromptDisabled) {
+		t.Errorf("--discard-commits with always = %v, want a prompt", err)
+	}
+	confirmPolicies = map[string]confirmSetting{"remove_unpushed": {Policy: confirmNever}}
+	removeDiscardCommits = false
+	if agreed, err := protectUnpushed(info, "work"); agreed || err != nil {
+		t.Errorf("never = %v, %v", agreed, err)
+	}
+	confirmPolicies = nil
+
+	removeBackup = true
+	if agreed, err := protectUnpushed(info, "work"); !agreed || err != nil {
+		t.Fatalf("--backup = %v, %v", agreed, err)
+	}
+	output, err := gitCommand("for-each-ref", "--format=%(refname)", backupRefPrefix).Output()
+	if err != nil || !strings.HasPrefix(string(output), backupRefPrefix+"work/") {
+		t.Errorf("backup refs = %q, %v", output, err)
+	}
+
+	path, err := bundleBranch(info, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(filepath.Base(path), "repo-work-") {
+		t.Errorf("bundle = %s", path)
+	}
+	runGitCommand(t, repoDir, "bundle", "verify", path)
+}
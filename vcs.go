@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// vcs is what manages the working copies of a repository. wt adds, removes
+// and lists git worktrees. A repository jj (Jujutsu) shares with git,
+// "colocated", is a git repository too, but jj owns its main worktree: it
+// keeps git's HEAD detached at the parent of its working-copy commit and
+// snapshots every file there that is not ignored into that commit. wt goes
+// through vcs for worktrees so such repositories can be special-cased, and
+// so 'jj workspace' can become a backend of its own.
+type vcs struct {
+	Name string
+	// Main is the main worktree; jj's working copy in a jj repository.
+	Main string
+}
+
+const (
+	vcsGit = "git"
+	vcsJJ  = "jj"
+)
+
+// detectVCS returns the vcs of the repository whose main worktree is main:
+// jj when jj keeps its .jj directory next to git's.
+func detectVCS(main string) vcs {
+	if info, err := os.Stat(filepath.Join(main, ".jj")); err == nil && info.IsDir() {
+		return vcs{Name: vcsJJ, Main: main}
+	}
+	return vcs{Name: vcsGit, Main: main}
+}
+
+// markVCS records in the main worktree's entry which vcs manages it, for
+// wt list; the others are plain git worktrees either way.
+func markVCS(entries []worktreeEntry) {
+	if len(entries) > 0 && !entries[0].Bare {
+		entries[0].VCS = detectVCS(entries[0].Path).Name
+	}
+}
+
+// repoVCS returns the vcs of the current repository.
+func repoVCS() vcs {
+	entries, err := snapshot.Worktrees()
+	if err != nil || len(entries) == 0 || entries[0].VCS == "" {
+		return vcs{Name: vcsGit}
+	}
+	return vcs{Name: entries[0].VCS, Main: entries[0].Path}
+}
+
+// git returns a git command that runs in the main worktree, which outlives
+// the others, so the worktree wt was started in can be moved or removed.
+func (v vcs) git(args ...string) *timedCommand {
+	if v.Main == "" {
+		return gitCommand(args...)
+	}
+	return gitCommand(append([]string{"-C", v.Main}, args...)...)
+}
+
+// addWorktree returns the command that adds a worktree at path, with the
+// further arguments of 'git worktree add'. jj does not know about git
+// worktrees, so in a jj repository they are added with git as well, but
+// hidden from jj before git checks out any file.
+func (v vcs) addWorktree(path string, args ...string) *timedCommand {
+	path = absolutePath(path)
+	v.hide(path)
+	return v.git(worktreeAddArgs(append([]string{path}, args...)...)...)
+}
+
+// moveWorktree returns the command that moves the worktree at src to dst,
+// hidden from jj like a new one.
+func (v vcs) moveWorktree(src, dst string) *timedCommand {
+	dst = absolutePath(dst)
+	v.hide(dst)
+	return v.git("worktree", "move", absolutePath(src), dst)
+}
+
+// removeWorktree returns the command that removes the worktree at path,
+// with its local changes when force is set.
+func (v vcs) removeWorktree(path string, force bool) *timedCommand {
+	args := []string{"worktree", "remove"}
+	if force {
+		args = append(args, "--force")
+	}
+	return v.git(append(args, absolutePath(path))...)
+}
+
+// absolutePath makes path absolute, as git runs in the main worktree.
+func absolutePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// hide is hideWorktree for a worktree about to be created, which only
+// warns: the worktree is of use either way.
+func (v vcs) hide(path string) {
+	if err := v.hideWorktree(path); err != nil {
+		warnf("warning: jj may snapshot the files of %s: %v\n", path, err)
+	}
+}
+
+// hideWorktree keeps the worktree at path out of the main worktree's
+// vcs. jj would snapshot the files of a worktree inside its working copy,
+// as with the inside-dotdir strategy, into its working-copy commit; the
+// worktree is added to info/exclude, which jj reads too.
+func (v vcs) hideWorktree(path string) error {
+	rel, ok := v.nestedPath(path)
+	if v.Name != vcsJJ || !ok {
+		return nil
+	}
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return err
+	}
+	return addExclude(commonDir, "/"+rel+"/", "wt worktree, hidden from jj")
+}
+
+// nestedPath returns the path of a worktree at path relative to the main
+// worktree, if it is inside it.
+func (v vcs) nestedPath(path string) (string, bool) {
+	if v.Main == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(resolvePath(v.Main), resolvePath(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// checkJJ reports, in a jj repository, that jj does not see the worktrees
+// and which worktrees inside jj's working copy it would snapshot.
+func checkJJ() []doctorResult {
+	const name = "jj"
+	entries, err := snapshot.Worktrees()
+	if err != nil || len(entries) == 0 || entries[0].VCS != vcsJJ {
+		return nil
+	}
+	v := repoVCS()
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return nil
+	}
+	var results []doctorResult
+	for _, e := range entries[1:] {
+		if rel, ok := v.nestedPath(e.Path); ok && !hasExclude(commonDir, "/"+rel+"/") {
+			results = append(results, doctorResult{Name: name, Status: doctorWarn,
+				Message: fmt.Sprintf("%s is inside jj's working copy; jj snapshots its files into the working-copy commit", e.Path),
+				Hint:    fmt.Sprintf("add /%s/ to %s", rel, filepath.Join(commonDir, "info", "exclude"))})
+		}
+	}
+	if len(results) > 0 {
+		return results
+	}
+	return []doctorResult{{Name: name, Status: doctorOK,
+		Message: fmt.Sprintf("%s is a jj repository; its worktrees are git worktrees, which jj does not see", v.Main)}}
+}
+
+func init() {
+	doctorChecks = append(doctorChecks, checkJJ)
+}
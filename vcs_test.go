@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDetectVCS(t *testing.T) {
+	dir := t.TempDir()
+	if got := detectVCS(dir).Name; got != vcsGit {
+		t.Errorf("detectVCS(git repository) = %s, want git", got)
+	}
+	if err := os.Mkdir(filepath.Join(dir, ".jj"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := detectVCS(dir).Name; got != vcsJJ {
+		t.Errorf("detectVCS(jj repository) = %s, want jj", got)
+	}
+}
+
+func TestVCSNestedPath(t *testing.T) {
+	mainDir := t.TempDir()
+	v := vcs{Name: vcsJJ, Main: mainDir}
+	if rel, ok := v.nestedPath(filepath.Join(mainDir, ".worktrees", "feature")); !ok || rel != ".worktrees/feature" {
+		t.Errorf("nestedPath(inside) = %q, %v", rel, ok)
+	}
+	for _, path := range []string{mainDir, filepath.Dir(mainDir), filepath.Join(filepath.Dir(mainDir), "sibling")} {
+		if rel, ok := v.nestedPath(path); ok {
+			t.Errorf("nestedPath(%s) = %q, want not nested", path, rel)
+		}
+	}
+}
+
+func TestHideWorktreeFromJJ(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	tmpDir := t.TempDir()
+	repoDir := filepath.Join(tmpDir, "repo")
+	setupTestRepo(t, repoDir)
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	chdirFamily(t, repoDir)
+	exclude := filepath.Join(repoDir, ".git", "info", "exclude")
+
+	// A plain git repository is left alone.
+	nested := filepath.Join(repoDir, ".worktrees", "feature")
+	if err := repoVCS().hideWorktree(nested); err != nil {
+		t.Fatal(err)
+	}
+	if hasExclude(filepath.Join(repoDir, ".git"), "/.worktrees/feature/") {
+		t.Error("worktree excluded in a git repository")
+	}
+
+	if err := os.Mkdir(filepath.Join(repoDir, ".jj"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	snapshot.invalidate()
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "feature", nested)
+	outside := filepath.Join(tmpDir, "outside")
+	runGitCommand(t, repoDir, "worktree", "add", "-q", "-b", "outside", outside)
+	if results := checkJJ(); len(results) != 1 || results[0].Status != doctorWarn || !strings.Contains(results[0].Message, nested) {
+		t.Errorf("checkJJ() with a nested worktree = %+v", results)
+	}
+
+	v := repoVCS()
+	if v.Name != vcsJJ {
+		t.Fatalf("repoVCS() = %s, want jj", v.Name)
+	}
+	for _, path := range []string{nested, nested, outside} {
+		if err := v.hideWorktree(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "/.worktrees/feature/\n"); got != 1 {
+		t.Errorf("exclude lists the nested worktree %d times:\n%s", got, data)
+	}
+	if strings.Contains(string(data), "outside") {
+		t.Errorf("exclude lists a worktree outside jj's working copy:\n%s", data)
+	}
+	if results := checkJJ(); len(results) != 1 || results[0].Status != doctorOK {
+		t.Errorf("checkJJ() after hideWorktree = %+v", results)
+	}
+
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := formatListLine(listItem{worktreeStatus: worktreeStatus{worktreeEntry: entries[0]}}); !strings.HasSuffix(line, " jj") {
+		t.Errorf("list line of jj's working copy = %q", line)
+	}
+}
+
+func TestVCSWorktreeCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows does not move or remove the current directory")
+	}
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	repoDir := filepath.Join(t.TempDir(), "repo")
+	setupTestRepo(t, repoDir)
+	if err := os.Mkdir(filepath.Join(repoDir, ".jj"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	chdirFamily(t, repoDir)
+	v := repoVCS()
+	gitDir := filepath.Join(repoDir, ".git")
+
+	// Relative paths are taken from the current directory, and new
+	// worktrees are hidden from jj.
+	if output, err := v.addWorktree(filepath.Join(".worktrees", "added"), "-b", "added").CombinedOutput(); err != nil {
+		t.Fatalf("addWorktree() = %v: %s", err, output)
+	}
+	if !hasExclude(gitDir, "/.worktrees/added/") {
+		t.Error("added worktree is not hidden from jj")
+	}
+
+	// git runs in the main worktree, so the current one can be moved.
+	added := filepath.Join(repoDir, ".worktrees", "added")
+	if err := os.Chdir(added); err != nil {
+		t.Fatal(err)
+	}
+	moved := filepath.Join(repoDir, ".worktrees", "moved")
+	if output, err := v.moveWorktree(".", moved).CombinedOutput(); err != nil {
+		t.Fatalf("moveWorktree() = %v: %s", err, output)
+	}
+	if !hasExclude(gitDir, "/.worktrees/moved/") {
+		t.Error("moved worktree is not hidden from jj")
+	}
+
+	if err := os.Chdir(moved); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := v.removeWorktree(moved, false).CombinedOutput(); err != nil {
+		t.Fatalf("removeWorktree() = %v: %s", err, output)
+	}
+	if _, err := os.Stat(moved); !os.IsNotExist(err) {
+		t.Errorf("removed worktree still exists: %v", err)
+	}
+}
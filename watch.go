@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchPoll is how often --watch looks for local changes between refreshes.
+const watchPoll = 250 * time.Millisecond
+
+// watchStatus shows the status table and refreshes it every --interval, or
+// as soon as a commit, checkout, stage or worktree change touches the
+// repository's git directory, until Ctrl-C.
+func watchStatus() error {
+	if statusInterval < watchPoll {
+		return fmt.Errorf("invalid --interval %s: must be at least %s", statusInterval, watchPoll)
+	}
+	commonDir, err := gitCommonDir()
+	if err != nil {
+		return err
+	}
+	var checks *ciChecks
+	if statusChecks {
+		if checks, err = newCIChecks(commonDir); err != nil {
+			return err
+		}
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	defer signal.Stop(stop)
+	ticker := time.NewTicker(watchPoll)
+	defer ticker.Stop()
+
+	clear := stdoutIsTerminal()
+	var last int64
+	var next time.Time
+	for {
+		now := time.Now()
+		if stamp := watchStamp(commonDir); stamp != last || !now.Before(next) {
+			last, next = stamp, now.Add(statusInterval)
+			frame := renderWatchFrame(checks, now)
+			if clear {
+				// Home and clear, like watch(1)
+				fmt.Print("\033[H\033[2J")
+			}
+			os.Stdout.Write(frame)
+			if !clear {
+				fmt.Println()
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// renderWatchFrame renders one refresh of --watch. Errors are shown instead
+// of ending the watch, since a rebase or checkout in progress may cause
+// them for a moment.
+func renderWatchFrame(checks *ciChecks, now time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Every %s: wt status    %s\n", statusInterval, now.Format("15:04:05"))
+	snapshot.invalidate()
+	items, err := collectStatusItems()
+	if err != nil {
+		fmt.Fprintf(&buf, "\n%v\n", err)
+		return buf.Bytes()
+	}
+	var states map[string]string
+	if checks != nil {
+		states = checks.get(items, now)
+		if checks.err != nil {
+			fmt.Fprintf(&buf, "CI: %v\n", firstLine(checks.err.Error()))
+		}
+	}
+	buf.WriteString("\n")
+	printStatusTable(&buf, items, states, now)
+	return buf.Bytes()
+}
+
+// watchStamp changes whenever git records something in commonDir that the
+// status table shows: refs, the worktree list, and the HEAD and index of
+// every worktree. Edits that are not staged yet wait for the interval.
+func watchStamp(commonDir string) int64 {
+	stamp := refsStamp(commonDir)
+	note := func(path string) {
+		if info, err := os.Stat(path); err == nil && info.ModTime().UnixNano() > stamp {
+			stamp = info.ModTime().UnixNano()
+		}
+	}
+	for _, dir := range append([]string{commonDir}, globDirs(filepath.Join(commonDir, "worktrees", "*"))...) {
+		note(filepath.Join(dir, "HEAD"))
+		note(filepath.Join(dir, "index"))
+	}
+	return stamp
+}
+
+func globDirs(pattern string) []string {
+	matches, _ := filepath.Glob(pattern)
+	return matches
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
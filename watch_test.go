@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintStatusTableColumns(t *testing.T) {
+	now := time.Now()
+	items := []listItem{{worktreeStatus: worktreeStatus{worktreeEntry: worktreeEntry{Path: "/wt/feature", Branch: "feature"}, LastCommit: now.Add(-time.Hour)}}}
+
+	var out strings.Builder
+	printStatusTable(&out, items, nil, now)
+	if strings.Contains(out.String(), "CI") || strings.Contains(out.String(), "NOTE") {
+		t.Errorf("table without checks or notes has their columns:\n%s", out.String())
+	}
+
+	out.Reset()
+	printStatusTable(&out, items, map[string]string{"feature": "failed"}, now)
+	lines := strings.Split(out.String(), "\n")
+	if !strings.Contains(lines[0], "CI") || !strings.Contains(lines[1], "failed") {
+		t.Errorf("table with checks:\n%s", out.String())
+	}
+}
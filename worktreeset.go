@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// worktreeSetVersion is the format version written by 'wt snapshot export'.
+const worktreeSetVersion = 1
+
+// worktreeSet is the file 'wt snapshot export' writes: enough to recreate
+// the worktrees of a repository in another clone.
+type worktreeSet struct {
+	Version   int               `yaml:"version"`
+	Repo      string            `yaml:"repo,omitempty"`
+	Base      string            `yaml:"base,omitempty"`
+	Remotes   map[string]string `yaml:"remotes,omitempty"`
+	Worktrees []worktreeSetItem `yaml:"worktrees"`
+}
+
+// worktreeSetItem is one worktree of the set. Remote and Merge are the
+// branch's upstream; Head is used when neither the branch nor its upstream
+// can be found on restore.
+type worktreeSetItem struct {
+	Branch string `yaml:"branch"`
+	Head   string `yaml:"head,omitempty"`
+	Remote string `yaml:"remote,omitempty"`
+	Merge  string `yaml:"merge,omitempty"`
+}
+
+func gitConfigGet(key string) string {
+	output, _ := gitCommand("config", "--get", key).Output()
+	return strings.TrimSpace(string(output))
+}
+
+// gitRemotes returns the URL of every remote.
+func gitRemotes() map[string]string {
+	remotes := make(map[string]string)
+	output, err := gitCommand("remote").Output()
+	if err != nil {
+		return remotes
+	}
+	for _, name := range strings.Fields(string(output)) {
+		remotes[name] = gitConfigGet("remote." + name + ".url")
+	}
+	return remotes
+}
+
+// exportWorktreeSet describes the linked worktrees of the current
+// repository. The main worktree is the clone itself and detached
+// worktrees have no branch to recreate, so both are left out.
+func exportWorktreeSet() (worktreeSet, error) {
+	info, err := getRepoInfo()
+	if err != nil {
+		return worktreeSet{}, err
+	}
+	entries, err := snapshot.Worktrees()
+	if err != nil {
+		return worktreeSet{}, err
+	}
+
+	set := worktreeSet{Version: worktreeSetVersion, Repo: repoIdentity(info), Base: getDefaultBase(), Remotes: make(map[string]string)}
+	all := gitRemotes()
+	for i, e := range entries {
+		if i == 0 || e.Bare || e.Prunable {
+			continue
+		}
+		if e.Branch == "" {
+			warnf("warning: skipping detached worktree %s\n", e.Path)
+			continue
+		}
+		item := worktreeSetItem{Branch: e.Branch, Head: e.Head}
+		if remote := gitConfigGet("branch." + e.Branch + ".remote"); remote != "" && remote != "." {
+			item.Remote = remote
+			item.Merge = strings.TrimPrefix(gitConfigGet("branch."+e.Branch+".merge"), "refs/heads/")
+			if url, ok := all[remote]; ok {
+				set.Remotes[remote] = url
+			}
+		}
+		set.Worktrees = append(set.Worktrees, item)
+	}
+	return set, nil
+}
+
+func readWorktreeSet(file string) (worktreeSet, error) {
+	var set worktreeSet
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return set, err
+	}
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		return set, fmt.Errorf("%s: %w", file, err)
+	}
+	if set.Version > worktreeSetVersion {
+		return set, fmt.Errorf("%s: format version %d is newer than this wt supports (%d)", file, set.Version, worktreeSetVersion)
+	}
+	for i, item := range set.Worktrees {
+		if item.Branch == "" {
+			return set, fmt.Errorf("%s: worktree %d has no branch", file, i+1)
+		}
+	}
+	return set, nil
+}
+
+func gitRefExists(ref string) bool {
+	return gitCommand("rev-parse", "--verify", "--quiet", ref).Run() == nil
+}
+
+// restoreSource decides what a worktree is recreated from, in order: the
+// local branch, its upstream, the recorded commit, or else the base.
+func restoreSource(item worktreeSetItem, base string) (args []string, from string) {
+	if gitRefExists("refs/heads/" + item.Branch) {
+		return []string{item.Branch}, "local branch"
+	}
+	if item.Remote != "" && item.Merge != "" {
+		upstream := item.Remote + "/" + item.Merge
+		if gitRefExists("refs/remotes/" + upstream) {
+			return []string{"--track", "-b", item.Branch, upstream}, upstream
+		}
+	}
+	if item.Head != "" && gitRefExists(item.Head+"^{commit}") {
+		return []string{"-b", item.Branch, item.Head}, "commit " + item.Head[:min(7, len(item.Head))]
+	}
+	return []string{"-b", item.Branch, base}, base + " (branch not found)"
+}
+
+// addMissingRemotes adds the remotes of the set this clone lacks and
+// fetches every remote an upstream is needed from.
+func addMissingRemotes(set worktreeSet, dryRun bool) {
+	existing := gitRemotes()
+	names := make([]string, 0, len(set.Remotes))
+	for name := range set.Remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		url := set.Remotes[name]
+		switch current, ok := existing[name]; {
+		case !ok:
+			infof("Adding remote %s (%s)\n", name, url)
+			if !dryRun {
+				if output, err := gitCommand("remote", "add", name, url).CombinedOutput(); err != nil {
+					warnf("warning: failed to add remote %s: %s\n", name, strings.TrimSpace(string(output)))
+				}
+			}
+		case current != url:
+			warnf("warning: remote %s is %s here, the snapshot has %s\n", name, current, url)
+		}
+	}
+
+	fetched := make(map[string]bool)
+	for _, item := range set.Worktrees {
+		if item.Remote == "" || fetched[item.Remote] || gitRefExists("refs/heads/"+item.Branch) {
+			continue
+		}
+		fetched[item.Remote] = true
+		infof("Fetching %s\n", item.Remote)
+		if dryRun {
+			continue
+		}
+		if err := objectGit("", gitOutput(), os.Stderr, "fetch", item.Remote); err != nil {
+			warnf("warning: failed to fetch %s: %v\n", item.Remote, err)
+		}
+	}
+}
+
+// restoreWorktreeSet recreates the worktrees of set that don't exist yet,
+// at the paths the current configuration gives them.
+func restoreWorktreeSet(set worktreeSet, dryRun bool) error {
+	info, err := getRepoInfo()
+	if err != nil {
+		return err
+	}
+	// Without a remote host the identity is just a directory name.
+	if set.Repo != "" && info.Host != "" && set.Repo != repoIdentity(info) {
+		warnf("warning: snapshot is of %s, this repository is %s\n", set.Repo, repoIdentity(info))
+	}
+	base := set.Base
+	if base == "" || !gitRefExists(base) {
+		base = getDefaultBase()
+	}
+
+	addMissingRemotes(set, dryRun)
+
+	restored, skipped, failed := 0, 0, 0
+	for _, item := range set.Worktrees {
+		if existing, ok := worktreeExists(item.Branch); ok {
+			infof("%s: already checked out at %s\n", item.Branch, existing)
+			skipped++
+			continue
+		}
+		path, err := buildWorktreePath(info, item.Branch)
+		if err != nil {
+			warnf("%s: %v\n", item.Branch, err)
+			failed++
+			continue
+		}
+		args, from := restoreSource(item, base)
+		if dryRun {
+			fmt.Printf("Would create %s from %s at %s\n", item.Branch, from, path)
+			restored++
+			continue
+		}
+
+		gitCmd := repoVCS().addWorktree(path, args...)
+		gitCmd.Stdout = gitOutput()
+		gitCmd.Stderr = os.Stderr
+		if err := gitCmd.Run(); err != nil {
+			warnf("%s: failed to create worktree: %v\n", item.Branch, err)
+			recordAudit(item.Branch, path, "", err)
+			failed++
+			continue
+		}
+		snapshot.invalidate()
+		successf("Restored %s from %s at %s", item.Branch, from, path)
+		prepareWorktree(path)
+		applyWorktreeGitConfig(info, path)
+		runPostCheckoutHooks(info, item.Branch, path)
+		restored++
+	}
+
+	if ciMode() {
+		fmt.Println(machineSummary("snapshot-restore", "restored", restored, "skipped", skipped, "failed", failed))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d worktree(s) could not be restored", failed)
+	}
+	return nil
+}
+
+var snapshotRestoreDryRun bool
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Export and restore the set of worktrees",
+	Long: `Export the branches of all worktrees to a file and recreate them elsewhere,
+e.g. on a new machine or after wiping the worktree root.
+
+The file lists every linked worktree's branch with its upstream remote and
+commit, plus the remote URLs needed to fetch them. Restore, run inside a
+clone of the repository, adds missing remotes, fetches, and creates each
+worktree from the local branch, its upstream, the recorded commit or else
+the base branch, at the path the current configuration gives it.
+Worktrees that already exist are left alone.
+
+Examples:
+  wt snapshot export > worktrees.yaml
+  wt snapshot restore worktrees.yaml --dry-run
+  wt snapshot restore worktrees.yaml`,
+}
+
+var snapshotExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print the worktree set as YAML",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set, err := exportWorktreeSet()
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(set)
+		if err != nil {
+			return err
+		}
+		fmt.Println("# Worktrees written by 'wt snapshot export'; recreate them with")
+		fmt.Println("# 'wt snapshot restore <file>' in a clone of the repository.")
+		fmt.Print(string(data))
+		return nil
+	},
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Recreate the worktrees of an exported set",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		set, err := readWorktreeSet(args[0])
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		return restoreWorktreeSet(set, snapshotRestoreDryRun)
+	},
+}
+
+func init() {
+	snapshotRestoreCmd.Flags().BoolVar(&snapshotRestoreDryRun, "dry-run", false, "Show what would be created without changing anything")
+	snapshotCmd.AddCommand(snapshotExportCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorktreeSetRoundTrip(t *testing.T) {
+	originalRoot, originalStrategy, originalPattern := worktreeRoot, worktreeStrategy, worktreePattern
+	t.Cleanup(func() {
+		worktreeRoot, worktreeStrategy, worktreePattern = originalRoot, originalStrategy, originalPattern
+	})
+	tmpDir := t.TempDir()
+	worktreeRoot = filepath.Join(tmpDir, "worktrees")
+	worktreeStrategy = "global"
+	worktreePattern = ""
+
+	upstream := filepath.Join(tmpDir, "upstream")
+	setupTestRepo(t, upstream)
+	runGitCommand(t, upstream, "branch", "shared")
+
+	oldClone := filepath.Join(tmpDir, "old")
+	runGitCommand(t, tmpDir, "clone", "-q", upstream, oldClone)
+	runGitCommand(t, oldClone, "worktree", "add", "-q", "--track", "-b", "shared", filepath.Join(tmpDir, "old-shared"), "origin/shared")
+	runGitCommand(t, oldClone, "worktree", "add", "-q", "-b", "unpushed", filepath.Join(tmpDir, "old-unpushed"))
+	runGitCommand(t, oldClone, "worktree", "add", "-q", "--detach", filepath.Join(tmpDir, "old-detached"))
+
+	origDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(origDir) })
+	if err := os.Chdir(oldClone); err != nil {
+		t.Fatal(err)
+	}
+	set, err := exportWorktreeSet()
+	if err != nil {
+		t.Fatalf("exportWorktreeSet() error: %v", err)
+	}
+	if len(set.Worktrees) != 2 {
+		t.Fatalf("exported %d worktrees, want 2 (main and detached are skipped): %+v", len(set.Worktrees), set.Worktrees)
+	}
+	if shared := set.Worktrees[0]; shared.Branch != "shared" || shared.Remote != "origin" || shared.Merge != "shared" {
+		t.Errorf("shared = %+v, want upstream origin/shared", shared)
+	}
+	if set.Remotes["origin"] != upstream {
+		t.Errorf("remotes = %v, want origin %s", set.Remotes, upstream)
+	}
+
+	newClone := filepath.Join(tmpDir, "new")
+	runGitCommand(t, tmpDir, "clone", "-q", upstream, newClone)
+	if err := os.Chdir(newClone); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreWorktreeSet(set, false); err != nil {
+		t.Fatalf("restoreWorktreeSet() error: %v", err)
+	}
+	for _, branch := range []string{"shared", "unpushed"} {
+		if _, ok := worktreeExists(branch); !ok {
+			t.Errorf("worktree for %s was not restored", branch)
+		}
+	}
+	tracking, _ := exec.Command("git", "rev-parse", "--abbrev-ref", "shared@{upstream}").Output()
+	if strings.TrimSpace(string(tracking)) != "origin/shared" {
+		t.Errorf("shared tracks %q, want origin/shared", tracking)
+	}
+
+	// Restoring again leaves the existing worktrees alone.
+	if err := restoreWorktreeSet(set, false); err != nil {
+		t.Errorf("second restoreWorktreeSet() error: %v", err)
+	}
+}
+
+func TestReadWorktreeSet(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	set, err := readWorktreeSet(write("ok.yaml", "version: 1\nworktrees:\n  - branch: feature-x\n    remote: origin\n    merge: feature-x\n"))
+	if err != nil || len(set.Worktrees) != 1 || set.Worktrees[0].Remote != "origin" {
+		t.Errorf("readWorktreeSet() = %+v, %v", set, err)
+	}
+	if _, err := readWorktreeSet(write("future.yaml", "version: 99\nworktrees: []\n")); err == nil {
+		t.Error("expected newer format version to be rejected")
+	}
+	if _, err := readWorktreeSet(write("nobranch.yaml", "version: 1\nworktrees:\n  - head: abc\n")); err == nil {
+		t.Error("expected worktree without branch to be rejected")
+	}
+}